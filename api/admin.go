@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/bojand/ghz-web/service"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+)
+
+// SetupAdminAPI sets up the API
+func SetupAdminAPI(g *echo.Group, rts service.RetentionService, rcs service.RecomputeService) {
+	api := &AdminAPI{rts: rts, rcs: rcs}
+
+	g.GET("/retention/", api.getRetentionStats).Name = "ghz api: get retention stats"
+	g.POST("/retention/run/", api.runRetention).Name = "ghz api: run retention"
+	g.POST("/recompute/", api.recompute).Name = "ghz api: recompute runs"
+}
+
+// AdminAPI provides the api
+type AdminAPI struct {
+	rts service.RetentionService
+	rcs service.RecomputeService
+}
+
+func (api *AdminAPI) getRetentionStats(c echo.Context) error {
+	stats := api.rts.LastStats()
+	if stats == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Not Found")
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+func (api *AdminAPI) runRetention(c echo.Context) error {
+	stats, err := api.rts.Prune()
+	if err == model.ErrRetentionRunning {
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// recompute rebuilds the aggregates of a run, of all runs of a test or of all runs of a project
+// from their stored details, identified by the runId, testId or projectId query parameter.
+// With dryRun the changes are returned without being stored.
+func (api *AdminAPI) recompute(c echo.Context) error {
+	dryRun := getDryRunParam(c)
+
+	var recompute func(id uint, dryRun bool) (*model.RecomputeStats, error)
+	var param string
+
+	for name, fn := range map[string]func(uint, bool) (*model.RecomputeStats, error){
+		"runId":     api.rcs.RecomputeRun,
+		"testId":    api.rcs.RecomputeTest,
+		"projectId": api.rcs.RecomputeProject,
+	} {
+		if c.QueryParam(name) == "" {
+			continue
+		}
+
+		if recompute != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Only one of runId, testId or projectId is allowed")
+		}
+
+		recompute, param = fn, name
+	}
+
+	if recompute == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "One of runId, testId or projectId is required")
+	}
+
+	id, err := strconv.Atoi(c.QueryParam(param))
+	if err != nil || id <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+param)
+	}
+
+	stats, err := recompute(uint(id), dryRun)
+	if gorm.IsRecordNotFoundError(err) {
+		return echo.NewHTTPError(http.StatusNotFound, "Not Found")
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
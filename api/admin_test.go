@@ -0,0 +1,220 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/bojand/ghz-web/model"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/stretchr/testify/assert"
+	baloo "gopkg.in/h2non/baloo.v3"
+)
+
+func TestAdminAPI(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	rts := &model.RetentionService{DB: db, Config: &config.RetentionConfig{BatchSize: 100, KeepRuns: 1}}
+	rcs := &model.RecomputeService{DB: db}
+
+	var httpTest *baloo.Client
+	var echoServer *echo.Echo
+
+	echoServer = echo.New()
+	echoServer.Use(middleware.AddTrailingSlash())
+	echoServer.Use(middleware.Logger())
+
+	defer echoServer.Close()
+
+	const basePath = "/admin"
+
+	t.Run("Start API", func(t *testing.T) {
+		adminGroup := echoServer.Group(basePath)
+		SetupAdminAPI(adminGroup, rts, rcs)
+
+		go func() {
+			echoServer.Start("localhost:0")
+		}()
+	})
+
+	t.Run("Sync to get the port", func(t *testing.T) {
+		done := make(chan bool, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			done <- true
+			close(done)
+		}()
+
+		<-done
+	})
+
+	t.Run("Create http test", func(t *testing.T) {
+		httpTest = baloo.New(echoServer.Listener.Addr().String())
+	})
+
+	t.Run("Create runs", func(t *testing.T) {
+		tst := &model.Test{Project: &model.Project{}, Name: "Admin Test"}
+		err := db.Create(tst).Error
+		assert.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			err := db.Create(&model.Run{TestID: tst.ID}).Error
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("GET /retention/ should 404 before first run", func(t *testing.T) {
+		httpTest.Get(basePath + "/retention/").
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+
+	t.Run("POST /retention/run/", func(t *testing.T) {
+		httpTest.Post(basePath + "/retention/run/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				stats := new(model.RetentionStats)
+				err := json.NewDecoder(res.Body).Decode(stats)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), stats.Projects)
+				assert.Equal(t, uint(2), stats.Deleted.Runs)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /retention/", func(t *testing.T) {
+		httpTest.Get(basePath + "/retention/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				stats := new(model.RetentionStats)
+				err := json.NewDecoder(res.Body).Decode(stats)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), stats.Projects)
+				assert.Equal(t, uint(2), stats.Deleted.Runs)
+				assert.False(t, stats.StartTime.IsZero())
+
+				return nil
+			}).
+			Done()
+	})
+
+	var rid string
+
+	t.Run("Create run with details", func(t *testing.T) {
+		tst := &model.Test{Project: &model.Project{}, Name: "Recompute Test"}
+		err := db.Create(tst).Error
+		assert.NoError(t, err)
+
+		r := &model.Run{TestID: tst.ID, Count: 100}
+		err = db.Create(r).Error
+		assert.NoError(t, err)
+
+		for i := 1; i <= 4; i++ {
+			err := db.Create(&model.Detail{RunID: r.ID, Latency: float64(i * 1000)}).Error
+			assert.NoError(t, err)
+		}
+
+		rid = strconv.FormatUint(uint64(r.ID), 10)
+	})
+
+	t.Run("POST /recompute/ dry run", func(t *testing.T) {
+		httpTest.Post(basePath + "/recompute/").
+			SetQueryParams(map[string]string{"runId": rid, "dryRun": "true"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				stats := new(model.RecomputeStats)
+				err := json.NewDecoder(res.Body).Decode(stats)
+
+				assert.NoError(t, err)
+				assert.True(t, stats.DryRun)
+				assert.Equal(t, uint(1), stats.Runs)
+				assert.Equal(t, uint(1), stats.Changed)
+				assert.NotEmpty(t, stats.Results[0].Changes)
+				assert.Equal(t, "count", stats.Results[0].Changes[0].Field)
+				assert.Equal(t, float64(100), stats.Results[0].Changes[0].Old)
+				assert.Equal(t, float64(4), stats.Results[0].Changes[0].New)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("POST /recompute/", func(t *testing.T) {
+		httpTest.Post(basePath + "/recompute/").
+			SetQueryParams(map[string]string{"runId": rid}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				stats := new(model.RecomputeStats)
+				err := json.NewDecoder(res.Body).Decode(stats)
+
+				assert.NoError(t, err)
+				assert.False(t, stats.DryRun)
+				assert.Equal(t, uint(1), stats.Changed)
+
+				return nil
+			}).
+			Done()
+
+		id, err := strconv.Atoi(rid)
+		assert.NoError(t, err)
+
+		r, err := (&model.RunService{DB: db}).FindByID(uint(id))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(4), r.Count)
+		assert.Len(t, r.Histogram, 11)
+	})
+
+	t.Run("POST /recompute/ 400 without an id", func(t *testing.T) {
+		httpTest.Post(basePath + "/recompute/").
+			Expect(t).
+			Status(400).
+			Type("json").
+			Done()
+
+		httpTest.Post(basePath + "/recompute/").
+			SetQueryParams(map[string]string{"runId": rid, "testId": "1"}).
+			Expect(t).
+			Status(400).
+			Type("json").
+			Done()
+	})
+
+	t.Run("POST /recompute/ 404 for unknown project", func(t *testing.T) {
+		httpTest.Post(basePath + "/recompute/").
+			SetQueryParams(map[string]string{"projectId": "12345"}).
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+}
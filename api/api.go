@@ -20,7 +20,15 @@ func Setup(
 	ps service.ProjectService,
 	ts service.TestService,
 	rs service.RunService,
-	ds service.DetailService) {
+	ds service.DetailService,
+	trs service.TrashService,
+	rts service.RetentionService,
+	rcs service.RecomputeService,
+	es service.ErrorService,
+	ss service.SubmissionService,
+	txs service.TxService,
+	js service.JobService,
+	jq *JobQueue) {
 
 	SetupInfoAPI(info, g)
 
@@ -36,7 +44,18 @@ func Setup(
 	detailGroup := runsGroup.Group("/:rid/details")
 	SetupDetailAPI(detailGroup, ds)
 
-	SetupRawAPI(g, ps, ts, rs, ds)
+	trashGroup := g.Group("/trash")
+	SetupTrashAPI(trashGroup, trs, &config.Trash)
+
+	adminGroup := g.Group("/admin")
+	SetupAdminAPI(adminGroup, rts, rcs)
+
+	jobsGroup := g.Group("/jobs")
+	SetupJobAPI(jobsGroup, js)
+
+	SetupErrorAPI(g, ps, ts, rs, es)
+
+	SetupRawAPI(g, ps, ts, rs, ds, ss, txs, &config.Idempotency, jq)
 }
 
 // Model for common api objects
@@ -58,4 +77,8 @@ type Model struct {
 type Listable struct {
 	// The total number of items
 	Total uint `json:"total" example:"10"`
+
+	// The cursor of the next page, passed as the after parameter to get the page.
+	// Only set for lists paged by id.
+	Next string `json:"next,omitempty" example:"123"`
 }
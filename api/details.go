@@ -29,18 +29,6 @@ type Detail struct {
 	Status string `json:"status"`
 }
 
-// DetailListRequest request
-type DetailListRequest struct {
-	// The property by which to sort the results
-	Sort string `json:"sort" query:"sort" validate:"omitempty,oneof=id"`
-
-	// The sort order
-	Order string `json:"order" query:"order" validate:"omitempty,oneof=asc desc"`
-
-	// The page to view
-	Page uint `json:"page" query:"page"`
-}
-
 // DetailListResponse response holds a list of details
 type DetailListResponse struct {
 	Listable
@@ -71,8 +59,16 @@ type DetailAPI struct {
 // @Param tid path int true "Test ID"
 // @Param rid path int true "Run ID"
 // @Param page query integer false "The page to view"
+// @Param limit query integer false "The number of details in the page, up to 500. Default: 20"
+// @Param after query integer false "The cursor of the page, as the next value of the previous page"
 // @Param order query string false "The sort order. Default: 'asc'"
-// @Param sort query sring false "The property to sort by. Default: 'id'"
+// @Param sort query string false "The property to sort by: id, timestamp, latency or status. Default: 'id'"
+// @Param status query string false "Filter by status containing the value"
+// @Param error query string false "Filter by error containing the value"
+// @Param minLatency query string false "Filter by minimum latency, such as 150ms"
+// @Param maxLatency query string false "Filter by maximum latency, such as 2s"
+// @Param minTimestamp query string false "Filter by minimum timestamp, as an RFC 3339 time or a date"
+// @Param maxTimestamp query string false "Filter by maximum timestamp, as an RFC 3339 time or a date"
 // @Success 200 {object} api.DetailListResponse
 // @Failure 400 {object} echo.HTTPError
 // @Failure 404 {object} echo.HTTPError
@@ -88,25 +84,19 @@ func (api *DetailAPI) listDetails(c echo.Context) error {
 
 	rid := r.ID
 
-	dlReq := new(DetailListRequest)
-
-	if err := bindAndValidate(c, dlReq); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	limit, page, err := getListParams(c)
+	if err != nil {
+		return err
 	}
 
-	page := dlReq.Page
-	sort := dlReq.Sort
-	order := "asc"
-
-	doSort := false
-
-	if sort != "" {
-		doSort = true
-
-		order = dlReq.Order
+	q, err := getQuery(c, model.DetailQueryFields)
+	if err != nil {
+		return err
 	}
 
-	limit := uint(20)
+	if err := getAfterParam(c, q); err != nil {
+		return err
+	}
 
 	countCh := make(chan uint, 1)
 	dataCh := make(chan []*model.Detail, 1)
@@ -114,20 +104,14 @@ func (api *DetailAPI) listDetails(c echo.Context) error {
 	defer close(errCh)
 
 	go func() {
-		count, err := api.ds.Count(rid)
+		count, err := api.ds.CountQuery(rid, q)
 		errCh <- err
 		countCh <- count
 		close(countCh)
 	}()
 
 	go func() {
-		var details []*model.Detail
-		var err error
-		if doSort {
-			details, err = api.ds.FindByRunIDSorted(rid, limit, page, sort, order)
-		} else {
-			details, err = api.ds.FindByRunID(rid, limit, page)
-		}
+		details, err := api.ds.FindByRunIDQuery(rid, limit, page, q)
 		errCh <- err
 		dataCh <- details
 		close(dataCh)
@@ -161,9 +145,40 @@ func (api *DetailAPI) listDetails(c echo.Context) error {
 	pl := &DetailListResponse{Data: resData}
 	pl.Total = count
 
+	if len(data) > 0 {
+		pl.Next = nextCursor(q, limit, uint(len(data)), data[len(data)-1].ID)
+	}
+
+	setListHeaders(c, count, limit, page, pl.Next)
+
 	return c.JSON(http.StatusOK, pl)
 }
 
+// Deletes all the details for the run
+// @Summary Deletes all the details for the specific run
+// @Description Deletes all the details for the specific run.
+// @ID delete-all-details
+// @Produce json
+// @Param pid path int true "Project ID"
+// @Param tid path int true "Test ID"
+// @Param rid path int true "Run ID"
+// @Success 200 {object} model.CascadeResult
+// @Failure 400 {object} echo.HTTPError
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/runs/{rid}/details [delete]
 func (api *DetailAPI) deleteAll(c echo.Context) error {
-	return echo.NewHTTPError(http.StatusNotImplemented, "Not Implemented")
+	ro := c.Get("run")
+	r, ok := ro.(*model.Run)
+
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "No run in context")
+	}
+
+	res, err := api.ds.DeleteAll(r.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, res)
 }
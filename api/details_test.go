@@ -33,13 +33,15 @@ func TestDetailAPI(t *testing.T) {
 	}
 
 	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
-		&model.Bucket{}, &model.LatencyDistribution{})
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{}, &model.Submission{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	ts := &model.TestService{DB: db}
 	ps := &model.ProjectService{DB: db}
 	rs := &model.RunService{DB: db}
 	ds := &model.DetailService{DB: db, Config: &conf.Database}
+	ss := &model.SubmissionService{DB: db}
+	txs := &model.TxService{DB: db, Config: &conf.Database}
 
 	var projectID, testID, runID uint
 	var pid, tid, rid string
@@ -71,7 +73,7 @@ func TestDetailAPI(t *testing.T) {
 		SetupDetailAPI(detailGroup, ds)
 
 		apiGroup := echoServer.Group("/api")
-		SetupRawAPI(apiGroup, ps, ts, rs, ds)
+		SetupRawAPI(apiGroup, ps, ts, rs, ds, ss, txs, &conf.Idempotency, nil)
 
 		go func() {
 			echoServer.Start("localhost:0")
@@ -176,4 +178,54 @@ func TestDetailAPI(t *testing.T) {
 			}).
 			Done()
 	})
+
+	t.Run("GET list of details should 400 on unknown parameter", func(t *testing.T) {
+		params := []map[string]string{
+			{"minStatus": "x"},
+			{"includeTrashed": "true"},
+		}
+
+		for _, p := range params {
+			httpTest.Get("/projects/" + pid + "/tests/" + tid + "/runs/" + rid + "/details/").
+				SetQueryParams(p).
+				Expect(t).
+				Status(400).
+				Type("json").
+				Done()
+		}
+	})
+
+	t.Run("DELETE all details", func(t *testing.T) {
+		httpTest.Delete("/projects/" + pid + "/tests/" + tid + "/runs/" + rid + "/details/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				dr := new(model.CascadeResult)
+				err = json.NewDecoder(res.Body).Decode(dr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, 937, int(dr.Details))
+				assert.Equal(t, 0, int(dr.Runs))
+
+				return nil
+			}).
+			Done()
+
+		httpTest.Get("/projects/" + pid + "/tests/" + tid + "/runs/" + rid + "/details/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				dl := new(DetailListResponse)
+				err = json.NewDecoder(res.Body).Decode(dl)
+
+				assert.NoError(t, err)
+				assert.Len(t, dl.Data, 0)
+				assert.Equal(t, 0, int(dl.Total))
+
+				return nil
+			}).
+			Done()
+	})
 }
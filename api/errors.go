@@ -0,0 +1,132 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/bojand/ghz-web/service"
+	"github.com/labstack/echo"
+)
+
+// SetupErrorAPI sets up the API
+func SetupErrorAPI(g *echo.Group, ps service.ProjectService, ts service.TestService,
+	rs service.RunService, es service.ErrorService) {
+
+	api := &ErrorAPI{ps: ps, ts: ts, rs: rs, es: es}
+
+	g.GET("/projects/:pid/errors/", api.getProjectErrors, api.populateProject).
+		Name = "ghz api: get project errors"
+	g.GET("/projects/:pid/tests/:tid/errors/", api.getTestErrors, api.populateProject, api.populateTest).
+		Name = "ghz api: get test errors"
+	g.GET("/projects/:pid/tests/:tid/runs/:rid/errors/", api.getRunErrors, api.populateProject, api.populateTest).
+		Name = "ghz api: get run errors"
+}
+
+// ErrorAPI provides the api
+type ErrorAPI struct {
+	ps service.ProjectService
+	ts service.TestService
+	rs service.RunService
+	es service.ErrorService
+}
+
+// Get project errors
+// @Summary Gets the errors of the project grouped by kind
+// @Description Groups the errors of the completed runs of all the tests of the project by their code and message template,
+// @Description along with the first and the last run each kind of error occurred in.
+// @ID get-project-errors
+// @Produce json
+// @Param pid path int true "Project ID"
+// @Success 200 {object} model.ErrorReport
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/errors [get]
+func (api *ErrorAPI) getProjectErrors(c echo.Context) error {
+	po := c.Get("project")
+	p, ok := po.(*model.Project)
+
+	if p == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No project in context")
+	}
+
+	rep, err := api.es.FindProjectErrors(p.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, rep)
+}
+
+// Get test errors
+// @Summary Gets the errors of the test grouped by kind
+// @Description Groups the errors of the completed runs of the test by their code and message template,
+// @Description along with the runs each kind of error occurred in.
+// @ID get-test-errors
+// @Produce json
+// @Param pid path int true "Project ID"
+// @Param tid path int true "Test ID"
+// @Success 200 {object} model.ErrorReport
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/errors [get]
+func (api *ErrorAPI) getTestErrors(c echo.Context) error {
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if t == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No test in context")
+	}
+
+	rep, err := api.es.FindTestErrors(t.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, rep)
+}
+
+// Get run errors
+// @Summary Gets the errors of the run grouped by kind
+// @Description Groups the errors of the run by their code and message template,
+// @Description flagging the kinds of errors that did not occur in any earlier run of the test as new.
+// @ID get-run-errors
+// @Produce json
+// @Param pid path int true "Project ID"
+// @Param tid path int true "Test ID"
+// @Param rid path int true "Run ID"
+// @Success 200 {object} model.RunErrors
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/runs/{rid}/errors [get]
+func (api *ErrorAPI) getRunErrors(c echo.Context) error {
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if t == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No test in context")
+	}
+
+	r, err := getRun(api.rs, c)
+	if err != nil {
+		return err
+	}
+
+	if r.TestID != t.ID {
+		return echo.NewHTTPError(http.StatusNotFound, "Not Found")
+	}
+
+	re, err := api.es.FindRunErrors(r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, re)
+}
+
+func (api *ErrorAPI) populateProject(next echo.HandlerFunc) echo.HandlerFunc {
+	return populateProject(api.ps, next)
+}
+
+func (api *ErrorAPI) populateTest(next echo.HandlerFunc) echo.HandlerFunc {
+	return populateTest(api.ts, next)
+}
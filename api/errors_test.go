@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/stretchr/testify/assert"
+	baloo "gopkg.in/h2non/baloo.v3"
+)
+
+func TestErrorAPI(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
+		&model.Bucket{}, &model.LatencyDistribution{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ts := &model.TestService{DB: db}
+	ps := &model.ProjectService{DB: db}
+	rs := &model.RunService{DB: db}
+	es := &model.ErrorService{DB: db}
+
+	var pid, tid, rid, otherRid string
+
+	var httpTest *baloo.Client
+	var echoServer *echo.Echo
+
+	echoServer = echo.New()
+	echoServer.Use(middleware.AddTrailingSlash())
+	echoServer.Use(middleware.Logger())
+
+	defer echoServer.Close()
+
+	t.Run("Start API", func(t *testing.T) {
+		SetupErrorAPI(echoServer.Group(""), ps, ts, rs, es)
+
+		go func() {
+			echoServer.Start("localhost:0")
+		}()
+	})
+
+	t.Run("Sync to get the port", func(t *testing.T) {
+		done := make(chan bool, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			done <- true
+			close(done)
+		}()
+
+		<-done
+	})
+
+	t.Run("Create http test", func(t *testing.T) {
+		httpTest = baloo.New(echoServer.Listener.Addr().String())
+	})
+
+	t.Run("Create tests with runs", func(t *testing.T) {
+		p := &model.Project{Name: "errors"}
+		assert.NoError(t, db.Create(p).Error)
+
+		tst := &model.Test{ProjectID: p.ID, Name: "errors"}
+		assert.NoError(t, db.Create(tst).Error)
+
+		other := &model.Test{ProjectID: p.ID, Name: "other errors"}
+		assert.NoError(t, db.Create(other).Error)
+
+		pid = strconv.FormatUint(uint64(p.ID), 10)
+		tid = strconv.FormatUint(uint64(tst.ID), 10)
+
+		start := time.Now().Add(-time.Hour)
+
+		r := &model.Run{TestID: tst.ID, Date: start,
+			ErrorDist: map[string]int{"rpc error: code = Unavailable desc = connection to 10.0.0.1:80 refused": 2}}
+		assert.NoError(t, rs.Create(r))
+
+		r = &model.Run{TestID: tst.ID, Date: start.Add(time.Minute),
+			ErrorDist: map[string]int{
+				"rpc error: code = Unavailable desc = connection to 10.0.0.2:80 refused": 1,
+				"rpc error: code = Internal desc = Internal error.":                      3,
+			}}
+		assert.NoError(t, rs.Create(r))
+		rid = strconv.FormatUint(uint64(r.ID), 10)
+
+		or := &model.Run{TestID: other.ID, Date: start, ErrorDist: map[string]int{"deadline exceeded": 1}}
+		assert.NoError(t, rs.Create(or))
+		otherRid = strconv.FormatUint(uint64(or.ID), 10)
+	})
+
+	t.Run("GET project errors", func(t *testing.T) {
+		httpTest.Get("/projects/" + pid + "/errors/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rep := new(model.ErrorReport)
+				err := json.NewDecoder(res.Body).Decode(rep)
+
+				assert.NoError(t, err)
+				assert.Equal(t, 3, rep.Runs)
+				assert.Equal(t, 7, rep.Total)
+				assert.Len(t, rep.Groups, 3)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET test errors", func(t *testing.T) {
+		httpTest.Get("/projects/" + pid + "/tests/" + tid + "/errors/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rep := new(model.ErrorReport)
+				err := json.NewDecoder(res.Body).Decode(rep)
+
+				assert.NoError(t, err)
+				assert.Equal(t, 2, rep.Runs)
+				assert.Equal(t, 2, rep.RunsWithErrors)
+				assert.Equal(t, 6, rep.Total)
+				assert.Len(t, rep.Groups, 2)
+				assert.Equal(t, "Unavailable: connection to <addr> refused", rep.Groups[0].Key)
+				assert.Equal(t, 2, rep.Groups[0].Runs)
+				assert.NotNil(t, rep.Groups[0].FirstSeen)
+				assert.NotNil(t, rep.Groups[0].LastSeen)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET run errors", func(t *testing.T) {
+		httpTest.Get("/projects/" + pid + "/tests/" + tid + "/runs/" + rid + "/errors/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				re := new(model.RunErrors)
+				err := json.NewDecoder(res.Body).Decode(re)
+
+				assert.NoError(t, err)
+				assert.Equal(t, 4, re.Total)
+				assert.Equal(t, 1, re.New)
+				assert.Len(t, re.Groups, 2)
+				assert.Equal(t, "Internal", re.Groups[0].Code)
+				assert.True(t, re.Groups[0].New)
+				assert.False(t, re.Groups[1].New)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET run errors of another test 404", func(t *testing.T) {
+		httpTest.Get("/projects/" + pid + "/tests/" + tid + "/runs/" + otherRid + "/errors/").
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+
+	t.Run("GET test errors 404", func(t *testing.T) {
+		httpTest.Get("/projects/" + pid + "/tests/12345/errors/").
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+
+	t.Run("GET run errors 404", func(t *testing.T) {
+		httpTest.Get("/projects/" + pid + "/tests/" + tid + "/runs/12345/errors/").
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/labstack/echo"
+)
+
+// StatusHistory response
+type StatusHistory struct {
+	Total   uint                  `json:"total"`
+	Data    []*model.StatusChange `json:"data"`
+	Summary *model.StatusSummary  `json:"summary"`
+}
+
+// getStatusHistory responds with a page of status changes and the status summary of the test or project
+func getStatusHistory(c echo.Context, id uint,
+	count func(id uint) (uint, error),
+	find func(id, limit, page uint) ([]*model.StatusChange, error),
+	summary func(id, window uint) (*model.StatusSummary, error)) error {
+
+	limit, page, err := getListParams(c)
+	if err != nil {
+		return err
+	}
+
+	total, err := count(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	data, err := find(id, limit, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	sum, err := summary(id, getWindowParam(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	setListHeaders(c, total, limit, page, "")
+
+	return c.JSON(http.StatusOK, &StatusHistory{Total: total, Data: data, Summary: sum})
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/stretchr/testify/assert"
+	baloo "gopkg.in/h2non/baloo.v3"
+)
+
+func TestStatusHistoryAPI(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ts := &model.TestService{DB: db}
+	ps := &model.ProjectService{DB: db}
+	rs := &model.RunService{DB: db}
+
+	var pid, tid string
+	var runID uint
+
+	var httpTest *baloo.Client
+	var echoServer *echo.Echo
+
+	echoServer = echo.New()
+	echoServer.Use(middleware.AddTrailingSlash())
+	echoServer.Use(middleware.Logger())
+
+	defer echoServer.Close()
+
+	const basePath = "/projects"
+
+	t.Run("Start API", func(t *testing.T) {
+		projectGroup := echoServer.Group(basePath)
+		SetupProjectAPI(projectGroup, ps)
+
+		testsGroup := projectGroup.Group("/:pid/tests")
+		SetupTestAPI(testsGroup, ts, rs)
+
+		go func() {
+			echoServer.Start("localhost:0")
+		}()
+	})
+
+	t.Run("Sync to get the port", func(t *testing.T) {
+		done := make(chan bool, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			done <- true
+			close(done)
+		}()
+
+		<-done
+	})
+
+	t.Run("Create http test", func(t *testing.T) {
+		httpTest = baloo.New(echoServer.Listener.Addr().String())
+	})
+
+	t.Run("Create test with runs", func(t *testing.T) {
+		tst := &model.Test{Project: &model.Project{}, Name: "history"}
+		err := db.Create(tst).Error
+		assert.NoError(t, err)
+
+		pid = strconv.FormatUint(uint64(tst.ProjectID), 10)
+		tid = strconv.FormatUint(uint64(tst.ID), 10)
+
+		for i := 0; i < 3; i++ {
+			r := &model.Run{TestID: tst.ID, Date: time.Now().Add(time.Duration(i-3) * time.Minute)}
+			if i == 2 {
+				r.ErrorDist = map[string]int{"error": 1}
+			}
+
+			err := rs.Create(r)
+			assert.NoError(t, err)
+
+			runID = r.ID
+		}
+	})
+
+	t.Run("PUT failing test records status change", func(t *testing.T) {
+		httpTest.Put(basePath + "/" + pid + "/tests/" + tid + "/").
+			JSON(map[string]interface{}{"name": "history", "failOnError": true}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				tm := new(model.Test)
+				err := json.NewDecoder(res.Body).Decode(tm)
+
+				assert.NoError(t, err)
+				assert.Equal(t, model.StatusFail, tm.Status)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET test status history", func(t *testing.T) {
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/status-history/").
+			SetQueryParams(map[string]string{"window": "2"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				sh := new(StatusHistory)
+				err := json.NewDecoder(res.Body).Decode(sh)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), sh.Total)
+				assert.Len(t, sh.Data, 1)
+				assert.Equal(t, runID, sh.Data[0].RunID)
+				assert.Equal(t, model.StatusOK, sh.Data[0].From)
+				assert.Equal(t, model.StatusFail, sh.Data[0].To)
+
+				assert.Equal(t, model.StatusFail, sh.Summary.Status)
+				assert.Equal(t, uint(1), sh.Summary.Streak)
+				assert.NotNil(t, sh.Summary.LastFailure)
+				assert.Equal(t, uint(2), sh.Summary.Window)
+				assert.Equal(t, 0.5, sh.Summary.PassRate)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET project status history", func(t *testing.T) {
+		httpTest.Get(basePath + "/" + pid + "/status-history/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				sh := new(StatusHistory)
+				err := json.NewDecoder(res.Body).Decode(sh)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), sh.Total)
+				assert.Len(t, sh.Data, 1)
+				assert.Equal(t, uint(3), sh.Summary.Window)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET status history 404 on unknown test", func(t *testing.T) {
+		httpTest.Get(basePath + "/" + pid + "/tests/4321/status-history/").
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+}
@@ -0,0 +1,462 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/bojand/ghz-web/model"
+	"github.com/bojand/ghz-web/parser"
+	"github.com/bojand/ghz-web/service"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+)
+
+// The formats of uploads
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatInflux = "influx"
+	formatCSV    = "csv"
+)
+
+// JobResponse is the response to the job endpoints
+type JobResponse struct {
+	*model.Job
+
+	// The responses of the runs created so far
+	Result *RawImportResponse `json:"result,omitempty"`
+}
+
+// JobQueue processes spooled raw uploads in the background.
+// The jobs of a test are processed in the order they were received.
+type JobQueue struct {
+	api     *RawAPI
+	js      service.JobService
+	conf    *config.IngestConfig
+	workers []*jobWorker
+}
+
+// jobWorker processes the jobs assigned to it one at a time
+type jobWorker struct {
+	mu    sync.Mutex
+	jobs  []uint
+	ready chan struct{}
+}
+
+// jobRun is a run of a job along with the function that creates it
+type jobRun struct {
+	rr     *RawRequest
+	create func(api *RawAPI, p *model.Project, t *model.Test) (*RawResponse, error)
+}
+
+// NewJobQueue creates a new job queue
+func NewJobQueue(
+	ps service.ProjectService,
+	ts service.TestService,
+	rs service.RunService,
+	ds service.DetailService,
+	ss service.SubmissionService,
+	txs service.TxService,
+	js service.JobService,
+	idempotency *config.IdempotencyConfig,
+	conf *config.IngestConfig) *JobQueue {
+
+	return &JobQueue{
+		api:  &RawAPI{ps: ps, ts: ts, rs: rs, ds: ds, ss: ss, txs: txs, conf: idempotency},
+		js:   js,
+		conf: conf,
+	}
+}
+
+// Start creates the spool directory and starts the workers.
+// Jobs left unfinished by a previous run of the server are queued again.
+func (q *JobQueue) Start() error {
+	if err := os.MkdirAll(q.conf.SpoolDir, 0755); err != nil {
+		return err
+	}
+
+	jobs, err := q.js.FindUnfinished()
+	if err != nil {
+		return err
+	}
+
+	q.workers = make([]*jobWorker, q.conf.Workers)
+	for i := range q.workers {
+		q.workers[i] = &jobWorker{ready: make(chan struct{}, 1)}
+		go q.work(q.workers[i])
+	}
+
+	for _, j := range jobs {
+		q.enqueue(j)
+	}
+
+	return nil
+}
+
+// enqueue assigns the job to a worker. The jobs of a test are assigned to the same worker,
+// keyed by the names of the project and test so that the jobs of a test yet to be created are too.
+func (q *JobQueue) enqueue(j *model.Job) {
+	key := "job " + strconv.FormatUint(uint64(j.ID), 10)
+	if j.ProjectName != "" && j.TestName != "" {
+		key = "test " + j.ProjectName + "/" + j.TestName
+	} else if j.TestID != 0 {
+		key = "test " + strconv.FormatUint(uint64(j.TestID), 10)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	w := q.workers[h.Sum32()%uint32(len(q.workers))]
+
+	w.mu.Lock()
+	w.jobs = append(w.jobs, j.ID)
+	w.mu.Unlock()
+
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *JobQueue) work(w *jobWorker) {
+	for range w.ready {
+		for {
+			w.mu.Lock()
+			if len(w.jobs) == 0 {
+				w.mu.Unlock()
+				break
+			}
+
+			id := w.jobs[0]
+			w.jobs = w.jobs[1:]
+			w.mu.Unlock()
+
+			q.process(id)
+		}
+	}
+}
+
+// process processes the job and removes its spooled upload once it is finished
+func (q *JobQueue) process(id uint) {
+	j, err := q.js.FindByID(id)
+	if err != nil {
+		return
+	}
+
+	j.Status = model.JobProcessing
+	j.RunsDone, j.DetailsCreated, j.DetailsFailed = 0, 0, 0
+	j.Error, j.Result = "", ""
+
+	if err = q.js.Update(j); err != nil {
+		return
+	}
+
+	if err = q.run(j); err != nil {
+		j.Status = model.JobFailed
+		j.Error = errorMessage(err)
+	} else {
+		j.Status = model.JobDone
+	}
+
+	if err = q.js.Update(j); err == nil {
+		os.Remove(j.Path)
+	}
+}
+
+// run creates the runs of the job, each within its own transaction unless the job is best effort.
+// The project and test of new results are found or created by name along with the first run.
+// The progress of the job is updated as each run is created. The details that fail to be created
+// by a best effort job are counted, while the job fails otherwise.
+func (q *JobQueue) run(j *model.Job) error {
+	f, err := os.Open(j.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	runs, err := readJobRuns(j.Format, f, j.Recompute)
+	if err != nil {
+		return err
+	}
+
+	j.Runs = uint(len(runs))
+
+	var p *model.Project
+	var t *model.Test
+
+	named := &RawRequest{ProjectName: j.ProjectName, TestName: j.TestName}
+
+	if j.TestID != 0 {
+		if p, err = q.api.ps.FindByID(j.ProjectID); err != nil {
+			return err
+		}
+
+		if t, err = q.api.ts.FindByID(j.TestID); err != nil {
+			return err
+		}
+	}
+
+	result := new(RawImportResponse)
+
+	for _, jr := range runs {
+		var rres *RawResponse
+
+		err := q.api.transact(j.BestEffort, func(api *RawAPI) error {
+			var err error
+			if t == nil {
+				src := jr.rr
+				if !src.named() && named.named() {
+					src = named
+				}
+
+				if p, t, err = api.newTest(src); err != nil {
+					return err
+				}
+			}
+
+			rres, err = api.importRun(jr.rr, t, func() (*RawResponse, error) {
+				return jr.create(api, p, t)
+			})
+
+			if err == nil && !j.BestEffort && rres.Details.Fail != uint(0) {
+				return errors.New(rres.Details.rolledBack())
+			}
+
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
+
+		t = rres.Test
+
+		result.Runs = append(result.Runs, rres)
+
+		j.ProjectID, j.TestID = p.ID, t.ID
+		j.RunsDone++
+		j.DetailsCreated += rres.Details.Success
+		j.DetailsFailed += rres.Details.Fail
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+
+		j.Result = string(data)
+
+		if err = q.js.Update(j); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readJobRuns reads the runs of the spooled upload in the format.
+// The summaries of the runs are computed from their details if recompute is set or they have none.
+func readJobRuns(format string, reader io.Reader, recompute bool) ([]*jobRun, error) {
+	createBatch := func(rr *RawRequest) *jobRun {
+		return &jobRun{rr: rr, create: func(api *RawAPI, p *model.Project, t *model.Test) (*RawResponse, error) {
+			return api.createBatch(rr, p, t)
+		}}
+	}
+
+	switch format {
+	case formatJSON:
+		rr := new(RawRequest)
+		if err := json.NewDecoder(reader).Decode(rr); err != nil {
+			return nil, err
+		}
+
+		if len(rr.Details) > 0 && (recompute || !rr.hasSummary()) {
+			rr.summarize()
+		}
+
+		return []*jobRun{createBatch(rr)}, nil
+
+	case formatNDJSON:
+		br := bufio.NewReader(reader)
+
+		rr, err := readStreamSummary(br)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*jobRun{{rr: rr, create: func(api *RawAPI, p *model.Project, t *model.Test) (*RawResponse, error) {
+			return api.createStream(br, rr, p, t, recompute)
+		}}}, nil
+
+	case formatInflux, formatCSV:
+		parse := parser.ParseInflux
+		if format == formatCSV {
+			parse = parseCSV
+		}
+
+		results, err := parse(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		runs := make([]*jobRun, len(results))
+		for i, res := range results {
+			runs[i] = createBatch(newImportRequest(res))
+		}
+
+		return runs, nil
+	}
+
+	return nil, errors.New("Unsupported format " + format)
+}
+
+// errorMessage returns the message of the error
+func errorMessage(err error) string {
+	if he, ok := err.(*echo.HTTPError); ok {
+		return fmt.Sprint(he.Message)
+	}
+
+	return err.Error()
+}
+
+// createJob spools the upload in the format and queues a job to process it.
+// The names of the project and test of new results are recorded with the job,
+// which finds or creates them once it is processed.
+func (api *RawAPI) createJob(c echo.Context, format string, p *model.Project, t *model.Test) error {
+	if api.jobs == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Asynchronous ingestion is disabled")
+	}
+
+	f, err := ioutil.TempFile(api.jobs.conf.SpoolDir, "job-")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	j := &model.Job{Status: model.JobQueued, Format: format, BestEffort: getBestEffortParam(c),
+		Recompute: getRecomputeParam(c), Path: f.Name()}
+
+	err = api.spool(c, f, j, p, t)
+	f.Close()
+
+	if err == nil {
+		if err = api.jobs.js.Create(j); err != nil {
+			err = echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	api.jobs.enqueue(j)
+
+	return c.JSON(http.StatusAccepted, &JobResponse{Job: j})
+}
+
+// spool writes the upload to the file. The run summary of JSON uploads is read along the way,
+// both to reject invalid uploads and to find the names of the project and test of new results.
+// Nothing is created, the names are only resolved to the existing project and test.
+func (api *RawAPI) spool(c echo.Context, f *os.File, j *model.Job, p *model.Project, t *model.Test) error {
+	body := io.TeeReader(c.Request().Body, f)
+
+	named := &RawRequest{ProjectName: c.QueryParam("projectName"), TestName: c.QueryParam("testName")}
+
+	var err error
+	switch j.Format {
+	case formatJSON:
+		named = new(RawRequest)
+		err = json.NewDecoder(body).Decode(named)
+	case formatNDJSON:
+		named, err = readStreamSummary(bufio.NewReader(body))
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := io.Copy(ioutil.Discard, body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if t == nil && named.named() {
+		j.ProjectName, j.TestName = model.NormalizeName(named.ProjectName), model.NormalizeName(named.TestName)
+
+		found, err := api.ts.FindByNames(named.ProjectName, named.TestName)
+		if err != nil && !gorm.IsRecordNotFoundError(err) {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		if err == nil {
+			t = found
+			if p, err = api.ps.FindByID(t.ProjectID); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+		}
+	}
+
+	if t != nil {
+		j.ProjectID, j.TestID = p.ID, t.ID
+		j.ProjectName, j.TestName = p.Name, t.Name
+	}
+
+	return nil
+}
+
+// SetupJobAPI sets up the API
+func SetupJobAPI(g *echo.Group, js service.JobService) {
+	api := &JobAPI{js: js}
+
+	g.GET("/:id/", api.get).Name = "ghz api: get job"
+}
+
+// JobAPI provides the api
+type JobAPI struct {
+	js service.JobService
+}
+
+// Get job api
+// @Summary Gets the ingestion job
+// @Description Gets the status and progress of the asynchronous ingestion job, along with the runs created so far.
+// @ID get-job
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} api.JobResponse
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /jobs/{id} [get]
+func (api *JobAPI) get(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Invalid id")
+	}
+
+	j, err := api.js.FindByID(uint(id))
+	if gorm.IsRecordNotFoundError(err) {
+		return echo.NewHTTPError(http.StatusNotFound, "Not Found")
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	res := &JobResponse{Job: j}
+
+	if j.Result != "" {
+		res.Result = new(RawImportResponse)
+		if err := json.Unmarshal([]byte(j.Result), res.Result); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
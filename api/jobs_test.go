@@ -0,0 +1,404 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/bojand/ghz-web/model"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/stretchr/testify/assert"
+	baloo "gopkg.in/h2non/baloo.v3"
+)
+
+// waitForJob waits for the job to finish
+func waitForJob(t *testing.T, js *model.JobService, id uint) *model.Job {
+	for i := 0; i < 100; i++ {
+		j, err := js.FindByID(id)
+		assert.NoError(t, err)
+
+		if j.Finished() {
+			return j
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	assert.FailNow(t, "job did not finish")
+
+	return nil
+}
+
+func TestJobAPI(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	// the workers and the requests share the connection as they do in the app
+	db.DB().SetMaxOpenConns(1)
+
+	conf, cerr := config.Read("../test/config1.toml")
+	if cerr != nil {
+		assert.FailNow(t, cerr.Error())
+	}
+
+	spoolDir, err := ioutil.TempDir("", "ghz-spool")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer os.RemoveAll(spoolDir)
+
+	conf.Ingest.SpoolDir = filepath.Join(spoolDir, "jobs")
+
+	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{}, &model.Submission{}, &model.Job{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ts := &model.TestService{DB: db}
+	ps := &model.ProjectService{DB: db}
+	rs := &model.RunService{DB: db}
+	ds := &model.DetailService{DB: db, Config: &conf.Database}
+	ss := &model.SubmissionService{DB: db}
+	txs := &model.TxService{DB: db, Config: &conf.Database}
+	js := &model.JobService{DB: db}
+
+	jq := NewJobQueue(ps, ts, rs, ds, ss, txs, js, &conf.Idempotency, &conf.Ingest)
+
+	var testID uint
+	var pid, tid string
+
+	var httpTest *baloo.Client
+	var echoServer *echo.Echo
+
+	echoServer = echo.New()
+	echoServer.Use(middleware.AddTrailingSlash())
+	echoServer.Use(middleware.Logger())
+
+	defer echoServer.Close()
+
+	var run0data, run1data, lines, csv []byte
+
+	// postJob posts the upload and returns the id of the queued job
+	postJob := func(t *testing.T, path, contentType string, body []byte) uint {
+		var id uint
+
+		httpTest.Post(path).
+			SetQueryParams(map[string]string{"async": "true"}).
+			AddHeader("Content-Type", contentType).
+			BodyString(string(body)).
+			Expect(t).
+			Status(202).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				jr := new(JobResponse)
+				err := json.NewDecoder(res.Body).Decode(jr)
+
+				assert.NoError(t, err)
+				assert.NotZero(t, jr.ID)
+				assert.Equal(t, model.JobQueued, jr.Status)
+				assert.Nil(t, jr.Result)
+
+				id = jr.ID
+
+				return nil
+			}).
+			Done()
+
+		return id
+	}
+
+	t.Run("Start API", func(t *testing.T) {
+		err := jq.Start()
+		assert.NoError(t, err)
+
+		apiGroup := echoServer.Group("/api")
+		SetupJobAPI(apiGroup.Group("/jobs"), js)
+		SetupRawAPI(apiGroup, ps, ts, rs, ds, ss, txs, &conf.Idempotency, jq)
+
+		go func() {
+			echoServer.Start("localhost:0")
+		}()
+	})
+
+	t.Run("Sync to get the port", func(t *testing.T) {
+		done := make(chan bool, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			done <- true
+			close(done)
+		}()
+
+		<-done
+	})
+
+	t.Run("Create http test", func(t *testing.T) {
+		httpTest = baloo.New(echoServer.Listener.Addr().String())
+	})
+
+	t.Run("Read data files", func(t *testing.T) {
+		run0data, err = ioutil.ReadFile("../test/run0.json")
+		assert.NoError(t, err)
+
+		run1data, err = ioutil.ReadFile("../test/run1.json")
+		assert.NoError(t, err)
+
+		lines, err = ioutil.ReadFile("../test/lines.txt")
+		assert.NoError(t, err)
+
+		csv, err = ioutil.ReadFile("../test/run0.csv")
+		assert.NoError(t, err)
+	})
+
+	t.Run("POST create raw async", func(t *testing.T) {
+		id := postJob(t, "/api/raw/", "application/json", run0data)
+
+		j := waitForJob(t, js, id)
+		assert.Equal(t, model.JobDone, j.Status)
+		assert.Empty(t, j.Error)
+		assert.Equal(t, uint(1), j.Runs)
+		assert.Equal(t, uint(1), j.RunsDone)
+		assert.Equal(t, uint(937), j.DetailsCreated)
+		assert.Equal(t, uint(0), j.DetailsFailed)
+		assert.NotZero(t, j.TestID)
+
+		_, err := os.Stat(j.Path)
+		assert.True(t, os.IsNotExist(err))
+
+		httpTest.Get("/api/jobs/" + strconv.FormatUint(uint64(id), 10) + "/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				jr := new(JobResponse)
+				err := json.NewDecoder(res.Body).Decode(jr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, id, jr.ID)
+				assert.Equal(t, model.JobDone, jr.Status)
+				assert.NotNil(t, jr.Result)
+				assert.Len(t, jr.Result.Runs, 1)
+
+				rr := jr.Result.Runs[0]
+				assert.Equal(t, j.TestID, rr.Test.ID)
+				assert.NotZero(t, rr.Run.ID)
+				assert.Equal(t, uint(937), rr.Details.Success)
+
+				testID = rr.Test.ID
+				tid = strconv.FormatUint(uint64(testID), 10)
+				pid = strconv.FormatUint(uint64(rr.Project.ID), 10)
+
+				count, err := ds.Count(rr.Run.ID)
+				assert.NoError(t, err)
+				assert.Equal(t, uint(937), count)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("POST create raw stream async to test", func(t *testing.T) {
+		id := postJob(t, "/api/projects/"+pid+"/tests/"+tid+"/raw/stream/", "application/x-ndjson",
+			[]byte(toNDJSON(t, run1data)))
+
+		j := waitForJob(t, js, id)
+		assert.Equal(t, model.JobDone, j.Status)
+		assert.Equal(t, testID, j.TestID)
+		assert.Equal(t, uint(1), j.RunsDone)
+		assert.NotZero(t, j.DetailsCreated)
+
+		count, err := rs.Count(testID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), count)
+	})
+
+	t.Run("POST import async by names", func(t *testing.T) {
+		var id uint
+
+		httpTest.Post("/api/raw/influx/").
+			SetQueryParams(map[string]string{"async": "true", "projectName": "Jobs", "testName": "Influx"}).
+			AddHeader("Content-Type", "text/plain").
+			BodyString(string(lines)).
+			Expect(t).
+			Status(202).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				jr := new(JobResponse)
+				err := json.NewDecoder(res.Body).Decode(jr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, "jobs", jr.ProjectName)
+				assert.Equal(t, "influx", jr.TestName)
+				assert.Zero(t, jr.TestID)
+
+				id = jr.ID
+
+				return nil
+			}).
+			Done()
+
+		j := waitForJob(t, js, id)
+		assert.Equal(t, model.JobDone, j.Status)
+		assert.NotZero(t, j.TestID)
+		assert.Equal(t, uint(10), j.Runs)
+		assert.Equal(t, uint(10), j.RunsDone)
+
+		count, err := rs.Count(j.TestID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(10), count)
+	})
+
+	t.Run("POST jobs of a test are processed in order", func(t *testing.T) {
+		first := postJob(t, "/api/projects/"+pid+"/tests/"+tid+"/raw/csv/", "text/csv", csv)
+		second := postJob(t, "/api/projects/"+pid+"/tests/"+tid+"/raw/csv/", "text/csv", csv)
+
+		j1 := waitForJob(t, js, first)
+		j2 := waitForJob(t, js, second)
+
+		assert.Equal(t, model.JobDone, j1.Status)
+		assert.Equal(t, model.JobDone, j2.Status)
+		assert.False(t, j2.UpdatedAt.Before(j1.UpdatedAt))
+	})
+
+	t.Run("POST invalid upload is rejected", func(t *testing.T) {
+		httpTest.Post("/api/raw/").
+			SetQueryParams(map[string]string{"async": "true"}).
+			AddHeader("Content-Type", "application/json").
+			BodyString(`{"date":`).
+			Expect(t).
+			Status(400).
+			Done()
+
+		files, err := ioutil.ReadDir(conf.Ingest.SpoolDir)
+		assert.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("POST invalid import fails the job", func(t *testing.T) {
+		id := postJob(t, "/api/projects/"+pid+"/tests/"+tid+"/raw/csv/", "text/csv", []byte("status,error\nOK,\n"))
+
+		j := waitForJob(t, js, id)
+		assert.Equal(t, model.JobFailed, j.Status)
+		assert.Equal(t, "missing duration (ms) column", j.Error)
+		assert.Equal(t, uint(0), j.RunsDone)
+	})
+
+	t.Run("POST best effort job with an invalid detail is done", func(t *testing.T) {
+		var data map[string]interface{}
+		err := json.Unmarshal(run1data, &data)
+		assert.NoError(t, err)
+
+		// a run not submitted before
+		data["date"] = "2018-09-03T10:00:00Z"
+
+		other, err := json.Marshal(data)
+		assert.NoError(t, err)
+
+		var id uint
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/stream/").
+			SetQueryParams(map[string]string{"async": "true", "bestEffort": "true"}).
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString(toNDJSON(t, other, `{"latency":`)).
+			Expect(t).
+			Status(202).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				jr := new(JobResponse)
+				err := json.NewDecoder(res.Body).Decode(jr)
+
+				assert.NoError(t, err)
+
+				id = jr.ID
+
+				return nil
+			}).
+			Done()
+
+		j := waitForJob(t, js, id)
+		assert.Equal(t, model.JobDone, j.Status)
+		assert.Empty(t, j.Error)
+		assert.Equal(t, uint(1), j.RunsDone)
+		assert.NotZero(t, j.DetailsCreated)
+		assert.Equal(t, uint(1), j.DetailsFailed)
+	})
+
+	t.Run("POST job by names with an invalid detail creates no project or test", func(t *testing.T) {
+		var data map[string]interface{}
+		err := json.Unmarshal(run1data, &data)
+		assert.NoError(t, err)
+
+		data["projectName"] = "Failed Jobs"
+		data["testName"] = "Failed Stream"
+
+		named, err := json.Marshal(data)
+		assert.NoError(t, err)
+
+		id := postJob(t, "/api/raw/stream/", "application/x-ndjson", []byte(toNDJSON(t, named, `{"latency":`)))
+
+		j := waitForJob(t, js, id)
+		assert.Equal(t, model.JobFailed, j.Status)
+		assert.Contains(t, j.Error, "No results were saved.")
+		assert.Equal(t, uint(0), j.RunsDone)
+
+		_, err = ps.FindByName("failedjobs")
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+
+	t.Run("Unfinished jobs are processed on start", func(t *testing.T) {
+		path := filepath.Join(conf.Ingest.SpoolDir, "job-restart")
+		err := ioutil.WriteFile(path, csv, 0644)
+		assert.NoError(t, err)
+
+		p, err := strconv.Atoi(pid)
+		assert.NoError(t, err)
+
+		j := &model.Job{Status: model.JobProcessing, Format: formatCSV, Path: path,
+			ProjectID: uint(p), TestID: testID, RunsDone: 1}
+		err = js.Create(j)
+		assert.NoError(t, err)
+
+		restarted := NewJobQueue(ps, ts, rs, ds, ss, txs, js, &conf.Idempotency, &conf.Ingest)
+		err = restarted.Start()
+		assert.NoError(t, err)
+
+		j = waitForJob(t, js, j.ID)
+		assert.Equal(t, model.JobDone, j.Status)
+		assert.Equal(t, uint(1), j.Runs)
+		assert.Equal(t, uint(1), j.RunsDone)
+
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("GET unknown job", func(t *testing.T) {
+		httpTest.Get("/api/jobs/12345/").
+			Expect(t).
+			Status(404).
+			Done()
+	})
+
+	t.Run("POST async when disabled", func(t *testing.T) {
+		disabledGroup := echoServer.Group("/disabled")
+		SetupRawAPI(disabledGroup, ps, ts, rs, ds, ss, txs, &conf.Idempotency, nil)
+
+		httpTest.Post("/disabled/raw/").
+			SetQueryParams(map[string]string{"async": "true"}).
+			AddHeader("Content-Type", "application/json").
+			BodyString(string(run0data)).
+			Expect(t).
+			Status(400).
+			Done()
+	})
+}
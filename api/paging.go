@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/labstack/echo"
+)
+
+// setListHeaders sets the total number of items and the links to the pages of the list.
+// The next link uses the cursor if it is set, and the next page number otherwise.
+func setListHeaders(c echo.Context, total, limit, page uint, next string) {
+	header := c.Response().Header()
+	header.Set("X-Total-Count", strconv.FormatUint(uint64(total), 10))
+
+	req := c.Request()
+	base := c.Scheme() + "://" + req.Host + req.URL.Path
+
+	link := func(rel string, set func(v url.Values)) string {
+		v := req.URL.Query()
+		v.Del("page")
+		v.Del("after")
+		set(v)
+
+		u := base
+		if query := v.Encode(); query != "" {
+			u += "?" + query
+		}
+
+		return "<" + u + ">; rel=\"" + rel + "\""
+	}
+
+	links := []string{link("first", func(v url.Values) {})}
+
+	cursor := req.URL.Query().Get("after") != ""
+
+	if next != "" {
+		links = append(links, link("next", func(v url.Values) {
+			v.Set("after", next)
+		}))
+	} else if !cursor && (page+1)*limit < total {
+		links = append(links, link("next", func(v url.Values) {
+			v.Set("page", strconv.FormatUint(uint64(page+1), 10))
+		}))
+	}
+
+	if !cursor && page > 0 {
+		links = append(links, link("prev", func(v url.Values) {
+			v.Set("page", strconv.FormatUint(uint64(page-1), 10))
+		}))
+	}
+
+	header.Set("Link", strings.Join(links, ", "))
+}
+
+// nextCursor returns the cursor of the page following a full page of a list paged by id
+func nextCursor(q *model.Query, limit, n, lastID uint) string {
+	if !q.Seekable() || n < limit {
+		return ""
+	}
+
+	return strconv.FormatUint(uint64(lastID), 10)
+}
@@ -1,48 +1,218 @@
 package api
 
 import (
+	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/bojand/ghz-web/model"
 	"github.com/labstack/echo"
 )
 
-// TODO add tests
+const (
+	// defaultListLimit is the number of items in a page of a list by default
+	defaultListLimit = 20
 
-func getPageParam(c echo.Context) uint {
+	// maxListLimit is the maximum number of items in a page of a list
+	maxListLimit = 500
+)
+
+func getPageParam(c echo.Context) (uint, error) {
 	pageparam := c.QueryParam("page")
-	page := uint(0)
-	if pageparam != "" {
-		pageNum, err := strconv.Atoi(pageparam)
-		if err == nil {
-			if pageNum < 0 {
-				pageNum = pageNum * -1
+	if pageparam == "" {
+		return 0, nil
+	}
+
+	page, err := strconv.ParseUint(pageparam, 10, 32)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest,
+			"Invalid page '"+pageparam+"'. The page must be a non-negative integer")
+	}
+
+	return uint(page), nil
+}
+
+func getLimitParam(c echo.Context) (uint, error) {
+	limitparam := c.QueryParam("limit")
+	if limitparam == "" {
+		return defaultListLimit, nil
+	}
+
+	limit, err := strconv.ParseUint(limitparam, 10, 32)
+	if err != nil || limit == 0 || limit > maxListLimit {
+		return 0, echo.NewHTTPError(http.StatusBadRequest,
+			"Invalid limit '"+limitparam+"'. The limit must be between 1 and "+strconv.Itoa(maxListLimit))
+	}
+
+	return uint(limit), nil
+}
+
+// getListParams returns the limit and the page of the list request
+func getListParams(c echo.Context) (uint, uint, error) {
+	limit, err := getLimitParam(c)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	page, err := getPageParam(c)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return limit, page, nil
+}
+
+// getAfterParam sets the id cursor of the query from the after parameter.
+// A list is paged either by the page number or by the cursor.
+func getAfterParam(c echo.Context, q *model.Query) error {
+	afterparam := c.QueryParam("after")
+	if afterparam == "" {
+		return nil
+	}
+
+	after, err := strconv.ParseUint(afterparam, 10, 32)
+	if err != nil || after == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"Invalid cursor '"+afterparam+"'. The cursor must be the id of an item")
+	}
+
+	if c.QueryParam("page") != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid cursor. Lists can not be paged by both page and after")
+	}
+
+	if err := q.SetAfter(uint(after)); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return nil
+}
+
+// getIncludeTrashedParam returns whether the list includes the items in the trash
+func getIncludeTrashedParam(c echo.Context) (bool, error) {
+	param := c.QueryParam("includeTrashed")
+	if param == "" {
+		return false, nil
+	}
+
+	includeTrashed, err := strconv.ParseBool(param)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusBadRequest,
+			"Invalid includeTrashed '"+param+"'. The value must be true or false")
+	}
+
+	return includeTrashed, nil
+}
+
+func getWindowParam(c echo.Context) uint {
+	window := uint(0)
+	if windowNum, err := strconv.Atoi(c.QueryParam("window")); err == nil && windowNum > 0 {
+		window = uint(windowNum)
+	}
+
+	return window
+}
+
+func getBestEffortParam(c echo.Context) bool {
+	bestEffort, err := strconv.ParseBool(c.QueryParam("bestEffort"))
+	return err == nil && bestEffort
+}
+
+func getAsyncParam(c echo.Context) bool {
+	async, err := strconv.ParseBool(c.QueryParam("async"))
+	return err == nil && async
+}
+
+func getRecomputeParam(c echo.Context) bool {
+	recompute, err := strconv.ParseBool(c.QueryParam("recompute"))
+	return err == nil && recompute
+}
+
+func getDryRunParam(c echo.Context) bool {
+	dryRun, err := strconv.ParseBool(c.QueryParam("dryRun"))
+	return err == nil && dryRun
+}
+
+// listParams are the paging and sorting parameters accepted by every list
+var listParams = []string{"limit", "page", "after", "sort", "order"}
+
+// getQuery returns the query of the list request for the resource fields.
+// Each field is filtered by the parameter of its name, and by the min and max parameters
+// such as minRps and maxRps for the bounds of numeric, duration and time fields.
+// Any other parameter than the filters, the list parameters and the given parameters
+// of the list is rejected.
+func getQuery(c echo.Context, fields model.QueryFields, params ...string) (*model.Query, error) {
+	q := model.NewQuery(fields)
+
+	known := make(map[string]bool)
+	for _, p := range listParams {
+		known[p] = true
+	}
+
+	for _, p := range params {
+		known[p] = true
+	}
+
+	type filterParam struct {
+		field string
+		op    model.FilterOp
+	}
+
+	filters := make(map[string]filterParam)
+
+	for name, f := range fields {
+		title := strings.ToUpper(name[:1]) + name[1:]
+
+		ops := []struct {
+			param string
+			op    model.FilterOp
+		}{
+			{name, model.FilterEq},
+			{"min" + title, model.FilterMin},
+			{"max" + title, model.FilterMax},
+		}
+
+		for _, o := range ops {
+			if f.Supports(o.op) {
+				filters[o.param] = filterParam{name, o.op}
 			}
+		}
+	}
+
+	names := make([]string, 0, len(c.QueryParams()))
+	for name := range c.QueryParams() {
+		names = append(names, name)
+	}
 
-			page = uint(pageNum)
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := filters[name]; !ok && !known[name] {
+			return nil, echo.NewHTTPError(http.StatusBadRequest,
+				"Invalid parameter '"+name+"'. The list does not support the parameter")
 		}
 	}
 
-	return page
-}
+	if field := c.QueryParam("sort"); field != "" {
+		if err := q.SetSort(field, c.QueryParam("order")); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	} else if c.QueryParam("order") != "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid order. The order requires a sort field")
+	}
 
-func getSortAndOrder(c echo.Context) (bool, string, string) {
-	doSort := false
-	sort := c.QueryParam("sort")
-	order := c.QueryParam("order")
-	if sort != "" {
-		sort = strings.ToLower(sort)
-		if sort == "id" || sort == "name" {
-			doSort = true
+	for _, name := range names {
+		f, ok := filters[name]
+		if !ok {
+			continue
 		}
 
-		if doSort {
-			order = strings.ToLower(order)
-			if order != "asc" && order != "desc" {
-				order = "asc"
+		for _, v := range c.QueryParams()[name] {
+			if err := q.AddFilter(f.field, f.op, v); err != nil {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
 			}
 		}
 	}
 
-	return doSort, sort, order
+	return q, nil
 }
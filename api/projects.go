@@ -11,8 +11,9 @@ import (
 
 // ProjectList response
 type ProjectList struct {
-	Total uint             `json:"total"`
-	Data  []*model.Project `json:"data"`
+	Listable
+
+	Data []*model.Project `json:"data"`
 }
 
 // SetupProjectAPI sets up the API
@@ -27,6 +28,8 @@ func SetupProjectAPI(g *echo.Group, ps service.ProjectService) {
 	g.GET("/:pid/", api.get).Name = "ghz api: get project"
 	g.PUT("/:pid/", api.update).Name = "ghz api:  update project"
 	g.DELETE("/:pid/", api.delete).Name = "ghz api: delete project"
+
+	g.GET("/:pid/status-history/", api.getStatusHistory).Name = "ghz api: get project status history"
 }
 
 // ProjectAPI provides the api
@@ -49,6 +52,17 @@ func (api *ProjectAPI) create(c echo.Context) error {
 	return c.JSON(http.StatusCreated, p)
 }
 
+func (api *ProjectAPI) getStatusHistory(c echo.Context) error {
+	po := c.Get("project")
+	p, ok := po.(*model.Project)
+
+	if p == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No project in context")
+	}
+
+	return getStatusHistory(c, p.ID, api.ps.CountStatusHistory, api.ps.FindStatusHistory, api.ps.FindStatusSummary)
+}
+
 func (api *ProjectAPI) get(c echo.Context) error {
 	po := c.Get("project")
 	p, ok := po.(*model.Project)
@@ -90,15 +104,46 @@ func (api *ProjectAPI) update(c echo.Context) error {
 }
 
 func (api *ProjectAPI) delete(c echo.Context) error {
-	return echo.NewHTTPError(http.StatusNotImplemented, "Not Implemented")
+	po := c.Get("project")
+	p, ok := po.(*model.Project)
+
+	if p == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No project in context")
+	}
+
+	res, err := api.ps.Delete(p)
+
+	if gorm.IsRecordNotFoundError(err) {
+		return echo.NewHTTPError(http.StatusNotFound, "Not Found")
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, res)
 }
 
 func (api *ProjectAPI) listProjects(c echo.Context) error {
-	page := getPageParam(c)
+	limit, page, err := getListParams(c)
+	if err != nil {
+		return err
+	}
+
+	q, err := getQuery(c, model.ProjectQueryFields, "includeTrashed")
+	if err != nil {
+		return err
+	}
 
-	doSort, sort, order := getSortAndOrder(c)
+	includeTrashed, err := getIncludeTrashedParam(c)
+	if err != nil {
+		return err
+	}
 
-	limit := uint(20)
+	countQuery, listQuery := api.ps.CountQuery, api.ps.ListQuery
+	if includeTrashed {
+		countQuery, listQuery = api.ps.CountQueryUnscoped, api.ps.ListQueryUnscoped
+	}
 
 	countCh := make(chan uint, 1)
 	dataCh := make(chan []*model.Project, 1)
@@ -106,20 +151,14 @@ func (api *ProjectAPI) listProjects(c echo.Context) error {
 	defer close(errCh)
 
 	go func() {
-		count, err := api.ps.Count()
+		count, err := countQuery(q)
 		errCh <- err
 		countCh <- count
 		close(countCh)
 	}()
 
 	go func() {
-		var projects []*model.Project
-		var err error
-		if doSort {
-			projects, err = api.ps.ListSorted(limit, page, sort, order)
-		} else {
-			projects, err = api.ps.List(limit, page)
-		}
+		projects, err := listQuery(limit, page, q)
 		errCh <- err
 		dataCh <- projects
 		close(dataCh)
@@ -135,7 +174,10 @@ func (api *ProjectAPI) listProjects(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err2.Error())
 	}
 
-	pl := &ProjectList{Total: count, Data: data}
+	pl := &ProjectList{Data: data}
+	pl.Total = count
+
+	setListHeaders(c, count, limit, page, "")
 
 	return c.JSON(http.StatusOK, pl)
 }
@@ -30,7 +30,8 @@ func TestProjectAPI(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(&model.Project{}, &model.Test{})
+	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	ps := &model.ProjectService{DB: db}
@@ -236,9 +237,34 @@ func TestProjectAPI(t *testing.T) {
 	})
 
 	t.Run("DELETE /:id", func(t *testing.T) {
-		httpTest.Delete(basePath + "/" + pid + "/").
+		p := &model.Project{Name: "To Delete"}
+		err := ps.Create(p)
+		assert.NoError(t, err)
+
+		tst := &model.Test{ProjectID: p.ID, Name: "Test To Delete"}
+		err = db.Create(tst).Error
+		assert.NoError(t, err)
+
+		httpTest.Delete(basePath + "/" + strconv.FormatUint(uint64(p.ID), 10) + "/").
 			Expect(t).
-			Status(501).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				dr := new(model.CascadeResult)
+				err = json.NewDecoder(res.Body).Decode(dr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), dr.Projects)
+				assert.Equal(t, uint(1), dr.Tests)
+				assert.Equal(t, uint(0), dr.Runs)
+
+				return nil
+			}).
+			Done()
+
+		httpTest.Get(basePath + "/" + strconv.FormatUint(uint64(p.ID), 10) + "/").
+			Expect(t).
+			Status(404).
 			Type("json").
 			Done()
 	})
@@ -275,6 +301,95 @@ func TestProjectAPI(t *testing.T) {
 			Done()
 	})
 
+	t.Run("GET /?includeTrashed=true", func(t *testing.T) {
+		httpTest.Get(basePath + "/").
+			SetQueryParams(map[string]string{"includeTrashed": "true"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				pl := new(ProjectList)
+				err = json.NewDecoder(res.Body).Decode(pl)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(4), pl.Total)
+				assert.Len(t, pl.Data, 4)
+
+				names := make([]string, len(pl.Data))
+				for i, p := range pl.Data {
+					names[i] = p.Name
+				}
+
+				assert.Contains(t, names, "todelete")
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /?includeTrashed=false", func(t *testing.T) {
+		httpTest.Get(basePath + "/").
+			SetQueryParams(map[string]string{"includeTrashed": "false"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				pl := new(ProjectList)
+				err = json.NewDecoder(res.Body).Decode(pl)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(3), pl.Total)
+				assert.Len(t, pl.Data, 3)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /?includeTrashed=invalid should 400", func(t *testing.T) {
+		httpTest.Get(basePath + "/").
+			SetQueryParams(map[string]string{"includeTrashed": "sometimes"}).
+			Expect(t).
+			Status(400).
+			Type("json").
+			Done()
+	})
+
+	t.Run("GET /?minName=a should 400", func(t *testing.T) {
+		params := []map[string]string{
+			{"minName": "a"},
+			{"histogram": "true"},
+			{"foo": "bar"},
+		}
+
+		for _, p := range params {
+			httpTest.Get(basePath + "/").
+				SetQueryParams(p).
+				Expect(t).
+				Status(400).
+				Type("json").
+				Done()
+		}
+	})
+
+	t.Run("GET /?order=desc without sort should 400", func(t *testing.T) {
+		httpTest.Get(basePath + "/").
+			SetQueryParams(map[string]string{"order": "desc"}).
+			Expect(t).
+			Status(400).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				he := new(echo.HTTPError)
+				err := json.NewDecoder(res.Body).Decode(he)
+
+				assert.NoError(t, err)
+				assert.Equal(t, "Invalid order. The order requires a sort field", he.Message)
+
+				return nil
+			}).
+			Done()
+	})
+
 	t.Run("GET /?sort=ID", func(t *testing.T) {
 		httpTest.Get(basePath + "/").
 			SetQueryParams(map[string]string{"sort": "ID"}).
@@ -2,16 +2,41 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/bojand/ghz-web/config"
 	"github.com/bojand/ghz-web/model"
 	"github.com/bojand/ghz-web/service"
+	"github.com/jinzhu/gorm"
 	"github.com/labstack/echo"
 )
 
 // RawRequest request to the create raw api
 type RawRequest struct {
+	// Name of the project of new results. The project is created if it does not exist.
+	// A project with a random name is created if not set.
+	ProjectName string `json:"projectName,omitempty"`
+
+	// Description of the project if it is created
+	ProjectDescription string `json:"projectDescription,omitempty"`
+
+	// Name of the test of new results. The test is created if it does not exist.
+	// A test with a random name is created if not set.
+	TestName string `json:"testName,omitempty"`
+
+	// Description of the test if it is created
+	TestDescription string `json:"testDescription,omitempty"`
+
+	// Thresholds of the test if it is created. The thresholds of existing tests are not changed.
+	Thresholds map[model.Threshold]*model.ThresholdSetting `json:"thresholds,omitempty"`
+
+	// Whether the test fails on its thresholds if it is created
+	FailOnThreshold bool `json:"failOnThreshold,omitempty"`
+
 	// Date of the test
 	Date time.Time `json:"date"`
 
@@ -69,6 +94,11 @@ func (rr *RawRequest) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	// the date of results with only details is set when their summary is computed
+	if aux.Date == "" {
+		return nil
+	}
+
 	var err error
 	rr.Date, err = time.Parse(time.RFC3339, aux.Date)
 	if err != nil {
@@ -125,6 +155,9 @@ type RawResponse struct {
 	// Created run
 	Run *model.Run `json:"run"`
 
+	// Comparison of the run against the test's baseline
+	Baseline *model.BaselineComparison `json:"baseline,omitempty"`
+
 	// The summary of created details
 	Details *DetailsCreated `json:"details"`
 }
@@ -136,14 +169,41 @@ type DetailsCreated struct {
 
 	// Number of failed detail objects
 	Fail uint `json:"fail"`
+
+	// Errors of the chunks of details that failed
+	Errors []string `json:"errors,omitempty"`
+}
+
+// add adds the result of creating a batch of details
+func (dc *DetailsCreated) add(res *model.BatchResult) {
+	dc.Success += res.Created
+	dc.Fail += res.Failed
+
+	for _, err := range res.Errors {
+		dc.Errors = append(dc.Errors, err.Error())
+	}
+}
+
+// rolledBack returns the description of the details that failed when no results were saved
+func (dc *DetailsCreated) rolledBack() string {
+	msg := fmt.Sprintf("%d of %d details failed to be created. No results were saved.", dc.Fail, dc.Success+dc.Fail)
+	if len(dc.Errors) > 0 {
+		msg = msg + " " + strings.Join(dc.Errors, "; ")
+	}
+
+	return msg
 }
 
 // RawAPI provides the api
 type RawAPI struct {
-	ps service.ProjectService
-	ts service.TestService
-	rs service.RunService
-	ds service.DetailService
+	ps   service.ProjectService
+	ts   service.TestService
+	rs   service.RunService
+	ds   service.DetailService
+	ss   service.SubmissionService
+	txs  service.TxService
+	conf *config.IdempotencyConfig
+	jobs *JobQueue
 }
 
 // SetupRawAPI sets up the API
@@ -151,26 +211,47 @@ func SetupRawAPI(g *echo.Group,
 	ps service.ProjectService,
 	ts service.TestService,
 	rs service.RunService,
-	ds service.DetailService) {
+	ds service.DetailService,
+	ss service.SubmissionService,
+	txs service.TxService,
+	conf *config.IdempotencyConfig,
+	jobs *JobQueue) {
 
-	api := &RawAPI{ps: ps, ts: ts, rs: rs, ds: ds}
+	api := &RawAPI{ps: ps, ts: ts, rs: rs, ds: ds, ss: ss, txs: txs, conf: conf, jobs: jobs}
 
 	g.POST("/raw/", api.createNew).Name = "ghz api: create raw 2"
+	g.POST("/raw/stream/", api.createNewStream).Name = "ghz api: create raw stream 2"
+	g.POST("/raw/influx/", api.importNewInflux).Name = "ghz api: import influx 2"
+	g.POST("/raw/csv/", api.importNewCSV).Name = "ghz api: import csv 2"
 
 	g.Use(api.populateProject)
 	g.Use(api.populateTest)
 
 	g.POST("/projects/:pid/tests/:tid/raw/", api.createRaw).Name = "ghz api: create raw"
+	g.POST("/projects/:pid/tests/:tid/raw/stream/", api.createRawStream).Name = "ghz api: create raw stream"
+	g.POST("/projects/:pid/tests/:tid/raw/influx/", api.importInflux).Name = "ghz api: import influx"
+	g.POST("/projects/:pid/tests/:tid/raw/csv/", api.importCSV).Name = "ghz api: import csv"
+
+	g.POST("/projects/:pid/tests/:tid/raw/runs/", api.openRun).Name = "ghz api: open run"
+	g.POST("/projects/:pid/tests/:tid/raw/runs/:rid/details/", api.appendRunDetails).Name = "ghz api: append run details"
+	g.POST("/projects/:pid/tests/:tid/raw/runs/:rid/finish/", api.finishRun).Name = "ghz api: finish run"
 }
 
 // Create raw result api
 // @Summary Created raw results for given project and test
-// @Description Created raw results for given project and test
+// @Description Created raw results for given project and test.
+// @Description The results are created all or nothing unless bestEffort is set,
+// @Description in which case the results created before a failure are kept and the run is marked as partial.
 // @ID post-create-raw
 // @Accept  json
 // @Produce json
 // @Param RawRequest body api.RawRequest true "Raw request"
+// @Param bestEffort query bool false "Keep the results created before a failure"
+// @Param async query bool false "Process the results in the background and return the job"
+// @Param recompute query bool false "Compute the run summary from the details even if it is set"
+// @Param Idempotency-Key header string false "Key identifying the submission. Submitting the same key again returns the original response."
 // @Success 200 {object} api.RawResponse
+// @Success 202 {object} api.JobResponse
 // @Failure 400 {object} echo.HTTPError
 // @Failure 404 {object} echo.HTTPError
 // @Failure 500 {object} echo.HTTPError
@@ -190,53 +271,350 @@ func (api *RawAPI) createRaw(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "No test in context")
 	}
 
-	rr := new(RawRequest)
+	if getAsyncParam(c) {
+		return api.createJob(c, formatJSON, p, t)
+	}
 
-	if err := c.Bind(rr); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	rr, err := bindRaw(c)
+	if err != nil {
+		return err
 	}
 
-	return api.createBatch(c, rr, p, t)
+	return api.createResults(c, rr, t.ID, func(api *RawAPI) (*RawResponse, error) {
+		return api.createBatch(rr, p, t)
+	})
 }
 
 // Create new raw result api
 // @Summary Created new raw results
-// @Description Created new raw results. Automatically creates prject, test and run.
+// @Description Created new raw results. Finds the project and test by the names in the request,
+// @Description creating the ones that do not exist, or creates a new project and test if no names are set.
+// @Description The results are created all or nothing unless bestEffort is set,
+// @Description in which case the results created before a failure are kept and the run is marked as partial.
 // @ID post-create-raw-new
 // @Accept  json
 // @Produce json
 // @Param RawRequest body api.RawRequest true "Raw request"
+// @Param bestEffort query bool false "Keep the results created before a failure"
+// @Param async query bool false "Process the results in the background and return the job"
+// @Param recompute query bool false "Compute the run summary from the details even if it is set"
+// @Param Idempotency-Key header string false "Key identifying the submission. Submitting the same key again returns the original response."
 // @Success 200 {object} api.RawResponse
+// @Success 202 {object} api.JobResponse
 // @Failure 400 {object} echo.HTTPError
 // @Failure 404 {object} echo.HTTPError
+// @Failure 409 {object} echo.HTTPError
 // @Failure 500 {object} echo.HTTPError
-// @Router /projects/{pid}/tests/{tid}/raw/ [post]
+// @Router /raw/ [post]
 func (api *RawAPI) createNew(c echo.Context) error {
+	if getAsyncParam(c) {
+		return api.createJob(c, formatJSON, nil, nil)
+	}
+
+	rr, err := bindRaw(c)
+	if err != nil {
+		return err
+	}
+
+	tid, err := api.findTestID(rr)
+	if err != nil {
+		return err
+	}
+
+	return api.createResults(c, rr, tid, func(api *RawAPI) (*RawResponse, error) {
+		p, t, err := api.newTest(rr)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.createBatch(rr, p, t)
+	})
+}
+
+// createResults creates the submitted results. By default the results are created
+// within a single transaction so nothing is saved if any part fails. If the bestEffort
+// query parameter is set the results created before a failure are kept.
+// Results already submitted to the test, with the same idempotency key or content,
+// return the original response instead.
+func (api *RawAPI) createResults(c echo.Context, rr *RawRequest, tid uint,
+	create func(api *RawAPI) (*RawResponse, error)) error {
+
+	sub, err := api.newSubmission(rr, tid, idempotencyKey(c))
+	if err != nil {
+		return err
+	}
+
+	if sub != nil {
+		if original, err := api.findSubmission(sub); original != nil || err != nil {
+			if err != nil {
+				return err
+			}
+
+			c.Response().Header().Set(headerIdempotentReplayed, "true")
+			return c.JSONBlob(http.StatusCreated, []byte(original.Response))
+		}
+	}
+
+	bestEffort := getBestEffortParam(c)
+
+	var rres *RawResponse
+	err = api.transact(bestEffort, func(api *RawAPI) error {
+		var cerr error
+		if rres, cerr = create(api); cerr != nil {
+			return cerr
+		}
+
+		if cerr = detailsFailed(rres.Details, rres, bestEffort); cerr != nil {
+			return cerr
+		}
+
+		// a named test may have been created along with the results
+		if sub != nil && rr.named() {
+			sub.TestID = rres.Test.ID
+		}
+
+		return api.recordSubmission(sub, rres)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, rres)
+}
+
+// transact calls fn with an api using the services of a single transaction, so nothing
+// is saved if fn fails. If bestEffort is set fn is called with the api itself instead,
+// keeping whatever was created before a failure.
+func (api *RawAPI) transact(bestEffort bool, fn func(api *RawAPI) error) error {
+	if bestEffort {
+		return fn(api)
+	}
+
+	err := api.txs.Transact(func(tx *model.TxServices) error {
+		return fn(&RawAPI{ps: tx.Projects, ts: tx.Tests, rs: tx.Runs, ds: tx.Details, ss: tx.Submissions,
+			txs: api.txs, conf: api.conf})
+	})
+
+	if err != nil {
+		if _, ok := err.(*echo.HTTPError); ok {
+			return err
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return nil
+}
+
+// detailsFailed returns the error for details that failed to be created, if any.
+// With bestEffort the error has the response body, otherwise the description of the rollback.
+func detailsFailed(details *DetailsCreated, body interface{}, bestEffort bool) error {
+	if details.Fail == uint(0) {
+		return nil
+	}
+
+	if bestEffort {
+		return echo.NewHTTPError(http.StatusInternalServerError, body)
+	}
+
+	return echo.NewHTTPError(http.StatusInternalServerError, details.rolledBack())
+}
+
+// named returns whether the request names the project or the test of new results
+func (rr *RawRequest) named() bool {
+	return strings.TrimSpace(rr.ProjectName) != "" || strings.TrimSpace(rr.TestName) != ""
+}
+
+// newProjectAndTest returns the project and test of new results described by the request
+func (rr *RawRequest) newProjectAndTest() (*model.Project, *model.Test) {
+	p := &model.Project{Name: rr.ProjectName, Description: rr.ProjectDescription}
+
+	t := &model.Test{
+		Name:            rr.TestName,
+		Description:     rr.TestDescription,
+		Thresholds:      rr.Thresholds,
+		FailOnThreshold: rr.FailOnThreshold,
+	}
+
+	return p, t
+}
+
+// hasSummary returns whether the request has the latency summary of the run
+func (rr *RawRequest) hasSummary() bool {
+	return rr.Average != 0 || rr.Fastest != 0 || rr.Slowest != 0 || rr.Rps != 0 ||
+		len(rr.LatencyDistribution) > 0 || len(rr.Histogram) > 0
+}
+
+// summarize sets the summary of the request computed from its details
+func (rr *RawRequest) summarize() {
+	r := &model.Run{Date: rr.Date}
+	r.Summarize(rr.Details)
+
+	setSummary(rr, r)
+
+	if rr.Date.IsZero() {
+		rr.Date = time.Now()
+	}
+}
+
+// setSummary sets the summary of the request to the summary of the run
+func setSummary(rr *RawRequest, r *model.Run) {
+	rr.Date = r.Date
+	rr.Count = r.Count
+	rr.Total = r.Total
+	rr.Average = r.Average
+	rr.Fastest = r.Fastest
+	rr.Slowest = r.Slowest
+	rr.Rps = r.Rps
+	rr.ErrorDist = r.ErrorDist
+	rr.StatusCodeDist = r.StatusCodeDist
+
+	rr.LatencyDistribution = nil
+	for _, l := range r.LatencyDistribution {
+		rr.LatencyDistribution = append(rr.LatencyDistribution,
+			&RawLatencyDistribution{Percentage: l.Percentage, Latency: l.Latency})
+	}
+
+	rr.Histogram = nil
+	for _, b := range r.Histogram {
+		rr.Histogram = append(rr.Histogram, &RawBucket{Mark: b.Mark, Count: b.Count, Frequency: b.Frequency})
+	}
+}
+
+// bindRaw binds the raw request, computing its summary from the details
+// if it has none or the recompute query parameter is set
+func bindRaw(c echo.Context) (*RawRequest, error) {
 	rr := new(RawRequest)
 
 	if err := c.Bind(rr); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if len(rr.Details) > 0 && (getRecomputeParam(c) || !rr.hasSummary()) {
+		rr.summarize()
 	}
 
-	p := new(model.Project)
+	return rr, nil
+}
+
+// createProjectAndTest creates a new project and test for new results
+func (api *RawAPI) createProjectAndTest(rr *RawRequest) (*model.Project, *model.Test, error) {
+	p, t := rr.newProjectAndTest()
 
 	err := api.ps.Create(p)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	t := new(model.Test)
 	t.ProjectID = p.ID
 
 	err = api.ts.Create(t)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return p, t, nil
+}
+
+// findTestID returns the id of the existing test named in the request, or 0 if the request
+// does not name an existing test. Nothing is created, as the test is created along with the results.
+func (api *RawAPI) findTestID(rr *RawRequest) (uint, error) {
+	if !rr.named() {
+		return 0, nil
+	}
+
+	t, err := api.ts.FindByNames(rr.ProjectName, rr.TestName)
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	return api.createBatch(c, rr, p, t)
+	return t.ID, nil
 }
 
-func (api *RawAPI) createBatch(c echo.Context, rr *RawRequest, p *model.Project, t *model.Test) error {
+// newTest returns the project and test of new results. The project and test named in the request
+// are found or created, otherwise a new project and test are created.
+func (api *RawAPI) newTest(rr *RawRequest) (*model.Project, *model.Test, error) {
+	if rr.named() {
+		return api.findOrCreateTest(rr)
+	}
+
+	return api.createProjectAndTest(rr)
+}
+
+// findOrCreateTest finds the project and test named in the request, creating the missing ones.
+// They are created within the transaction of the results, so nothing is kept if the results fail.
+// A project or test in the trash is a conflict, as it has to be restored first.
+func (api *RawAPI) findOrCreateTest(rr *RawRequest) (*model.Project, *model.Test, error) {
+	p, t := rr.newProjectAndTest()
+
+	if err := api.ts.FindOrCreate(p, t); err != nil {
+		if te, ok := err.(*model.InTrashError); ok {
+			return nil, nil, echo.NewHTTPError(http.StatusConflict,
+				te.Error()+". Restore it with POST /api/trash/"+te.Kind+"s/"+strconv.FormatUint(uint64(te.ID), 10)+"/restore/")
+		}
+
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return p, t, nil
+}
+
+func (api *RawAPI) createBatch(rr *RawRequest, p *model.Project, t *model.Test) (*RawResponse, error) {
+	r := newRun(rr, t)
+
+	callLatencies := make([]float64, len(rr.Details))
+	for i, d := range rr.Details {
+		callLatencies[i] = d.Latency
+	}
+
+	comparison, err := api.evaluate(t, r, callLatencies)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	err = api.rs.Create(r)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if t, err = api.updateTestStatus(t, r); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	details := &DetailsCreated{}
+	details.add(api.ds.CreateBatch(r.ID, rr.Details))
+
+	if err = api.markPartial(r, details); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return &RawResponse{
+		Project:  p,
+		Test:     t,
+		Run:      r,
+		Baseline: comparison,
+		Details:  details,
+	}, nil
+}
+
+// markPartial marks the run as partial if some of its details failed to be created
+func (api *RawAPI) markPartial(r *model.Run, details *DetailsCreated) error {
+	if details.Fail == uint(0) || r.Partial {
+		return nil
+	}
+
+	r.Partial = true
+
+	return api.rs.Update(r)
+}
+
+// newRun creates the run for the test from the raw request without the details
+func newRun(rr *RawRequest, t *model.Test) *model.Run {
 	r := new(model.Run)
 	r.TestID = t.ID
 	r.Date = rr.Date
@@ -273,34 +651,28 @@ func (api *RawAPI) createBatch(c echo.Context, rr *RawRequest, p *model.Project,
 		}
 	}
 
-	median, nine5 := r.GetThresholdValues()
-	hasErrors := r.HasErrors()
+	return r
+}
 
-	t.SetStatus(rr.Average, median, nine5, rr.Fastest, rr.Slowest,
-		rr.Rps, hasErrors)
+// evaluate sets the significance and the status of the run and the test using the
+// call latencies of the run, and returns the comparison against the test's baseline
+func (api *RawAPI) evaluate(t *model.Test, r *model.Run, callLatencies []float64) (*model.BaselineComparison, error) {
+	return api.rs.Evaluate(t, r, callLatencies)
+}
 
-	err := api.rs.Create(r)
+// updateTestStatus stores the test status if the run is the test's latest run.
+// Otherwise the stored test is returned, as the status belongs to the latest run.
+func (api *RawAPI) updateTestStatus(t *model.Test, r *model.Run) (*model.Test, error) {
+	latest, err := api.rs.FindLatest(t.ID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return nil, err
 	}
 
-	created, errored := api.ds.CreateBatch(r.ID, rr.Details)
-
-	rres := &RawResponse{
-		Project: p,
-		Test:    t,
-		Run:     r,
-		Details: &DetailsCreated{
-			Success: created,
-			Fail:    errored,
-		},
-	}
-
-	if errored != uint(0) {
-		return echo.NewHTTPError(http.StatusInternalServerError, rres)
+	if latest != nil && latest.ID == r.ID {
+		return t, api.ts.UpdateStatus(t, r)
 	}
 
-	return c.JSON(http.StatusCreated, rres)
+	return api.ts.FindByID(t.ID)
 }
 
 func (api *RawAPI) populateProject(next echo.HandlerFunc) echo.HandlerFunc {
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/bojand/ghz-web/parser"
+	"github.com/labstack/echo"
+)
+
+// RawImportResponse is the response to the import endpoints
+type RawImportResponse struct {
+	// The runs created from the imported output
+	Runs []*RawResponse `json:"runs"`
+}
+
+// importParser parses imported ghz output
+type importParser func(reader io.Reader) ([]*parser.Result, error)
+
+func parseCSV(reader io.Reader) ([]*parser.Result, error) {
+	res, err := parser.ParseCSV(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*parser.Result{res}, nil
+}
+
+// Import InfluxDB line protocol api
+// @Summary Imports ghz output in InfluxDB line protocol for given project and test
+// @Description Imports ghz output in InfluxDB line protocol for given project and test.
+// @Description Creates a run for each ghz_run summary, with the details and ghz_histogram buckets that belong to it.
+// @Description The runs are created all or nothing unless bestEffort is set.
+// @Description Runs already imported to the test are not created again.
+// @ID post-import-influx
+// @Accept  plain
+// @Produce json
+// @Param bestEffort query bool false "Keep the results created before a failure"
+// @Param async query bool false "Process the results in the background and return the job"
+// @Success 201 {object} api.RawImportResponse
+// @Success 202 {object} api.JobResponse
+// @Failure 400 {object} echo.HTTPError
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/raw/influx/ [post]
+func (api *RawAPI) importInflux(c echo.Context) error {
+	return api.importRaw(c, formatInflux, parser.ParseInflux)
+}
+
+// Import CSV api
+// @Summary Imports ghz output in CSV format for given project and test
+// @Description Imports ghz output in CSV format for given project and test.
+// @Description Creates a run with the details of the calls, with the summary computed from the calls.
+// @Description The run is created all or nothing unless bestEffort is set.
+// @Description A run already imported to the test is not created again.
+// @ID post-import-csv
+// @Accept  plain
+// @Produce json
+// @Param bestEffort query bool false "Keep the results created before a failure"
+// @Param async query bool false "Process the results in the background and return the job"
+// @Success 201 {object} api.RawImportResponse
+// @Success 202 {object} api.JobResponse
+// @Failure 400 {object} echo.HTTPError
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/raw/csv/ [post]
+func (api *RawAPI) importCSV(c echo.Context) error {
+	return api.importRaw(c, formatCSV, parseCSV)
+}
+
+// Import new InfluxDB line protocol api
+// @Summary Imports new ghz output in InfluxDB line protocol
+// @Description Imports new ghz output in InfluxDB line protocol. Finds the project and test by name,
+// @Description creating the ones that do not exist, or creates a new project and test if no names are set.
+// @Description Creates a run for each ghz_run summary, with the details and ghz_histogram buckets that belong to it.
+// @Description The runs are created all or nothing unless bestEffort is set.
+// @ID post-import-influx-new
+// @Accept  plain
+// @Produce json
+// @Param projectName query string false "Name of the project"
+// @Param testName query string false "Name of the test"
+// @Param bestEffort query bool false "Keep the results created before a failure"
+// @Param async query bool false "Process the results in the background and return the job"
+// @Success 201 {object} api.RawImportResponse
+// @Success 202 {object} api.JobResponse
+// @Failure 400 {object} echo.HTTPError
+// @Failure 409 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /raw/influx/ [post]
+func (api *RawAPI) importNewInflux(c echo.Context) error {
+	return api.importNew(c, formatInflux, parser.ParseInflux)
+}
+
+// Import new CSV api
+// @Summary Imports new ghz output in CSV format
+// @Description Imports new ghz output in CSV format. Finds the project and test by name,
+// @Description creating the ones that do not exist, or creates a new project and test if no names are set.
+// @Description Creates a run with the details of the calls, with the summary computed from the calls.
+// @Description The run is created all or nothing unless bestEffort is set.
+// @ID post-import-csv-new
+// @Accept  plain
+// @Produce json
+// @Param projectName query string false "Name of the project"
+// @Param testName query string false "Name of the test"
+// @Param bestEffort query bool false "Keep the results created before a failure"
+// @Param async query bool false "Process the results in the background and return the job"
+// @Success 201 {object} api.RawImportResponse
+// @Success 202 {object} api.JobResponse
+// @Failure 400 {object} echo.HTTPError
+// @Failure 409 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /raw/csv/ [post]
+func (api *RawAPI) importNewCSV(c echo.Context) error {
+	return api.importNew(c, formatCSV, parseCSV)
+}
+
+func (api *RawAPI) importRaw(c echo.Context, format string, parse importParser) error {
+	po := c.Get("project")
+	p, ok := po.(*model.Project)
+
+	if p == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No project in context")
+	}
+
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No test in context")
+	}
+
+	if getAsyncParam(c) {
+		return api.createJob(c, format, p, t)
+	}
+
+	requests, err := readImport(c, parse)
+	if err != nil {
+		return err
+	}
+
+	return api.importResults(c, requests, nil, p, t)
+}
+
+func (api *RawAPI) importNew(c echo.Context, format string, parse importParser) error {
+	if getAsyncParam(c) {
+		return api.createJob(c, format, nil, nil)
+	}
+
+	requests, err := readImport(c, parse)
+	if err != nil {
+		return err
+	}
+
+	named := &RawRequest{ProjectName: c.QueryParam("projectName"), TestName: c.QueryParam("testName")}
+
+	return api.importResults(c, requests, named, nil, nil)
+}
+
+// readImport parses the request body into a raw request for each run
+func readImport(c echo.Context, parse importParser) ([]*RawRequest, error) {
+	results, err := parse(c.Request().Body)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if len(results) == 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "No runs to import")
+	}
+
+	requests := make([]*RawRequest, len(results))
+	for i, res := range results {
+		requests[i] = newImportRequest(res)
+	}
+
+	return requests, nil
+}
+
+// importResults creates the imported runs in the test. If there is none the test named by the
+// request is found or created, or a new project and test are created if the request names none.
+// The runs are created within a single transaction unless the bestEffort query parameter is set,
+// in which case the runs created before a failure are kept.
+func (api *RawAPI) importResults(c echo.Context, requests []*RawRequest, named *RawRequest,
+	p *model.Project, t *model.Test) error {
+
+	bestEffort := getBestEffortParam(c)
+	ires := new(RawImportResponse)
+
+	err := api.transact(bestEffort, func(api *RawAPI) error {
+		var err error
+		if t == nil {
+			if p, t, err = api.newTest(named); err != nil {
+				return err
+			}
+		}
+
+		for _, rr := range requests {
+			rres, err := api.importRun(rr, t, func() (*RawResponse, error) {
+				return api.createBatch(rr, p, t)
+			})
+			if err != nil {
+				return err
+			}
+
+			ires.Runs = append(ires.Runs, rres)
+
+			if err = detailsFailed(rres.Details, ires, bestEffort); err != nil {
+				return err
+			}
+
+			t = rres.Test
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, ires)
+}
+
+// importRun creates the run of the raw request in the test using create.
+// A run already imported to the test returns the original response instead.
+func (api *RawAPI) importRun(rr *RawRequest, t *model.Test,
+	create func() (*RawResponse, error)) (*RawResponse, error) {
+
+	sub, err := api.newSubmission(rr, t.ID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if sub != nil {
+		original, err := api.findSubmission(sub)
+		if err != nil {
+			return nil, err
+		}
+
+		if original != nil {
+			rres := new(RawResponse)
+			if err := json.Unmarshal([]byte(original.Response), rres); err != nil {
+				return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+
+			return rres, nil
+		}
+	}
+
+	rres, err := create()
+	if err != nil || rres.Details.Fail != uint(0) {
+		return rres, err
+	}
+
+	return rres, api.recordSubmission(sub, rres)
+}
+
+// newImportRequest creates the raw request of the parsed run.
+// Runs without a date are dated at the time of the import.
+func newImportRequest(res *parser.Result) *RawRequest {
+	r := res.Run
+
+	rr := &RawRequest{Options: r.Options, Details: res.Details}
+	setSummary(rr, r)
+
+	if rr.Date.IsZero() {
+		rr.Date = time.Now()
+	}
+
+	return rr
+}
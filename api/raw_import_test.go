@@ -0,0 +1,287 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/bojand/ghz-web/model"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/stretchr/testify/assert"
+	baloo "gopkg.in/h2non/baloo.v3"
+)
+
+func TestRawImportAPI(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	conf, cerr := config.Read("../test/config1.toml")
+	if cerr != nil {
+		assert.FailNow(t, cerr.Error())
+	}
+
+	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{}, &model.Submission{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ts := &model.TestService{DB: db}
+	ps := &model.ProjectService{DB: db}
+	rs := &model.RunService{DB: db}
+	ds := &model.DetailService{DB: db, Config: &conf.Database}
+	ss := &model.SubmissionService{DB: db}
+	txs := &model.TxService{DB: db, Config: &conf.Database}
+
+	var testID uint
+	var pid, tid string
+	var runIDs []uint
+
+	var httpTest *baloo.Client
+	var echoServer *echo.Echo
+
+	echoServer = echo.New()
+	echoServer.Use(middleware.AddTrailingSlash())
+	echoServer.Use(middleware.Logger())
+
+	defer echoServer.Close()
+
+	var lines, histogram, points, csv string
+
+	t.Run("Start API", func(t *testing.T) {
+		apiGroup := echoServer.Group("/api")
+		SetupRawAPI(apiGroup, ps, ts, rs, ds, ss, txs, &conf.Idempotency, nil)
+
+		go func() {
+			echoServer.Start("localhost:0")
+		}()
+	})
+
+	t.Run("Sync to get the port", func(t *testing.T) {
+		done := make(chan bool, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			done <- true
+			close(done)
+		}()
+
+		<-done
+	})
+
+	t.Run("Create http test", func(t *testing.T) {
+		httpTest = baloo.New(echoServer.Listener.Addr().String())
+	})
+
+	t.Run("Read data files", func(t *testing.T) {
+		for file, data := range map[string]*string{
+			"../test/lines.txt":     &lines,
+			"../test/histogram.txt": &histogram,
+			"../test/points.txt":    &points,
+			"../test/run0.csv":      &csv,
+		} {
+			content, err := ioutil.ReadFile(file)
+			assert.NoError(t, err)
+
+			*data = string(content)
+		}
+	})
+
+	t.Run("POST import influx by names", func(t *testing.T) {
+		httpTest.Post("/api/raw/influx/").
+			SetQueryParams(map[string]string{"projectName": "Imports", "testName": "Influx"}).
+			AddHeader("Content-Type", "text/plain").
+			BodyString(lines + histogram).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				ir := new(RawImportResponse)
+				err := json.NewDecoder(res.Body).Decode(ir)
+
+				assert.NoError(t, err)
+				assert.Len(t, ir.Runs, 10)
+
+				for _, rr := range ir.Runs {
+					assert.Equal(t, "imports", rr.Project.Name)
+					assert.Equal(t, "influx", rr.Test.Name)
+					assert.NotZero(t, rr.Run.ID)
+					assert.Equal(t, uint64(1000), rr.Run.Count)
+					assert.Len(t, rr.Run.Histogram, 11)
+					assert.Len(t, rr.Run.LatencyDistribution, 2)
+					assert.Equal(t, uint(0), rr.Details.Success)
+
+					runIDs = append(runIDs, rr.Run.ID)
+				}
+
+				testID = ir.Runs[0].Test.ID
+				tid = strconv.FormatUint(uint64(testID), 10)
+				pid = strconv.FormatUint(uint64(ir.Runs[0].Project.ID), 10)
+
+				return nil
+			}).
+			Done()
+
+		count, err := rs.Count(testID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(10), count)
+
+		r, err := rs.FindByID(runIDs[0])
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(48657369), r.Total)
+		assert.Len(t, r.Histogram, 11)
+	})
+
+	t.Run("POST import influx again returns the original runs", func(t *testing.T) {
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/influx/").
+			AddHeader("Content-Type", "text/plain").
+			BodyString(lines + histogram).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				ir := new(RawImportResponse)
+				err := json.NewDecoder(res.Body).Decode(ir)
+
+				assert.NoError(t, err)
+				assert.Len(t, ir.Runs, 10)
+
+				for i, rr := range ir.Runs {
+					assert.Equal(t, runIDs[i], rr.Run.ID)
+				}
+
+				return nil
+			}).
+			Done()
+
+		count, err := rs.Count(testID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(10), count)
+	})
+
+	t.Run("POST import influx points", func(t *testing.T) {
+		var rid uint
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/influx/").
+			AddHeader("Content-Type", "text/plain").
+			BodyString(points).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				ir := new(RawImportResponse)
+				err := json.NewDecoder(res.Body).Decode(ir)
+
+				assert.NoError(t, err)
+				assert.Len(t, ir.Runs, 1)
+
+				rr := ir.Runs[0]
+				assert.Equal(t, testID, rr.Test.ID)
+				assert.Equal(t, uint64(846), rr.Run.Count)
+				assert.Equal(t, uint(846), rr.Details.Success)
+				assert.Equal(t, uint(0), rr.Details.Fail)
+
+				rid = rr.Run.ID
+
+				return nil
+			}).
+			Done()
+
+		count, err := ds.Count(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(846), count)
+	})
+
+	t.Run("POST import new csv", func(t *testing.T) {
+		var rid uint
+
+		httpTest.Post("/api/raw/csv/").
+			AddHeader("Content-Type", "text/csv").
+			BodyString(csv).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				ir := new(RawImportResponse)
+				err := json.NewDecoder(res.Body).Decode(ir)
+
+				assert.NoError(t, err)
+				assert.Len(t, ir.Runs, 1)
+
+				rr := ir.Runs[0]
+				assert.NotZero(t, rr.Project.ID)
+				assert.NotEqual(t, testID, rr.Test.ID)
+				assert.Equal(t, uint64(937), rr.Run.Count)
+				assert.Equal(t, time.Duration(276048), rr.Run.Fastest)
+				assert.Equal(t, time.Duration(8043194), rr.Run.Slowest)
+				assert.Equal(t, uint(937), rr.Details.Success)
+
+				rid = rr.Run.ID
+
+				return nil
+			}).
+			Done()
+
+		count, err := ds.Count(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(937), count)
+	})
+
+	t.Run("POST import invalid input saves nothing", func(t *testing.T) {
+		projects, err := ps.Count()
+		assert.NoError(t, err)
+
+		httpTest.Post("/api/raw/influx/").
+			AddHeader("Content-Type", "text/plain").
+			BodyString(lines + "ghz_run count=a").
+			Expect(t).
+			Status(400).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				var body map[string]string
+				err := json.NewDecoder(res.Body).Decode(&body)
+
+				assert.NoError(t, err)
+				assert.Contains(t, body["message"], "line 11")
+
+				return nil
+			}).
+			Done()
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/csv/").
+			AddHeader("Content-Type", "text/csv").
+			BodyString("status,error\nOK,\n").
+			Expect(t).
+			Status(400).
+			Done()
+
+		httpTest.Post("/api/raw/csv/").
+			AddHeader("Content-Type", "text/csv").
+			BodyString("duration (ms),status,error\n").
+			Expect(t).
+			Status(400).
+			Done()
+
+		count, err := ps.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, projects, count)
+	})
+
+	t.Run("POST import to unknown test", func(t *testing.T) {
+		httpTest.Post("/api/projects/"+pid+"/tests/12345/raw/csv/").
+			AddHeader("Content-Type", "text/csv").
+			BodyString(csv).
+			Expect(t).
+			Status(404).
+			Done()
+	})
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+)
+
+// Open run api
+// @Summary Opens a running run for given project and test
+// @Description Creates a run in the running state from the date and options of the request,
+// @Description along with the details in the request if any. More details are appended to the run
+// @Description while the load test is executing, and the run is evaluated once it is finished.
+// @Description Runs that receive no details for the configured timeout are aborted.
+// @ID post-open-run
+// @Accept  json
+// @Produce json
+// @Param RawRequest body api.RawRequest true "Raw request"
+// @Param pid path int true "Project ID"
+// @Param tid path int true "Test ID"
+// @Success 201 {object} api.RawResponse
+// @Failure 400 {object} echo.HTTPError
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/raw/runs [post]
+func (api *RawAPI) openRun(c echo.Context) error {
+	po := c.Get("project")
+	p, ok := po.(*model.Project)
+
+	if p == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No project in context")
+	}
+
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No test in context")
+	}
+
+	rr := new(RawRequest)
+	if err := c.Bind(rr); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	r := &model.Run{TestID: t.ID, Date: rr.Date, Options: rr.Options, State: model.RunRunning}
+	if r.Date.IsZero() {
+		r.Date = time.Now()
+	}
+
+	bestEffort := getBestEffortParam(c)
+
+	var rres *RawResponse
+	err := api.transact(bestEffort, func(api *RawAPI) error {
+		if err := api.rs.Create(r); err != nil {
+			return err
+		}
+
+		details, err := api.appendDetails(r, rr.Details)
+		if err != nil {
+			return err
+		}
+
+		rres = &RawResponse{Project: p, Test: t, Run: r, Details: details}
+
+		return detailsFailed(details, rres, bestEffort)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, rres)
+}
+
+// Append run details api
+// @Summary Appends details to a running run
+// @Description Appends a chunk of details to a running run. The count of the run is the number of details received so far.
+// @Description The details are created all or nothing unless bestEffort is set.
+// @ID post-append-run-details
+// @Accept  json
+// @Produce json
+// @Param details body model.Detail true "Array of details"
+// @Param pid path int true "Project ID"
+// @Param tid path int true "Test ID"
+// @Param rid path int true "Run ID"
+// @Param bestEffort query bool false "Keep the details created before a failure"
+// @Success 200 {object} api.RawResponse
+// @Failure 400 {object} echo.HTTPError
+// @Failure 404 {object} echo.HTTPError
+// @Failure 409 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/raw/runs/{rid}/details [post]
+func (api *RawAPI) appendRunDetails(c echo.Context) error {
+	p, t, r, err := api.getRunningRun(c)
+	if err != nil {
+		return err
+	}
+
+	var details []*model.Detail
+	if err := c.Bind(&details); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	bestEffort := getBestEffortParam(c)
+
+	var rres *RawResponse
+	err = api.transact(bestEffort, func(api *RawAPI) error {
+		created, err := api.appendDetails(r, details)
+		if err != nil {
+			return err
+		}
+
+		rres = &RawResponse{Project: p, Test: t, Run: r, Details: created}
+
+		return detailsFailed(created, rres, bestEffort)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rres)
+}
+
+// Finish run api
+// @Summary Finishes a running run
+// @Description Computes the summary of the running run from its details, evaluates it against
+// @Description the thresholds and baseline of the test and completes it.
+// @ID post-finish-run
+// @Produce json
+// @Param pid path int true "Project ID"
+// @Param tid path int true "Test ID"
+// @Param rid path int true "Run ID"
+// @Success 200 {object} api.RawResponse
+// @Failure 404 {object} echo.HTTPError
+// @Failure 409 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/raw/runs/{rid}/finish [post]
+func (api *RawAPI) finishRun(c echo.Context) error {
+	p, t, r, err := api.getRunningRun(c)
+	if err != nil {
+		return err
+	}
+
+	var rres *RawResponse
+	err = api.transact(false, func(api *RawAPI) error {
+		latencies, err := api.ds.Summarize(r)
+		if err != nil {
+			return err
+		}
+
+		comparison, err := api.evaluate(t, r, latencies)
+		if err != nil {
+			return err
+		}
+
+		r.State = model.RunCompleted
+
+		if err = api.rs.Update(r); err != nil {
+			return err
+		}
+
+		if t, err = api.updateTestStatus(t, r); err != nil {
+			return err
+		}
+
+		rres = &RawResponse{Project: p, Test: t, Run: r, Baseline: comparison,
+			Details: &DetailsCreated{Success: uint(len(latencies))}}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rres)
+}
+
+// appendDetails creates the details of the running run and adds them to its progress
+func (api *RawAPI) appendDetails(r *model.Run, details []*model.Detail) (*DetailsCreated, error) {
+	created := &DetailsCreated{}
+	if len(details) == 0 {
+		return created, nil
+	}
+
+	created.add(api.ds.CreateBatch(r.ID, details))
+
+	err := api.rs.AddProgress(r, created.Success)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, echo.NewHTTPError(http.StatusConflict, "Run is no longer running")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// getRunningRun returns the project and test in context along with the running run of the test
+func (api *RawAPI) getRunningRun(c echo.Context) (*model.Project, *model.Test, *model.Run, error) {
+	po := c.Get("project")
+	p, ok := po.(*model.Project)
+
+	if p == nil || !ok {
+		return nil, nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "No project in context")
+	}
+
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if !ok {
+		return nil, nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "No test in context")
+	}
+
+	r, err := getRun(api.rs, c)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if r.TestID != t.ID {
+		return nil, nil, nil, echo.NewHTTPError(http.StatusNotFound, "Not Found")
+	}
+
+	if r.State != model.RunRunning {
+		return nil, nil, nil, echo.NewHTTPError(http.StatusConflict, "Run is "+string(r.State))
+	}
+
+	return p, t, r, nil
+}
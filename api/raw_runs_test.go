@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/bojand/ghz-web/model"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/stretchr/testify/assert"
+	baloo "gopkg.in/h2non/baloo.v3"
+)
+
+func TestRawRunsAPI(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	conf, cerr := config.Read("../test/config1.toml")
+	if cerr != nil {
+		assert.FailNow(t, cerr.Error())
+	}
+
+	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{}, &model.Submission{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ts := &model.TestService{DB: db}
+	ps := &model.ProjectService{DB: db}
+	rs := &model.RunService{DB: db}
+	ds := &model.DetailService{DB: db, Config: &conf.Database}
+	ss := &model.SubmissionService{DB: db}
+	txs := &model.TxService{DB: db, Config: &conf.Database}
+
+	tst := &model.Test{Project: &model.Project{}, Name: "Live", FailOnThreshold: true,
+		Thresholds: map[model.Threshold]*model.ThresholdSetting{
+			model.ThresholdMean: &model.ThresholdSetting{Threshold: time.Millisecond},
+		}}
+
+	var basePath, rid string
+	var details []json.RawMessage
+
+	var httpTest *baloo.Client
+	var echoServer *echo.Echo
+
+	echoServer = echo.New()
+	echoServer.Use(middleware.AddTrailingSlash())
+	echoServer.Use(middleware.Logger())
+
+	defer echoServer.Close()
+
+	t.Run("Start API", func(t *testing.T) {
+		apiGroup := echoServer.Group("/api")
+		SetupRawAPI(apiGroup, ps, ts, rs, ds, ss, txs, &conf.Idempotency, nil)
+
+		go func() {
+			echoServer.Start("localhost:0")
+		}()
+	})
+
+	t.Run("Sync to get the port", func(t *testing.T) {
+		done := make(chan bool, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			done <- true
+			close(done)
+		}()
+
+		<-done
+	})
+
+	t.Run("Create http test", func(t *testing.T) {
+		httpTest = baloo.New(echoServer.Listener.Addr().String())
+	})
+
+	t.Run("Create test and read data file", func(t *testing.T) {
+		err := db.Create(tst).Error
+		assert.NoError(t, err)
+
+		basePath = "/api/projects/" + strconv.FormatUint(uint64(tst.ProjectID), 10) +
+			"/tests/" + strconv.FormatUint(uint64(tst.ID), 10) + "/raw/runs/"
+
+		content, err := ioutil.ReadFile("../test/run0.json")
+		assert.NoError(t, err)
+
+		var data struct {
+			Details []json.RawMessage `json:"details"`
+		}
+
+		err = json.Unmarshal(content, &data)
+		assert.NoError(t, err)
+
+		details = data.Details
+		assert.Len(t, details, 937)
+	})
+
+	t.Run("POST open run", func(t *testing.T) {
+		httpTest.Post(basePath).
+			JSON(map[string]interface{}{
+				"date":    "2018-09-02T23:06:03.153Z",
+				"options": map[string]interface{}{"call": "helloworld.Greeter.SayHello", "n": 1000},
+				"details": details[:300],
+			}).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err := json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+				assert.NotZero(t, rr.Run.ID)
+				assert.Equal(t, model.RunRunning, rr.Run.State)
+				assert.Equal(t, uint64(300), rr.Run.Count)
+				assert.Equal(t, uint(300), rr.Details.Success)
+
+				rid = strconv.FormatUint(uint64(rr.Run.ID), 10)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("POST append run details", func(t *testing.T) {
+		for i, chunk := range [][]json.RawMessage{details[300:600], details[600:]} {
+			httpTest.Post(basePath + rid + "/details/").
+				JSON(chunk).
+				Expect(t).
+				Status(200).
+				Type("json").
+				AssertFunc(func(res *http.Response, req *http.Request) error {
+					rr := new(RawResponse)
+					err := json.NewDecoder(res.Body).Decode(rr)
+
+					assert.NoError(t, err)
+					assert.Equal(t, model.RunRunning, rr.Run.State)
+					assert.Equal(t, uint(len(chunk)), rr.Details.Success)
+
+					if i == 0 {
+						assert.Equal(t, uint64(600), rr.Run.Count)
+					}
+
+					return nil
+				}).
+				Done()
+		}
+
+		id, err := strconv.Atoi(rid)
+		assert.NoError(t, err)
+
+		r, err := rs.FindByID(uint(id))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(937), r.Count)
+		assert.Equal(t, model.RunRunning, r.State)
+
+		// running runs do not count as the latest run of the test
+		latest, err := rs.FindLatest(tst.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, latest)
+	})
+
+	t.Run("POST finish run", func(t *testing.T) {
+		httpTest.Post(basePath + rid + "/finish/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err := json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, model.RunCompleted, rr.Run.State)
+				assert.Equal(t, uint64(937), rr.Run.Count)
+				assert.Equal(t, time.Duration(276048), rr.Run.Fastest)
+				assert.Equal(t, time.Duration(8043194), rr.Run.Slowest)
+				assert.Len(t, rr.Run.LatencyDistribution, 7)
+				assert.Len(t, rr.Run.Histogram, 11)
+				assert.Equal(t, model.StatusFail, rr.Run.Status)
+				assert.Len(t, rr.Run.ThresholdResults, 1)
+				assert.Equal(t, model.StatusFail, rr.Test.Status)
+				assert.Equal(t, uint(937), rr.Details.Success)
+
+				return nil
+			}).
+			Done()
+
+		latest, err := rs.FindLatest(tst.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, rid, strconv.FormatUint(uint64(latest.ID), 10))
+		assert.Len(t, latest.Histogram, 11)
+	})
+
+	t.Run("POST finished run 409", func(t *testing.T) {
+		httpTest.Post(basePath + rid + "/finish/").
+			Expect(t).
+			Status(409).
+			Type("json").
+			Done()
+
+		httpTest.Post(basePath + rid + "/details/").
+			JSON(details[:1]).
+			Expect(t).
+			Status(409).
+			Type("json").
+			Done()
+	})
+
+	t.Run("POST append to aborted run 409", func(t *testing.T) {
+		r := &model.Run{TestID: tst.ID, Date: time.Now(), State: model.RunRunning}
+		err := rs.Create(r)
+		assert.NoError(t, err)
+
+		n, err := rs.AbortStale(time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), n)
+
+		httpTest.Post(basePath + strconv.FormatUint(uint64(r.ID), 10) + "/details/").
+			JSON(details[:1]).
+			Expect(t).
+			Status(409).
+			Type("json").
+			Done()
+	})
+
+	t.Run("POST run of another test 404", func(t *testing.T) {
+		other := &model.Test{ProjectID: tst.ProjectID, Name: "Other"}
+		err := db.Create(other).Error
+		assert.NoError(t, err)
+
+		r := &model.Run{TestID: other.ID, Date: time.Now(), State: model.RunRunning}
+		err = rs.Create(r)
+		assert.NoError(t, err)
+
+		httpTest.Post(basePath + strconv.FormatUint(uint64(r.ID), 10) + "/finish/").
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/labstack/echo"
+)
+
+// streamChunkSize is the number of details inserted at a time when streaming
+const streamChunkSize = 1000
+
+// Create raw result stream api
+// @Summary Created raw results for given project and test from a stream
+// @Description Created raw results from newline delimited JSON. The first line is the raw request
+// @Description holding the run summary, and each following line is a detail of a call.
+// @Description The results are created all or nothing unless bestEffort is set,
+// @Description in which case the results created before a failure are kept and the run is marked as partial.
+// @ID post-create-raw-stream
+// @Accept  application/x-ndjson
+// @Produce json
+// @Param bestEffort query bool false "Keep the results created before a failure"
+// @Param async query bool false "Process the results in the background and return the job"
+// @Param recompute query bool false "Compute the run summary from the details even if it is set"
+// @Param Idempotency-Key header string false "Key identifying the submission. Submitting the same key again returns the original response."
+// @Success 200 {object} api.RawResponse
+// @Success 202 {object} api.JobResponse
+// @Failure 400 {object} echo.HTTPError
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/raw/stream/ [post]
+func (api *RawAPI) createRawStream(c echo.Context) error {
+	po := c.Get("project")
+	p, ok := po.(*model.Project)
+
+	if p == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No project in context")
+	}
+
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No test in context")
+	}
+
+	if getAsyncParam(c) {
+		return api.createJob(c, formatNDJSON, p, t)
+	}
+
+	reader := bufio.NewReader(c.Request().Body)
+
+	rr, err := readStreamSummary(reader)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return api.createResults(c, rr, t.ID, func(api *RawAPI) (*RawResponse, error) {
+		return api.createStream(reader, rr, p, t, getRecomputeParam(c))
+	})
+}
+
+// Create new raw result stream api
+// @Summary Created new raw results from a stream
+// @Description Created new raw results from newline delimited JSON. Finds the project and test by the names
+// @Description in the run summary, creating the ones that do not exist, or creates a new project and test if no names are set.
+// @Description The results are created all or nothing unless bestEffort is set,
+// @Description in which case the results created before a failure are kept and the run is marked as partial.
+// @ID post-create-raw-stream-new
+// @Accept  application/x-ndjson
+// @Produce json
+// @Param bestEffort query bool false "Keep the results created before a failure"
+// @Param async query bool false "Process the results in the background and return the job"
+// @Param recompute query bool false "Compute the run summary from the details even if it is set"
+// @Param Idempotency-Key header string false "Key identifying the submission. Submitting the same key again returns the original response."
+// @Success 200 {object} api.RawResponse
+// @Success 202 {object} api.JobResponse
+// @Failure 400 {object} echo.HTTPError
+// @Failure 409 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /raw/stream/ [post]
+func (api *RawAPI) createNewStream(c echo.Context) error {
+	if getAsyncParam(c) {
+		return api.createJob(c, formatNDJSON, nil, nil)
+	}
+
+	reader := bufio.NewReader(c.Request().Body)
+
+	rr, err := readStreamSummary(reader)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	tid, err := api.findTestID(rr)
+	if err != nil {
+		return err
+	}
+
+	return api.createResults(c, rr, tid, func(api *RawAPI) (*RawResponse, error) {
+		p, t, err := api.newTest(rr)
+		if err != nil {
+			return nil, err
+		}
+
+		return api.createStream(reader, rr, p, t, getRecomputeParam(c))
+	})
+}
+
+// createStream creates the run and then inserts the streamed details in chunks.
+// The run is evaluated once all details are read, using a bounded sample of their latencies.
+// The summary of the run is computed from the details if recompute is set or the run has none,
+// with the latency distribution and histogram computed from a bounded sample.
+func (api *RawAPI) createStream(reader *bufio.Reader, rr *RawRequest,
+	p *model.Project, t *model.Test, recompute bool) (*RawResponse, error) {
+
+	recompute = recompute || !rr.hasSummary()
+
+	r := newRun(rr, t)
+
+	err := api.rs.Create(r)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	sample := model.NewLatencySample(model.DefaultSampleSize)
+	summarizer := model.NewSummarizer(model.DefaultSampleSize)
+	res := &DetailsCreated{}
+
+	chunk := make([]*model.Detail, 0, streamChunkSize)
+
+	flush := func() {
+		res.add(api.ds.CreateBatch(r.ID, chunk))
+		chunk = chunk[:0]
+	}
+
+	// details sent along with the summary are created first
+	for _, d := range rr.Details {
+		sample.Add(d.Latency)
+		summarizer.Add(d)
+		chunk = append(chunk, d)
+
+		if len(chunk) == streamChunkSize {
+			flush()
+		}
+	}
+
+	rr.Details = nil
+
+	for {
+		line, err := readStreamLine(reader)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		d := new(model.Detail)
+		if err := json.Unmarshal(line, d); err != nil {
+			res.Fail++
+			continue
+		}
+
+		sample.Add(d.Latency)
+		summarizer.Add(d)
+		chunk = append(chunk, d)
+
+		if len(chunk) == streamChunkSize {
+			flush()
+		}
+	}
+
+	if len(chunk) > 0 {
+		flush()
+	}
+
+	if recompute && summarizer.Count() > 0 {
+		summarizer.Summarize(r)
+
+		if r.Date.IsZero() {
+			r.Date = time.Now()
+		}
+	}
+
+	comparison, err := api.evaluate(t, r, sample.Latencies())
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	r.Partial = res.Fail != uint(0)
+
+	if err = api.rs.Update(r); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if t, err = api.updateTestStatus(t, r); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return &RawResponse{
+		Project:  p,
+		Test:     t,
+		Run:      r,
+		Baseline: comparison,
+		Details:  res,
+	}, nil
+}
+
+// readStreamSummary reads the raw request from the first line of the stream
+func readStreamSummary(reader *bufio.Reader) (*RawRequest, error) {
+	line, err := readStreamLine(reader)
+	if err == io.EOF {
+		return nil, errors.New("Missing run summary")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rr := new(RawRequest)
+	if err := json.Unmarshal(line, rr); err != nil {
+		return nil, err
+	}
+
+	return rr, nil
+}
+
+// readStreamLine reads the next non empty line of the stream
+func readStreamLine(reader *bufio.Reader) ([]byte, error) {
+	for {
+		line, err := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+
+		if len(line) > 0 {
+			return line, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/bojand/ghz-web/model"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/stretchr/testify/assert"
+	baloo "gopkg.in/h2non/baloo.v3"
+)
+
+// toNDJSON converts the raw JSON data to a stream of the summary followed by the details
+func toNDJSON(t *testing.T, data []byte, extraLines ...string) string {
+	var raw map[string]interface{}
+	err := json.Unmarshal(data, &raw)
+	assert.NoError(t, err)
+
+	details, _ := raw["details"].([]interface{})
+	delete(raw, "details")
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+
+	err = enc.Encode(raw)
+	assert.NoError(t, err)
+
+	for _, d := range details {
+		err = enc.Encode(d)
+		assert.NoError(t, err)
+	}
+
+	for _, l := range extraLines {
+		buf.WriteString(l + "\n")
+	}
+
+	return buf.String()
+}
+
+func TestRawStreamAPI(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	conf, cerr := config.Read("../test/config1.toml")
+	if cerr != nil {
+		assert.FailNow(t, cerr.Error())
+	}
+
+	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{}, &model.Submission{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ts := &model.TestService{DB: db}
+	ps := &model.ProjectService{DB: db}
+	rs := &model.RunService{DB: db}
+	ds := &model.DetailService{DB: db, Config: &conf.Database}
+	ss := &model.SubmissionService{DB: db}
+	txs := &model.TxService{DB: db, Config: &conf.Database}
+
+	var testID, runID uint
+	var pid, tid string
+
+	var httpTest *baloo.Client
+	var echoServer *echo.Echo
+
+	echoServer = echo.New()
+	echoServer.Use(middleware.AddTrailingSlash())
+	echoServer.Use(middleware.Logger())
+
+	defer echoServer.Close()
+
+	var run0data, run1data, run2data []byte
+
+	t.Run("Start API", func(t *testing.T) {
+		apiGroup := echoServer.Group("/api")
+		SetupRawAPI(apiGroup, ps, ts, rs, ds, ss, txs, &conf.Idempotency, nil)
+
+		go func() {
+			echoServer.Start("localhost:0")
+		}()
+	})
+
+	t.Run("Sync to get the port", func(t *testing.T) {
+		done := make(chan bool, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			done <- true
+			close(done)
+		}()
+
+		<-done
+	})
+
+	t.Run("Create http test", func(t *testing.T) {
+		httpTest = baloo.New(echoServer.Listener.Addr().String())
+	})
+
+	t.Run("Read data files", func(t *testing.T) {
+		run0data, err = ioutil.ReadFile("../test/run0.json")
+		assert.NoError(t, err)
+
+		run1data, err = ioutil.ReadFile("../test/run1.json")
+		assert.NoError(t, err)
+
+		run2data, err = ioutil.ReadFile("../test/run2.json")
+		assert.NoError(t, err)
+	})
+
+	t.Run("POST create raw stream", func(t *testing.T) {
+		httpTest.Post("/api/raw/stream/").
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString(toNDJSON(t, run0data)).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err := json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+
+				assert.NotZero(t, rr.Project.ID)
+				assert.NotZero(t, rr.Test.ID)
+				assert.NotZero(t, rr.Run.ID)
+				assert.Equal(t, uint64(1000), rr.Run.Count)
+				assert.Len(t, rr.Run.LatencyDistribution, 7)
+				assert.Equal(t, uint(937), rr.Details.Success)
+				assert.Equal(t, uint(0), rr.Details.Fail)
+				assert.False(t, rr.Run.Partial)
+
+				testID = rr.Test.ID
+				tid = strconv.FormatUint(uint64(testID), 10)
+				pid = strconv.FormatUint(uint64(rr.Project.ID), 10)
+				runID = rr.Run.ID
+
+				return nil
+			}).
+			Done()
+
+		count, err := ds.Count(runID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(937), count)
+	})
+
+	t.Run("POST create raw stream with recompute", func(t *testing.T) {
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/stream/").
+			SetQueryParams(map[string]string{"recompute": "true"}).
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString(toNDJSON(t, run0data)).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err := json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+
+				assert.NotEqual(t, runID, rr.Run.ID)
+				assert.Equal(t, uint64(937), rr.Run.Count)
+				assert.Equal(t, time.Duration(276048), rr.Run.Fastest)
+				assert.Equal(t, time.Duration(8043194), rr.Run.Slowest)
+				assert.Len(t, rr.Run.LatencyDistribution, 7)
+				assert.Len(t, rr.Run.Histogram, 11)
+				assert.Equal(t, uint(937), rr.Details.Success)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("POST create raw stream with known ids and thresholds", func(t *testing.T) {
+		tm, err := ts.FindByID(testID)
+		assert.NoError(t, err)
+
+		tm.FailOnThreshold = true
+		tm.Thresholds = map[model.Threshold]*model.ThresholdSetting{
+			model.Threshold("p99.9"): &model.ThresholdSetting{Threshold: time.Nanosecond},
+		}
+
+		err = ts.Update(tm)
+		assert.NoError(t, err)
+
+		var rid uint
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/stream/").
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString(toNDJSON(t, run1data)).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err := json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+
+				assert.Equal(t, testID, rr.Test.ID)
+				assert.NotZero(t, rr.Details.Success)
+				assert.Equal(t, uint(0), rr.Details.Fail)
+
+				assert.Equal(t, model.StatusFail, rr.Run.Status)
+				assert.Len(t, rr.Run.ThresholdResults, 1)
+				assert.NotZero(t, rr.Run.ThresholdResults[0].Value)
+				assert.NotNil(t, rr.Run.Significance)
+				assert.Equal(t, runID, rr.Run.Significance.CompareRunID)
+
+				rid = rr.Run.ID
+
+				return nil
+			}).
+			Done()
+
+		r, err := rs.FindByID(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, model.StatusFail, r.Status)
+		assert.Len(t, r.ThresholdResults, 1)
+		assert.NotNil(t, r.Significance)
+	})
+
+	t.Run("POST create raw stream with more details than a chunk", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		buf.WriteString(`{"date":"2018-09-02T23:06:03.149Z","count":1100}` + "\n")
+
+		for i := 0; i < streamChunkSize+100; i++ {
+			buf.WriteString(`{"timestamp":"2018-09-02T23:06:03.149Z","latency":` + strconv.Itoa(1000+i) + `}` + "\n")
+		}
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/stream/").
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString(buf.String()).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err := json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(streamChunkSize+100), rr.Details.Success)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("POST create raw stream with invalid detail saves nothing", func(t *testing.T) {
+		runs, err := rs.Count(testID)
+		assert.NoError(t, err)
+
+		projects, err := ps.Count()
+		assert.NoError(t, err)
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/stream/").
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString(toNDJSON(t, run2data, `{"latency":`)).
+			Expect(t).
+			Status(500).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				var body map[string]string
+				err := json.NewDecoder(res.Body).Decode(&body)
+
+				assert.NoError(t, err)
+				assert.Contains(t, body["message"], "1 of ")
+				assert.Contains(t, body["message"], "No results were saved.")
+
+				return nil
+			}).
+			Done()
+
+		httpTest.Post("/api/raw/stream/").
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString(toNDJSON(t, run2data, `{"latency":`)).
+			Expect(t).
+			Status(500).
+			Type("json").
+			Done()
+
+		count, err := rs.Count(testID)
+		assert.NoError(t, err)
+		assert.Equal(t, runs, count)
+
+		count, err = ps.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, projects, count)
+	})
+
+	t.Run("POST create raw stream with names and invalid detail creates no project or test", func(t *testing.T) {
+		var data map[string]interface{}
+		err := json.Unmarshal(run2data, &data)
+		assert.NoError(t, err)
+
+		data["projectName"] = "Rolled Back Project"
+		data["testName"] = "Rolled Back Test"
+
+		named, err := json.Marshal(data)
+		assert.NoError(t, err)
+
+		httpTest.Post("/api/raw/stream/").
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString(toNDJSON(t, named, `{"latency":`)).
+			Expect(t).
+			Status(500).
+			Type("json").
+			Done()
+
+		_, err = ps.FindByName("rolledbackproject")
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		_, err = ts.FindByNames("rolledbackproject", "rolledbacktest")
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+
+	t.Run("POST create raw stream with invalid detail and best effort", func(t *testing.T) {
+		var rid uint
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/stream/").
+			SetQueryParams(map[string]string{"bestEffort": "true"}).
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString(toNDJSON(t, run2data, `{"latency":`)).
+			Expect(t).
+			Status(500).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err := json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+				assert.NotZero(t, rr.Details.Success)
+				assert.Equal(t, uint(1), rr.Details.Fail)
+				assert.True(t, rr.Run.Partial)
+
+				rid = rr.Run.ID
+
+				return nil
+			}).
+			Done()
+
+		r, err := rs.FindByID(rid)
+		assert.NoError(t, err)
+		assert.True(t, r.Partial)
+
+		count, err := ds.Count(rid)
+		assert.NoError(t, err)
+		assert.NotZero(t, count)
+	})
+
+	t.Run("POST create raw stream 400 on invalid summary", func(t *testing.T) {
+		httpTest.Post("/api/raw/stream/").
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString("{\"count\":\n").
+			Expect(t).
+			Status(400).
+			Type("json").
+			Done()
+	})
+
+	t.Run("POST create raw stream 400 on empty body", func(t *testing.T) {
+		httpTest.Post("/api/raw/stream/").
+			AddHeader("Content-Type", "application/x-ndjson").
+			BodyString("\n\n").
+			Expect(t).
+			Status(400).
+			Type("json").
+			Done()
+	})
+}
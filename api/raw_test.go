@@ -33,15 +33,17 @@ func TestRawAPI(t *testing.T) {
 	}
 
 	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
-		&model.Bucket{}, &model.LatencyDistribution{})
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{}, &model.Submission{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	ts := &model.TestService{DB: db}
 	ps := &model.ProjectService{DB: db}
 	rs := &model.RunService{DB: db}
 	ds := &model.DetailService{DB: db, Config: &conf.Database}
+	ss := &model.SubmissionService{DB: db}
+	txs := &model.TxService{DB: db, Config: &conf.Database}
 
-	var projectID, testID uint
+	var projectID, testID, runID, run1ID uint
 	var pid, tid string
 
 	var httpTest *baloo.Client
@@ -68,7 +70,7 @@ func TestRawAPI(t *testing.T) {
 		SetupRunAPI(runsGroup, rs, ds)
 
 		apiGroup := echoServer.Group("/api")
-		SetupRawAPI(apiGroup, ps, ts, rs, ds)
+		SetupRawAPI(apiGroup, ps, ts, rs, ds, ss, txs, &conf.Idempotency, nil)
 
 		go func() {
 			echoServer.Start("localhost:0")
@@ -145,6 +147,8 @@ func TestRawAPI(t *testing.T) {
 				testID = rr.Test.ID
 				tid = strconv.FormatUint(uint64(testID), 10)
 
+				runID = rr.Run.ID
+
 				projectID = rr.Project.ID
 				pid = strconv.FormatUint(uint64(projectID), 10)
 
@@ -180,6 +184,369 @@ func TestRawAPI(t *testing.T) {
 				assert.NotZero(t, rr.Project.ID)
 				assert.NotZero(t, rr.Test.ID)
 				assert.NotZero(t, rr.Run.ID)
+				assert.Nil(t, rr.Baseline)
+
+				run1ID = rr.Run.ID
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("POST create same raw data returns the original", func(t *testing.T) {
+		count, err := rs.Count(testID)
+		assert.NoError(t, err)
+
+		var data map[string]interface{}
+
+		err = json.Unmarshal(run1data, &data)
+		assert.NoError(t, err)
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/").
+			JSON(data).
+			Expect(t).
+			Status(201).
+			Type("json").
+			Header("Idempotent-Replayed", "true").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err = json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, run1ID, rr.Run.ID)
+				assert.NotZero(t, rr.Details.Success)
+
+				return nil
+			}).
+			Done()
+
+		after, err := rs.Count(testID)
+		assert.NoError(t, err)
+		assert.Equal(t, count, after)
+	})
+
+	t.Run("POST create raw data with idempotency key", func(t *testing.T) {
+		var data map[string]interface{}
+
+		err := json.Unmarshal(run0data, &data)
+		assert.NoError(t, err)
+
+		data["date"] = time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+		var rid uint
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/").
+			SetHeader("Idempotency-Key", "build-123").
+			JSON(data).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				assert.Empty(t, res.Header.Get("Idempotent-Replayed"))
+
+				rr := new(RawResponse)
+				err = json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+				assert.NotZero(t, rr.Run.ID)
+
+				rid = rr.Run.ID
+
+				return nil
+			}).
+			Done()
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/").
+			SetHeader("Idempotency-Key", "build-123").
+			JSON(data).
+			Expect(t).
+			Status(201).
+			Type("json").
+			Header("Idempotent-Replayed", "true").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err = json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, rid, rr.Run.ID)
+
+				return nil
+			}).
+			Done()
+
+		data["date"] = time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+
+		httpTest.Post("/api/projects/"+pid+"/tests/"+tid+"/raw/").
+			SetHeader("Idempotency-Key", "build-123").
+			JSON(data).
+			Expect(t).
+			Status(422).
+			Type("json").
+			Done()
+	})
+
+	t.Run("POST create raw data with baseline", func(t *testing.T) {
+		tm, err := ts.FindByID(testID)
+		assert.NoError(t, err)
+
+		tm.BaselineRunID = runID
+		tm.FailOnThreshold = true
+		tm.Thresholds = map[model.Threshold]*model.ThresholdSetting{
+			model.ThresholdMean: &model.ThresholdSetting{MaxRegression: 1},
+		}
+
+		err = ts.Update(tm)
+		assert.NoError(t, err)
+
+		var data map[string]interface{}
+
+		err = json.Unmarshal(run1data, &data)
+
+		assert.NoError(t, err)
+
+		// a later run with the same results
+		data["date"] = time.Now().Format(time.RFC3339)
+
+		httpTest.Post("/api/projects/" + pid + "/tests/" + tid + "/raw/").
+			JSON(data).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err = json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+
+				assert.NotNil(t, rr.Baseline)
+				assert.Equal(t, []uint{runID}, rr.Baseline.RunIDs)
+
+				cmp := rr.Baseline.Comparisons[model.ThresholdMean]
+				assert.NotNil(t, cmp)
+				assert.NotZero(t, cmp.Baseline)
+				assert.NotZero(t, cmp.Value)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("POST create raw data stores threshold results", func(t *testing.T) {
+		tm, err := ts.FindByID(testID)
+		assert.NoError(t, err)
+
+		tm.BaselineRunID = 0
+		tm.FailOnThreshold = true
+		tm.Thresholds = map[model.Threshold]*model.ThresholdSetting{
+			model.ThresholdMean: &model.ThresholdSetting{Threshold: time.Nanosecond},
+		}
+
+		err = ts.Update(tm)
+		assert.NoError(t, err)
+
+		var data map[string]interface{}
+
+		err = json.Unmarshal(run1data, &data)
+		assert.NoError(t, err)
+
+		data["date"] = time.Now().Add(time.Hour).Format(time.RFC3339)
+
+		var rid uint
+
+		httpTest.Post("/api/projects/" + pid + "/tests/" + tid + "/raw/").
+			JSON(data).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err = json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+
+				assert.Equal(t, model.StatusFail, rr.Run.Status)
+				assert.Len(t, rr.Run.ThresholdResults, 1)
+				assert.Equal(t, model.ThresholdMean, rr.Run.ThresholdResults[0].Threshold)
+				assert.Equal(t, float64(1), rr.Run.ThresholdResults[0].Limit)
+				assert.Equal(t, model.StatusFail, rr.Run.ThresholdResults[0].Status)
+				assert.Equal(t, model.StatusFail, rr.Test.Status)
+
+				rid = rr.Run.ID
+
+				return nil
+			}).
+			Done()
+
+		r, err := rs.FindByID(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, model.StatusFail, r.Status)
+		assert.Len(t, r.ThresholdResults, 1)
+
+		tm, err = ts.FindByID(testID)
+		assert.NoError(t, err)
+		assert.Equal(t, model.StatusFail, tm.Status)
+		assert.Equal(t, model.StatusFail, tm.Thresholds[model.ThresholdMean].Status)
+	})
+
+	t.Run("POST create raw data with names finds or creates project and test", func(t *testing.T) {
+		var ids []uint
+
+		for i, file := range []string{"../test/run2.json", "../test/run3.json"} {
+			data := make(map[string]interface{})
+
+			content, err := ioutil.ReadFile(file)
+			assert.NoError(t, err)
+
+			err = json.Unmarshal(content, &data)
+			assert.NoError(t, err)
+
+			data["projectName"] = "CI Project"
+			data["projectDescription"] = "Nightly runs"
+			data["testName"] = "Say Hello"
+			data["failOnThreshold"] = true
+			data["thresholds"] = map[string]interface{}{
+				"mean": map[string]interface{}{"threshold": 1000000000 * (i + 1)},
+			}
+
+			httpTest.Post("/api/raw/").
+				JSON(data).
+				Expect(t).
+				Status(201).
+				Type("json").
+				AssertFunc(func(res *http.Response, req *http.Request) error {
+					rr := new(RawResponse)
+					err = json.NewDecoder(res.Body).Decode(rr)
+
+					assert.NoError(t, err)
+
+					assert.Equal(t, "ciproject", rr.Project.Name)
+					assert.Equal(t, "Nightly runs", rr.Project.Description)
+					assert.Equal(t, "sayhello", rr.Test.Name)
+					assert.Equal(t, rr.Project.ID, rr.Test.ProjectID)
+					assert.True(t, rr.Test.FailOnThreshold)
+					assert.Equal(t, time.Second, rr.Test.Thresholds[model.ThresholdMean].Threshold)
+					assert.Equal(t, rr.Test.ID, rr.Run.TestID)
+
+					ids = append(ids, rr.Project.ID, rr.Test.ID)
+
+					return nil
+				}).
+				Done()
+		}
+
+		assert.Len(t, ids, 4)
+		assert.Equal(t, ids[0], ids[2])
+		assert.Equal(t, ids[1], ids[3])
+
+		count, err := rs.Count(ids[1])
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), count)
+	})
+
+	t.Run("POST create raw data with names of a test in the trash 409", func(t *testing.T) {
+		p, err := ps.FindByName("ciproject")
+		assert.NoError(t, err)
+
+		tst, err := ts.FindByName(p.ID, "sayhello")
+		assert.NoError(t, err)
+
+		_, err = ts.Delete(tst)
+		assert.NoError(t, err)
+
+		data := map[string]interface{}{"projectName": "CI Project", "testName": "Say Hello"}
+
+		httpTest.Post("/api/raw/").
+			JSON(data).
+			Expect(t).
+			Status(409).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				he := new(echo.HTTPError)
+				err := json.NewDecoder(res.Body).Decode(he)
+
+				assert.NoError(t, err)
+				assert.Equal(t, "Test sayhello is in the trash. Restore it with POST /api/trash/tests/"+
+					strconv.FormatUint(uint64(tst.ID), 10)+"/restore/", he.Message)
+
+				return nil
+			}).
+			Done()
+
+		_, err = (&model.TrashService{DB: db}).RestoreTest(tst.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("POST create raw data with only details computes the summary", func(t *testing.T) {
+		var data map[string]interface{}
+
+		err := json.Unmarshal(run1data, &data)
+		assert.NoError(t, err)
+
+		details := data["details"].([]interface{})
+
+		httpTest.Post("/api/raw/").
+			JSON(map[string]interface{}{"details": details}).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err = json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+
+				assert.Equal(t, uint64(len(details)), rr.Run.Count)
+				assert.NotZero(t, rr.Run.Total)
+				assert.NotZero(t, rr.Run.Average)
+				assert.NotZero(t, rr.Run.Fastest)
+				assert.True(t, rr.Run.Slowest >= rr.Run.Fastest)
+				assert.NotZero(t, rr.Run.Rps)
+				assert.False(t, rr.Run.Date.IsZero())
+				assert.Len(t, rr.Run.LatencyDistribution, 7)
+				assert.Len(t, rr.Run.Histogram, 11)
+				assert.NotEmpty(t, rr.Run.StatusCodeDist)
+				assert.Equal(t, uint(len(details)), rr.Details.Success)
+
+				runID = rr.Run.ID
+
+				return nil
+			}).
+			Done()
+
+		r, err := rs.FindByID(runID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(len(details)), r.Count)
+		assert.Len(t, r.Histogram, 11)
+		assert.Len(t, r.LatencyDistribution, 7)
+	})
+
+	t.Run("POST create raw data with recompute overrides the summary", func(t *testing.T) {
+		var data map[string]interface{}
+
+		err := json.Unmarshal(run0data, &data)
+		assert.NoError(t, err)
+
+		data["projectName"] = "Recompute"
+		data["testName"] = "Run0"
+
+		details := data["details"].([]interface{})
+		assert.NotEqual(t, data["count"], float64(len(details)))
+
+		httpTest.Post("/api/raw/").
+			SetQueryParams(map[string]string{"recompute": "true"}).
+			JSON(data).
+			Expect(t).
+			Status(201).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rr := new(RawResponse)
+				err = json.NewDecoder(res.Body).Decode(rr)
+
+				assert.NoError(t, err)
+
+				assert.Equal(t, uint64(len(details)), rr.Run.Count)
+				assert.Len(t, rr.Run.LatencyDistribution, 7)
+				assert.Len(t, rr.Run.Histogram, 11)
 
 				return nil
 			}).
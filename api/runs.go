@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,8 +32,9 @@ func formatDuration(durationNano float64, div float64) string {
 
 // RunList response
 type RunList struct {
-	Total uint         `json:"total"`
-	Data  []*model.Run `json:"data"`
+	Listable
+
+	Data []*model.Run `json:"data"`
 }
 
 // DetailExport is detail for export
@@ -85,6 +87,7 @@ func SetupRunAPI(g *echo.Group, rs service.RunService, ds service.DetailService)
 	g.POST("/", api.create).Name = "ghz api: create run"
 	g.GET("/", api.listRuns).Name = "ghz api: list runs"
 	g.GET("/latest/", api.getLatest).Name = "ghz api: list runs"
+	g.GET("/compare/", api.compare).Name = "ghz api: compare runs"
 
 	g.Use(api.populateRun)
 
@@ -92,6 +95,7 @@ func SetupRunAPI(g *echo.Group, rs service.RunService, ds service.DetailService)
 	g.PUT("/:rid/", api.update).Name = "ghz api: update run"
 	g.DELETE("/:rid/", api.delete).Name = "ghz api: delete run"
 	g.GET("/:rid/export/", api.export).Name = "ghz api: export"
+	g.GET("/:rid/timeline/", api.getTimeline).Name = "ghz api: get run timeline"
 }
 
 // RunAPI provides the api
@@ -156,6 +160,55 @@ func (api *RunAPI) getLatest(c echo.Context) error {
 	return c.JSON(http.StatusOK, r)
 }
 
+// compare compares the target run against the base run. The base run has to belong to
+// the test, while the target run can belong to any test. Both runs have to be completed.
+func (api *RunAPI) compare(c echo.Context) error {
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No test in context")
+	}
+
+	baseID, err := strconv.Atoi(c.QueryParam("base"))
+	if err != nil || baseID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid base run id")
+	}
+
+	targetID, err := strconv.Atoi(c.QueryParam("target"))
+	if err != nil || targetID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid target run id")
+	}
+
+	base, err := api.rs.FindByID(uint(baseID))
+	if gorm.IsRecordNotFoundError(err) || (err == nil && base.TestID != t.ID) {
+		return echo.NewHTTPError(http.StatusNotFound, "Base run not found")
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	target, err := api.rs.FindByID(uint(targetID))
+	if gorm.IsRecordNotFoundError(err) {
+		return echo.NewHTTPError(http.StatusNotFound, "Target run not found")
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	if base.State != model.RunCompleted {
+		return echo.NewHTTPError(http.StatusConflict, "Base run is "+string(base.State))
+	}
+
+	if target.State != model.RunCompleted {
+		return echo.NewHTTPError(http.StatusConflict, "Target run is "+string(target.State))
+	}
+
+	return c.JSON(http.StatusOK, model.CompareRuns(base, target))
+}
+
 func (api *RunAPI) update(c echo.Context) error {
 	r := new(model.Run)
 
@@ -204,11 +257,29 @@ func (api *RunAPI) listRuns(c echo.Context) error {
 
 	tid := t.ID
 
-	page := getPageParam(c)
+	limit, page, err := getListParams(c)
+	if err != nil {
+		return err
+	}
+
+	q, err := getQuery(c, model.RunQueryFields, "includeTrashed", "histogram", "latency", "populate")
+	if err != nil {
+		return err
+	}
 
-	doSort, sort, order := getSortAndOrder(c)
+	if err := getAfterParam(c, q); err != nil {
+		return err
+	}
 
-	limit := uint(20)
+	includeTrashed, err := getIncludeTrashedParam(c)
+	if err != nil {
+		return err
+	}
+
+	countQuery, findQuery := api.rs.CountQuery, api.rs.FindByTestIDQuery
+	if includeTrashed {
+		countQuery, findQuery = api.rs.CountQueryUnscoped, api.rs.FindByTestIDQueryUnscoped
+	}
 
 	countCh := make(chan uint, 1)
 	dataCh := make(chan []*model.Run, 1)
@@ -216,14 +287,12 @@ func (api *RunAPI) listRuns(c echo.Context) error {
 	defer close(errCh)
 
 	go func() {
-		count, err := api.rs.Count(tid)
+		count, err := countQuery(tid, q)
 		errCh <- err
 		countCh <- count
 		close(countCh)
 	}()
 
-	var err error
-
 	histogram := false
 	latency := false
 
@@ -244,13 +313,7 @@ func (api *RunAPI) listRuns(c echo.Context) error {
 	}
 
 	go func() {
-		var runs []*model.Run
-		err = nil
-		if doSort {
-			runs, err = api.rs.FindByTestIDSorted(tid, limit, page, sort, order, histogram, latency)
-		} else {
-			runs, err = api.rs.FindByTestID(tid, limit, page, true)
-		}
+		runs, err := findQuery(tid, limit, page, q, histogram, latency)
 		errCh <- err
 		dataCh <- runs
 		close(dataCh)
@@ -266,13 +329,37 @@ func (api *RunAPI) listRuns(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err2.Error())
 	}
 
-	rl := &RunList{Total: count, Data: data}
+	rl := &RunList{Data: data}
+	rl.Total = count
+
+	if len(data) > 0 {
+		rl.Next = nextCursor(q, limit, uint(len(data)), data[len(data)-1].ID)
+	}
+
+	setListHeaders(c, count, limit, page, rl.Next)
 
 	return c.JSON(http.StatusOK, rl)
 }
 
 func (api *RunAPI) delete(c echo.Context) error {
-	return echo.NewHTTPError(http.StatusNotImplemented, "Not Implemented")
+	ro := c.Get("run")
+	r, ok := ro.(*model.Run)
+
+	if r == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No Run in context")
+	}
+
+	res, err := api.rs.Delete(r)
+
+	if gorm.IsRecordNotFoundError(err) {
+		return echo.NewHTTPError(http.StatusNotFound, "Not Found")
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, res)
 }
 
 func (api *RunAPI) export(c echo.Context) error {
@@ -29,7 +29,7 @@ func TestRunAPI(t *testing.T) {
 	defer db.Close()
 
 	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
-		&model.Bucket{}, &model.LatencyDistribution{})
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	ts := &model.TestService{DB: db}
@@ -308,9 +308,88 @@ func TestRunAPI(t *testing.T) {
 	})
 
 	t.Run("DELETE /:id", func(t *testing.T) {
-		httpTest.Delete(basePath + "/" + pid + "/tests/" + tid + "/runs/" + rid + "/").
+		nr := &model.Run{
+			TestID: testID,
+			Count:  100,
+			LatencyDistribution: []*model.LatencyDistribution{
+				&model.LatencyDistribution{Percentage: 50, Latency: 1 * time.Millisecond},
+			},
+			Histogram: []*model.Bucket{
+				&model.Bucket{Mark: 0.01, Count: 100},
+			},
+		}
+		err := rs.Create(nr)
+		assert.NoError(t, err)
+
+		nrid := strconv.FormatUint(uint64(nr.ID), 10)
+
+		httpTest.Delete(basePath + "/" + pid + "/tests/" + tid + "/runs/" + nrid + "/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				dr := new(model.CascadeResult)
+				err = json.NewDecoder(res.Body).Decode(dr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), dr.Runs)
+				assert.Equal(t, uint(1), dr.LatencyDistributions)
+				assert.Equal(t, uint(1), dr.Buckets)
+
+				return nil
+			}).
+			Done()
+
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/" + nrid + "/").
 			Expect(t).
-			Status(501).
+			Status(404).
+			Type("json").
+			Done()
+	})
+
+	t.Run("GET / includes deleted run only with includeTrashed", func(t *testing.T) {
+		ids := func(params map[string]string) (uint, []uint) {
+			var total uint
+			var ids []uint
+
+			httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/").
+				SetQueryParams(params).
+				Expect(t).
+				Status(200).
+				Type("json").
+				AssertFunc(func(res *http.Response, req *http.Request) error {
+					rl := new(RunList)
+					err := json.NewDecoder(res.Body).Decode(rl)
+
+					assert.NoError(t, err)
+
+					total = rl.Total
+					for _, r := range rl.Data {
+						ids = append(ids, r.ID)
+					}
+
+					return nil
+				}).
+				Done()
+
+			return total, ids
+		}
+
+		var deleted model.Run
+		err := db.Unscoped().Where("test_id = ? AND deleted_at IS NOT NULL", testID).First(&deleted).Error
+		assert.NoError(t, err)
+
+		total, found := ids(map[string]string{"limit": "500"})
+		assert.NotContains(t, found, deleted.ID)
+
+		trashedTotal, found := ids(map[string]string{"limit": "500", "includeTrashed": "true"})
+		assert.Contains(t, found, deleted.ID)
+		assert.Equal(t, total+1, trashedTotal)
+
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/").
+			SetQueryParams(map[string]string{"includeTrashed": "1x"}).
+			Expect(t).
+			Status(400).
 			Type("json").
 			Done()
 	})
@@ -534,6 +613,234 @@ func TestRunAPI(t *testing.T) {
 			Done()
 	})
 
+	t.Run("GET /:tid/runs?sort=rps&minAverage=20ms&maxRps=5020", func(t *testing.T) {
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid2 + "/runs/").
+			SetQueryParams(map[string]string{"sort": "rps", "minAverage": "20ms", "maxRps": "5020"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rl := new(RunList)
+				err = json.NewDecoder(res.Body).Decode(rl)
+
+				assert.NoError(t, err)
+				assert.Equal(t, 6, int(rl.Total))
+				assert.Len(t, rl.Data, 6)
+				assert.Equal(t, float64(5015), rl.Data[0].Rps)
+				assert.Equal(t, 20*time.Millisecond, rl.Data[0].Average)
+				assert.Equal(t, float64(5020), rl.Data[5].Rps)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /:tid/runs?status=fail", func(t *testing.T) {
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid2 + "/runs/").
+			SetQueryParams(map[string]string{"status": "fail"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rl := new(RunList)
+				err = json.NewDecoder(res.Body).Decode(rl)
+
+				assert.NoError(t, err)
+				assert.Equal(t, 0, int(rl.Total))
+				assert.Len(t, rl.Data, 0)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /:tid/runs 400 on invalid query", func(t *testing.T) {
+		params := []map[string]string{
+			{"sort": "foo"},
+			{"sort": "rps", "order": "up"},
+			{"minRps": "fast"},
+			{"maxAverage": "soon"},
+			{"minDate": "yesterday"},
+			{"status": "broken"},
+			{"date": "2018-01-02"},
+			{"minRsp": "5"},
+			{"minStatus": "x"},
+			{"format": "csv"},
+		}
+
+		for _, p := range params {
+			httpTest.Get(basePath + "/" + pid + "/tests/" + tid2 + "/runs/").
+				SetQueryParams(p).
+				Expect(t).
+				Status(400).
+				Type("json").
+				Done()
+		}
+	})
+
+	t.Run("GET /:tid/runs 400 on unknown parameter", func(t *testing.T) {
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid2 + "/runs/").
+			SetQueryParams(map[string]string{"minRsp": "5", "populate": "true"}).
+			Expect(t).
+			Status(400).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				he := new(echo.HTTPError)
+				err := json.NewDecoder(res.Body).Decode(he)
+
+				assert.NoError(t, err)
+				assert.Equal(t, "Invalid parameter 'minRsp'. The list does not support the parameter", he.Message)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /:tid/runs?limit=10 pages by cursor", func(t *testing.T) {
+		var next string
+
+		httpTest.Get(basePath+"/"+pid+"/tests/"+tid2+"/runs/").
+			SetQueryParams(map[string]string{"limit": "10"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			Header("X-Total-Count", "25").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rl := new(RunList)
+				err = json.NewDecoder(res.Body).Decode(rl)
+
+				assert.NoError(t, err)
+				assert.Equal(t, 25, int(rl.Total))
+				assert.Len(t, rl.Data, 10)
+				assert.Equal(t, strconv.FormatUint(uint64(rl.Data[9].ID), 10), rl.Next)
+				assert.Contains(t, res.Header.Get("Link"), "after="+rl.Next+"&limit=10>; rel=\"next\"")
+
+				next = rl.Next
+
+				return nil
+			}).
+			Done()
+
+		httpTest.Get(basePath+"/"+pid+"/tests/"+tid2+"/runs/").
+			SetQueryParams(map[string]string{"limit": "20", "after": next}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			Header("X-Total-Count", "25").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rl := new(RunList)
+				err = json.NewDecoder(res.Body).Decode(rl)
+
+				assert.NoError(t, err)
+				assert.Equal(t, 25, int(rl.Total))
+				assert.Len(t, rl.Data, 15)
+				assert.Empty(t, rl.Next)
+				assert.NotContains(t, res.Header.Get("Link"), "rel=\"next\"")
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /:tid/runs?sort=rps&page=1 links by page", func(t *testing.T) {
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid2 + "/runs/").
+			SetQueryParams(map[string]string{"sort": "rps", "limit": "10", "page": "1"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rl := new(RunList)
+				err = json.NewDecoder(res.Body).Decode(rl)
+
+				assert.NoError(t, err)
+				assert.Len(t, rl.Data, 10)
+				assert.Empty(t, rl.Next)
+				assert.Equal(t, float64(5010), rl.Data[0].Rps)
+
+				link := res.Header.Get("Link")
+				assert.Contains(t, link, "limit=10&page=2&sort=rps>; rel=\"next\"")
+				assert.Contains(t, link, "limit=10&page=0&sort=rps>; rel=\"prev\"")
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /:tid/runs 400 on invalid paging", func(t *testing.T) {
+		params := []map[string]string{
+			{"page": "-1"},
+			{"limit": "0"},
+			{"limit": "501"},
+			{"limit": "ten"},
+			{"after": "abc"},
+			{"after": "10", "page": "1"},
+			{"after": "10", "sort": "rps"},
+		}
+
+		for _, p := range params {
+			httpTest.Get(basePath + "/" + pid + "/tests/" + tid2 + "/runs/").
+				SetQueryParams(p).
+				Expect(t).
+				Status(400).
+				Type("json").
+				Done()
+		}
+	})
+
+	t.Run("GET /:rid/timeline/", func(t *testing.T) {
+		start := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+
+		r := &model.Run{TestID: testID, Date: start}
+		assert.NoError(t, rs.Create(r))
+
+		for i := 0; i < 6; i++ {
+			d := &model.Detail{RunID: r.ID, Timestamp: start.Add(time.Duration(i) * 300 * time.Millisecond),
+				Latency: float64(time.Duration(i+1) * time.Millisecond)}
+			assert.NoError(t, db.Create(d).Error)
+		}
+
+		id := strconv.FormatUint(uint64(r.ID), 10)
+
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/" + id + "/timeline/").
+			SetQueryParams(map[string]string{"width": "1s", "percentiles": "50,90"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				tl := new(model.Timeline)
+				err := json.NewDecoder(res.Body).Decode(tl)
+
+				assert.NoError(t, err)
+				assert.Equal(t, r.ID, tl.RunID)
+				assert.Equal(t, uint64(6), tl.Count)
+				assert.Len(t, tl.Windows, 2)
+				assert.Equal(t, uint64(4), tl.Windows[0].Count)
+				assert.Equal(t, float64(4), tl.Windows[0].Rps)
+				assert.Len(t, tl.Windows[0].LatencyDistribution, 2)
+				assert.Equal(t, 2*time.Millisecond, tl.Windows[0].LatencyDistribution[0].Latency)
+				assert.Equal(t, uint64(2), tl.Windows[1].Count)
+
+				return nil
+			}).
+			Done()
+
+		params := []map[string]string{
+			{"width": "soon"},
+			{"width": "10us"},
+			{"width": "1ms", "percentiles": "50,0"},
+			{"percentiles": "99.9"},
+		}
+
+		for _, p := range params {
+			httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/" + id + "/timeline/").
+				SetQueryParams(p).
+				Expect(t).
+				Status(400).
+				Type("json").
+				Done()
+		}
+	})
+
 	t.Run("GET export unknown run", func(t *testing.T) {
 		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/4343212/export/").
 			Expect(t).
@@ -617,4 +924,130 @@ func TestRunAPI(t *testing.T) {
 			Type("text/csv").
 			Done()
 	})
+
+	t.Run("GET /:tid/runs/compare", func(t *testing.T) {
+		base := &model.Run{
+			TestID:    testID,
+			Date:      time.Now(),
+			Count:     100,
+			Average:   10 * time.Millisecond,
+			Rps:       1000,
+			ErrorDist: map[string]int{"timeout": 2, "unavailable": 1},
+			LatencyDistribution: []*model.LatencyDistribution{
+				&model.LatencyDistribution{Percentage: 50, Latency: 10 * time.Millisecond},
+			},
+			Histogram: []*model.Bucket{
+				&model.Bucket{Mark: 0.01, Count: 60},
+				&model.Bucket{Mark: 0.02, Count: 40},
+			},
+		}
+		err := rs.Create(base)
+		assert.NoError(t, err)
+
+		target := &model.Run{
+			TestID:    testID2,
+			Date:      time.Now(),
+			Count:     100,
+			Average:   15 * time.Millisecond,
+			Rps:       800,
+			ErrorDist: map[string]int{"timeout": 4, "internal": 1},
+			LatencyDistribution: []*model.LatencyDistribution{
+				&model.LatencyDistribution{Percentage: 50, Latency: 12 * time.Millisecond},
+			},
+			Histogram: []*model.Bucket{
+				&model.Bucket{Mark: 0.01, Count: 20},
+				&model.Bucket{Mark: 0.03, Count: 80},
+			},
+		}
+		err = rs.Create(target)
+		assert.NoError(t, err)
+
+		baseID := strconv.FormatUint(uint64(base.ID), 10)
+		targetID := strconv.FormatUint(uint64(target.ID), 10)
+
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/compare/").
+			SetQueryParams(map[string]string{"base": baseID, "target": targetID}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				rc := new(model.RunComparison)
+				err = json.NewDecoder(res.Body).Decode(rc)
+
+				assert.NoError(t, err)
+				assert.Equal(t, base.ID, rc.BaseRunID)
+				assert.Equal(t, testID, rc.BaseTestID)
+				assert.Equal(t, target.ID, rc.TargetRunID)
+				assert.Equal(t, testID2, rc.TargetTestID)
+
+				assert.Equal(t, float64(5*time.Millisecond), rc.Summary["average"].Diff)
+				assert.Equal(t, float64(50), rc.Summary["average"].Change)
+				assert.Equal(t, float64(-20), rc.Summary["rps"].Change)
+				assert.Equal(t, float64(2), rc.Summary["errorRate"].Diff)
+
+				assert.Len(t, rc.LatencyDistribution, 1)
+				assert.Equal(t, float64(20), rc.LatencyDistribution[0].Change)
+
+				assert.Len(t, rc.Histogram, 2)
+				assert.Equal(t, 60, rc.Histogram[0].BaseCount)
+				assert.Equal(t, 20, rc.Histogram[0].TargetCount)
+				assert.Equal(t, 40, rc.Histogram[1].BaseCount)
+				assert.Equal(t, 80, rc.Histogram[1].TargetCount)
+
+				assert.Equal(t, []string{"internal"}, rc.NewErrors)
+				assert.Equal(t, []string{"unavailable"}, rc.GoneErrors)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /:tid/runs/compare 400 on invalid ids", func(t *testing.T) {
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/compare/").
+			SetQueryParams(map[string]string{"base": "foo", "target": rid}).
+			Expect(t).
+			Status(400).
+			Type("json").
+			Done()
+	})
+
+	t.Run("GET /:tid/runs/compare 404 on unknown target", func(t *testing.T) {
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/compare/").
+			SetQueryParams(map[string]string{"base": rid, "target": "4343212"}).
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+
+	t.Run("GET /:tid/runs/compare 404 on base of other test", func(t *testing.T) {
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid2 + "/runs/compare/").
+			SetQueryParams(map[string]string{"base": rid, "target": rid}).
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+
+	t.Run("GET /:tid/runs/compare 409 on running run", func(t *testing.T) {
+		running := &model.Run{TestID: testID, Date: time.Now(), State: model.RunRunning}
+		err := rs.Create(running)
+		assert.NoError(t, err)
+
+		runningID := strconv.FormatUint(uint64(running.ID), 10)
+
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/compare/").
+			SetQueryParams(map[string]string{"base": rid, "target": runningID}).
+			Expect(t).
+			Status(409).
+			Type("json").
+			Done()
+
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/runs/compare/").
+			SetQueryParams(map[string]string{"base": runningID, "target": rid}).
+			Expect(t).
+			Status(409).
+			Type("json").
+			Done()
+	})
 }
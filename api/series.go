@@ -0,0 +1,118 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/labstack/echo"
+)
+
+const (
+	// defaultSeriesBuckets is the number of buckets of a series by default
+	defaultSeriesBuckets = 200
+
+	// maxSeriesBuckets is the maximum number of buckets of a series
+	maxSeriesBuckets = 1000
+)
+
+// Get test run series
+// @Summary Gets the downsampled time series of run metrics of the test
+// @Description Divides the time range into buckets and aggregates the metrics of the completed runs
+// @Description in each bucket into the min, max and average values. Durations are in nanoseconds
+// @Description and errors is the percentage of calls that resulted in an error.
+// @ID get-test-series
+// @Produce json
+// @Param pid path int true "Project ID"
+// @Param tid path int true "Test ID"
+// @Param metric query string true "Comma separated metrics: count, total, average, fastest, slowest, rps, errors or percentiles such as p95"
+// @Param from query string false "The start of the series, as an RFC 3339 time or a date. Default: the date of the first run"
+// @Param to query string false "The end of the series, as an RFC 3339 time or a date. Default: the date of the last run"
+// @Param buckets query integer false "The number of buckets, up to 1000. Default: 200"
+// @Success 200 {object} model.TimeSeries
+// @Failure 400 {object} echo.HTTPError
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/series [get]
+func (api *TestAPI) getSeries(c echo.Context) error {
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if t == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No Test in context")
+	}
+
+	metrics, err := getMetricsParam(c)
+	if err != nil {
+		return err
+	}
+
+	fromTime, err := getTimeParam(c, "from")
+	if err != nil {
+		return err
+	}
+
+	toTime, err := getTimeParam(c, "to")
+	if err != nil {
+		return err
+	}
+
+	if !fromTime.IsZero() && !toTime.IsZero() && toTime.Before(fromTime) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid time range. The from time must be before the to time")
+	}
+
+	buckets := uint(defaultSeriesBuckets)
+	if bucketsparam := c.QueryParam("buckets"); bucketsparam != "" {
+		n, err := strconv.ParseUint(bucketsparam, 10, 32)
+		if err != nil || n == 0 || n > maxSeriesBuckets {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"Invalid buckets '"+bucketsparam+"'. The buckets must be between 1 and "+strconv.Itoa(maxSeriesBuckets))
+		}
+
+		buckets = uint(n)
+	}
+
+	ts, err := api.rs.FindSeries(t.ID, metrics, fromTime, toTime, buckets)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, ts)
+}
+
+// getMetricsParam returns the series metrics of the comma separated metric parameter
+func getMetricsParam(c echo.Context) ([]model.SeriesMetric, error) {
+	param := c.QueryParam("metric")
+	if strings.TrimSpace(param) == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Missing metric")
+	}
+
+	var metrics []model.SeriesMetric
+	for _, name := range strings.Split(param, ",") {
+		m, err := model.ParseSeriesMetric(strings.TrimSpace(name))
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		metrics = append(metrics, m)
+	}
+
+	return metrics, nil
+}
+
+// getTimeParam returns the time of the parameter, or the zero time if it is not set
+func getTimeParam(c echo.Context, name string) (time.Time, error) {
+	param := c.QueryParam(name)
+	if param == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := model.ParseTime(param)
+	if err != nil {
+		return t, echo.NewHTTPError(http.StatusBadRequest, "Invalid "+name+" '"+param+"': "+err.Error())
+	}
+
+	return t, nil
+}
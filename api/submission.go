@@ -0,0 +1,112 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/labstack/echo"
+)
+
+const (
+	// headerIdempotencyKey is the request header holding the idempotency key of a submission
+	headerIdempotencyKey = "Idempotency-Key"
+
+	// headerIdempotentReplayed is the response header set when the original response is returned
+	headerIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// hash returns the hash of the run summary and date of the request, excluding the details
+func (rr *RawRequest) hash() (string, error) {
+	summary := *rr
+	summary.Details = nil
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// idempotencyKey returns the idempotency key of the request, if any
+func idempotencyKey(c echo.Context) string {
+	return strings.TrimSpace(c.Request().Header.Get(headerIdempotencyKey))
+}
+
+// newSubmission creates the submission of the request to the test with the idempotency key, if any.
+// Returns nil if idempotent submissions are disabled.
+func (api *RawAPI) newSubmission(rr *RawRequest, tid uint, key string) (*model.Submission, error) {
+	if api.conf == nil || !api.conf.Enabled() {
+		return nil, nil
+	}
+
+	hash, err := rr.hash()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	sub := &model.Submission{Hash: hash, TestID: tid}
+
+	if key != "" {
+		sub.IdempotencyKey = &key
+	}
+
+	return sub, nil
+}
+
+// findSubmission finds the original submission of the same results within the window,
+// by the idempotency key or otherwise by the content hash. Returns nil if there is none.
+func (api *RawAPI) findSubmission(sub *model.Submission) (*model.Submission, error) {
+	since := api.conf.GetExpiryTime(time.Now())
+
+	if sub.IdempotencyKey != nil {
+		original, err := api.ss.FindByKey(*sub.IdempotencyKey, since)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		if original != nil {
+			if original.TestID != sub.TestID || original.Hash != sub.Hash {
+				return nil, echo.NewHTTPError(http.StatusUnprocessableEntity,
+					"Idempotency key has already been used for different results")
+			}
+
+			return original, nil
+		}
+	}
+
+	original, err := api.ss.FindByHash(sub.TestID, sub.Hash, since)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return original, nil
+}
+
+// recordSubmission records the submission along with the response of the created results
+func (api *RawAPI) recordSubmission(sub *model.Submission, rres *RawResponse) error {
+	if sub == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(rres)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	sub.RunID = rres.Run.ID
+	sub.Response = string(data)
+
+	if err := api.ss.Create(sub, api.conf.GetExpiryTime(time.Now())); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return nil
+}
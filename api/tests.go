@@ -11,8 +11,31 @@ import (
 
 // TestList response
 type TestList struct {
-	Total uint          `json:"total"`
-	Data  []*model.Test `json:"data"`
+	Listable
+
+	Data []*model.Test `json:"data"`
+}
+
+// BaselineRequest is the request to set the baseline of a test.
+// Setting neither clears the baseline.
+type BaselineRequest struct {
+	// Pin the run with the id as the baseline
+	RunID uint `json:"runID"`
+
+	// Use the median of the last passing runs as the baseline
+	Runs uint `json:"runs"`
+}
+
+// BaselineResponse is the baseline of a test
+type BaselineResponse struct {
+	// The pinned baseline run id
+	RunID uint `json:"runID"`
+
+	// The number of last passing runs used for the baseline
+	Runs uint `json:"runs"`
+
+	// The computed baseline
+	Baseline *model.Baseline `json:"baseline"`
 }
 
 // SetupTestAPI sets up the API
@@ -27,6 +50,12 @@ func SetupTestAPI(g *echo.Group, ts service.TestService, rs service.RunService)
 	g.GET("/:tid/", api.get).Name = "ghz api: get test"
 	g.PUT("/:tid/", api.update).Name = "ghz api: update test"
 	g.DELETE("/:tid/", api.delete).Name = "ghz api: delete test"
+
+	g.GET("/:tid/baseline/", api.getBaseline).Name = "ghz api: get test baseline"
+	g.PUT("/:tid/baseline/", api.setBaseline).Name = "ghz api: set test baseline"
+
+	g.GET("/:tid/status-history/", api.getStatusHistory).Name = "ghz api: get test status history"
+	g.GET("/:tid/series/", api.getSeries).Name = "ghz api: get test run series"
 }
 
 // TestAPI provides the api
@@ -95,20 +124,18 @@ func (api *TestAPI) update(c echo.Context) error {
 
 	t.ProjectID = p.ID
 
-	var err error
+	// baseline is managed using the baseline api
+	t.BaselineRunID = tm.BaselineRunID
+	t.BaselineRuns = tm.BaselineRuns
 
 	// we've may have changed a setting that effects the status
 	// so update accordingly
-	latestRun, err := api.rs.FindLatest(t.ID)
-	if err == nil && latestRun != nil {
-		median, nine5 := latestRun.GetThresholdValues()
-		hasErrors := latestRun.HasErrors()
-
-		t.SetStatus(latestRun.Average, median, nine5, latestRun.Fastest, latestRun.Slowest,
-			latestRun.Rps, hasErrors)
+	latestRun, err := api.setStatus(t)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
 	}
 
-	if err = api.ts.Update(t); gorm.IsRecordNotFoundError(err) {
+	if err = api.ts.UpdateStatus(t, latestRun); gorm.IsRecordNotFoundError(err) {
 		return echo.NewHTTPError(http.StatusNotFound, "Not Found")
 	}
 
@@ -119,8 +146,128 @@ func (api *TestAPI) update(c echo.Context) error {
 	return c.JSON(http.StatusOK, t)
 }
 
+func (api *TestAPI) getBaseline(c echo.Context) error {
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if t == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No Test in context")
+	}
+
+	baseline, err := api.rs.FindBaseline(t, 0)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, &BaselineResponse{RunID: t.BaselineRunID, Runs: t.BaselineRuns, Baseline: baseline})
+}
+
+func (api *TestAPI) setBaseline(c echo.Context) error {
+	br := new(BaselineRequest)
+
+	if err := c.Bind(br); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if br.RunID != 0 && br.Runs != 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Only one of runID or runs can be set")
+	}
+
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if t == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No Test in context")
+	}
+
+	if br.RunID != 0 {
+		r, err := api.rs.FindByID(br.RunID)
+		if gorm.IsRecordNotFoundError(err) || (err == nil && r.TestID != t.ID) {
+			return echo.NewHTTPError(http.StatusBadRequest, model.ErrBaselineRunNotInTest.Error())
+		}
+
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+		}
+	}
+
+	t.BaselineRunID = br.RunID
+	t.BaselineRuns = br.Runs
+
+	latestRun, err := api.setStatus(t)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	if err := api.ts.UpdateStatus(t, latestRun); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	baseline, err := api.rs.FindBaseline(t, 0)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, &BaselineResponse{RunID: t.BaselineRunID, Runs: t.BaselineRuns, Baseline: baseline})
+}
+
+// setStatus sets the status of the test based on its latest run,
+// and stores the new evaluation of the latest run which is returned
+func (api *TestAPI) setStatus(t *model.Test) (*model.Run, error) {
+	latestRun, err := api.rs.FindLatest(t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if latestRun == nil {
+		return nil, nil
+	}
+
+	percentiles, err := api.rs.FindPercentileValues(latestRun, t.GetPercentiles())
+	if err != nil {
+		return nil, err
+	}
+
+	baseline, err := api.rs.FindBaseline(t, latestRun.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	t.SetStatus(latestRun, percentiles, baseline)
+
+	return latestRun, api.rs.UpdateStatus(latestRun)
+}
+
+func (api *TestAPI) getStatusHistory(c echo.Context) error {
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if t == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No Test in context")
+	}
+
+	return getStatusHistory(c, t.ID, api.ts.CountStatusHistory, api.ts.FindStatusHistory, api.ts.FindStatusSummary)
+}
+
 func (api *TestAPI) delete(c echo.Context) error {
-	return echo.NewHTTPError(http.StatusNotImplemented, "Not Implemented")
+	to := c.Get("test")
+	t, ok := to.(*model.Test)
+
+	if t == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No Test in context")
+	}
+
+	res, err := api.ts.Delete(t)
+
+	if gorm.IsRecordNotFoundError(err) {
+		return echo.NewHTTPError(http.StatusNotFound, "Not Found")
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, res)
 }
 
 func (api *TestAPI) listTests(c echo.Context) error {
@@ -133,11 +280,25 @@ func (api *TestAPI) listTests(c echo.Context) error {
 
 	pid := p.ID
 
-	page := getPageParam(c)
+	limit, page, err := getListParams(c)
+	if err != nil {
+		return err
+	}
 
-	doSort, sort, order := getSortAndOrder(c)
+	q, err := getQuery(c, model.TestQueryFields, "includeTrashed")
+	if err != nil {
+		return err
+	}
 
-	limit := uint(20)
+	includeTrashed, err := getIncludeTrashedParam(c)
+	if err != nil {
+		return err
+	}
+
+	countQuery, findQuery := api.ts.CountQuery, api.ts.FindByProjectIDQuery
+	if includeTrashed {
+		countQuery, findQuery = api.ts.CountQueryUnscoped, api.ts.FindByProjectIDQueryUnscoped
+	}
 
 	countCh := make(chan uint, 1)
 	dataCh := make(chan []*model.Test, 1)
@@ -145,22 +306,14 @@ func (api *TestAPI) listTests(c echo.Context) error {
 	defer close(errCh)
 
 	go func() {
-		count, err := api.ts.Count(pid)
+		count, err := countQuery(pid, q)
 		errCh <- err
 		countCh <- count
 		close(countCh)
 	}()
 
-	var err error
-
 	go func() {
-		var tests []*model.Test
-		err = nil
-		if doSort {
-			tests, err = api.ts.FindByProjectIDSorted(pid, limit, page, sort, order)
-		} else {
-			tests, err = api.ts.FindByProjectID(pid, limit, page)
-		}
+		tests, err := findQuery(pid, limit, page, q)
 		errCh <- err
 		dataCh <- tests
 		close(dataCh)
@@ -176,7 +329,10 @@ func (api *TestAPI) listTests(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err2.Error())
 	}
 
-	tl := &TestList{Total: count, Data: data}
+	tl := &TestList{Data: data}
+	tl.Total = count
+
+	setListHeaders(c, count, limit, page, "")
 
 	return c.JSON(http.StatusOK, tl)
 }
@@ -27,7 +27,8 @@ func TestTestAPI(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(&model.Project{}, &model.Test{})
+	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	ts := &model.TestService{DB: db}
@@ -376,6 +377,164 @@ func TestTestAPI(t *testing.T) {
 			Done()
 	})
 
+	t.Run("PUT /:tid/baseline/", func(t *testing.T) {
+		r := &model.Run{TestID: testID, Average: 2 * time.Millisecond}
+		err := runService.Create(r)
+		assert.NoError(t, err)
+
+		httpTest.Put(basePath + "/" + pid + "/tests/" + tid + "/baseline/").
+			JSON(map[string]uint{"runID": r.ID}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				br := new(BaselineResponse)
+				err := json.NewDecoder(res.Body).Decode(br)
+
+				assert.NoError(t, err)
+				assert.Equal(t, r.ID, br.RunID)
+				assert.Equal(t, uint(0), br.Runs)
+				assert.NotNil(t, br.Baseline)
+				assert.Equal(t, []uint{r.ID}, br.Baseline.RunIDs)
+
+				return nil
+			}).
+			Done()
+
+		httpTest.Put(basePath + "/" + pid + "/tests/" + tid + "/").
+			JSON(map[string]string{"name": "updatedtestname", "description": "updated test description"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			Done()
+
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/baseline/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				br := new(BaselineResponse)
+				err := json.NewDecoder(res.Body).Decode(br)
+
+				assert.NoError(t, err)
+				assert.Equal(t, r.ID, br.RunID)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("PUT /:tid/baseline/ with last runs", func(t *testing.T) {
+		httpTest.Put(basePath + "/" + pid + "/tests/" + tid + "/baseline/").
+			JSON(map[string]uint{"runs": 5}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				br := new(BaselineResponse)
+				err := json.NewDecoder(res.Body).Decode(br)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(0), br.RunID)
+				assert.Equal(t, uint(5), br.Runs)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("PUT /:tid/baseline/ should 400 with both run and runs", func(t *testing.T) {
+		httpTest.Put(basePath + "/" + pid + "/tests/" + tid + "/baseline/").
+			JSON(map[string]uint{"runID": 1, "runs": 5}).
+			Expect(t).
+			Status(400).
+			Type("json").
+			Done()
+	})
+
+	t.Run("PUT /:tid/baseline/ should 400 with run of other test", func(t *testing.T) {
+		r := &model.Run{TestID: testID2}
+		err := runService.Create(r)
+		assert.NoError(t, err)
+
+		httpTest.Put(basePath + "/" + pid + "/tests/" + tid + "/baseline/").
+			JSON(map[string]uint{"runID": r.ID}).
+			Expect(t).
+			Status(400).
+			Type("json").
+			Done()
+	})
+
+	t.Run("GET /:tid/series/", func(t *testing.T) {
+		start, _ := time.Parse("2006-01-02", "2018-03-01")
+
+		for i := 0; i < 3; i++ {
+			r := &model.Run{
+				TestID:  testID2,
+				Date:    start.Add(time.Duration(i) * 24 * time.Hour),
+				Average: time.Duration(i+1) * time.Millisecond,
+				Rps:     float64(100 * (i + 1)),
+				LatencyDistribution: []*model.LatencyDistribution{
+					&model.LatencyDistribution{Percentage: 95, Latency: time.Duration(i+2) * time.Millisecond},
+				},
+			}
+
+			assert.NoError(t, runService.Create(r))
+		}
+
+		tid2 := strconv.FormatUint(uint64(testID2), 10)
+
+		httpTest.Get(basePath + "/" + pid2 + "/tests/" + tid2 + "/series/").
+			SetQueryParams(map[string]string{"metric": "p95,rps", "from": "2018-03-01", "to": "2018-03-04", "buckets": "1"}).
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				ts := new(model.TimeSeries)
+				err := json.NewDecoder(res.Body).Decode(ts)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(3), ts.Runs)
+				assert.Equal(t, uint(1), ts.Buckets)
+				assert.Len(t, ts.Series, 2)
+
+				p95 := ts.Series[0]
+				assert.Equal(t, model.SeriesMetric("p95"), p95.Metric)
+				assert.Len(t, p95.Points, 1)
+				assert.Equal(t, float64(2*time.Millisecond), p95.Points[0].Min)
+				assert.Equal(t, float64(4*time.Millisecond), p95.Points[0].Max)
+				assert.Equal(t, float64(3*time.Millisecond), p95.Points[0].Avg)
+
+				rps := ts.Series[1]
+				assert.Equal(t, model.SeriesRPS, rps.Metric)
+				assert.Equal(t, float64(200), rps.Points[0].Avg)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /:tid/series/ should 400 on invalid params", func(t *testing.T) {
+		params := []map[string]string{
+			{},
+			{"metric": "foo"},
+			{"metric": "average,p99.9"},
+			{"metric": "average", "from": "yesterday"},
+			{"metric": "average", "from": "2018-03-02", "to": "2018-03-01"},
+			{"metric": "average", "buckets": "0"},
+			{"metric": "average", "buckets": "1001"},
+		}
+
+		for _, p := range params {
+			httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/series/").
+				SetQueryParams(p).
+				Expect(t).
+				Status(400).
+				Type("json").
+				Done()
+		}
+	})
+
 	t.Run("populateTest with unknown ID should 404", func(t *testing.T) {
 		e := echo.New()
 
@@ -544,6 +703,22 @@ func TestTestAPI(t *testing.T) {
 			Done()
 	})
 
+	t.Run("GET /:pid/tests should 400 on unknown parameter", func(t *testing.T) {
+		params := []map[string]string{
+			{"minStatus": "x"},
+			{"populate": "true"},
+		}
+
+		for _, p := range params {
+			httpTest.Get(basePath + "/" + pid2 + "/tests/").
+				SetQueryParams(p).
+				Expect(t).
+				Status(400).
+				Type("json").
+				Done()
+		}
+	})
+
 	t.Run("GET /:pid/tests on empty project", func(t *testing.T) {
 		httpTest.Get(basePath + "/" + pid3 + "/tests/").
 			Expect(t).
@@ -566,9 +741,63 @@ func TestTestAPI(t *testing.T) {
 	t.Run("DELETE /:id", func(t *testing.T) {
 		httpTest.Delete(basePath + "/" + pid + "/tests/" + tid + "/").
 			Expect(t).
-			Status(501).
+			Status(200).
 			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				dr := new(model.CascadeResult)
+				err = json.NewDecoder(res.Body).Decode(dr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(0), dr.Projects)
+				assert.Equal(t, uint(1), dr.Tests)
+
+				return nil
+			}).
 			Done()
+
+		httpTest.Get(basePath + "/" + pid + "/tests/" + tid + "/").
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+
+	t.Run("GET / includes deleted test only with includeTrashed", func(t *testing.T) {
+		ids := func(params map[string]string) (uint, []uint) {
+			var total uint
+			var ids []uint
+
+			httpTest.Get(basePath + "/" + pid + "/tests/").
+				SetQueryParams(params).
+				Expect(t).
+				Status(200).
+				Type("json").
+				AssertFunc(func(res *http.Response, req *http.Request) error {
+					tl := new(TestList)
+					err := json.NewDecoder(res.Body).Decode(tl)
+
+					assert.NoError(t, err)
+
+					total = tl.Total
+					for _, tst := range tl.Data {
+						ids = append(ids, tst.ID)
+					}
+
+					return nil
+				}).
+				Done()
+
+			return total, ids
+		}
+
+		id, _ := strconv.ParseUint(tid, 10, 32)
+
+		total, found := ids(map[string]string{})
+		assert.NotContains(t, found, uint(id))
+
+		trashedTotal, found := ids(map[string]string{"includeTrashed": "true"})
+		assert.Contains(t, found, uint(id))
+		assert.Equal(t, total+1, trashedTotal)
 	})
 
 	t.Run("DELETE should 404 on unknown id", func(t *testing.T) {
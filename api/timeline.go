@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/labstack/echo"
+)
+
+// Get run timeline
+// @Summary Gets the timeline of the run
+// @Description Splits the details of the run into windows of the width by the time the calls started.
+// @Description Each window holds the number of calls, the calls per second, the number of errors,
+// @Description the latency percentiles and the status code distribution of the calls.
+// @ID get-run-timeline
+// @Produce json
+// @Param pid path int true "Project ID"
+// @Param tid path int true "Test ID"
+// @Param rid path int true "Run ID"
+// @Param width query string false "The width of the windows, such as 500ms. Default: 1s, or wider to keep within 10000 windows"
+// @Param percentiles query string false "Comma separated latency percentiles. Default: 10,25,50,75,90,95,99"
+// @Success 200 {object} model.Timeline
+// @Failure 400 {object} echo.HTTPError
+// @Failure 404 {object} echo.HTTPError
+// @Failure 500 {object} echo.HTTPError
+// @Router /projects/{pid}/tests/{tid}/runs/{rid}/timeline [get]
+func (api *RunAPI) getTimeline(c echo.Context) error {
+	ro := c.Get("run")
+	r, ok := ro.(*model.Run)
+
+	if r == nil || !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "No Run in context")
+	}
+
+	width := time.Duration(0)
+	if widthparam := c.QueryParam("width"); widthparam != "" {
+		d, err := time.ParseDuration(widthparam)
+		if err != nil || d < time.Millisecond {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"Invalid width '"+widthparam+"'. The width must be a duration of at least 1ms, such as 500ms")
+		}
+
+		width = d
+	}
+
+	var percentiles []float64
+	if param := c.QueryParam("percentiles"); param != "" {
+		for _, v := range strings.Split(param, ",") {
+			p, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil || p <= 0 || p > 100 {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					"Invalid percentile '"+v+"'. Percentiles must be whole numbers between 1 and 100")
+			}
+
+			percentiles = append(percentiles, float64(p))
+		}
+	}
+
+	tl, err := api.ds.Timeline(r, width, percentiles)
+	if err == model.ErrTimelineWindows {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, tl)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/bojand/ghz-web/model"
+	"github.com/bojand/ghz-web/service"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+)
+
+// SetupTrashAPI sets up the API
+func SetupTrashAPI(g *echo.Group, trs service.TrashService, conf *config.TrashConfig) {
+	api := &TrashAPI{trs: trs, conf: conf}
+
+	g.GET("/projects/", api.listProjects).Name = "ghz api: list trashed projects"
+	g.GET("/tests/", api.listTests).Name = "ghz api: list trashed tests"
+	g.GET("/runs/", api.listRuns).Name = "ghz api: list trashed runs"
+
+	g.POST("/projects/:id/restore/", api.restoreProject).Name = "ghz api: restore project"
+	g.POST("/tests/:id/restore/", api.restoreTest).Name = "ghz api: restore test"
+	g.POST("/runs/:id/restore/", api.restoreRun).Name = "ghz api: restore run"
+
+	g.POST("/purge/", api.purge).Name = "ghz api: purge trash"
+}
+
+// TrashAPI provides the api
+type TrashAPI struct {
+	trs  service.TrashService
+	conf *config.TrashConfig
+}
+
+func (api *TrashAPI) listProjects(c echo.Context) error {
+	limit, page, err := getListParams(c)
+	if err != nil {
+		return err
+	}
+
+	count, err := api.trs.CountProjects()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	data, err := api.trs.ListProjects(limit, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	list := &ProjectList{Data: data}
+	list.Total = count
+
+	setListHeaders(c, count, limit, page, "")
+
+	return c.JSON(http.StatusOK, list)
+}
+
+func (api *TrashAPI) listTests(c echo.Context) error {
+	limit, page, err := getListParams(c)
+	if err != nil {
+		return err
+	}
+
+	count, err := api.trs.CountTests()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	data, err := api.trs.ListTests(limit, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	list := &TestList{Data: data}
+	list.Total = count
+
+	setListHeaders(c, count, limit, page, "")
+
+	return c.JSON(http.StatusOK, list)
+}
+
+func (api *TrashAPI) listRuns(c echo.Context) error {
+	limit, page, err := getListParams(c)
+	if err != nil {
+		return err
+	}
+
+	count, err := api.trs.CountRuns()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	data, err := api.trs.ListRuns(limit, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	list := &RunList{Data: data}
+	list.Total = count
+
+	setListHeaders(c, count, limit, page, "")
+
+	return c.JSON(http.StatusOK, list)
+}
+
+func (api *TrashAPI) restoreProject(c echo.Context) error {
+	return api.restore(c, api.trs.RestoreProject)
+}
+
+func (api *TrashAPI) restoreTest(c echo.Context) error {
+	return api.restore(c, api.trs.RestoreTest)
+}
+
+func (api *TrashAPI) restoreRun(c echo.Context) error {
+	return api.restore(c, api.trs.RestoreRun)
+}
+
+func (api *TrashAPI) restore(c echo.Context, restoreFn func(uint) (*model.CascadeResult, error)) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Invalid id")
+	}
+
+	res, err := restoreFn(uint(id))
+
+	if gorm.IsRecordNotFoundError(err) {
+		return echo.NewHTTPError(http.StatusNotFound, "Not Found")
+	}
+
+	if err == model.ErrParentInTrash {
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (api *TrashAPI) purge(c echo.Context) error {
+	res, err := api.trs.Purge(api.conf.GetPurgeTime(time.Now()))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Bad Request: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
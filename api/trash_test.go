@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/bojand/ghz-web/model"
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/stretchr/testify/assert"
+	baloo "gopkg.in/h2non/baloo.v3"
+)
+
+func TestTrashAPI(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&model.Project{}, &model.Test{}, &model.Run{}, &model.Detail{},
+		&model.Bucket{}, &model.LatencyDistribution{}, &model.StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ps := &model.ProjectService{DB: db}
+	ts := &model.TestService{DB: db}
+	trs := &model.TrashService{DB: db}
+
+	var projectID uint
+	var pid, tid string
+
+	var httpTest *baloo.Client
+	var echoServer *echo.Echo
+
+	echoServer = echo.New()
+	echoServer.Use(middleware.AddTrailingSlash())
+	echoServer.Use(middleware.Logger())
+
+	defer echoServer.Close()
+
+	const basePath = "/trash"
+
+	t.Run("Start API", func(t *testing.T) {
+		trashGroup := echoServer.Group(basePath)
+		SetupTrashAPI(trashGroup, trs, &config.TrashConfig{PurgeAfterDays: 0})
+
+		go func() {
+			echoServer.Start("localhost:0")
+		}()
+	})
+
+	t.Run("Sync to get the port", func(t *testing.T) {
+		done := make(chan bool, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			done <- true
+			close(done)
+		}()
+
+		<-done
+	})
+
+	t.Run("Create http test", func(t *testing.T) {
+		httpTest = baloo.New(echoServer.Listener.Addr().String())
+	})
+
+	t.Run("Create and delete project and test", func(t *testing.T) {
+		p := &model.Project{Name: "Trash API Project"}
+		err := ps.Create(p)
+		assert.NoError(t, err)
+
+		tst := &model.Test{ProjectID: p.ID, Name: "Trash API Test"}
+		err = ts.Create(tst)
+		assert.NoError(t, err)
+
+		_, err = ps.Delete(p)
+		assert.NoError(t, err)
+
+		projectID = p.ID
+		pid = strconv.FormatUint(uint64(p.ID), 10)
+		tid = strconv.FormatUint(uint64(tst.ID), 10)
+	})
+
+	t.Run("GET /projects/", func(t *testing.T) {
+		httpTest.Get(basePath + "/projects/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				pl := new(ProjectList)
+				err := json.NewDecoder(res.Body).Decode(pl)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), pl.Total)
+				assert.Len(t, pl.Data, 1)
+				assert.Equal(t, projectID, pl.Data[0].ID)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("GET /tests/", func(t *testing.T) {
+		httpTest.Get(basePath + "/tests/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				tl := new(TestList)
+				err := json.NewDecoder(res.Body).Decode(tl)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), tl.Total)
+				assert.Len(t, tl.Data, 1)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("POST /tests/:id/restore/ should 409 when project is in trash", func(t *testing.T) {
+		httpTest.Post(basePath + "/tests/" + tid + "/restore/").
+			Expect(t).
+			Status(409).
+			Type("json").
+			Done()
+	})
+
+	t.Run("POST /projects/:id/restore/ should 404 on unknown id", func(t *testing.T) {
+		httpTest.Post(basePath + "/projects/5454/restore/").
+			Expect(t).
+			Status(404).
+			Type("json").
+			Done()
+	})
+
+	t.Run("POST /projects/:id/restore/", func(t *testing.T) {
+		httpTest.Post(basePath + "/projects/" + pid + "/restore/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				cr := new(model.CascadeResult)
+				err := json.NewDecoder(res.Body).Decode(cr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), cr.Projects)
+				assert.Equal(t, uint(1), cr.Tests)
+
+				return nil
+			}).
+			Done()
+	})
+
+	t.Run("POST /purge/", func(t *testing.T) {
+		p, err := ps.FindByID(projectID)
+		assert.NoError(t, err)
+
+		_, err = ps.Delete(p)
+		assert.NoError(t, err)
+
+		time.Sleep(10 * time.Millisecond)
+
+		httpTest.Post(basePath + "/purge/").
+			Expect(t).
+			Status(200).
+			Type("json").
+			AssertFunc(func(res *http.Response, req *http.Request) error {
+				cr := new(model.CascadeResult)
+				err := json.NewDecoder(res.Body).Decode(cr)
+
+				assert.NoError(t, err)
+				assert.Equal(t, uint(1), cr.Projects)
+				assert.Equal(t, uint(1), cr.Tests)
+
+				return nil
+			}).
+			Done()
+
+		count, err := trs.CountProjects()
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), count)
+	})
+}
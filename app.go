@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/bojand/ghz-web/api"
 	"github.com/bojand/ghz-web/config"
 	"github.com/bojand/ghz-web/docs"
@@ -25,6 +27,9 @@ type Application struct {
 	Server *echo.Echo
 	DB     *gorm.DB
 	Info   *config.Info
+
+	retention *model.RetentionService
+	jobs      *api.JobQueue
 }
 
 // Start starts the app
@@ -41,6 +46,12 @@ func (app *Application) Start() {
 
 	app.setupServer()
 
+	app.startRetention()
+
+	app.startJobs()
+
+	app.startRunTimeouts()
+
 	app.Logger.Fatal(app.Server.Start(app.Config.Server.GetHostPort()))
 }
 
@@ -83,9 +94,21 @@ func (app *Application) setupDatabase() error {
 		&model.Detail{},
 		&model.LatencyDistribution{},
 		&model.Bucket{},
+		&model.StatusChange{},
+		&model.Submission{},
+		&model.Job{},
 	)
 
+	// runs stored before the option columns existed are filtered by them as well
+	if err := (&model.RunService{DB: db}).MigrateOptions(); err != nil {
+		return err
+	}
+
 	if app.Config.Database.GetDialect() == "sqlite3" {
+		// sqlite allows a single writer, so the ingestion workers and the requests
+		// share one connection and wait for each other instead of failing as locked
+		db.DB().SetMaxOpenConns(1)
+
 		// for sqlite we need this for foreign key constraint
 		db.Exec("PRAGMA foreign_keys = ON;")
 	}
@@ -100,6 +123,13 @@ func (app *Application) setupServer() {
 	ts := model.TestService{DB: app.DB}
 	rs := model.RunService{DB: app.DB}
 	ds := model.DetailService{DB: app.DB, Config: &app.Config.Database}
+	trs := model.TrashService{DB: app.DB}
+	ss := model.SubmissionService{DB: app.DB}
+	txs := model.TxService{DB: app.DB, Config: &app.Config.Database}
+	js := model.JobService{DB: app.DB}
+	rcs := model.RecomputeService{DB: app.DB, Config: &app.Config.Database}
+	es := model.ErrorService{DB: app.DB}
+	app.retention = &model.RetentionService{DB: app.DB, Config: &app.Config.Retention}
 
 	docs.SwaggerInfo.Host = app.Config.Server.GetHostPort()
 	docs.SwaggerInfo.BasePath = app.Config.Server.RootURL + "/api"
@@ -120,7 +150,11 @@ func (app *Application) setupServer() {
 
 	apiRoot := root.Group("/api")
 
-	api.Setup(app.Config, app.Info, apiRoot, &ps, &ts, &rs, &ds)
+	if app.Config.Ingest.Enabled() {
+		app.jobs = api.NewJobQueue(&ps, &ts, &rs, &ds, &ss, &txs, &js, &app.Config.Idempotency, &app.Config.Ingest)
+	}
+
+	api.Setup(app.Config, app.Info, apiRoot, &ps, &ts, &rs, &ds, &trs, app.retention, &rcs, &es, &ss, &txs, &js, app.jobs)
 
 	s.Static("/", "ui/dist").Name = "ghz api: static"
 
@@ -130,6 +164,73 @@ func (app *Application) setupServer() {
 	api.PrintRoutes(s)
 }
 
+func (app *Application) startRetention() {
+	if !app.Config.Retention.Enabled {
+		return
+	}
+
+	interval := app.Config.Retention.GetInterval()
+
+	app.Logger.Infof("Starting retention job. Interval: %+v", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			stats, err := app.retention.Prune()
+			if err == model.ErrRetentionRunning {
+				app.Logger.Infof("Retention job skipped: %+v", err)
+			} else if err != nil {
+				app.Logger.Errorf("Retention job failed: %+v", err)
+			} else {
+				app.Logger.Infof("Retention job done. Projects: %+v Deleted: %+v", stats.Projects, stats.Deleted)
+			}
+
+			<-ticker.C
+		}
+	}()
+}
+
+func (app *Application) startJobs() {
+	if app.jobs == nil {
+		return
+	}
+
+	app.Logger.Infof("Starting ingestion workers. Workers: %+v Spool directory: %+v",
+		app.Config.Ingest.Workers, app.Config.Ingest.SpoolDir)
+
+	if err := app.jobs.Start(); err != nil {
+		app.Logger.Fatalf("Failed to start ingestion workers: %+v", err)
+	}
+}
+
+func (app *Application) startRunTimeouts() {
+	if !app.Config.Runs.Enabled() {
+		return
+	}
+
+	timeout := app.Config.Runs.GetTimeout()
+
+	app.Logger.Infof("Starting run timeout job. Timeout: %+v", timeout)
+
+	rs := &model.RunService{DB: app.DB}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			n, err := rs.AbortStale(app.Config.Runs.GetAbortCutoff(time.Now()))
+			if err != nil {
+				app.Logger.Errorf("Run timeout job failed: %+v", err)
+			} else if n > 0 {
+				app.Logger.Infof("Run timeout job done. Aborted: %+v", n)
+			}
+		}
+	}()
+}
+
 // CustomValidator is our validator for the API
 type CustomValidator struct {
 	validator *validator.Validate
@@ -54,11 +54,190 @@ func (lc *LogConfig) Validate() error {
 	return nil
 }
 
+// TrashConfig trash settings
+type TrashConfig struct {
+	// Number of days deleted items are kept in the trash before they can be purged
+	PurgeAfterDays uint `default:"30"`
+}
+
+// GetPurgeTime returns the time before which items in the trash can be purged
+func (tc *TrashConfig) GetPurgeTime(now time.Time) time.Time {
+	return now.Add(-time.Duration(tc.PurgeAfterDays) * 24 * time.Hour)
+}
+
+// IdempotencyConfig settings of idempotent raw result submissions
+type IdempotencyConfig struct {
+	// Number of hours submissions are remembered for. A submission with the same
+	// idempotency key or content within the window returns the original response.
+	// 0 disables idempotent submissions.
+	WindowHours uint `default:"24"`
+}
+
+// Enabled returns whether idempotent submissions are enabled
+func (ic *IdempotencyConfig) Enabled() bool {
+	return ic.WindowHours > 0
+}
+
+// GetExpiryTime returns the time before which submissions are expired
+func (ic *IdempotencyConfig) GetExpiryTime(now time.Time) time.Time {
+	return now.Add(-time.Duration(ic.WindowHours) * time.Hour)
+}
+
+// IngestConfig settings of asynchronous raw result ingestion
+type IngestConfig struct {
+	// Directory the uploads are spooled to until they are processed
+	SpoolDir string `default:"spool"`
+
+	// Number of workers processing the uploads. 0 disables asynchronous ingestion.
+	Workers uint `default:"4"`
+}
+
+// Enabled returns whether asynchronous ingestion is enabled
+func (ic *IngestConfig) Enabled() bool {
+	return ic.Workers > 0
+}
+
+// Validate validates the ingestion settings
+func (ic *IngestConfig) Validate() error {
+	ic.SpoolDir = strings.TrimSpace(ic.SpoolDir)
+
+	if ic.Enabled() && ic.SpoolDir == "" {
+		return errors.New("Ingest spool directory is required")
+	}
+
+	return nil
+}
+
+// RunsConfig settings of runs created incrementally while the load test is executing
+type RunsConfig struct {
+	// Number of minutes a running run may go without receiving details before it is aborted.
+	// 0 keeps running runs open forever.
+	TimeoutMinutes uint `default:"30"`
+}
+
+// Enabled returns whether running runs are aborted after the timeout
+func (rc *RunsConfig) Enabled() bool {
+	return rc.TimeoutMinutes > 0
+}
+
+// GetTimeout returns the time a running run may go without receiving details
+func (rc *RunsConfig) GetTimeout() time.Duration {
+	return time.Duration(rc.TimeoutMinutes) * time.Minute
+}
+
+// GetAbortCutoff returns the time before which running runs that last received details are aborted
+func (rc *RunsConfig) GetAbortCutoff(now time.Time) time.Time {
+	return now.Add(-rc.GetTimeout())
+}
+
+// RetentionPolicy data retention settings applied to a project
+type RetentionPolicy struct {
+	// Number of days after the date of their run to keep run details for. 0 keeps them forever.
+	DetailsDays uint
+
+	// Number of most recent runs to keep per test. 0 keeps all runs.
+	KeepRuns uint
+
+	// Keep the summaries of runs pruned by KeepRuns forever and only delete their details
+	KeepRunSummaries bool
+}
+
+// GetDetailsCutoff returns the time before which run details can be pruned.
+// Returns zero time if details are kept forever.
+func (rp *RetentionPolicy) GetDetailsCutoff(now time.Time) time.Time {
+	if rp.DetailsDays == 0 {
+		return time.Time{}
+	}
+
+	return now.Add(-time.Duration(rp.DetailsDays) * 24 * time.Hour)
+}
+
+// ProjectRetentionConfig overrides the retention policy for a single project.
+// Unset settings fall back to the global retention settings.
+type ProjectRetentionConfig struct {
+	ProjectID        uint
+	DetailsDays      *uint
+	KeepRuns         *uint
+	KeepRunSummaries *bool
+}
+
+// RetentionConfig data retention settings
+type RetentionConfig struct {
+	// Whether the background pruning job is enabled
+	Enabled bool
+
+	// Interval in minutes between pruning job runs
+	IntervalMinutes uint `default:"60"`
+
+	// Maximum number of records deleted per statement
+	BatchSize uint `default:"1000"`
+
+	// Global retention policy
+	DetailsDays      uint
+	KeepRuns         uint
+	KeepRunSummaries bool
+
+	// Per project overrides
+	Projects []ProjectRetentionConfig
+}
+
+// GetInterval returns the interval between pruning job runs
+func (rc *RetentionConfig) GetInterval() time.Duration {
+	return time.Duration(rc.IntervalMinutes) * time.Minute
+}
+
+// GetPolicy returns the retention policy for the project
+func (rc *RetentionConfig) GetPolicy(projectID uint) RetentionPolicy {
+	policy := RetentionPolicy{
+		DetailsDays:      rc.DetailsDays,
+		KeepRuns:         rc.KeepRuns,
+		KeepRunSummaries: rc.KeepRunSummaries,
+	}
+
+	for _, pc := range rc.Projects {
+		if pc.ProjectID != projectID {
+			continue
+		}
+
+		if pc.DetailsDays != nil {
+			policy.DetailsDays = *pc.DetailsDays
+		}
+
+		if pc.KeepRuns != nil {
+			policy.KeepRuns = *pc.KeepRuns
+		}
+
+		if pc.KeepRunSummaries != nil {
+			policy.KeepRunSummaries = *pc.KeepRunSummaries
+		}
+	}
+
+	return policy
+}
+
+// Validate validates the retention settings
+func (rc *RetentionConfig) Validate() error {
+	if rc.IntervalMinutes == 0 {
+		return errors.New("Retention interval must be greater than 0")
+	}
+
+	if rc.BatchSize == 0 {
+		return errors.New("Retention batch size must be greater than 0")
+	}
+
+	return nil
+}
+
 // Config is the application config
 type Config struct {
-	Database DBConfig
-	Server   ServerConfig
-	Log      LogConfig
+	Database    DBConfig
+	Server      ServerConfig
+	Log         LogConfig
+	Trash       TrashConfig
+	Retention   RetentionConfig
+	Idempotency IdempotencyConfig
+	Ingest      IngestConfig
+	Runs        RunsConfig
 }
 
 // Validate the config
@@ -73,6 +252,16 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	err = c.Retention.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = c.Ingest.Validate()
+	if err != nil {
+		return err
+	}
+
 	c.Server.RootURL = strings.TrimSpace(c.Server.RootURL)
 
 	return nil
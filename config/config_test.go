@@ -2,11 +2,15 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConfig_Read(t *testing.T) {
+	threeDays := uint(3)
+	keepSummaries := false
+
 	var tests = []struct {
 		name     string
 		in       string
@@ -15,21 +19,45 @@ func TestConfig_Read(t *testing.T) {
 		{"config1.toml",
 			"../test/config1.toml",
 			&Config{
-				Server:   ServerConfig{Port: 3000, Address: "localhost"},
-				Database: DBConfig{Type: "sqlite", Host: "localhost", Name: "ghz", Path: "ghz.db", SSLMode: "disable"},
-				Log:      LogConfig{Level: "info"}}},
+				Server:      ServerConfig{Port: 3000, Address: "localhost"},
+				Database:    DBConfig{Type: "sqlite", Host: "localhost", Name: "ghz", Path: "ghz.db", SSLMode: "disable", BatchSize: 1000},
+				Log:         LogConfig{Level: "info"},
+				Trash:       TrashConfig{PurgeAfterDays: 30},
+				Retention:   RetentionConfig{IntervalMinutes: 60, BatchSize: 1000},
+				Idempotency: IdempotencyConfig{WindowHours: 24},
+				Ingest:      IngestConfig{SpoolDir: "spool", Workers: 4},
+				Runs:        RunsConfig{TimeoutMinutes: 30}}},
 		{"config2.toml",
 			"../test/config2.toml",
 			&Config{
 				Server:   ServerConfig{Port: 4321, Address: "localhost"},
-				Database: DBConfig{Type: "postgres", Host: "123.0.0.1", Name: "ghz", Path: "ghz.db", SSLMode: "disable", User: "dbuser", Port: 1234},
-				Log:      LogConfig{Level: "warn", Path: "/tmp/ghz.log"}}},
+				Database: DBConfig{Type: "postgres", Host: "123.0.0.1", Name: "ghz", Path: "ghz.db", SSLMode: "disable", User: "dbuser", Port: 1234, BatchSize: 500},
+				Log:      LogConfig{Level: "warn", Path: "/tmp/ghz.log"},
+				Trash:    TrashConfig{PurgeAfterDays: 7},
+				Retention: RetentionConfig{
+					Enabled:          true,
+					IntervalMinutes:  30,
+					BatchSize:        1000,
+					DetailsDays:      14,
+					KeepRuns:         100,
+					KeepRunSummaries: true,
+					Projects: []ProjectRetentionConfig{
+						{ProjectID: 2, DetailsDays: &threeDays, KeepRunSummaries: &keepSummaries},
+					}},
+				Idempotency: IdempotencyConfig{WindowHours: 2},
+				Ingest:      IngestConfig{SpoolDir: "/tmp/ghz-spool", Workers: 2},
+				Runs:        RunsConfig{TimeoutMinutes: 10}}},
 		{"config3.toml",
 			"../test/config3.toml",
 			&Config{
-				Server:   ServerConfig{Port: 3000, Address: "localhost"},
-				Database: DBConfig{Type: "postgres", Host: "localhost", Name: "ghz", Path: "ghz.db", SSLMode: "disable"},
-				Log:      LogConfig{Level: "debug", Path: ""}}},
+				Server:      ServerConfig{Port: 3000, Address: "localhost"},
+				Database:    DBConfig{Type: "postgres", Host: "localhost", Name: "ghz", Path: "ghz.db", SSLMode: "disable", BatchSize: 1000},
+				Log:         LogConfig{Level: "debug", Path: ""},
+				Trash:       TrashConfig{PurgeAfterDays: 30},
+				Retention:   RetentionConfig{IntervalMinutes: 60, BatchSize: 1000},
+				Idempotency: IdempotencyConfig{WindowHours: 24},
+				Ingest:      IngestConfig{SpoolDir: "spool", Workers: 4},
+				Runs:        RunsConfig{TimeoutMinutes: 30}}},
 	}
 
 	for _, tt := range tests {
@@ -58,6 +86,127 @@ func TestLogConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestTrashConfig_GetPurgeTime(t *testing.T) {
+	now := time.Date(2018, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name     string
+		in       *TrashConfig
+		expected time.Time
+	}{
+		{"7 days", &TrashConfig{PurgeAfterDays: 7}, time.Date(2018, 10, 3, 12, 0, 0, 0, time.UTC)},
+		{"0 days", &TrashConfig{PurgeAfterDays: 0}, now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := tt.in.GetPurgeTime(now)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestIdempotencyConfig_GetExpiryTime(t *testing.T) {
+	now := time.Date(2018, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name     string
+		in       *IdempotencyConfig
+		enabled  bool
+		expected time.Time
+	}{
+		{"24 hours", &IdempotencyConfig{WindowHours: 24}, true, time.Date(2018, 10, 9, 12, 0, 0, 0, time.UTC)},
+		{"0 hours", &IdempotencyConfig{WindowHours: 0}, false, now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.enabled, tt.in.Enabled())
+			assert.Equal(t, tt.expected, tt.in.GetExpiryTime(now))
+		})
+	}
+}
+
+func TestIngestConfig_Validate(t *testing.T) {
+	var tests = []struct {
+		name     string
+		in       *IngestConfig
+		enabled  bool
+		expected string
+	}{
+		{"enabled", &IngestConfig{SpoolDir: " spool ", Workers: 4}, true, ""},
+		{"disabled", &IngestConfig{Workers: 0}, false, ""},
+		{"missing spool dir", &IngestConfig{SpoolDir: " ", Workers: 4}, true, "Ingest spool directory is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.in.Validate()
+
+			assert.Equal(t, tt.enabled, tt.in.Enabled())
+
+			if tt.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRetentionConfig_GetPolicy(t *testing.T) {
+	days := uint(3)
+	keepRuns := uint(0)
+	keepSummaries := false
+
+	rc := &RetentionConfig{
+		DetailsDays:      14,
+		KeepRuns:         100,
+		KeepRunSummaries: true,
+		Projects: []ProjectRetentionConfig{
+			{ProjectID: 2, DetailsDays: &days},
+			{ProjectID: 3, KeepRuns: &keepRuns, KeepRunSummaries: &keepSummaries},
+		},
+	}
+
+	var tests = []struct {
+		name     string
+		in       uint
+		expected RetentionPolicy
+	}{
+		{"no override", 1, RetentionPolicy{DetailsDays: 14, KeepRuns: 100, KeepRunSummaries: true}},
+		{"details override", 2, RetentionPolicy{DetailsDays: 3, KeepRuns: 100, KeepRunSummaries: true}},
+		{"runs override", 3, RetentionPolicy{DetailsDays: 14, KeepRuns: 0, KeepRunSummaries: false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := rc.GetPolicy(tt.in)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestRetentionPolicy_GetDetailsCutoff(t *testing.T) {
+	now := time.Date(2018, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name     string
+		in       *RetentionPolicy
+		expected time.Time
+	}{
+		{"7 days", &RetentionPolicy{DetailsDays: 7}, time.Date(2018, 10, 3, 12, 0, 0, 0, time.UTC)},
+		{"forever", &RetentionPolicy{DetailsDays: 0}, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := tt.in.GetDetailsCutoff(now)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
 func TestServerConfig_GetHostPort(t *testing.T) {
 	var tests = []struct {
 		name     string
@@ -75,3 +224,24 @@ func TestServerConfig_GetHostPort(t *testing.T) {
 		})
 	}
 }
+
+func TestRunsConfig_GetAbortCutoff(t *testing.T) {
+	now := time.Date(2018, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name     string
+		in       *RunsConfig
+		enabled  bool
+		expected time.Time
+	}{
+		{"30 minutes", &RunsConfig{TimeoutMinutes: 30}, true, time.Date(2018, 10, 10, 11, 30, 0, 0, time.UTC)},
+		{"0 minutes", &RunsConfig{TimeoutMinutes: 0}, false, now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.enabled, tt.in.Enabled())
+			assert.Equal(t, tt.expected, tt.in.GetAbortCutoff(now))
+		})
+	}
+}
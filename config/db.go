@@ -27,6 +27,21 @@ type DBConfig struct {
 
 	// Path to db for sqlite
 	Path string `default:"ghz.db"`
+
+	// Number of details inserted per transaction when creating details in bulk
+	BatchSize uint `default:"1000"`
+}
+
+// DefaultBatchSize is the number of details inserted per transaction if not configured
+const DefaultBatchSize = 1000
+
+// GetBatchSize returns the number of details inserted per transaction
+func (db *DBConfig) GetBatchSize() int {
+	if db.BatchSize == 0 {
+		return DefaultBatchSize
+	}
+
+	return int(db.BatchSize)
 }
 
 // GetConnectionString returns the database connection string
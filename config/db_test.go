@@ -30,6 +30,23 @@ func TestDBConfig_GetDialect(t *testing.T) {
 	}
 }
 
+func TestDBConfig_GetBatchSize(t *testing.T) {
+	var tests = []struct {
+		name     string
+		in       *DBConfig
+		expected int
+	}{
+		{"default", &DBConfig{}, DefaultBatchSize},
+		{"configured", &DBConfig{BatchSize: 250}, 250},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.in.GetBatchSize())
+		})
+	}
+}
+
 func TestDBConfig_Validate(t *testing.T) {
 	var tests = []struct {
 		name     string
@@ -18,10 +18,12 @@ var (
 	v       = flag.Bool("v", false, "Print the version.")
 )
 
-var usage = `Usage: ghz-web [options...]
+var usage = `Usage: ghz-web [options...] [command]
 Options:
   -config	Path to the config JSON file.
   -v  Print the version.
+Commands:
+  recompute	Recompute the aggregates of stored runs from their details.
 `
 
 func main() {
@@ -54,5 +56,14 @@ func main() {
 		Info:   info,
 	}
 
+	if flag.Arg(0) == "recompute" {
+		if err := app.Recompute(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	app.Start()
 }
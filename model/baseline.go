@@ -0,0 +1,192 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrBaselineRunNotInTest is returned when the pinned baseline run does not belong to the test
+var ErrBaselineRunNotInTest = errors.New("Baseline run does not belong to test")
+
+// Baseline holds the baseline values the test's thresholds are compared against
+type Baseline struct {
+	// IDs of the runs the baseline was computed from
+	RunIDs []uint `json:"runIDs"`
+
+	// Baseline value for each threshold. Durations are in nanoseconds.
+	Values map[Threshold]float64 `json:"values"`
+}
+
+// Comparison is the comparison of a run value against the baseline value for a threshold
+type Comparison struct {
+	// The baseline value
+	Baseline float64 `json:"baseline"`
+
+	// The run value
+	Value float64 `json:"value"`
+
+	// Change of the run value relative to the baseline as a percentage
+	Change float64 `json:"change"`
+
+	// Status is fail if the regression is over the maximum regression of the threshold
+	Status Status `json:"status"`
+}
+
+// BaselineComparison is the comparison of a run against the test's baseline
+type BaselineComparison struct {
+	// IDs of the runs the baseline was computed from
+	RunIDs []uint `json:"runIDs"`
+
+	// Comparisons for each threshold with a maximum regression
+	Comparisons map[Threshold]*Comparison `json:"comparisons"`
+}
+
+// GetThresholdValue returns the value of the run for the latency or rps threshold.
+// Durations are returned in nanoseconds. The percentiles map holds the latency values
+// for the percentile thresholds.
+func (r *Run) GetThresholdValue(thc Threshold, percentiles map[Threshold]time.Duration) (float64, bool) {
+	var val time.Duration
+
+	switch thc {
+	case ThresholdMean:
+		val = r.Average
+	case ThresholdMedian:
+		val, _ = r.GetThresholdValues()
+	case Threshold95th:
+		_, val = r.GetThresholdValues()
+	case ThresholdFastest:
+		val = r.Fastest
+	case ThresholdSlowest:
+		val = r.Slowest
+	case ThresholdRPS:
+		return r.Rps, r.Rps > 0
+	default:
+		p, ok := thc.Percentile()
+		if !ok {
+			return 0, false
+		}
+
+		val = percentiles[PercentileThreshold(p)]
+	}
+
+	return float64(val), val > 0
+}
+
+// NewBaseline creates a baseline from the runs, using the median value of the runs
+// for each threshold. The percentiles holds the latency values for the percentile
+// thresholds of each run.
+func NewBaseline(thresholds []Threshold, runs []*Run, percentiles []map[Threshold]time.Duration) *Baseline {
+	b := &Baseline{
+		RunIDs: make([]uint, len(runs)),
+		Values: make(map[Threshold]float64, len(thresholds)),
+	}
+
+	for i, r := range runs {
+		b.RunIDs[i] = r.ID
+	}
+
+	for _, thc := range thresholds {
+		vals := make([]float64, 0, len(runs))
+
+		for i, r := range runs {
+			var rp map[Threshold]time.Duration
+			if i < len(percentiles) {
+				rp = percentiles[i]
+			}
+
+			if val, ok := r.GetThresholdValue(thc, rp); ok {
+				vals = append(vals, val)
+			}
+		}
+
+		if len(vals) > 0 {
+			b.Values[thc] = median(vals)
+		}
+	}
+
+	return b
+}
+
+// compareBaseline compares the run values against the baseline for each of the test's
+// thresholds with a maximum regression and sets the status of the failed thresholds
+func (t *Test) compareBaseline(r *Run, percentiles map[Threshold]time.Duration, b *Baseline) *BaselineComparison {
+	bc := &BaselineComparison{
+		RunIDs:      b.RunIDs,
+		Comparisons: make(map[Threshold]*Comparison),
+	}
+
+	for thc, ths := range t.Thresholds {
+		if ths == nil || ths.MaxRegression <= 0 || thc == ThresholdRegression {
+			continue
+		}
+
+		base, ok := b.Values[thc]
+		if !ok || base == 0 {
+			continue
+		}
+
+		val, ok := r.GetThresholdValue(thc, percentiles)
+		if !ok {
+			continue
+		}
+
+		cmp := &Comparison{
+			Baseline: base,
+			Value:    val,
+			Change:   (val - base) * 100 / base,
+			Status:   StatusOK,
+		}
+
+		// for rps a drop is a regression, for latencies an increase is
+		regression := cmp.Change
+		if thc == ThresholdRPS {
+			regression = -cmp.Change
+		}
+
+		if regression > ths.MaxRegression {
+			cmp.Status = StatusFail
+			t.failThreshold(thc)
+		}
+
+		r.ThresholdResults = append(r.ThresholdResults, &ThresholdResult{
+			Threshold:  thc,
+			Limit:      ths.MaxRegression,
+			Value:      regression,
+			Regression: true,
+			Status:     cmp.Status,
+		})
+
+		bc.Comparisons[thc] = cmp
+	}
+
+	return bc
+}
+
+// GetBaselineThresholds returns the thresholds that have a maximum regression
+func (t *Test) GetBaselineThresholds() []Threshold {
+	thresholds := make([]Threshold, 0)
+
+	for thc, ths := range t.Thresholds {
+		if ths != nil && ths.MaxRegression > 0 && thc != ThresholdRegression {
+			thresholds = append(thresholds, thc)
+		}
+	}
+
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i] < thresholds[j] })
+
+	return thresholds
+}
+
+func median(vals []float64) float64 {
+	sorted := make([]float64, len(vals))
+	copy(sorted, vals)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,286 @@
+package model
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunModel_GetThresholdValue(t *testing.T) {
+	r := &Run{
+		Average: milli2,
+		Fastest: milli1,
+		Slowest: milli5,
+		Rps:     2000,
+		LatencyDistribution: []*LatencyDistribution{
+			&LatencyDistribution{Percentage: 50, Latency: milli2},
+			&LatencyDistribution{Percentage: 95, Latency: milli4},
+		},
+	}
+
+	percentiles := map[Threshold]time.Duration{Threshold("p99"): milli5}
+
+	var tests = []struct {
+		in         Threshold
+		expected   float64
+		expectedOK bool
+	}{
+		{ThresholdMean, float64(milli2), true},
+		{ThresholdMedian, float64(milli2), true},
+		{Threshold95th, float64(milli4), true},
+		{ThresholdFastest, float64(milli1), true},
+		{ThresholdSlowest, float64(milli5), true},
+		{ThresholdRPS, 2000, true},
+		{Threshold("p99.0"), float64(milli5), true},
+		{Threshold("p99.9"), 0, false},
+		{ThresholdErrorRate, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.in), func(t *testing.T) {
+			actual, ok := r.GetThresholdValue(tt.in, percentiles)
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestNewBaseline(t *testing.T) {
+	runs := []*Run{
+		&Run{Model: Model{ID: 1}, Average: milli1, Rps: 1000},
+		&Run{Model: Model{ID: 2}, Average: milli3, Rps: 3000},
+		&Run{Model: Model{ID: 3}, Average: milli2, Rps: 0},
+	}
+
+	percentiles := []map[Threshold]time.Duration{
+		{Threshold("p99"): milli4},
+		{Threshold("p99"): milli5},
+		{},
+	}
+
+	b := NewBaseline([]Threshold{ThresholdMean, ThresholdRPS, Threshold("p99"), ThresholdMedian},
+		runs, percentiles)
+
+	assert.Equal(t, &Baseline{
+		RunIDs: []uint{1, 2, 3},
+		Values: map[Threshold]float64{
+			ThresholdMean:    float64(milli2),
+			ThresholdRPS:     2000,
+			Threshold("p99"): float64(milli4+milli5) / 2,
+		},
+	}, b)
+}
+
+func TestTestModel_SetStatus_Baseline(t *testing.T) {
+	baseline := &Baseline{
+		RunIDs: []uint{1},
+		Values: map[Threshold]float64{
+			Threshold95th: float64(100 * time.Millisecond),
+			ThresholdRPS:  1000,
+		},
+	}
+
+	r := &Run{
+		Rps: 880,
+		LatencyDistribution: []*LatencyDistribution{
+			&LatencyDistribution{Percentage: 95, Latency: 110 * time.Millisecond},
+		},
+	}
+
+	var tests = []struct {
+		name               string
+		model              *Test
+		baseline           *Baseline
+		expectedStatus     Status
+		expectedThresholds map[Threshold]Status
+		expectedComparison *BaselineComparison
+	}{
+		{"no baseline", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold95th: &ThresholdSetting{MaxRegression: 5},
+			}}, nil, StatusOK,
+			map[Threshold]Status{Threshold95th: StatusOK}, nil},
+		{"within max regression", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold95th: &ThresholdSetting{MaxRegression: 15},
+				ThresholdRPS:  &ThresholdSetting{MaxRegression: 15},
+			}}, baseline, StatusOK,
+			map[Threshold]Status{Threshold95th: StatusOK, ThresholdRPS: StatusOK},
+			&BaselineComparison{RunIDs: []uint{1}, Comparisons: map[Threshold]*Comparison{
+				Threshold95th: &Comparison{Baseline: float64(100 * time.Millisecond),
+					Value: float64(110 * time.Millisecond), Change: 10, Status: StatusOK},
+				ThresholdRPS: &Comparison{Baseline: 1000, Value: 880, Change: -12, Status: StatusOK},
+			}}},
+		{"over max regression", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold95th: &ThresholdSetting{MaxRegression: 15},
+				ThresholdRPS:  &ThresholdSetting{MaxRegression: 10},
+			}}, baseline, StatusFail,
+			map[Threshold]Status{Threshold95th: StatusOK, ThresholdRPS: StatusFail},
+			&BaselineComparison{RunIDs: []uint{1}, Comparisons: map[Threshold]*Comparison{
+				Threshold95th: &Comparison{Baseline: float64(100 * time.Millisecond),
+					Value: float64(110 * time.Millisecond), Change: 10, Status: StatusOK},
+				ThresholdRPS: &Comparison{Baseline: 1000, Value: 880, Change: -12, Status: StatusFail},
+			}}},
+		{"over max regression as key metric", &Test{FailOnKeyMetric: true, KeyMetric: Threshold95th,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold95th: &ThresholdSetting{MaxRegression: 5},
+			}}, baseline, StatusFail,
+			map[Threshold]Status{Threshold95th: StatusFail},
+			&BaselineComparison{RunIDs: []uint{1}, Comparisons: map[Threshold]*Comparison{
+				Threshold95th: &Comparison{Baseline: float64(100 * time.Millisecond),
+					Value: float64(110 * time.Millisecond), Change: 10, Status: StatusFail},
+			}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := tt.model
+			comparison := actual.SetStatus(r, nil, tt.baseline)
+
+			assert.Equal(t, tt.expectedStatus, actual.Status)
+
+			for thc, status := range tt.expectedThresholds {
+				assert.Equal(t, status, actual.Thresholds[thc].Status, string(thc))
+			}
+
+			if tt.expectedComparison == nil {
+				assert.Nil(t, comparison)
+				return
+			}
+
+			assert.Equal(t, tt.expectedComparison.RunIDs, comparison.RunIDs)
+			assert.Len(t, comparison.Comparisons, len(tt.expectedComparison.Comparisons))
+
+			for thc, cmp := range tt.expectedComparison.Comparisons {
+				assert.Equal(t, cmp.Baseline, comparison.Comparisons[thc].Baseline)
+				assert.Equal(t, cmp.Value, comparison.Comparisons[thc].Value)
+				assert.InDelta(t, cmp.Change, comparison.Comparisons[thc].Change, 0.0001)
+				assert.Equal(t, cmp.Status, comparison.Comparisons[thc].Status)
+			}
+		})
+	}
+}
+
+func TestRunService_FindBaseline(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	dao := RunService{DB: db}
+	tst := &Test{Name: "baseline", Thresholds: map[Threshold]*ThresholdSetting{
+		ThresholdMean:    &ThresholdSetting{MaxRegression: 10},
+		ThresholdMedian:  &ThresholdSetting{MaxRegression: 10},
+		Threshold("p99"): &ThresholdSetting{MaxRegression: 10},
+		ThresholdSlowest: &ThresholdSetting{Threshold: milli5},
+	}}
+
+	var rids []uint
+
+	t.Run("create runs", func(t *testing.T) {
+		tst.Project = &Project{}
+		err := db.Create(tst).Error
+		assert.NoError(t, err)
+
+		other := &Test{Project: &Project{}, Name: "other"}
+		err = db.Create(other).Error
+		assert.NoError(t, err)
+
+		now := time.Now()
+
+		for i := 1; i <= 4; i++ {
+			r := &Run{
+				TestID:  tst.ID,
+				Date:    now.Add(time.Duration(i) * time.Minute),
+				Average: time.Duration(i) * time.Millisecond,
+				LatencyDistribution: []*LatencyDistribution{
+					&LatencyDistribution{Percentage: 50, Latency: time.Duration(i) * time.Millisecond},
+				},
+			}
+
+			// the third run fails
+			if i == 3 {
+				r.ErrorDist = map[string]int{"error": 1}
+			}
+
+			err := dao.Create(r)
+			assert.NoError(t, err)
+
+			for k := 1; k <= 10; k++ {
+				err := db.Create(&Detail{RunID: r.ID, Latency: float64(i * k)}).Error
+				assert.NoError(t, err)
+			}
+
+			rids = append(rids, r.ID)
+		}
+
+		r := &Run{TestID: other.ID}
+		err = dao.Create(r)
+		assert.NoError(t, err)
+
+		rids = append(rids, r.ID)
+	})
+
+	t.Run("no baseline", func(t *testing.T) {
+		b, err := dao.FindBaseline(tst, 0)
+		assert.NoError(t, err)
+		assert.Nil(t, b)
+	})
+
+	t.Run("pinned run", func(t *testing.T) {
+		tst.BaselineRunID = rids[1]
+
+		b, err := dao.FindBaseline(tst, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, &Baseline{
+			RunIDs: []uint{rids[1]},
+			Values: map[Threshold]float64{
+				ThresholdMean:    float64(2 * time.Millisecond),
+				ThresholdMedian:  float64(2 * time.Millisecond),
+				Threshold("p99"): 20,
+			},
+		}, b)
+	})
+
+	t.Run("pinned run of other test", func(t *testing.T) {
+		tst.BaselineRunID = rids[4]
+
+		b, err := dao.FindBaseline(tst, 0)
+		assert.Equal(t, ErrBaselineRunNotInTest, err)
+		assert.Nil(t, b)
+	})
+
+	t.Run("pinned run not found", func(t *testing.T) {
+		tst.BaselineRunID = 4321
+
+		b, err := dao.FindBaseline(tst, 0)
+		assert.NoError(t, err)
+		assert.Nil(t, b)
+	})
+
+	t.Run("last passing runs", func(t *testing.T) {
+		tst.BaselineRunID = 0
+		tst.BaselineRuns = 2
+
+		b, err := dao.FindBaseline(tst, rids[3])
+		assert.NoError(t, err)
+		assert.Equal(t, &Baseline{
+			RunIDs: []uint{rids[1], rids[0]},
+			Values: map[Threshold]float64{
+				ThresholdMean:    float64(1500 * time.Microsecond),
+				ThresholdMedian:  float64(1500 * time.Microsecond),
+				Threshold("p99"): 15,
+			},
+		}, b)
+	})
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// BatchResult is the result of creating a batch of details
+type BatchResult struct {
+	// Number of successfully created details
+	Created uint
+
+	// Number of details that failed to be created
+	Failed uint
+
+	// Errors of the chunks that failed
+	Errors []*ChunkError
+}
+
+// ChunkError is the error of a chunk of details that failed to be created.
+// Chunks are created in separate transactions so none of its details are created.
+type ChunkError struct {
+	// Index of the first detail of the chunk within the batch
+	Offset int
+
+	// Number of details in the chunk
+	Size int
+
+	// The cause
+	Err error
+}
+
+// Error returns the description of the chunk error
+func (e *ChunkError) Error() string {
+	return "Chunk of details " + strconv.Itoa(e.Offset) + "-" + strconv.Itoa(e.Offset+e.Size-1) +
+		" failed: " + e.Err.Error()
+}
+
+// detailColumns are the columns set when inserting details in bulk
+var detailColumns = []string{"created_at", "updated_at", "run_id", "timestamp", "latency", "error", "status"}
+
+// detailValues returns the values of the detail columns
+func detailValues(d *Detail) []interface{} {
+	return []interface{}{d.CreatedAt, d.UpdatedAt, d.RunID, d.Timestamp, d.Latency, d.Error, d.Status}
+}
+
+// maxBindVars is the maximum number of bind variables of a single statement
+var maxBindVars = map[string]int{
+	"mssql": 2100 - 1,
+	"mysql": 65535,
+}
+
+// maxStmtBytes is the estimated size that statements with multiple rows of values are kept under,
+// well below the default max_allowed_packet of mysql
+const maxStmtBytes = 1 << 20
+
+// detailRowBytes is the estimated size of a row of detail values without its strings,
+// along with its placeholders in the statement
+const detailRowBytes = 128
+
+// detailBytes estimates the size of the detail as a row of values of a statement
+func detailBytes(d *Detail) int {
+	return detailRowBytes + len(d.Error) + len(d.Status)
+}
+
+// detailInserter inserts a chunk of details within a transaction
+type detailInserter func(tx *gorm.DB, s []*Detail) error
+
+// getDetailInserter returns the fastest way of inserting details for the dialect
+func getDetailInserter(dialect string) detailInserter {
+	switch dialect {
+	case "postgres":
+		return copyDetails
+	case "sqlite3":
+		return preparedInsertDetails
+	default:
+		max, ok := maxBindVars[dialect]
+		if !ok {
+			max = maxBindVars["mssql"]
+		}
+
+		return multiValuesInserter(max / len(detailColumns))
+	}
+}
+
+// copyDetails inserts the details using the postgres COPY protocol
+func copyDetails(tx *gorm.DB, s []*Detail) error {
+	sqlTx, ok := tx.CommonDB().(*sql.Tx)
+	if !ok {
+		return errors.New("COPY requires a transaction")
+	}
+
+	stmt, err := sqlTx.Prepare(pq.CopyIn("details", detailColumns...))
+	if err != nil {
+		return err
+	}
+
+	for _, d := range s {
+		if _, err = stmt.Exec(detailValues(d)...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+
+	// flush the buffered rows
+	if _, err = stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+
+	return stmt.Close()
+}
+
+// preparedInsertDetails inserts the details one at a time using a single prepared statement
+func preparedInsertDetails(tx *gorm.DB, s []*Detail) error {
+	stmt, err := tx.CommonDB().Prepare(insertDetailsSQL(tx, 1))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, d := range s {
+		if _, err = stmt.Exec(detailValues(d)...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// multiValuesInserter returns an inserter of details using statements with
+// multiple rows of values, with at most rowsPerStmt rows per statement.
+// The rows of a statement are also kept under maxStmtBytes.
+func multiValuesInserter(rowsPerStmt int) detailInserter {
+	return func(tx *gorm.DB, s []*Detail) error {
+		for len(s) > 0 {
+			n := stmtRows(s, rowsPerStmt)
+
+			values := make([]interface{}, 0, n*len(detailColumns))
+			for _, d := range s[:n] {
+				values = append(values, detailValues(d)...)
+			}
+
+			// gorm replaces the ? placeholders with the bind variables of the dialect
+			if err := tx.Exec(insertDetailsSQL(tx, n), values...).Error; err != nil {
+				return err
+			}
+
+			s = s[n:]
+		}
+
+		return nil
+	}
+}
+
+// stmtRows returns the number of details inserted by the next statement. It inserts at most
+// rowsPerStmt details that fit within maxStmtBytes, and at least one detail.
+func stmtRows(s []*Detail, rowsPerStmt int) int {
+	n, size := 0, 0
+	for n < len(s) && n < rowsPerStmt {
+		size += detailBytes(s[n])
+		if n > 0 && size > maxStmtBytes {
+			break
+		}
+
+		n++
+	}
+
+	return n
+}
+
+// insertDetailsSQL returns the statement inserting the number of rows of details
+func insertDetailsSQL(tx *gorm.DB, rows int) string {
+	cols := make([]string, len(detailColumns))
+	vars := make([]string, len(detailColumns))
+	for i, c := range detailColumns {
+		cols[i] = tx.Dialect().Quote(c)
+		vars[i] = "?"
+	}
+
+	row := "(" + strings.Join(vars, ",") + ")"
+
+	values := make([]string, rows)
+	for i := range values {
+		values[i] = row
+	}
+
+	return "INSERT INTO " + tx.Dialect().Quote("details") +
+		" (" + strings.Join(cols, ",") + ") VALUES " + strings.Join(values, ",")
+}
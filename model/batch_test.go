@@ -0,0 +1,98 @@
+package model
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/mssql"
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// benchDetails is the number of details created per benchmark operation
+const benchDetails = 10000
+
+// benchDatabases are the databases to benchmark against. Databases other than sqlite
+// are only benchmarked if their connection string is set in the environment variable.
+var benchDatabases = []struct {
+	dialect string
+	env     string
+}{
+	{"sqlite3", ""},
+	{"postgres", "GHZ_BENCH_POSTGRES"},
+	{"mysql", "GHZ_BENCH_MYSQL"},
+	{"mssql", "GHZ_BENCH_MSSQL"},
+}
+
+func openBenchDB(b *testing.B, dialect, env string) *gorm.DB {
+	conn := dbName
+	if env != "" {
+		conn = os.Getenv(env)
+		if conn == "" {
+			b.Skip("set " + env + " to benchmark " + dialect)
+		}
+	}
+
+	db, err := gorm.Open(dialect, conn)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &LatencyDistribution{}, &Bucket{}, &StatusChange{})
+
+	if dialect == "sqlite3" {
+		db.Exec("PRAGMA foreign_keys = ON;")
+	}
+
+	return db
+}
+
+func BenchmarkDetailService_CreateBatch(b *testing.B) {
+	for _, bdb := range benchDatabases {
+		b.Run(bdb.dialect, func(b *testing.B) {
+			if bdb.dialect == "sqlite3" {
+				defer os.Remove(dbName)
+			}
+
+			db := openBenchDB(b, bdb.dialect, bdb.env)
+			defer db.Close()
+
+			r := &Run{Test: &Test{Project: &Project{}}}
+			if err := db.Create(r).Error; err != nil {
+				b.Fatal(err)
+			}
+
+			// the benchmark results are hard deleted, as the trash would keep them
+			defer transact(db, func(tx *gorm.DB) error {
+				return cascadeProjects(tx, deleteWhere, new(CascadeResult), "id = ?", r.Test.ProjectID)
+			})
+
+			for _, size := range []uint{100, 1000, 5000} {
+				b.Run("batch size "+strconv.FormatUint(uint64(size), 10), func(b *testing.B) {
+					ds := &DetailService{DB: db, Config: &config.DBConfig{Type: bdb.dialect, BatchSize: size}}
+
+					s := make([]*Detail, benchDetails)
+
+					b.ResetTimer()
+
+					for i := 0; i < b.N; i++ {
+						for n := range s {
+							s[n] = &Detail{Timestamp: time.Now(), Latency: float64(n)}
+						}
+
+						res := ds.CreateBatch(r.ID, s)
+						if res.Failed > 0 {
+							b.Fatal(res.Errors[0])
+						}
+					}
+
+					b.ReportMetric(float64(b.N*benchDetails)/b.Elapsed().Seconds(), "details/s")
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package model
+
+import (
+	"math"
+	"sort"
+)
+
+// ErrorChange is the kind of change of an error or status code between two runs
+type ErrorChange string
+
+const (
+	// ErrorNew means the error only occurred in the target run
+	ErrorNew = ErrorChange("new")
+
+	// ErrorGone means the error only occurred in the base run
+	ErrorGone = ErrorChange("gone")
+
+	// ErrorChanged means the error occurred in both runs with a different count
+	ErrorChanged = ErrorChange("changed")
+
+	// ErrorUnchanged means the error occurred in both runs with the same count
+	ErrorUnchanged = ErrorChange("unchanged")
+)
+
+// Delta is the difference of a value between the base and the target run
+type Delta struct {
+	// The base run value
+	Base float64 `json:"base"`
+
+	// The target run value
+	Target float64 `json:"target"`
+
+	// The target value minus the base value
+	Diff float64 `json:"diff"`
+
+	// Change of the target value relative to the base value as a percentage
+	Change float64 `json:"change"`
+}
+
+// PercentileDelta is the difference of a latency distribution percentile
+type PercentileDelta struct {
+	Percentage int `json:"percentage"`
+	Delta
+}
+
+// HistogramOverlay is a histogram bucket holding the counts of both runs for a shared mark
+type HistogramOverlay struct {
+	// The shared mark for the histogram bucket in seconds
+	Mark float64 `json:"mark"`
+
+	BaseCount       int     `json:"baseCount"`
+	TargetCount     int     `json:"targetCount"`
+	BaseFrequency   float64 `json:"baseFrequency"`
+	TargetFrequency float64 `json:"targetFrequency"`
+}
+
+// ErrorDelta is the difference in the count of an error or status code
+type ErrorDelta struct {
+	Key    string      `json:"key"`
+	Base   int         `json:"base"`
+	Target int         `json:"target"`
+	Diff   int         `json:"diff"`
+	Change ErrorChange `json:"change"`
+}
+
+// RunComparison is the comparison of a target run against a base run.
+// The runs may belong to different tests.
+type RunComparison struct {
+	BaseRunID    uint `json:"baseRunID"`
+	BaseTestID   uint `json:"baseTestID"`
+	TargetRunID  uint `json:"targetRunID"`
+	TargetTestID uint `json:"targetTestID"`
+
+	// Deltas of the summary fields. Durations are in nanoseconds.
+	Summary map[string]*Delta `json:"summary"`
+
+	LatencyDistribution []*PercentileDelta  `json:"latencyDistribution"`
+	Histogram           []*HistogramOverlay `json:"histogram"`
+
+	ErrorDist      []*ErrorDelta `json:"errorDistribution"`
+	StatusCodeDist []*ErrorDelta `json:"statusCodeDistribution"`
+
+	// Errors that only occurred in the target run
+	NewErrors []string `json:"newErrors"`
+
+	// Errors that only occurred in the base run
+	GoneErrors []string `json:"goneErrors"`
+}
+
+// CompareRuns compares the target run against the base run.
+// Both runs are expected to be completed, as the summaries of running and aborted runs are partial.
+func CompareRuns(base, target *Run) *RunComparison {
+	rc := &RunComparison{
+		BaseRunID:    base.ID,
+		BaseTestID:   base.TestID,
+		TargetRunID:  target.ID,
+		TargetTestID: target.TestID,
+		Summary: map[string]*Delta{
+			"count":     newDelta(float64(base.Count), float64(target.Count)),
+			"total":     newDelta(float64(base.Total), float64(target.Total)),
+			"average":   newDelta(float64(base.Average), float64(target.Average)),
+			"fastest":   newDelta(float64(base.Fastest), float64(target.Fastest)),
+			"slowest":   newDelta(float64(base.Slowest), float64(target.Slowest)),
+			"rps":       newDelta(base.Rps, target.Rps),
+			"errorRate": newDelta(base.GetErrorRate(), target.GetErrorRate()),
+		},
+		LatencyDistribution: compareLatencyDistribution(base.LatencyDistribution, target.LatencyDistribution),
+		Histogram:           overlayHistograms(base.Histogram, target.Histogram),
+		ErrorDist:           compareDistribution(base.ErrorDist, target.ErrorDist),
+		StatusCodeDist:      compareDistribution(base.StatusCodeDist, target.StatusCodeDist),
+		NewErrors:           make([]string, 0),
+		GoneErrors:          make([]string, 0),
+	}
+
+	for _, ed := range rc.ErrorDist {
+		if ed.Change == ErrorNew {
+			rc.NewErrors = append(rc.NewErrors, ed.Key)
+		} else if ed.Change == ErrorGone {
+			rc.GoneErrors = append(rc.GoneErrors, ed.Key)
+		}
+	}
+
+	return rc
+}
+
+func newDelta(base, target float64) *Delta {
+	d := &Delta{Base: base, Target: target, Diff: target - base}
+	if base != 0 {
+		d.Change = d.Diff * 100 / base
+	}
+
+	return d
+}
+
+func compareLatencyDistribution(base, target []*LatencyDistribution) []*PercentileDelta {
+	values := make(map[int][2]float64)
+
+	for _, l := range base {
+		if l != nil {
+			v := values[l.Percentage]
+			v[0] = float64(l.Latency)
+			values[l.Percentage] = v
+		}
+	}
+
+	for _, l := range target {
+		if l != nil {
+			v := values[l.Percentage]
+			v[1] = float64(l.Latency)
+			values[l.Percentage] = v
+		}
+	}
+
+	deltas := make([]*PercentileDelta, 0, len(values))
+	for p, v := range values {
+		deltas = append(deltas, &PercentileDelta{Percentage: p, Delta: *newDelta(v[0], v[1])})
+	}
+
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Percentage < deltas[j].Percentage })
+
+	return deltas
+}
+
+// overlayHistograms re-buckets both histograms onto evenly spaced marks spanning the
+// marks of both runs. Each bucket's mark is its upper bound, so the count of a source
+// bucket is added to the first shared bucket whose mark is not below the source mark.
+func overlayHistograms(base, target []*Bucket) []*HistogramOverlay {
+	n := 0
+	min, max := math.Inf(1), math.Inf(-1)
+
+	for _, buckets := range [][]*Bucket{base, target} {
+		if len(buckets) > n {
+			n = len(buckets)
+		}
+
+		for _, b := range buckets {
+			if b != nil {
+				min = math.Min(min, b.Mark)
+				max = math.Max(max, b.Mark)
+			}
+		}
+	}
+
+	overlay := make([]*HistogramOverlay, 0, n)
+	if math.IsInf(min, 1) {
+		return overlay
+	}
+
+	if min == max {
+		n = 1
+	}
+
+	size := 0.0
+	if n > 1 {
+		size = (max - min) / float64(n-1)
+	}
+
+	for i := 0; i < n; i++ {
+		overlay = append(overlay, &HistogramOverlay{Mark: min + size*float64(i)})
+	}
+	overlay[n-1].Mark = max
+
+	index := func(mark float64) int {
+		return sort.Search(n, func(i int) bool { return overlay[i].Mark >= mark })
+	}
+
+	baseTotal, targetTotal := 0, 0
+
+	for _, b := range base {
+		if b != nil {
+			overlay[index(b.Mark)].BaseCount += b.Count
+			baseTotal += b.Count
+		}
+	}
+
+	for _, b := range target {
+		if b != nil {
+			overlay[index(b.Mark)].TargetCount += b.Count
+			targetTotal += b.Count
+		}
+	}
+
+	for _, o := range overlay {
+		if baseTotal > 0 {
+			o.BaseFrequency = float64(o.BaseCount) / float64(baseTotal)
+		}
+
+		if targetTotal > 0 {
+			o.TargetFrequency = float64(o.TargetCount) / float64(targetTotal)
+		}
+	}
+
+	return overlay
+}
+
+func compareDistribution(base, target map[string]int) []*ErrorDelta {
+	deltas := make([]*ErrorDelta, 0, len(base)+len(target))
+
+	for key, n := range base {
+		ed := &ErrorDelta{Key: key, Base: n, Change: ErrorGone}
+
+		if tn, ok := target[key]; ok {
+			ed.Target = tn
+			ed.Change = ErrorChanged
+			if tn == n {
+				ed.Change = ErrorUnchanged
+			}
+		}
+
+		ed.Diff = ed.Target - ed.Base
+		deltas = append(deltas, ed)
+	}
+
+	for key, n := range target {
+		if _, ok := base[key]; !ok {
+			deltas = append(deltas, &ErrorDelta{Key: key, Target: n, Diff: n, Change: ErrorNew})
+		}
+	}
+
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Key < deltas[j].Key })
+
+	return deltas
+}
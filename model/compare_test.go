@@ -0,0 +1,124 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareRuns(t *testing.T) {
+	base := &Run{
+		Model:     Model{ID: 1},
+		TestID:    10,
+		Count:     200,
+		Average:   milli2,
+		Slowest:   milli4,
+		Rps:       500,
+		ErrorDist: map[string]int{"timeout": 2, "unavailable": 2},
+		LatencyDistribution: []*LatencyDistribution{
+			&LatencyDistribution{Percentage: 50, Latency: milli2},
+			&LatencyDistribution{Percentage: 95, Latency: milli4},
+		},
+		Histogram: []*Bucket{
+			&Bucket{Mark: 0.001, Count: 100},
+			&Bucket{Mark: 0.002, Count: 50},
+			&Bucket{Mark: 0.004, Count: 50},
+		},
+	}
+
+	target := &Run{
+		Model:          Model{ID: 2},
+		TestID:         20,
+		Count:          100,
+		Average:        milli3,
+		Slowest:        milli4,
+		Rps:            0,
+		ErrorDist:      map[string]int{"timeout": 2, "internal": 3},
+		StatusCodeDist: map[string]int{"OK": 95},
+		LatencyDistribution: []*LatencyDistribution{
+			&LatencyDistribution{Percentage: 50, Latency: milli3},
+			&LatencyDistribution{Percentage: 99, Latency: milli5},
+		},
+		Histogram: []*Bucket{
+			&Bucket{Mark: 0.002, Count: 25},
+			&Bucket{Mark: 0.007, Count: 75},
+		},
+	}
+
+	rc := CompareRuns(base, target)
+
+	t.Run("ids", func(t *testing.T) {
+		assert.Equal(t, uint(1), rc.BaseRunID)
+		assert.Equal(t, uint(10), rc.BaseTestID)
+		assert.Equal(t, uint(2), rc.TargetRunID)
+		assert.Equal(t, uint(20), rc.TargetTestID)
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		assert.Equal(t, &Delta{Base: 200, Target: 100, Diff: -100, Change: -50}, rc.Summary["count"])
+		assert.Equal(t, &Delta{Base: float64(milli2), Target: float64(milli3), Diff: float64(milli1), Change: 50},
+			rc.Summary["average"])
+		assert.Equal(t, &Delta{Base: float64(milli4), Target: float64(milli4)}, rc.Summary["slowest"])
+		assert.Equal(t, &Delta{Base: 500, Diff: -500, Change: -100}, rc.Summary["rps"])
+		assert.Equal(t, &Delta{}, rc.Summary["fastest"])
+		assert.Equal(t, &Delta{Base: 2, Target: 5, Diff: 3, Change: 150}, rc.Summary["errorRate"])
+	})
+
+	t.Run("latency distribution", func(t *testing.T) {
+		assert.Equal(t, []*PercentileDelta{
+			{Percentage: 50, Delta: Delta{Base: float64(milli2), Target: float64(milli3), Diff: float64(milli1), Change: 50}},
+			{Percentage: 95, Delta: Delta{Base: float64(milli4), Diff: -float64(milli4), Change: -100}},
+			{Percentage: 99, Delta: Delta{Target: float64(milli5), Diff: float64(milli5)}},
+		}, rc.LatencyDistribution)
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		assert.Len(t, rc.Histogram, 3)
+
+		marks := []float64{0.001, 0.004, 0.007}
+		baseCounts := []int{100, 100, 0}
+		targetCounts := []int{0, 25, 75}
+
+		for i, o := range rc.Histogram {
+			assert.InDelta(t, marks[i], o.Mark, 0.0000001)
+			assert.Equal(t, baseCounts[i], o.BaseCount)
+			assert.Equal(t, targetCounts[i], o.TargetCount)
+		}
+
+		assert.Equal(t, 0.5, rc.Histogram[0].BaseFrequency)
+		assert.Equal(t, 0.75, rc.Histogram[2].TargetFrequency)
+	})
+
+	t.Run("error distribution", func(t *testing.T) {
+		assert.Equal(t, []*ErrorDelta{
+			{Key: "internal", Target: 3, Diff: 3, Change: ErrorNew},
+			{Key: "timeout", Base: 2, Target: 2, Change: ErrorUnchanged},
+			{Key: "unavailable", Base: 2, Diff: -2, Change: ErrorGone},
+		}, rc.ErrorDist)
+
+		assert.Equal(t, []string{"internal"}, rc.NewErrors)
+		assert.Equal(t, []string{"unavailable"}, rc.GoneErrors)
+
+		assert.Equal(t, []*ErrorDelta{
+			{Key: "OK", Target: 95, Diff: 95, Change: ErrorNew},
+		}, rc.StatusCodeDist)
+	})
+
+	t.Run("empty runs", func(t *testing.T) {
+		rc := CompareRuns(&Run{}, &Run{})
+
+		assert.Empty(t, rc.LatencyDistribution)
+		assert.Empty(t, rc.Histogram)
+		assert.Empty(t, rc.ErrorDist)
+		assert.Empty(t, rc.NewErrors)
+	})
+
+	t.Run("single mark", func(t *testing.T) {
+		rc := CompareRuns(&Run{Histogram: []*Bucket{&Bucket{Mark: 0.5, Count: 3}}},
+			&Run{Histogram: []*Bucket{&Bucket{Mark: 0.5, Count: 1}, &Bucket{Mark: 0.5, Count: 1}}})
+
+		assert.Equal(t, []*HistogramOverlay{
+			{Mark: 0.5, BaseCount: 3, TargetCount: 2, BaseFrequency: 1, TargetFrequency: 1},
+		}, rc.Histogram)
+	})
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// CascadeResult holds the number of records affected by a cascading
+// delete, restore or purge operation
+type CascadeResult struct {
+	// Number of projects affected
+	Projects uint `json:"projects"`
+
+	// Number of tests affected
+	Tests uint `json:"tests"`
+
+	// Number of runs affected
+	Runs uint `json:"runs"`
+
+	// Number of latency distribution records affected
+	LatencyDistributions uint `json:"latencyDistributions"`
+
+	// Number of histogram buckets affected
+	Buckets uint `json:"buckets"`
+
+	// Number of details affected
+	Details uint `json:"details"`
+
+	// Number of status history records affected
+	StatusChanges uint `json:"statusChanges"`
+}
+
+// Add adds the counts of another result to this one
+func (cr *CascadeResult) Add(o *CascadeResult) {
+	cr.Projects += o.Projects
+	cr.Tests += o.Tests
+	cr.Runs += o.Runs
+	cr.LatencyDistributions += o.LatencyDistributions
+	cr.Buckets += o.Buckets
+	cr.Details += o.Details
+	cr.StatusChanges += o.StatusChanges
+}
+
+// transact executes fn within a transaction, committing if fn returns no error
+// and rolling back otherwise. If db is already a transaction fn is executed within it,
+// leaving the commit or rollback to the owner of the transaction.
+func transact(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if _, ok := db.CommonDB().(*sql.Tx); ok {
+		return fn(db)
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// rowsOp is an operation applied to all records of the model's table
+// matching the query, returning the number of rows affected
+type rowsOp func(tx *gorm.DB, m interface{}, query string, args ...interface{}) (uint, error)
+
+// deleteWhere hard deletes all matching records
+func deleteWhere(tx *gorm.DB, m interface{}, query string, args ...interface{}) (uint, error) {
+	res := tx.Unscoped().Where(query, args...).Delete(m)
+	return uint(res.RowsAffected), res.Error
+}
+
+// trashOp returns an operation that soft deletes all matching records
+// that are not already in the trash
+func trashOp(deletedAt time.Time) rowsOp {
+	return func(tx *gorm.DB, m interface{}, query string, args ...interface{}) (uint, error) {
+		res := tx.Model(m).Where(query, args...).UpdateColumn("deleted_at", deletedAt)
+		return uint(res.RowsAffected), res.Error
+	}
+}
+
+// restoreOp returns an operation that restores all matching records
+// moved to the trash at or after the given time
+func restoreOp(deletedAt time.Time) rowsOp {
+	return func(tx *gorm.DB, m interface{}, query string, args ...interface{}) (uint, error) {
+		res := tx.Unscoped().Model(m).Where(query, args...).
+			Where("deleted_at >= ?", deletedAt).
+			UpdateColumn("deleted_at", gorm.Expr("NULL"))
+		return uint(res.RowsAffected), res.Error
+	}
+}
+
+// cascadeRuns applies op to runs matching the condition along with their
+// details, latency distributions and histogram buckets
+func cascadeRuns(tx *gorm.DB, op rowsOp, res *CascadeResult, runCond string, args ...interface{}) error {
+	dataCond := "run_id IN (SELECT id FROM runs WHERE " + runCond + ")"
+
+	var err error
+
+	if res.Details, err = op(tx, &Detail{}, dataCond, args...); err != nil {
+		return err
+	}
+
+	if res.LatencyDistributions, err = op(tx, &LatencyDistribution{}, dataCond, args...); err != nil {
+		return err
+	}
+
+	if res.Buckets, err = op(tx, &Bucket{}, dataCond, args...); err != nil {
+		return err
+	}
+
+	res.Runs, err = op(tx, &Run{}, runCond, args...)
+
+	return err
+}
+
+// cascadeTests applies op to tests matching the condition along with all of their runs
+// and their status history
+func cascadeTests(tx *gorm.DB, op rowsOp, res *CascadeResult, testCond string, args ...interface{}) error {
+	childCond := "test_id IN (SELECT id FROM tests WHERE " + testCond + ")"
+
+	err := cascadeRuns(tx, op, res, childCond, args...)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusChanges, err = op(tx, &StatusChange{}, childCond, args...); err != nil {
+		return err
+	}
+
+	res.Tests, err = op(tx, &Test{}, testCond, args...)
+
+	return err
+}
+
+// cascadeProjects applies op to projects matching the condition along with all of their tests
+func cascadeProjects(tx *gorm.DB, op rowsOp, res *CascadeResult, projectCond string, args ...interface{}) error {
+	err := cascadeTests(tx, op, res, "project_id IN (SELECT id FROM projects WHERE "+projectCond+")", args...)
+	if err != nil {
+		return err
+	}
+
+	res.Projects, err = op(tx, &Project{}, projectCond, args...)
+
+	return err
+}
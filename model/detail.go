@@ -3,9 +3,7 @@ package model
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/bojand/ghz-web/config"
@@ -118,44 +116,35 @@ func (ds *DetailService) FindByID(id uint) (*Detail, error) {
 	return d, err
 }
 
-// FindByRunID finds tests by project
+// FindByRunID finds details by run
 func (ds *DetailService) FindByRunID(rid, num, page uint) ([]*Detail, error) {
-	r := &Run{}
-	r.ID = rid
-
-	offset := uint(0)
-	if page >= 0 && num >= 0 {
-		offset = page * num
-	}
+	return ds.FindByRunIDQuery(rid, num, page, NewQuery(DetailQueryFields))
+}
 
+// FindByRunIDQuery lists the details of the run matching the query
+func (ds *DetailService) FindByRunIDQuery(rid, num, page uint, q *Query) ([]*Detail, error) {
 	s := make([]*Detail, num)
 
-	err := ds.DB.Offset(offset).Limit(num).Order("id desc").Model(r).Related(&s).Error
+	err := q.find(ds.DB.Where("run_id = ?", rid), "id desc", num, page).Find(&s).Error
 
 	return s, err
 }
 
+// CountQuery returns the number of details of the run matching the query
+func (ds *DetailService) CountQuery(rid uint, q *Query) (uint, error) {
+	count := uint(0)
+	err := q.where(ds.DB.Model(&Detail{}).Where("run_id = ?", rid)).Count(&count).Error
+	return count, err
+}
+
 // FindByRunIDSorted lists details using sorting
 func (ds *DetailService) FindByRunIDSorted(rid, num, page uint, sortField, order string) ([]*Detail, error) {
-	if (sortField != "id" && sortField != "latency") || (order != "asc" && order != "desc") {
-		return nil, errors.New("Invalid sort parameters")
-	}
-
-	offset := uint(0)
-	if page >= 0 && num >= 0 {
-		offset = page * num
+	q, err := sortedQuery(DetailQueryFields, sortField, order)
+	if err != nil {
+		return nil, err
 	}
 
-	orderSQL := sortField + " " + order
-
-	r := &Run{}
-	r.ID = rid
-
-	s := make([]*Detail, num)
-
-	err := ds.DB.Order(orderSQL).Offset(offset).Limit(num).Model(r).Related(&s).Error
-
-	return s, err
+	return ds.FindByRunIDQuery(rid, num, page, q)
 }
 
 // FindByRunIDAll lists details using sorting
@@ -180,73 +169,122 @@ func (ds *DetailService) Update(d *Detail) error {
 	return ds.DB.Save(d).Error
 }
 
-// Delete deletes a detial
-func (ds *DetailService) Delete(r *Detail) error {
-	return errors.New("Not Implemented Yet")
+// Delete deletes a detail
+func (ds *DetailService) Delete(d *Detail) error {
+	n, err := deleteWhere(ds.DB, &Detail{}, "id = ?", d.ID)
+	if err == nil && n == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+
+	return err
 }
 
 // DeleteAll deletes all details for a run
-func (ds *DetailService) DeleteAll(rid uint) error {
-	return errors.New("Not Implemented Yet")
+func (ds *DetailService) DeleteAll(rid uint) (*CascadeResult, error) {
+	res := new(CascadeResult)
+
+	var err error
+	if res.Details, err = deleteWhere(ds.DB, &Detail{}, "run_id = ?", rid); err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
 
-// CreateBatch creates a batch of details returning the number successfully created,
-// and the number failed
-func (ds *DetailService) CreateBatch(rid uint, s []*Detail) (uint, uint) {
-	nReq := len(s)
+// Summarize sets the summary of the run computed from its stored details and returns the
+// latencies of the calls. The details are read in batches of the configured batch size.
+// The run is left unchanged if it has no details.
+func (ds *DetailService) Summarize(r *Run) ([]float64, error) {
+	size := config.DefaultBatchSize
+	if ds.Config != nil {
+		size = ds.Config.GetBatchSize()
+	}
 
-	if ds.Config.GetDialect() == "sqlite3" {
-		// for sqlite do db requests in series
+	s := NewSummarizer(0)
+	var latencies []float64
 
-		var nCreated, errCount uint
+	lastID := uint(0)
 
-		for _, d := range s {
-			d.RunID = rid
-			err := ds.Create(d)
+	for {
+		var details []*Detail
+		err := ds.DB.Where("run_id = ? AND id > ?", r.ID, lastID).Order("id asc").Limit(size).Find(&details).Error
+		if err != nil {
+			return nil, err
+		}
 
-			if err != nil {
-				fmt.Println(err)
-				errCount++
-			}
+		for _, d := range details {
+			s.Add(d)
+			latencies = append(latencies, d.Latency)
 		}
 
-		nCreated = uint(nReq) - errCount
+		if len(details) < size {
+			break
+		}
+
+		lastID = details[len(details)-1].ID
+	}
 
-		return nCreated, errCount
+	if s.Count() > 0 {
+		s.Summarize(r)
 	}
 
-	NC := 10
+	return latencies, nil
+}
+
+// CreateBatch creates a batch of details for the run. The details are inserted in chunks
+// of the configured batch size, each in its own transaction. The result holds the number
+// of details created and failed, along with the error of each chunk that failed.
+func (ds *DetailService) CreateBatch(rid uint, s []*Detail) *BatchResult {
+	size := config.DefaultBatchSize
+	if ds.Config != nil {
+		size = ds.Config.GetBatchSize()
+	}
 
-	var nErr uint32
-	nErr = 0
+	insert := getDetailInserter(ds.DB.Dialect().GetName())
 
-	sem := make(chan bool, NC)
+	res := new(BatchResult)
 
-	var nCreated, errCount uint
-	errCount = 0
+	for offset := 0; offset < len(s); offset += size {
+		end := offset + size
+		if end > len(s) {
+			end = len(s)
+		}
 
-	for _, item := range s {
-		sem <- true
+		chunk := s[offset:end]
 
-		go func(d *Detail) {
-			defer func() { <-sem }()
+		err := prepareDetails(rid, chunk)
+		if err == nil {
+			err = transact(ds.DB, func(tx *gorm.DB) error {
+				return insert(tx, chunk)
+			})
+		}
 
-			d.RunID = rid
-			err := ds.Create(d)
+		if err != nil {
+			res.Failed += uint(len(chunk))
+			res.Errors = append(res.Errors, &ChunkError{Offset: offset, Size: len(chunk), Err: err})
+			continue
+		}
 
-			if err != nil {
-				fmt.Println(err)
-				atomic.AddUint32(&nErr, 1)
-			}
-		}(item)
+		res.Created += uint(len(chunk))
 	}
 
-	for i := 0; i < cap(sem); i++ {
-		sem <- true
-	}
+	return res
+}
 
-	errCount = uint(atomic.LoadUint32(&nErr))
-	nCreated = uint(nReq) - errCount
+// prepareDetails sets the run and timestamps of the details and normalizes them
+// as they would be by GORM hooks, which are not called when inserting in bulk
+func prepareDetails(rid uint, s []*Detail) error {
+	now := gorm.NowFunc()
 
-	return nCreated, errCount
+	for _, d := range s {
+		d.RunID = rid
+		d.CreatedAt = now
+		d.UpdatedAt = now
+
+		if err := d.BeforeSave(nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
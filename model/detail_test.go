@@ -2,6 +2,7 @@ package model
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -321,10 +322,11 @@ func TestDetailService_CreateBatch(t *testing.T) {
 			s[n] = nd
 		}
 
-		created, errored := dao.CreateBatch(rid, s)
+		res := dao.CreateBatch(rid, s)
 
-		assert.Equal(t, M, int(created))
-		assert.Equal(t, 0, int(errored))
+		assert.Equal(t, M, int(res.Created))
+		assert.Equal(t, 0, int(res.Failed))
+		assert.Empty(t, res.Errors)
 	})
 
 	t.Run("fail create batch of details for unknown run id", func(t *testing.T) {
@@ -341,13 +343,118 @@ func TestDetailService_CreateBatch(t *testing.T) {
 			s[n] = nd
 		}
 
-		created, errored := dao.CreateBatch(3213, s)
+		res := dao.CreateBatch(3213, s)
 
-		assert.Equal(t, 0, int(created))
-		assert.Equal(t, M, int(errored))
+		assert.Equal(t, 0, int(res.Created))
+		assert.Equal(t, M, int(res.Failed))
+		assert.Len(t, res.Errors, 1)
+	})
+
+	t.Run("create batch of details in chunks", func(t *testing.T) {
+		dao := DetailService{DB: db, Config: &config.DBConfig{Type: "sqlite3", BatchSize: 40}}
+
+		M := 100
+		s := make([]*Detail, M)
+
+		for n := 0; n < M; n++ {
+			s[n] = &Detail{
+				Latency: 1000.0 + float64(n),
+				Error:   " some error ",
+			}
+		}
+
+		before, err := dao.Count(rid)
+		assert.NoError(t, err)
+
+		res := dao.CreateBatch(rid, s)
+
+		assert.Equal(t, M, int(res.Created))
+		assert.Equal(t, 0, int(res.Failed))
+		assert.Empty(t, res.Errors)
+
+		after, err := dao.Count(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, before+uint(M), after)
+
+		details, err := dao.FindByRunIDSorted(rid, 1, 0, "latency", "desc")
+		assert.NoError(t, err)
+		assert.Len(t, details, 1)
+		assert.Equal(t, rid, details[0].RunID)
+		assert.Equal(t, 1099.0, details[0].Latency)
+		assert.Equal(t, "some error", details[0].Error)
+		assert.Equal(t, "OK", details[0].Status)
+		assert.False(t, details[0].CreatedAt.IsZero())
+	})
+
+	t.Run("reports errors per chunk", func(t *testing.T) {
+		dao := DetailService{DB: db, Config: &config.DBConfig{Type: "sqlite3", BatchSize: 40}}
+
+		M := 100
+		s := make([]*Detail, M)
+
+		for n := 0; n < M; n++ {
+			s[n] = &Detail{Latency: 100.0 + float64(n)}
+		}
+
+		res := dao.CreateBatch(3213, s)
+
+		assert.Equal(t, 0, int(res.Created))
+		assert.Equal(t, M, int(res.Failed))
+		assert.Len(t, res.Errors, 3)
+
+		assert.Equal(t, 0, res.Errors[0].Offset)
+		assert.Equal(t, 40, res.Errors[0].Size)
+		assert.Equal(t, 40, res.Errors[1].Offset)
+		assert.Equal(t, 40, res.Errors[1].Size)
+		assert.Equal(t, 80, res.Errors[2].Offset)
+		assert.Equal(t, 20, res.Errors[2].Size)
+		assert.Contains(t, res.Errors[2].Error(), "Chunk of details 80-99 failed: ")
+	})
+
+	t.Run("inserts multiple rows per statement", func(t *testing.T) {
+		M := 25
+		s := make([]*Detail, M)
+
+		for n := 0; n < M; n++ {
+			s[n] = &Detail{Latency: 500.0 + float64(n)}
+		}
+
+		err := prepareDetails(rid, s)
+		assert.NoError(t, err)
+
+		before, err := dao.Count(rid)
+		assert.NoError(t, err)
+
+		err = transact(db, func(tx *gorm.DB) error {
+			return multiValuesInserter(10)(tx, s)
+		})
+		assert.NoError(t, err)
+
+		after, err := dao.Count(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, before+uint(M), after)
 	})
 }
 
+func TestStmtRows(t *testing.T) {
+	small := make([]*Detail, 100)
+	for n := range small {
+		small[n] = &Detail{Latency: 500.0, Status: "OK"}
+	}
+
+	large := make([]*Detail, 5)
+	for n := range large {
+		large[n] = &Detail{Latency: 500.0, Error: strings.Repeat("e", maxStmtBytes/3)}
+	}
+
+	huge := []*Detail{{Latency: 500.0, Error: strings.Repeat("e", maxStmtBytes)}}
+
+	assert.Equal(t, 10, stmtRows(small, 10))
+	assert.Equal(t, 100, stmtRows(small, 1000))
+	assert.Equal(t, 2, stmtRows(large, 1000))
+	assert.Equal(t, 1, stmtRows(huge, 1000))
+}
+
 func TestDetailService_Count(t *testing.T) {
 	defer os.Remove(dbName)
 
@@ -1063,3 +1170,87 @@ func TestDetailService_Update(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestDetailService_Delete(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	dao := DetailService{DB: db, Config: &config.DBConfig{Type: "sqlite3"}}
+	var rid, did uint
+
+	t.Run("new details with run, test and project", func(t *testing.T) {
+		r := &Run{
+			Test: &Test{Project: &Project{}},
+		}
+
+		for n := 0; n < 5; n++ {
+			d := &Detail{
+				Run:     r,
+				Latency: 100.0 + float64(n),
+				Status:  "OK",
+			}
+
+			err := dao.Create(d)
+			assert.NoError(t, err)
+
+			did = d.ID
+		}
+
+		rid = r.ID
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		d := &Detail{}
+		d.ID = did
+
+		err := dao.Delete(d)
+		assert.NoError(t, err)
+
+		_, err = dao.FindByID(did)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		count, err := dao.Count(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(4), count)
+	})
+
+	t.Run("fail delete unknown detail", func(t *testing.T) {
+		d := &Detail{}
+		d.ID = 4321
+
+		err := dao.Delete(d)
+		assert.Error(t, err)
+
+		d.ID = 0
+
+		err = dao.Delete(d)
+		assert.Error(t, err)
+
+		count, err := dao.Count(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(4), count)
+	})
+
+	t.Run("delete all", func(t *testing.T) {
+		res, err := dao.DeleteAll(rid)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &CascadeResult{Details: 4}, res)
+
+		count, err := dao.Count(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), count)
+
+		cr := &Run{}
+		err = db.First(cr, rid).Error
+		assert.NoError(t, err)
+	})
+}
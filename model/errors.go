@@ -0,0 +1,327 @@
+package model
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// maxErrorMessages is the maximum number of distinct messages kept as examples of an error group
+const maxErrorMessages = 5
+
+// grpcErrorPattern matches the error strings of gRPC status errors
+var grpcErrorPattern = regexp.MustCompile(`^rpc error: code = (\S+) desc = (?s)(.*)$`)
+
+// errorVariables are the patterns of the variable parts of error messages and their placeholders,
+// in the order they are replaced
+var errorVariables = []struct {
+	pattern     *regexp.Regexp
+	placeholder string
+	match       func(s string) bool
+}{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>", nil},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<addr>", nil},
+	{regexp.MustCompile(`\[[0-9a-fA-F:]*:[0-9a-fA-F:]*\](:\d+)?`), "<addr>", nil},
+	{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<str>", nil},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`), "<hex>", nil},
+	// long hex tokens such as trace ids, which have both digits and letters unlike words and numbers
+	{regexp.MustCompile(`\b[0-9a-fA-F]{8,}\b`), "<hex>", func(s string) bool {
+		return strings.ContainsAny(s, "0123456789") && strings.ContainsAny(s, "abcdefABCDEF")
+	}},
+	{regexp.MustCompile(`\b\d+(\.\d+)?(ns|us|µs|ms|s|m|h)\b`), "<duration>", nil},
+	{regexp.MustCompile(`\b\d+(\.\d+)?\b`), "<n>", nil},
+}
+
+// ErrorKind is a kind of error identified by the status code and the message template
+type ErrorKind struct {
+	// The gRPC status code, empty for errors that are not gRPC status errors
+	Code string `json:"code"`
+
+	// The message with the variable parts such as numbers, addresses and ids replaced by placeholders
+	Template string `json:"template"`
+}
+
+// Key returns the key identifying the kind of error
+func (k ErrorKind) Key() string {
+	if k.Code == "" {
+		return k.Template
+	}
+
+	return k.Code + ": " + k.Template
+}
+
+// ParseError parses the error string into its kind. The code and the message of gRPC status errors
+// such as "rpc error: code = Unavailable desc = ..." are parsed, and the variable parts
+// of the message are replaced by placeholders such as <n>, <addr> and <uuid>.
+func ParseError(msg string) ErrorKind {
+	var k ErrorKind
+
+	msg = strings.TrimSpace(msg)
+
+	if m := grpcErrorPattern.FindStringSubmatch(msg); m != nil {
+		k.Code = m[1]
+		msg = m[2]
+	}
+
+	for _, v := range errorVariables {
+		if v.match == nil {
+			msg = v.pattern.ReplaceAllString(msg, v.placeholder)
+			continue
+		}
+
+		match := v.match
+		placeholder := v.placeholder
+		msg = v.pattern.ReplaceAllStringFunc(msg, func(s string) string {
+			if match(s) {
+				return placeholder
+			}
+
+			return s
+		})
+	}
+
+	k.Template = strings.Join(strings.Fields(msg), " ")
+
+	return k
+}
+
+// ErrorGroup is the group of the errors of a kind
+type ErrorGroup struct {
+	ErrorKind
+
+	// The key identifying the kind of error
+	Key string `json:"key"`
+
+	// The number of errors
+	Count int `json:"count"`
+
+	// Examples of the distinct error messages of the group
+	Messages []string `json:"messages"`
+}
+
+func (g *ErrorGroup) add(msg string, n int) {
+	g.Count += n
+
+	if len(g.Messages) < maxErrorMessages {
+		g.Messages = append(g.Messages, msg)
+	}
+}
+
+// GroupErrors groups the error distribution by the kinds of errors, from the most frequent
+func GroupErrors(errorDist map[string]int) []*ErrorGroup {
+	groups := make(map[string]*ErrorGroup)
+
+	// the messages are added in order so that the examples do not depend on the map order
+	msgs := make([]string, 0, len(errorDist))
+	for msg := range errorDist {
+		msgs = append(msgs, msg)
+	}
+
+	sort.Strings(msgs)
+
+	for _, msg := range msgs {
+		k := ParseError(msg)
+
+		g := groups[k.Key()]
+		if g == nil {
+			g = &ErrorGroup{ErrorKind: k, Key: k.Key(), Messages: make([]string, 0)}
+			groups[g.Key] = g
+		}
+
+		g.add(msg, errorDist[msg])
+	}
+
+	list := make([]*ErrorGroup, 0, len(groups))
+	for _, g := range groups {
+		list = append(list, g)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+
+		return list[i].Key < list[j].Key
+	})
+
+	return list
+}
+
+// RunErrorGroup is a group of the errors of a run
+type RunErrorGroup struct {
+	ErrorGroup
+
+	// Whether the kind of error did not occur in any earlier run of the test
+	New bool `json:"new"`
+}
+
+// RunErrors holds the groups of the errors of a run
+type RunErrors struct {
+	RunID  uint `json:"runID"`
+	TestID uint `json:"testID"`
+
+	// The number of errors
+	Total int `json:"total"`
+
+	// The number of kinds of errors that did not occur in any earlier run of the test
+	New int `json:"new"`
+
+	Groups []*RunErrorGroup `json:"groups"`
+}
+
+// ErrorOccurrence is the occurrence of a kind of error in a run
+type ErrorOccurrence struct {
+	RunID  uint      `json:"runID"`
+	TestID uint      `json:"testID"`
+	Date   time.Time `json:"date"`
+	Count  int       `json:"count"`
+}
+
+// ErrorGroupHistory is the group of the errors of a kind over the runs they occurred in
+type ErrorGroupHistory struct {
+	ErrorGroup
+
+	// The number of runs with the errors
+	Runs int `json:"runs"`
+
+	// The first and the last run with the errors
+	FirstSeen *ErrorOccurrence `json:"firstSeen"`
+	LastSeen  *ErrorOccurrence `json:"lastSeen"`
+
+	// The occurrences of the errors from the oldest run
+	Occurrences []*ErrorOccurrence `json:"occurrences"`
+}
+
+// ErrorReport holds the groups of the errors of the completed runs of a test or a project
+type ErrorReport struct {
+	// The number of runs
+	Runs int `json:"runs"`
+
+	// The number of runs with errors
+	RunsWithErrors int `json:"runsWithErrors"`
+
+	// The number of errors
+	Total int `json:"total"`
+
+	// The groups of errors from the most frequent
+	Groups []*ErrorGroupHistory `json:"groups"`
+}
+
+// ErrorService analyzes the errors of runs
+type ErrorService struct {
+	DB *gorm.DB
+}
+
+// FindRunErrors groups the errors of the run, flagging the kinds of errors that did not occur
+// in any earlier completed run of the test
+func (es *ErrorService) FindRunErrors(r *Run) (*RunErrors, error) {
+	re := &RunErrors{RunID: r.ID, TestID: r.TestID, Groups: make([]*RunErrorGroup, 0)}
+
+	var earlier []*Run
+	err := es.DB.Select("id, error_dist").
+		Where("test_id = ? AND state = ? AND (date < ? OR (date = ? AND id < ?))",
+			r.TestID, RunCompleted, r.Date, r.Date, r.ID).
+		Where("error_dist IS NOT NULL AND error_dist <> ''").
+		Find(&earlier).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, er := range earlier {
+		for msg := range er.ErrorDist {
+			seen[ParseError(msg).Key()] = true
+		}
+	}
+
+	for _, g := range GroupErrors(r.ErrorDist) {
+		rg := &RunErrorGroup{ErrorGroup: *g, New: !seen[g.Key]}
+		if rg.New {
+			re.New++
+		}
+
+		re.Total += g.Count
+		re.Groups = append(re.Groups, rg)
+	}
+
+	return re, nil
+}
+
+// FindTestErrors groups the errors of the completed runs of the test
+func (es *ErrorService) FindTestErrors(tid uint) (*ErrorReport, error) {
+	return es.report("test_id = ?", tid)
+}
+
+// FindProjectErrors groups the errors of the completed runs of all the tests of the project
+func (es *ErrorService) FindProjectErrors(pid uint) (*ErrorReport, error) {
+	return es.report("test_id IN (SELECT id FROM tests WHERE project_id = ? AND deleted_at IS NULL)", pid)
+}
+
+// report groups the errors of the matching completed runs from the oldest
+func (es *ErrorService) report(query string, args ...interface{}) (*ErrorReport, error) {
+	var runs []*Run
+	err := es.DB.Select("id, test_id, date, error_dist").
+		Where(query, args...).Where("state = ?", RunCompleted).
+		Order("date asc, id asc").Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &ErrorReport{Runs: len(runs), Groups: make([]*ErrorGroupHistory, 0)}
+
+	groups := make(map[string]*ErrorGroupHistory)
+
+	for _, r := range runs {
+		if len(r.ErrorDist) > 0 {
+			rep.RunsWithErrors++
+		}
+
+		for _, g := range GroupErrors(r.ErrorDist) {
+			o := &ErrorOccurrence{RunID: r.ID, TestID: r.TestID, Date: r.Date, Count: g.Count}
+
+			h := groups[g.Key]
+			if h == nil {
+				h = &ErrorGroupHistory{
+					ErrorGroup:  ErrorGroup{ErrorKind: g.ErrorKind, Key: g.Key, Messages: make([]string, 0)},
+					FirstSeen:   o,
+					Occurrences: make([]*ErrorOccurrence, 0),
+				}
+
+				groups[g.Key] = h
+				rep.Groups = append(rep.Groups, h)
+			}
+
+			for _, msg := range g.Messages {
+				if len(h.Messages) < maxErrorMessages && !containsString(h.Messages, msg) {
+					h.Messages = append(h.Messages, msg)
+				}
+			}
+
+			h.Count += g.Count
+			h.Runs++
+			h.LastSeen = o
+			h.Occurrences = append(h.Occurrences, o)
+
+			rep.Total += g.Count
+		}
+	}
+
+	sort.SliceStable(rep.Groups, func(i, j int) bool {
+		return rep.Groups[i].Count > rep.Groups[j].Count
+	})
+
+	return rep, nil
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
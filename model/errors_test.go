@@ -0,0 +1,202 @@
+package model
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseError(t *testing.T) {
+	var tests = []struct {
+		name     string
+		in       string
+		expected ErrorKind
+	}{
+		{"grpc",
+			"rpc error: code = Internal desc = Internal error.",
+			ErrorKind{Code: "Internal", Template: "Internal error."}},
+		{"numbers",
+			"rpc error: code = ResourceExhausted desc = grpc: received message larger than max (4194305 vs. 4194304)",
+			ErrorKind{Code: "ResourceExhausted", Template: "grpc: received message larger than max (<n> vs. <n>)"}},
+		{"address",
+			"rpc error: code = Unavailable desc = all SubConns are in TransientFailure, latest connection error: connection error: desc = \"transport: Error while dialing dial tcp 10.0.0.12:50051: connect: connection refused\"",
+			ErrorKind{Code: "Unavailable", Template: "all SubConns are in TransientFailure, latest connection error: connection error: desc = <str>"}},
+		{"unquoted address",
+			"dial tcp 10.0.0.12:50051: connect: connection refused",
+			ErrorKind{Template: "dial tcp <addr>: connect: connection refused"}},
+		{"uuid and duration",
+			"rpc error: code = NotFound desc = user 3f2b8c1e-9a7d-4e6f-8b1a-2c3d4e5f6a7b not found after 1.5s",
+			ErrorKind{Code: "NotFound", Template: "user <uuid> not found after <duration>"}},
+		{"hex",
+			"rpc error: code = Aborted desc = txn 0x1f3a conflict on key 9f86d081884c7d65",
+			ErrorKind{Code: "Aborted", Template: "txn <hex> conflict on key <hex>"}},
+		{"words are kept",
+			"rpc error: code = Unimplemented desc = unknown service helloworld.Greeter2 facade",
+			ErrorKind{Code: "Unimplemented", Template: "unknown service helloworld.Greeter2 facade"}},
+		{"plain",
+			"  context deadline exceeded ",
+			ErrorKind{Template: "context deadline exceeded"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseError(tt.in))
+		})
+	}
+}
+
+func TestErrorKind_Key(t *testing.T) {
+	assert.Equal(t, "Internal: oops", ErrorKind{Code: "Internal", Template: "oops"}.Key())
+	assert.Equal(t, "oops", ErrorKind{Template: "oops"}.Key())
+}
+
+func TestGroupErrors(t *testing.T) {
+	groups := GroupErrors(map[string]int{
+		"rpc error: code = Unavailable desc = connection to 10.0.0.1:80 refused": 2,
+		"rpc error: code = Unavailable desc = connection to 10.0.0.2:80 refused": 3,
+		"rpc error: code = Internal desc = Internal error.":                      4,
+	})
+
+	assert.Len(t, groups, 2)
+
+	assert.Equal(t, "Unavailable: connection to <addr> refused", groups[0].Key)
+	assert.Equal(t, "Unavailable", groups[0].Code)
+	assert.Equal(t, 5, groups[0].Count)
+	assert.Equal(t, []string{
+		"rpc error: code = Unavailable desc = connection to 10.0.0.1:80 refused",
+		"rpc error: code = Unavailable desc = connection to 10.0.0.2:80 refused",
+	}, groups[0].Messages)
+
+	assert.Equal(t, "Internal: Internal error.", groups[1].Key)
+	assert.Equal(t, 4, groups[1].Count)
+
+	assert.Empty(t, GroupErrors(nil))
+}
+
+func TestErrorService(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	es := &ErrorService{DB: db}
+	rs := &RunService{DB: db}
+
+	p := &Project{Name: "errors"}
+	tst := &Test{Name: "errors"}
+	tst2 := &Test{Name: "errors 2"}
+
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	refused := "rpc error: code = Unavailable desc = connection to 10.0.0.1:80 refused"
+	internal := "rpc error: code = Internal desc = Internal error."
+	notFound := "rpc error: code = NotFound desc = user 42 not found"
+
+	var runs []*Run
+
+	t.Run("create runs", func(t *testing.T) {
+		assert.NoError(t, db.Create(p).Error)
+
+		tst.ProjectID = p.ID
+		tst2.ProjectID = p.ID
+		assert.NoError(t, db.Create(tst).Error)
+		assert.NoError(t, db.Create(tst2).Error)
+
+		dists := []map[string]int{
+			{refused: 2},
+			nil,
+			{"rpc error: code = Unavailable desc = connection to 10.0.0.2:80 refused": 1, internal: 3},
+		}
+
+		for i, dist := range dists {
+			r := &Run{TestID: tst.ID, Date: start.Add(time.Duration(i) * time.Hour), Count: 10, ErrorDist: dist}
+			assert.NoError(t, rs.Create(r))
+			runs = append(runs, r)
+		}
+
+		// running runs are not analyzed
+		running := &Run{TestID: tst.ID, Date: start.Add(time.Hour), State: RunRunning, ErrorDist: map[string]int{notFound: 1}}
+		assert.NoError(t, rs.Create(running))
+
+		other := &Run{TestID: tst2.ID, Date: start, Count: 10, ErrorDist: map[string]int{notFound: 5}}
+		assert.NoError(t, rs.Create(other))
+		runs = append(runs, other)
+	})
+
+	t.Run("run errors", func(t *testing.T) {
+		re, err := es.FindRunErrors(runs[0])
+		assert.NoError(t, err)
+		assert.Equal(t, 2, re.Total)
+		assert.Equal(t, 1, re.New)
+		assert.Len(t, re.Groups, 1)
+		assert.True(t, re.Groups[0].New)
+
+		re, err = es.FindRunErrors(runs[1])
+		assert.NoError(t, err)
+		assert.Equal(t, 0, re.Total)
+		assert.Empty(t, re.Groups)
+
+		re, err = es.FindRunErrors(runs[2])
+		assert.NoError(t, err)
+		assert.Equal(t, runs[2].ID, re.RunID)
+		assert.Equal(t, 4, re.Total)
+		assert.Equal(t, 1, re.New)
+		assert.Len(t, re.Groups, 2)
+
+		assert.Equal(t, "Internal: Internal error.", re.Groups[0].Key)
+		assert.True(t, re.Groups[0].New)
+		assert.Equal(t, "Unavailable: connection to <addr> refused", re.Groups[1].Key)
+		assert.False(t, re.Groups[1].New)
+
+		// errors of other tests are not considered
+		re, err = es.FindRunErrors(runs[3])
+		assert.NoError(t, err)
+		assert.Equal(t, 1, re.New)
+	})
+
+	t.Run("test errors", func(t *testing.T) {
+		rep, err := es.FindTestErrors(tst.ID)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 3, rep.Runs)
+		assert.Equal(t, 2, rep.RunsWithErrors)
+		assert.Equal(t, 6, rep.Total)
+		assert.Len(t, rep.Groups, 2)
+
+		g := rep.Groups[0]
+		assert.Equal(t, "Unavailable: connection to <addr> refused", g.Key)
+		assert.Equal(t, 3, g.Count)
+		assert.Equal(t, 2, g.Runs)
+		assert.Len(t, g.Messages, 2)
+		assert.Equal(t, runs[0].ID, g.FirstSeen.RunID)
+		assert.Equal(t, 2, g.FirstSeen.Count)
+		assert.Equal(t, runs[2].ID, g.LastSeen.RunID)
+		assert.Len(t, g.Occurrences, 2)
+
+		g = rep.Groups[1]
+		assert.Equal(t, "Internal: Internal error.", g.Key)
+		assert.Equal(t, runs[2].ID, g.FirstSeen.RunID)
+		assert.Equal(t, runs[2].ID, g.LastSeen.RunID)
+	})
+
+	t.Run("project errors", func(t *testing.T) {
+		rep, err := es.FindProjectErrors(p.ID)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 4, rep.Runs)
+		assert.Equal(t, 11, rep.Total)
+		assert.Len(t, rep.Groups, 3)
+
+		assert.Equal(t, "NotFound: user <n> not found", rep.Groups[0].Key)
+		assert.Equal(t, tst2.ID, rep.Groups[0].FirstSeen.TestID)
+	})
+}
@@ -0,0 +1,266 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// StatusChange is a change of a test's status caused by a run
+type StatusChange struct {
+	Model
+	TestID uint `json:"testID" gorm:"type:integer REFERENCES tests(id)"`
+
+	// The run that caused the change. The run may no longer exist.
+	RunID uint `json:"runID"`
+
+	// The status before and after the change
+	From Status `json:"from"`
+	To   Status `json:"to"`
+
+	// Time of the change, which is the date of the run that caused it
+	Date time.Time `json:"date"`
+}
+
+// StatusSummary holds numbers derived from the run statuses of a test or project
+type StatusSummary struct {
+	// The test of the summary, for the summaries of the tests of a project
+	TestID uint `json:"testID,omitempty"`
+
+	// The status of the latest run of a test.
+	// A project fails if the current status of any of its tests is failed.
+	Status Status `json:"status"`
+
+	// Number of consecutive latest runs with the current status
+	Streak uint `json:"streak"`
+
+	// Date of the latest failed run
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+
+	// Time since the latest failed run
+	SinceLastFailure time.Duration `json:"sinceLastFailure,omitempty"`
+
+	// Number of latest runs considered for the pass rate
+	Window uint `json:"window"`
+
+	// Fraction of the runs in the window that passed
+	PassRate float64 `json:"passRate"`
+
+	// The summaries of the tests of a project
+	Tests []*StatusSummary `json:"tests,omitempty"`
+}
+
+// DefaultStatusWindow is the default number of runs considered for the pass rate
+const DefaultStatusWindow = 20
+
+// recordStatusChange records the change of the test's status from the stored status.
+// The change is dated by the run, or by the current time if there is no run date.
+func recordStatusChange(tx *gorm.DB, t *Test, from Status, r *Run) error {
+	from = StatusFromString(string(from))
+	to := StatusFromString(string(t.Status))
+
+	if from == to {
+		return nil
+	}
+
+	sc := &StatusChange{TestID: t.ID, From: from, To: to, Date: gorm.NowFunc()}
+	if r != nil {
+		sc.RunID = r.ID
+
+		if !r.Date.IsZero() {
+			sc.Date = r.Date
+		}
+	}
+
+	return tx.Create(sc).Error
+}
+
+// findStatusHistory finds the status changes matching the condition, latest first
+func findStatusHistory(db *gorm.DB, num, page uint, query string, args ...interface{}) ([]*StatusChange, error) {
+	s := make([]*StatusChange, num)
+
+	err := db.Where(query, args...).Order("date desc").Order("id desc").
+		Offset(page * num).Limit(num).Find(&s).Error
+
+	return s, err
+}
+
+// countStatusHistory counts the status changes matching the condition
+func countStatusHistory(db *gorm.DB, query string, args ...interface{}) (uint, error) {
+	count := uint(0)
+	err := db.Model(&StatusChange{}).Where(query, args...).Count(&count).Error
+	return count, err
+}
+
+// findStatusSummary computes the status summary of the completed runs matching the condition.
+// Running and aborted runs have not been evaluated, so their status is not counted.
+func findStatusSummary(db *gorm.DB, window uint, now time.Time, query string, args ...interface{}) (*StatusSummary, error) {
+	if window == 0 {
+		window = DefaultStatusWindow
+	}
+
+	runs := func() *gorm.DB {
+		return db.Model(&Run{}).Where(query, args...).Where("state = ?", RunCompleted)
+	}
+
+	summary := &StatusSummary{Status: StatusOK}
+
+	latest := new(Run)
+	err := runs().Order("date desc").Order("id desc").First(latest).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return summary, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Status = latest.Status
+
+	// the streak is the number of runs since the latest run with a different status
+	last := new(Run)
+	err = runs().Where("status <> ?", string(latest.Status)).Order("date desc").First(last).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	streak := runs().Where("status = ?", string(latest.Status))
+	if err == nil {
+		streak = streak.Where("date > ?", last.Date)
+	}
+
+	if err = streak.Count(&summary.Streak).Error; err != nil {
+		return nil, err
+	}
+
+	failed := new(Run)
+	err = runs().Where("status = ?", string(StatusFail)).Order("date desc").First(failed).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	if err == nil {
+		summary.LastFailure = &failed.Date
+		summary.SinceLastFailure = now.Sub(failed.Date)
+	}
+
+	var statuses []string
+	err = runs().Order("date desc").Order("id desc").Limit(window).Pluck("status", &statuses).Error
+	if err != nil {
+		return nil, err
+	}
+
+	passed := 0
+	for _, s := range statuses {
+		if StatusFromString(s) == StatusOK {
+			passed++
+		}
+	}
+
+	summary.Window = uint(len(statuses))
+	if summary.Window > 0 {
+		summary.PassRate = float64(passed) / float64(summary.Window)
+	}
+
+	return summary, nil
+}
+
+// UpdateStatus updates the test and records the change of its status caused by the run.
+// The run may be nil if the status did not change because of a run.
+func (ts *TestService) UpdateStatus(t *Test, r *Run) error {
+	return transact(ts.DB, func(tx *gorm.DB) error {
+		stored := &Test{}
+		if err := tx.First(stored, t.ID).Error; err != nil {
+			return err
+		}
+
+		if err := (&TestService{DB: tx}).Update(t); err != nil {
+			return err
+		}
+
+		return recordStatusChange(tx, t, stored.Status, r)
+	})
+}
+
+// CountStatusHistory returns the number of status changes of the test
+func (ts *TestService) CountStatusHistory(tid uint) (uint, error) {
+	return countStatusHistory(ts.DB, "test_id = ?", tid)
+}
+
+// FindStatusHistory finds the status changes of the test, latest first
+func (ts *TestService) FindStatusHistory(tid, num, page uint) ([]*StatusChange, error) {
+	return findStatusHistory(ts.DB, num, page, "test_id = ?", tid)
+}
+
+// FindStatusSummary computes the status summary of the test's completed runs.
+// The pass rate is computed over the latest window runs.
+func (ts *TestService) FindStatusSummary(tid, window uint) (*StatusSummary, error) {
+	return findStatusSummary(ts.DB, window, time.Now(), "test_id = ?", tid)
+}
+
+const projectTestsCond = "test_id IN (SELECT id FROM tests WHERE project_id = ? AND deleted_at IS NULL)"
+
+// CountStatusHistory returns the number of status changes of the project's tests
+func (ps *ProjectService) CountStatusHistory(pid uint) (uint, error) {
+	return countStatusHistory(ps.DB, projectTestsCond, pid)
+}
+
+// FindStatusHistory finds the status changes of the project's tests, latest first
+func (ps *ProjectService) FindStatusHistory(pid, num, page uint) ([]*StatusChange, error) {
+	return findStatusHistory(ps.DB, num, page, projectTestsCond, pid)
+}
+
+// FindStatusSummary computes the status summary of the project from the summaries of its tests,
+// which are included in the summary. The project fails if any of its tests currently fails.
+// The streak is the shortest streak of the tests whose latest run has the project's status,
+// so an ok project has passed at least that many latest runs of every test.
+// The last failure is the latest failed run of any test, and the pass rate is computed
+// over the latest window runs of each test.
+func (ps *ProjectService) FindStatusSummary(pid, window uint) (*StatusSummary, error) {
+	var tests []*Test
+	if err := ps.DB.Where("project_id = ?", pid).Order("id asc").Find(&tests).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	summary := &StatusSummary{Status: StatusOK, Tests: make([]*StatusSummary, 0, len(tests))}
+
+	for _, t := range tests {
+		if StatusFromString(string(t.Status)) == StatusFail {
+			summary.Status = StatusFail
+		}
+	}
+
+	passed := 0.0
+	hasStreak := false
+
+	for _, t := range tests {
+		ts, err := findStatusSummary(ps.DB, window, now, "test_id = ?", t.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		ts.TestID = t.ID
+		summary.Tests = append(summary.Tests, ts)
+
+		if ts.Window > 0 && ts.Status == summary.Status && (!hasStreak || ts.Streak < summary.Streak) {
+			summary.Streak = ts.Streak
+			hasStreak = true
+		}
+
+		if ts.LastFailure != nil && (summary.LastFailure == nil || ts.LastFailure.After(*summary.LastFailure)) {
+			summary.LastFailure = ts.LastFailure
+			summary.SinceLastFailure = ts.SinceLastFailure
+		}
+
+		passed += ts.PassRate * float64(ts.Window)
+		summary.Window += ts.Window
+	}
+
+	if summary.Window > 0 {
+		summary.PassRate = passed / float64(summary.Window)
+	}
+
+	return summary, nil
+}
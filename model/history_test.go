@@ -0,0 +1,217 @@
+package model
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestService_StatusHistory(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ts := TestService{DB: db}
+	ps := ProjectService{DB: db}
+	rs := RunService{DB: db}
+
+	tst := &Test{Project: &Project{}, Name: "history"}
+	tst2 := &Test{Name: "history2"}
+
+	now := time.Now()
+
+	// statuses of the runs of the first test, oldest first
+	statuses := []Status{StatusOK, StatusFail, StatusFail, StatusOK, StatusOK, StatusOK}
+
+	t.Run("record status changes", func(t *testing.T) {
+		err := db.Create(tst).Error
+		assert.NoError(t, err)
+
+		tst2.ProjectID = tst.ProjectID
+		err = db.Create(tst2).Error
+		assert.NoError(t, err)
+
+		for i, status := range statuses {
+			r := &Run{
+				TestID:           tst.ID,
+				Date:             now.Add(time.Duration(i-len(statuses)) * time.Hour),
+				Status:           status,
+				ThresholdResults: []*ThresholdResult{},
+			}
+
+			err := rs.Create(r)
+			assert.NoError(t, err)
+
+			tst.Status = status
+			err = ts.UpdateStatus(tst, r)
+			assert.NoError(t, err)
+		}
+
+		r := &Run{TestID: tst2.ID, Date: now, Status: StatusFail, ThresholdResults: []*ThresholdResult{}}
+		err = rs.Create(r)
+		assert.NoError(t, err)
+
+		tst2.Status = StatusFail
+		err = ts.UpdateStatus(tst2, r)
+		assert.NoError(t, err)
+	})
+
+	t.Run("test status history", func(t *testing.T) {
+		count, err := ts.CountStatusHistory(tst.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), count)
+
+		changes, err := ts.FindStatusHistory(tst.ID, 20, 0)
+		assert.NoError(t, err)
+		assert.Len(t, changes, 2)
+
+		assert.Equal(t, StatusFail, changes[0].From)
+		assert.Equal(t, StatusOK, changes[0].To)
+		assert.Equal(t, StatusOK, changes[1].From)
+		assert.Equal(t, StatusFail, changes[1].To)
+		assert.NotZero(t, changes[0].RunID)
+		assert.True(t, changes[0].RunID > changes[1].RunID)
+		assert.False(t, changes[0].Date.IsZero())
+
+		r, err := rs.FindByID(changes[0].RunID)
+		assert.NoError(t, err)
+		assert.True(t, r.Date.Equal(changes[0].Date))
+
+		changes, err = ts.FindStatusHistory(tst.ID, 1, 1)
+		assert.NoError(t, err)
+		assert.Len(t, changes, 1)
+		assert.Equal(t, StatusFail, changes[0].To)
+	})
+
+	t.Run("test status summary", func(t *testing.T) {
+		summary, err := ts.FindStatusSummary(tst.ID, 4)
+		assert.NoError(t, err)
+
+		assert.Equal(t, StatusOK, summary.Status)
+		assert.Equal(t, uint(3), summary.Streak)
+		assert.NotNil(t, summary.LastFailure)
+		assert.True(t, summary.SinceLastFailure > 3*time.Hour)
+		assert.Equal(t, uint(4), summary.Window)
+		assert.Equal(t, 0.75, summary.PassRate)
+	})
+
+	t.Run("test status summary without runs", func(t *testing.T) {
+		summary, err := ts.FindStatusSummary(4321, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, &StatusSummary{Status: StatusOK}, summary)
+	})
+
+	t.Run("project status history", func(t *testing.T) {
+		count, err := ps.CountStatusHistory(tst.ProjectID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(3), count)
+
+		changes, err := ps.FindStatusHistory(tst.ProjectID, 20, 0)
+		assert.NoError(t, err)
+		assert.Len(t, changes, 3)
+		assert.Equal(t, tst2.ID, changes[0].TestID)
+	})
+
+	t.Run("project status summary", func(t *testing.T) {
+		summary, err := ps.FindStatusSummary(tst.ProjectID, 0)
+		assert.NoError(t, err)
+
+		assert.Equal(t, StatusFail, summary.Status)
+		assert.Equal(t, uint(1), summary.Streak)
+		assert.Equal(t, uint(7), summary.Window)
+		assert.InDelta(t, 4.0/7.0, summary.PassRate, 0.0001)
+
+		assert.Len(t, summary.Tests, 2)
+		assert.Equal(t, tst.ID, summary.Tests[0].TestID)
+		assert.Equal(t, StatusOK, summary.Tests[0].Status)
+		assert.Equal(t, uint(3), summary.Tests[0].Streak)
+		assert.Equal(t, tst2.ID, summary.Tests[1].TestID)
+		assert.Equal(t, StatusFail, summary.Tests[1].Status)
+	})
+
+	t.Run("project fails while a passing test ran last", func(t *testing.T) {
+		r := &Run{TestID: tst.ID, Date: now.Add(time.Minute), Status: StatusOK, ThresholdResults: []*ThresholdResult{}}
+		err := rs.Create(r)
+		assert.NoError(t, err)
+
+		err = ts.UpdateStatus(tst, r)
+		assert.NoError(t, err)
+
+		summary, err := ps.FindStatusSummary(tst.ProjectID, 0)
+		assert.NoError(t, err)
+
+		// the streak and the last failure are those of the failing test, not of the latest run
+		assert.Equal(t, StatusFail, summary.Status)
+		assert.Equal(t, uint(1), summary.Streak)
+		assert.NotNil(t, summary.LastFailure)
+		assert.Equal(t, summary.Tests[1].LastFailure, summary.LastFailure)
+		assert.Equal(t, uint(8), summary.Window)
+		assert.InDelta(t, 5.0/8.0, summary.PassRate, 0.0001)
+		assert.Equal(t, uint(4), summary.Tests[0].Streak)
+	})
+
+	t.Run("open and aborted runs do not change the status summary", func(t *testing.T) {
+		runs := []*Run{
+			&Run{TestID: tst2.ID, Date: now.Add(time.Minute), State: RunAborted},
+			&Run{TestID: tst2.ID, Date: now.Add(2 * time.Minute), State: RunRunning},
+		}
+
+		for _, r := range runs {
+			err := rs.Create(r)
+			assert.NoError(t, err)
+			assert.Equal(t, StatusOK, r.Status)
+		}
+
+		summary, err := ts.FindStatusSummary(tst2.ID, 0)
+		assert.NoError(t, err)
+
+		assert.Equal(t, StatusFail, summary.Status)
+		assert.Equal(t, uint(1), summary.Streak)
+		assert.Equal(t, uint(1), summary.Window)
+		assert.Equal(t, 0.0, summary.PassRate)
+
+		summary, err = ps.FindStatusSummary(tst.ProjectID, 0)
+		assert.NoError(t, err)
+
+		assert.Equal(t, StatusFail, summary.Status)
+		assert.Equal(t, uint(1), summary.Streak)
+		assert.Equal(t, uint(8), summary.Window)
+		assert.Equal(t, StatusFail, summary.Tests[1].Status)
+	})
+
+	t.Run("no change is not recorded", func(t *testing.T) {
+		err := ts.UpdateStatus(tst, nil)
+		assert.NoError(t, err)
+
+		count, err := ts.CountStatusHistory(tst.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), count)
+	})
+
+	t.Run("history is purged with the test", func(t *testing.T) {
+		_, err := ts.Delete(tst)
+		assert.NoError(t, err)
+
+		count, err := ps.CountStatusHistory(tst.ProjectID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), count)
+
+		res, err := (&TrashService{DB: db}).Purge(time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), res.StatusChanges)
+
+		count = 0
+		db.Unscoped().Model(&StatusChange{}).Where("test_id = ?", tst.ID).Count(&count)
+		assert.Equal(t, uint(0), count)
+	})
+}
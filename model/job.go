@@ -0,0 +1,104 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// JobStatus is the status of an ingestion job
+type JobStatus string
+
+// The statuses of an ingestion job
+const (
+	// JobQueued is the status of a job waiting to be processed
+	JobQueued JobStatus = "queued"
+
+	// JobProcessing is the status of a job being processed
+	JobProcessing JobStatus = "processing"
+
+	// JobDone is the status of a job whose results were created
+	JobDone JobStatus = "done"
+
+	// JobFailed is the status of a job that failed
+	JobFailed JobStatus = "failed"
+)
+
+// Job is an upload of raw results that is spooled and processed in the background
+type Job struct {
+	Model
+
+	Status JobStatus `json:"status"`
+
+	// Format of the upload: json, ndjson, influx or csv
+	Format string `json:"format"`
+
+	// The project and test of the results. Zero until known for new results.
+	ProjectID uint `json:"projectID,omitempty"`
+	TestID    uint `json:"testID,omitempty"`
+
+	// Names of the project and test of new results, if any
+	ProjectName string `json:"projectName,omitempty"`
+	TestName    string `json:"testName,omitempty"`
+
+	// Whether the results created before a failure are kept
+	BestEffort bool `json:"bestEffort"`
+
+	// Whether the summaries of the runs are computed from their details
+	Recompute bool `json:"recompute"`
+
+	// Path of the spooled upload
+	Path string `json:"-"`
+
+	// Progress of the job
+	Runs           uint `json:"runs"`
+	RunsDone       uint `json:"runsDone"`
+	DetailsCreated uint `json:"detailsCreated"`
+	DetailsFailed  uint `json:"detailsFailed"`
+
+	// Error message if the job failed
+	Error string `json:"error,omitempty" gorm:"type:text"`
+
+	// The responses of the created runs
+	Result string `json:"-" gorm:"type:text"`
+}
+
+// Finished returns whether the job is done or failed
+func (j *Job) Finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed
+}
+
+// JobService is our implementation
+type JobService struct {
+	DB *gorm.DB
+}
+
+// Create creates a new job
+func (js *JobService) Create(j *Job) error {
+	return js.DB.Create(j).Error
+}
+
+// FindByID finds job by id
+func (js *JobService) FindByID(id uint) (*Job, error) {
+	j := new(Job)
+	err := js.DB.First(j, id).Error
+
+	if err != nil {
+		j = nil
+	}
+
+	return j, err
+}
+
+// FindUnfinished finds the jobs that are not finished in the order they were created
+func (js *JobService) FindUnfinished() ([]*Job, error) {
+	s := make([]*Job, 0)
+
+	err := js.DB.Where("status IN (?)", []JobStatus{JobQueued, JobProcessing}).
+		Order("id asc").Find(&s).Error
+
+	return s, err
+}
+
+// Update updates the job
+func (js *JobService) Update(j *Job) error {
+	return js.DB.Save(j).Error
+}
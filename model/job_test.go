@@ -0,0 +1,81 @@
+package model
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobService(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Job{})
+
+	js := &JobService{DB: db}
+
+	var jobs []*Job
+
+	t.Run("Create", func(t *testing.T) {
+		for _, status := range []JobStatus{JobQueued, JobDone, JobProcessing, JobFailed, JobQueued} {
+			j := &Job{Status: status, Format: "json", Path: "spool/job"}
+			err := js.Create(j)
+
+			assert.NoError(t, err)
+			assert.NotZero(t, j.ID)
+
+			jobs = append(jobs, j)
+		}
+	})
+
+	t.Run("FindByID", func(t *testing.T) {
+		j, err := js.FindByID(jobs[1].ID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, JobDone, j.Status)
+		assert.Equal(t, "spool/job", j.Path)
+		assert.True(t, j.Finished())
+
+		j, err = js.FindByID(12345)
+
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, j)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		j := jobs[0]
+		j.Status = JobProcessing
+		j.Runs = 2
+		j.RunsDone = 1
+		j.DetailsCreated = 100
+
+		err := js.Update(j)
+		assert.NoError(t, err)
+
+		j, err = js.FindByID(j.ID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, JobProcessing, j.Status)
+		assert.Equal(t, uint(2), j.Runs)
+		assert.Equal(t, uint(1), j.RunsDone)
+		assert.Equal(t, uint(100), j.DetailsCreated)
+		assert.False(t, j.Finished())
+	})
+
+	t.Run("FindUnfinished", func(t *testing.T) {
+		s, err := js.FindUnfinished()
+
+		assert.NoError(t, err)
+		assert.Len(t, s, 3)
+		assert.Equal(t, jobs[0].ID, s[0].ID)
+		assert.Equal(t, jobs[2].ID, s[1].ID)
+		assert.Equal(t, jobs[4].ID, s[2].ID)
+	})
+}
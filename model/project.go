@@ -101,41 +101,74 @@ func (ps *ProjectService) Update(p *Project) error {
 	return ps.DB.Save(p).Error
 }
 
-// Delete deletes project
-func (ps *ProjectService) Delete(p *Project) error {
-	return errors.New("Not Implemented Yet")
+// Delete moves the project along with all of its tests and runs to the trash
+func (ps *ProjectService) Delete(p *Project) (*CascadeResult, error) {
+	res := new(CascadeResult)
+
+	err := transact(ps.DB, func(tx *gorm.DB) error {
+		if err := cascadeProjects(tx, trashOp(gorm.NowFunc()), res, "id = ?", p.ID); err != nil {
+			return err
+		}
+
+		if res.Projects == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
 
 // List lists projects
 func (ps *ProjectService) List(limit, page uint) ([]*Project, error) {
-	offset := uint(0)
-	if page >= 0 && limit >= 0 {
-		offset = page * limit
-	}
+	return ps.ListQuery(limit, page, NewQuery(ProjectQueryFields))
+}
+
+// ListQuery lists the projects matching the query
+func (ps *ProjectService) ListQuery(limit, page uint, q *Query) ([]*Project, error) {
+	return listProjects(ps.DB, limit, page, q)
+}
+
+// ListQueryUnscoped lists the projects matching the query including the ones in the trash
+func (ps *ProjectService) ListQueryUnscoped(limit, page uint, q *Query) ([]*Project, error) {
+	return listProjects(ps.DB.Unscoped(), limit, page, q)
+}
+
+// CountQuery returns the number of projects matching the query
+func (ps *ProjectService) CountQuery(q *Query) (uint, error) {
+	return countProjects(ps.DB, q)
+}
 
+// CountQueryUnscoped returns the number of projects matching the query including the ones in the trash
+func (ps *ProjectService) CountQueryUnscoped(q *Query) (uint, error) {
+	return countProjects(ps.DB.Unscoped(), q)
+}
+
+func listProjects(db *gorm.DB, limit, page uint, q *Query) ([]*Project, error) {
 	s := make([]*Project, limit)
 
-	err := ps.DB.Offset(offset).Limit(limit).Order("name desc").Find(&s).Error
+	err := q.find(db, "name desc", limit, page).Find(&s).Error
 
 	return s, err
 }
 
+func countProjects(db *gorm.DB, q *Query) (uint, error) {
+	count := uint(0)
+	err := q.where(db.Model(&Project{})).Count(&count).Error
+	return count, err
+}
+
 // ListSorted lists projects using sorting
 func (ps *ProjectService) ListSorted(limit, page uint, sortField, order string) ([]*Project, error) {
-	if (sortField != "name" && sortField != "id") || (order != "asc" && order != "desc") {
-		return nil, errors.New("Invalid sort parameters")
-	}
-
-	offset := uint(0)
-	if page >= 0 && limit >= 0 {
-		offset = page * limit
+	q, err := sortedQuery(ProjectQueryFields, sortField, order)
+	if err != nil {
+		return nil, err
 	}
 
-	orderSQL := sortField + " " + order
-
-	s := make([]*Project, limit)
-
-	err := ps.DB.Order(orderSQL).Offset(offset).Limit(limit).Find(&s).Error
-
-	return s, err
+	return ps.ListQuery(limit, page, q)
 }
@@ -489,3 +489,120 @@ func TestProjectService_Count(t *testing.T) {
 		assert.Equal(t, count, uint(10))
 	})
 }
+
+func TestProjectService_Delete(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	dao := ProjectService{DB: db}
+	var pid, otherPID uint
+
+	t.Run("create projects with tests, runs and details", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			p := &Project{Name: "TestProj" + strconv.FormatInt(int64(i), 10)}
+			err := dao.Create(p)
+			assert.NoError(t, err)
+
+			for j := 0; j < 2; j++ {
+				r := &Run{
+					Test: &Test{ProjectID: p.ID, Name: "Test" + strconv.FormatInt(int64(j), 10)},
+					LatencyDistribution: []*LatencyDistribution{
+						&LatencyDistribution{Percentage: 50},
+						&LatencyDistribution{Percentage: 95},
+					},
+					Histogram: []*Bucket{
+						&Bucket{Mark: 0.01},
+						&Bucket{Mark: 0.02},
+						&Bucket{Mark: 0.03},
+					},
+				}
+				err := db.Create(r).Error
+				assert.NoError(t, err)
+
+				for k := 0; k < 5; k++ {
+					err := db.Create(&Detail{RunID: r.ID, Latency: 1.23}).Error
+					assert.NoError(t, err)
+				}
+			}
+
+			if i == 0 {
+				pid = p.ID
+			} else {
+				otherPID = p.ID
+			}
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		p := &Project{}
+		p.ID = pid
+
+		res, err := dao.Delete(p)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &CascadeResult{
+			Projects:             1,
+			Tests:                2,
+			Runs:                 2,
+			LatencyDistributions: 4,
+			Buckets:              6,
+			Details:              10,
+		}, res)
+
+		_, err = dao.FindByID(pid)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		count := 0
+		db.Model(&Test{}).Where("project_id = ?", pid).Count(&count)
+		assert.Equal(t, 0, count)
+
+		db.Model(&Detail{}).Count(&count)
+		assert.Equal(t, 10, count)
+
+		db.Model(&Bucket{}).Count(&count)
+		assert.Equal(t, 6, count)
+	})
+
+	t.Run("deleted records are kept in trash", func(t *testing.T) {
+		count := 0
+		db.Unscoped().Model(&Project{}).Where("id = ? AND deleted_at IS NOT NULL", pid).Count(&count)
+		assert.Equal(t, 1, count)
+
+		db.Unscoped().Model(&Test{}).Where("project_id = ? AND deleted_at IS NOT NULL", pid).Count(&count)
+		assert.Equal(t, 2, count)
+
+		db.Unscoped().Model(&Detail{}).Where("deleted_at IS NOT NULL").Count(&count)
+		assert.Equal(t, 10, count)
+
+		db.Unscoped().Model(&Detail{}).Count(&count)
+		assert.Equal(t, 20, count)
+	})
+
+	t.Run("other project is kept", func(t *testing.T) {
+		p, err := dao.FindByID(otherPID)
+		assert.NoError(t, err)
+		assert.Equal(t, otherPID, p.ID)
+
+		count := 0
+		db.Model(&Test{}).Where("project_id = ?", otherPID).Count(&count)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("fail delete unknown project", func(t *testing.T) {
+		p := &Project{}
+		p.ID = 4321
+
+		res, err := dao.Delete(p)
+
+		assert.Error(t, err)
+		assert.Nil(t, res)
+	})
+}
@@ -0,0 +1,378 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// FieldType is the type of the values of a query field
+type FieldType int
+
+const (
+	// FieldNumber is a numeric field
+	FieldNumber FieldType = iota
+
+	// FieldDuration is a duration field stored in nanoseconds.
+	// Values are durations such as 150ms or a number of nanoseconds.
+	FieldDuration
+
+	// FieldTime is a time field. Values are RFC 3339 times or dates.
+	FieldTime
+
+	// FieldText is a text field matched by substring, ignoring case
+	FieldText
+
+	// FieldEnum is a text field matched exactly against a set of values
+	FieldEnum
+
+	// FieldOption is a property of the options of a run stored in its own column, matched exactly
+	FieldOption
+)
+
+// FilterOp is the comparison of a filter
+type FilterOp string
+
+const (
+	// FilterEq matches values equal to the filter value, or containing it for text fields
+	FilterEq FilterOp = "eq"
+
+	// FilterMin matches values greater than or equal to the filter value
+	FilterMin FilterOp = "min"
+
+	// FilterMax matches values less than or equal to the filter value
+	FilterMax FilterOp = "max"
+)
+
+// QueryField is a field of a resource that lists can be sorted or filtered by
+type QueryField struct {
+	// The column of the field
+	Column string
+
+	// The type of the field values
+	Type FieldType
+
+	// Whether lists can be sorted by the field
+	Sortable bool
+
+	// The allowed values of enum fields
+	Values []string
+}
+
+// ops returns the filter operations supported by the field
+func (f *QueryField) ops() []FilterOp {
+	switch f.Type {
+	case FieldNumber, FieldDuration:
+		return []FilterOp{FilterEq, FilterMin, FilterMax}
+	case FieldTime:
+		return []FilterOp{FilterMin, FilterMax}
+	default:
+		return []FilterOp{FilterEq}
+	}
+}
+
+// Supports returns whether the field can be filtered using the operation
+func (f *QueryField) Supports(op FilterOp) bool {
+	for _, o := range f.ops() {
+		if o == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// QueryFields are the fields of a resource by their names
+type QueryFields map[string]*QueryField
+
+// ProjectQueryFields are the query fields of projects
+var ProjectQueryFields = QueryFields{
+	"id":          &QueryField{Column: "id", Type: FieldNumber, Sortable: true},
+	"name":        &QueryField{Column: "name", Type: FieldText, Sortable: true},
+	"description": &QueryField{Column: "description", Type: FieldText},
+	"createdAt":   &QueryField{Column: "created_at", Type: FieldTime, Sortable: true},
+	"updatedAt":   &QueryField{Column: "updated_at", Type: FieldTime, Sortable: true},
+}
+
+// TestQueryFields are the query fields of tests
+var TestQueryFields = QueryFields{
+	"id":          &QueryField{Column: "id", Type: FieldNumber, Sortable: true},
+	"name":        &QueryField{Column: "name", Type: FieldText, Sortable: true},
+	"description": &QueryField{Column: "description", Type: FieldText},
+	"status":      &QueryField{Column: "status", Type: FieldEnum, Sortable: true, Values: []string{string(StatusOK), string(StatusFail)}},
+	"createdAt":   &QueryField{Column: "created_at", Type: FieldTime, Sortable: true},
+	"updatedAt":   &QueryField{Column: "updated_at", Type: FieldTime, Sortable: true},
+}
+
+// RunQueryFields are the query fields of runs
+var RunQueryFields = QueryFields{
+	"id":      &QueryField{Column: "id", Type: FieldNumber, Sortable: true},
+	"date":    &QueryField{Column: "date", Type: FieldTime, Sortable: true},
+	"count":   &QueryField{Column: "count", Type: FieldNumber, Sortable: true},
+	"total":   &QueryField{Column: "total", Type: FieldDuration, Sortable: true},
+	"average": &QueryField{Column: "average", Type: FieldDuration, Sortable: true},
+	"fastest": &QueryField{Column: "fastest", Type: FieldDuration, Sortable: true},
+	"slowest": &QueryField{Column: "slowest", Type: FieldDuration, Sortable: true},
+	"rps":     &QueryField{Column: "rps", Type: FieldNumber, Sortable: true},
+	"status":  &QueryField{Column: "status", Type: FieldEnum, Sortable: true, Values: []string{string(StatusOK), string(StatusFail)}},
+	"state": &QueryField{Column: "state", Type: FieldEnum, Sortable: true,
+		Values: []string{string(RunRunning), string(RunCompleted), string(RunAborted)}},
+	"call": &QueryField{Column: "options_call", Type: FieldOption},
+	"host": &QueryField{Column: "options_host", Type: FieldOption},
+}
+
+// DetailQueryFields are the query fields of details
+var DetailQueryFields = QueryFields{
+	"id":        &QueryField{Column: "id", Type: FieldNumber, Sortable: true},
+	"timestamp": &QueryField{Column: "timestamp", Type: FieldTime, Sortable: true},
+	"latency":   &QueryField{Column: "latency", Type: FieldDuration, Sortable: true},
+	"status":    &QueryField{Column: "status", Type: FieldText, Sortable: true},
+	"error":     &QueryField{Column: "error", Type: FieldText},
+}
+
+// Field returns the field by its name, ignoring case
+func (fields QueryFields) Field(name string) (string, *QueryField) {
+	if f, ok := fields[name]; ok {
+		return name, f
+	}
+
+	for n, f := range fields {
+		if strings.EqualFold(n, name) {
+			return n, f
+		}
+	}
+
+	return "", nil
+}
+
+// Filter is a condition on a field of a query
+type Filter struct {
+	Field string
+	Op    FilterOp
+	Value interface{}
+}
+
+// Query holds the sorting and filtering of a list of a resource
+type Query struct {
+	fields  QueryFields
+	sort    string
+	order   string
+	after   uint
+	filters []*Filter
+}
+
+// NewQuery creates a new query for the resource fields
+func NewQuery(fields QueryFields) *Query {
+	return &Query{fields: fields}
+}
+
+// Sorted returns whether the query has a sort field
+func (q *Query) Sorted() bool {
+	return q.sort != ""
+}
+
+// Filters returns the filters of the query
+func (q *Query) Filters() []*Filter {
+	return q.filters
+}
+
+// SetSort sets the sort field and order. The order defaults to asc.
+func (q *Query) SetSort(name, order string) error {
+	field, f := q.fields.Field(name)
+	if f == nil || !f.Sortable {
+		return fmt.Errorf("Invalid sort field '%s'. Valid fields are: %s", name, q.sortable())
+	}
+
+	order = strings.ToLower(order)
+	if order == "" {
+		order = "asc"
+	}
+
+	if order != "asc" && order != "desc" {
+		return fmt.Errorf("Invalid sort order '%s'. Valid orders are: asc, desc", order)
+	}
+
+	q.sort = field
+	q.order = order
+
+	return nil
+}
+
+// sortedQuery returns a query of the resource fields sorted by the field in the order
+func sortedQuery(fields QueryFields, sortField, order string) (*Query, error) {
+	q := NewQuery(fields)
+	if err := q.SetSort(sortField, order); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Seekable returns whether the list can be paged using an id cursor,
+// which requires the list to be in the order of the ids
+func (q *Query) Seekable() bool {
+	return !q.Sorted() || q.sort == "id"
+}
+
+// SetAfter sets the id cursor of the query. The list starts after the item with the id
+// in the order of the list.
+func (q *Query) SetAfter(id uint) error {
+	if !q.Seekable() {
+		return fmt.Errorf("Invalid cursor. Lists sorted by '%s' can only be paged by page number", q.sort)
+	}
+
+	q.after = id
+
+	return nil
+}
+
+// AddFilter parses the value and adds the filter on the field
+func (q *Query) AddFilter(name string, op FilterOp, value string) error {
+	field, f := q.fields.Field(name)
+	if f == nil {
+		return fmt.Errorf("Invalid filter field '%s'", name)
+	}
+
+	if !f.Supports(op) {
+		return fmt.Errorf("Invalid filter on '%s'. The field does not support %s values", field, op)
+	}
+
+	v, err := parseFilterValue(f, value)
+	if err != nil {
+		return fmt.Errorf("Invalid value '%s' for '%s': %s", value, field, err.Error())
+	}
+
+	q.filters = append(q.filters, &Filter{Field: field, Op: op, Value: v})
+
+	return nil
+}
+
+// where applies the filters of the query to the db
+func (q *Query) where(db *gorm.DB) *gorm.DB {
+	for _, filter := range q.filters {
+		f := q.fields[filter.Field]
+
+		switch {
+		case f.Type == FieldText:
+			db = db.Where("LOWER("+f.Column+") LIKE ? ESCAPE '!'",
+				"%"+escapeLike(strings.ToLower(filter.Value.(string)))+"%")
+		case filter.Op == FilterMin:
+			db = db.Where(f.Column+" >= ?", filter.Value)
+		case filter.Op == FilterMax:
+			db = db.Where(f.Column+" <= ?", filter.Value)
+		default:
+			db = db.Where(f.Column+" = ?", filter.Value)
+		}
+	}
+
+	return db
+}
+
+// find applies the filters, order and paging of the query to the db. The default order is used
+// if the query is not sorted, and it must be by id for lists paged using a cursor.
+func (q *Query) find(db *gorm.DB, def string, limit, page uint) *gorm.DB {
+	order := q.orderBy(def)
+
+	db = q.where(db)
+
+	if q.after > 0 {
+		if strings.HasSuffix(order, " desc") {
+			db = db.Where("id < ?", q.after)
+		} else {
+			db = db.Where("id > ?", q.after)
+		}
+	}
+
+	return db.Order(order).Offset(page * limit).Limit(limit)
+}
+
+// orderBy returns the order of the query, or the default order if it is not sorted
+func (q *Query) orderBy(def string) string {
+	if !q.Sorted() {
+		return def
+	}
+
+	return q.fields[q.sort].Column + " " + q.order
+}
+
+// sortable returns the names of the sortable fields
+func (q *Query) sortable() string {
+	names := make([]string, 0, len(q.fields))
+	for n, f := range q.fields {
+		if f.Sortable {
+			names = append(names, n)
+		}
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// parseFilterValue parses the filter value according to the field type
+func parseFilterValue(f *QueryField, value string) (interface{}, error) {
+	switch f.Type {
+	case FieldNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+
+		return n, nil
+	case FieldDuration:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n, nil
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected a duration such as 150ms or a number of nanoseconds")
+		}
+
+		return int64(d), nil
+	case FieldTime:
+		t, err := ParseTime(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return t, nil
+	case FieldEnum:
+		for _, v := range f.Values {
+			if strings.EqualFold(v, value) {
+				return v, nil
+			}
+		}
+
+		return nil, fmt.Errorf("expected one of: %s", strings.Join(f.Values, ", "))
+	default:
+		if value == "" {
+			return nil, fmt.Errorf("expected a value")
+		}
+
+		return value, nil
+	}
+}
+
+// ParseTime parses an RFC 3339 time or a date such as 2018-01-02
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return t, fmt.Errorf("expected an RFC 3339 time or a date such as 2018-01-02")
+	}
+
+	return t, nil
+}
+
+// escapeLike escapes the LIKE wildcards in the value using ! as the escape character
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
@@ -0,0 +1,319 @@
+package model
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery_SetSort(t *testing.T) {
+	var tests = []struct {
+		name        string
+		sort        string
+		order       string
+		expected    string
+		expectError bool
+	}{
+		{"default order", "rps", "", "rps asc", false},
+		{"desc", "average", "DESC", "average desc", false},
+		{"ignores case", "Date", "asc", "date asc", false},
+		{"column", "timestamp", "desc", "", true},
+		{"unknown", "foo", "asc", "", true},
+		{"not sortable", "call", "asc", "", true},
+		{"invalid order", "id", "up", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQuery(RunQueryFields)
+			err := q.SetSort(tt.sort, tt.order)
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.False(t, q.Sorted())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, q.orderBy("id desc"))
+			}
+		})
+	}
+}
+
+func TestQuery_SetAfter(t *testing.T) {
+	q := NewQuery(RunQueryFields)
+	assert.True(t, q.Seekable())
+	assert.NoError(t, q.SetAfter(10))
+
+	q = NewQuery(RunQueryFields)
+	assert.NoError(t, q.SetSort("id", "asc"))
+	assert.True(t, q.Seekable())
+	assert.NoError(t, q.SetAfter(10))
+
+	q = NewQuery(RunQueryFields)
+	assert.NoError(t, q.SetSort("rps", "asc"))
+	assert.False(t, q.Seekable())
+	assert.Error(t, q.SetAfter(10))
+}
+
+func TestQuery_AddFilter(t *testing.T) {
+	date, _ := time.Parse("2006-01-02", "2018-01-02")
+
+	var tests = []struct {
+		name        string
+		field       string
+		op          FilterOp
+		value       string
+		expected    interface{}
+		expectError bool
+	}{
+		{"number", "rps", FilterMin, "100.5", 100.5, false},
+		{"duration", "average", FilterMax, "150ms", int64(150 * time.Millisecond), false},
+		{"nanoseconds", "average", FilterMax, "1000", int64(1000), false},
+		{"date", "date", FilterMin, "2018-01-02", date, false},
+		{"time", "date", FilterMax, "2018-01-02T00:00:00Z", date, false},
+		{"enum", "status", FilterEq, "FAIL", "fail", false},
+		{"option", "call", FilterEq, "helloworld.Greeter.SayHello", "helloworld.Greeter.SayHello", false},
+		{"invalid number", "rps", FilterMin, "fast", nil, true},
+		{"invalid duration", "average", FilterMin, "soon", nil, true},
+		{"invalid time", "date", FilterMin, "yesterday", nil, true},
+		{"invalid enum", "state", FilterEq, "paused", nil, true},
+		{"empty option", "host", FilterEq, "", nil, true},
+		{"unsupported op", "date", FilterEq, "2018-01-02", nil, true},
+		{"bounded text", "call", FilterMin, "a", nil, true},
+		{"unknown", "foo", FilterEq, "1", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQuery(RunQueryFields)
+			err := q.AddFilter(tt.field, tt.op, tt.value)
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Empty(t, q.Filters())
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, q.Filters(), 1)
+				assert.Equal(t, tt.expected, q.Filters()[0].Value)
+			}
+		})
+	}
+}
+
+func TestQuery_Services(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ps := &ProjectService{DB: db}
+	ts := &TestService{DB: db}
+	rs := &RunService{DB: db}
+	ds := &DetailService{DB: db}
+
+	p := &Project{Name: "Query 100%"}
+	p2 := &Project{Name: "Query 1000"}
+	tst := &Test{Name: "query"}
+	tst2 := &Test{Name: "failing", Status: StatusFail}
+
+	var rid uint
+
+	date, _ := time.Parse("2006-01-02", "2018-01-01")
+
+	t.Run("create", func(t *testing.T) {
+		assert.NoError(t, db.Create(p).Error)
+		assert.NoError(t, db.Create(p2).Error)
+
+		tst.ProjectID = p.ID
+		tst2.ProjectID = p.ID
+		assert.NoError(t, db.Create(tst).Error)
+		assert.NoError(t, db.Create(tst2).Error)
+
+		for i := 0; i < 10; i++ {
+			host := "localhost:50051"
+			if i%2 == 0 {
+				host = "remote:50051"
+			}
+
+			r := &Run{
+				TestID:  tst.ID,
+				Date:    date.Add(time.Duration(i) * 24 * time.Hour),
+				Count:   100,
+				Average: time.Duration(i+1) * time.Millisecond,
+				Rps:     float64(100 * (i + 1)),
+				Options: &Options{Call: "helloworld.Greeter.SayHello", Host: host},
+			}
+
+			assert.NoError(t, rs.Create(r))
+
+			rid = r.ID
+		}
+
+		details := []*Detail{
+			&Detail{Latency: float64(time.Millisecond), Status: "OK"},
+			&Detail{Latency: float64(2 * time.Millisecond), Status: "Unavailable", Error: "connection refused"},
+			&Detail{Latency: float64(3 * time.Millisecond), Status: "DeadlineExceeded", Error: "context deadline exceeded"},
+			&Detail{Latency: float64(4 * time.Millisecond), Status: "OK"},
+		}
+
+		for _, d := range details {
+			d.RunID = rid
+			assert.NoError(t, db.Create(d).Error)
+		}
+	})
+
+	t.Run("projects by name", func(t *testing.T) {
+		q := NewQuery(ProjectQueryFields)
+		assert.NoError(t, q.AddFilter("name", FilterEq, "100%"))
+
+		count, err := ps.CountQuery(q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), count)
+
+		projects, err := ps.ListQuery(10, 0, q)
+		assert.NoError(t, err)
+		assert.Len(t, projects, 1)
+		assert.Equal(t, p.ID, projects[0].ID)
+	})
+
+	t.Run("tests by status", func(t *testing.T) {
+		q := NewQuery(TestQueryFields)
+		assert.NoError(t, q.AddFilter("status", FilterEq, "fail"))
+
+		count, err := ts.CountQuery(p.ID, q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), count)
+
+		tests, err := ts.FindByProjectIDQuery(p.ID, 10, 0, q)
+		assert.NoError(t, err)
+		assert.Len(t, tests, 1)
+		assert.Equal(t, tst2.ID, tests[0].ID)
+	})
+
+	t.Run("runs by date and rps", func(t *testing.T) {
+		q := NewQuery(RunQueryFields)
+		assert.NoError(t, q.SetSort("rps", "desc"))
+		assert.NoError(t, q.AddFilter("date", FilterMin, "2018-01-03"))
+		assert.NoError(t, q.AddFilter("rps", FilterMax, "800"))
+
+		count, err := rs.CountQuery(tst.ID, q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(6), count)
+
+		runs, err := rs.FindByTestIDQuery(tst.ID, 4, 0, q, true, true)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 4)
+		assert.Equal(t, float64(800), runs[0].Rps)
+		assert.Equal(t, float64(500), runs[3].Rps)
+		assert.Equal(t, "helloworld.Greeter.SayHello", runs[0].Options.Call)
+
+		runs, err = rs.FindByTestIDQuery(tst.ID, 4, 1, q, false, false)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 2)
+		assert.Equal(t, float64(300), runs[1].Rps)
+	})
+
+	t.Run("runs after cursor", func(t *testing.T) {
+		q := NewQuery(RunQueryFields)
+		assert.NoError(t, q.SetAfter(rid-2))
+
+		runs, err := rs.FindByTestIDQuery(tst.ID, 3, 0, q, false, false)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 3)
+		assert.Equal(t, rid-3, runs[0].ID)
+		assert.Equal(t, rid-5, runs[2].ID)
+
+		q = NewQuery(RunQueryFields)
+		assert.NoError(t, q.SetSort("id", "asc"))
+		assert.NoError(t, q.SetAfter(rid-2))
+
+		runs, err = rs.FindByTestIDQuery(tst.ID, 3, 0, q, false, false)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 2)
+		assert.Equal(t, rid-1, runs[0].ID)
+		assert.Equal(t, rid, runs[1].ID)
+
+		count, err := rs.CountQuery(tst.ID, q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(10), count)
+	})
+
+	t.Run("runs by latency and options", func(t *testing.T) {
+		q := NewQuery(RunQueryFields)
+		assert.NoError(t, q.AddFilter("average", FilterMin, "5ms"))
+		assert.NoError(t, q.AddFilter("call", FilterEq, "helloworld.Greeter.SayHello"))
+		assert.NoError(t, q.AddFilter("host", FilterEq, "localhost:50051"))
+
+		runs, err := rs.FindByTestIDQuery(tst.ID, 10, 0, q, false, false)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 3)
+
+		for _, r := range runs {
+			assert.Equal(t, "localhost:50051", r.Options.Host)
+			assert.True(t, r.Average >= 5*time.Millisecond)
+		}
+
+		q = NewQuery(RunQueryFields)
+		assert.NoError(t, q.AddFilter("call", FilterEq, "helloworld.Greeter"))
+
+		count, err := rs.CountQuery(tst.ID, q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), count)
+	})
+
+	t.Run("runs by options stored before the option columns", func(t *testing.T) {
+		err := db.Exec("UPDATE runs SET options_call = NULL, options_host = NULL").Error
+		assert.NoError(t, err)
+
+		err = rs.MigrateOptions()
+		assert.NoError(t, err)
+
+		q := NewQuery(RunQueryFields)
+		assert.NoError(t, q.AddFilter("host", FilterEq, "remote:50051"))
+
+		count, err := rs.CountQuery(tst.ID, q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(5), count)
+
+		q = NewQuery(RunQueryFields)
+		assert.NoError(t, q.AddFilter("call", FilterEq, "helloworld.Greeter.%"))
+
+		count, err = rs.CountQuery(tst.ID, q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), count)
+	})
+
+	t.Run("details by status and error", func(t *testing.T) {
+		q := NewQuery(DetailQueryFields)
+		assert.NoError(t, q.AddFilter("status", FilterEq, "ok"))
+
+		count, err := ds.CountQuery(rid, q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), count)
+
+		q = NewQuery(DetailQueryFields)
+		assert.NoError(t, q.SetSort("latency", "desc"))
+		assert.NoError(t, q.AddFilter("error", FilterEq, "DEADLINE"))
+
+		details, err := ds.FindByRunIDQuery(rid, 10, 0, q)
+		assert.NoError(t, err)
+		assert.Len(t, details, 1)
+		assert.Equal(t, "DeadlineExceeded", details[0].Status)
+
+		q = NewQuery(DetailQueryFields)
+		assert.NoError(t, q.SetSort("latency", "desc"))
+		assert.NoError(t, q.AddFilter("latency", FilterMax, "3ms"))
+
+		details, err = ds.FindByRunIDQuery(rid, 10, 0, q)
+		assert.NoError(t, err)
+		assert.Len(t, details, 3)
+		assert.Equal(t, float64(3*time.Millisecond), details[0].Latency)
+	})
+}
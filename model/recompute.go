@@ -0,0 +1,266 @@
+package model
+
+import (
+	"encoding/json"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/jinzhu/gorm"
+)
+
+// RunChange is the change of a field of a recomputed run
+type RunChange struct {
+	// The name of the field
+	Field string `json:"field"`
+
+	// The stored value
+	Old interface{} `json:"old"`
+
+	// The recomputed value
+	New interface{} `json:"new"`
+}
+
+// RecomputeResult holds the result of recomputing a run
+type RecomputeResult struct {
+	// The run id
+	RunID uint `json:"runId"`
+
+	// The test id
+	TestID uint `json:"testId"`
+
+	// Number of details the summary was computed from
+	Details uint64 `json:"details"`
+
+	// Whether the run was skipped as it has no details
+	Skipped bool `json:"skipped,omitempty"`
+
+	// The fields changed by the recomputation
+	Changes []*RunChange `json:"changes,omitempty"`
+}
+
+// RecomputeStats holds the stats of a recomputation
+type RecomputeStats struct {
+	// Whether the changes were only computed and not stored
+	DryRun bool `json:"dryRun"`
+
+	// Number of runs processed
+	Runs uint `json:"runs"`
+
+	// Number of runs changed
+	Changed uint `json:"changed"`
+
+	// Number of runs skipped as they have no details
+	Skipped uint `json:"skipped"`
+
+	// The result of each run
+	Results []*RecomputeResult `json:"results"`
+}
+
+// RecomputeService rebuilds the aggregates of stored runs from their details
+type RecomputeService struct {
+	DB     *gorm.DB
+	Config *config.DBConfig
+}
+
+// RecomputeRun recomputes the run
+func (rcs *RecomputeService) RecomputeRun(id uint, dryRun bool) (*RecomputeStats, error) {
+	if err := rcs.DB.First(&Run{}, id).Error; err != nil {
+		return nil, err
+	}
+
+	return rcs.recompute(dryRun, "id = ?", id)
+}
+
+// RecomputeTest recomputes all the runs of the test
+func (rcs *RecomputeService) RecomputeTest(tid uint, dryRun bool) (*RecomputeStats, error) {
+	if err := rcs.DB.First(&Test{}, tid).Error; err != nil {
+		return nil, err
+	}
+
+	return rcs.recompute(dryRun, "test_id = ?", tid)
+}
+
+// RecomputeProject recomputes all the runs of all the tests of the project
+func (rcs *RecomputeService) RecomputeProject(pid uint, dryRun bool) (*RecomputeStats, error) {
+	if err := rcs.DB.First(&Project{}, pid).Error; err != nil {
+		return nil, err
+	}
+
+	return rcs.recompute(dryRun, "test_id IN (SELECT id FROM tests WHERE project_id = ? AND deleted_at IS NULL)", pid)
+}
+
+// recompute recomputes the matching runs from the oldest to the newest, so that the
+// baselines of later runs are evaluated against the recomputed statuses of earlier ones.
+// In a dry run nothing is stored and runs are evaluated against the stored statuses.
+// Running runs are left to be finished.
+func (rcs *RecomputeService) recompute(dryRun bool, query string, args ...interface{}) (*RecomputeStats, error) {
+	var runIDs []uint
+	err := rcs.DB.Model(&Run{}).Where(query, args...).Where("state <> ?", RunRunning).
+		Order("date asc, id asc").Pluck("id", &runIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &RecomputeStats{DryRun: dryRun, Results: make([]*RecomputeResult, 0, len(runIDs))}
+
+	tests := make(map[uint]*Test)
+
+	for _, id := range runIDs {
+		res, err := rcs.recomputeRun(id, tests, dryRun)
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Runs++
+		if res.Skipped {
+			stats.Skipped++
+		} else if len(res.Changes) > 0 {
+			stats.Changed++
+		}
+
+		stats.Results = append(stats.Results, res)
+	}
+
+	return stats, nil
+}
+
+// recomputeRun recomputes the summary, latency distribution and histogram of the run
+// from its details and evaluates it against the thresholds of its test.
+// The status of the test is updated if the run is its latest one.
+func (rcs *RecomputeService) recomputeRun(id uint, tests map[uint]*Test, dryRun bool) (*RecomputeResult, error) {
+	runs := &RunService{DB: rcs.DB}
+
+	r, err := runs.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &RecomputeResult{RunID: r.ID, TestID: r.TestID}
+
+	updated := *r
+
+	latencies, err := (&DetailService{DB: rcs.DB, Config: rcs.Config}).Summarize(&updated)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Details = uint64(len(latencies))
+	if res.Details == 0 {
+		res.Skipped = true
+		return res, nil
+	}
+
+	t := tests[r.TestID]
+	if t == nil {
+		if t, err = (&TestService{DB: rcs.DB}).FindByID(r.TestID); err != nil {
+			return nil, err
+		}
+
+		tests[r.TestID] = t
+	}
+
+	if _, err = runs.Evaluate(t, &updated, latencies); err != nil {
+		return nil, err
+	}
+
+	if res.Changes, err = diffRuns(r, &updated); err != nil {
+		return nil, err
+	}
+
+	if dryRun || len(res.Changes) == 0 {
+		return res, nil
+	}
+
+	err = transact(rcs.DB, func(tx *gorm.DB) error {
+		if _, err := deleteWhere(tx, &LatencyDistribution{}, "run_id = ?", r.ID); err != nil {
+			return err
+		}
+
+		if _, err := deleteWhere(tx, &Bucket{}, "run_id = ?", r.ID); err != nil {
+			return err
+		}
+
+		if err := tx.Save(&updated).Error; err != nil {
+			return err
+		}
+
+		latest, err := (&RunService{DB: tx}).FindLatest(t.ID)
+		if err != nil || latest == nil || latest.ID != r.ID {
+			return err
+		}
+
+		return (&TestService{DB: tx}).UpdateStatus(t, &updated)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// runAggregates are the fields of a run rebuilt from its details, in the order they are compared
+func runAggregates(r *Run) []*RunChange {
+	type percentile struct {
+		Percentage int   `json:"percentage"`
+		Latency    int64 `json:"latency"`
+	}
+
+	type bucket struct {
+		Mark      float64 `json:"mark"`
+		Count     int     `json:"count"`
+		Frequency float64 `json:"frequency"`
+	}
+
+	dist := make([]percentile, len(r.LatencyDistribution))
+	for i, l := range r.LatencyDistribution {
+		dist[i] = percentile{l.Percentage, int64(l.Latency)}
+	}
+
+	histogram := make([]bucket, len(r.Histogram))
+	for i, b := range r.Histogram {
+		histogram[i] = bucket{b.Mark, b.Count, b.Frequency}
+	}
+
+	return []*RunChange{
+		{Field: "count", New: r.Count},
+		{Field: "total", New: r.Total},
+		{Field: "average", New: r.Average},
+		{Field: "fastest", New: r.Fastest},
+		{Field: "slowest", New: r.Slowest},
+		{Field: "rps", New: r.Rps},
+		{Field: "errorDistribution", New: r.ErrorDist},
+		{Field: "statusCodeDistribution", New: r.StatusCodeDist},
+		{Field: "latencyDistribution", New: dist},
+		{Field: "histogram", New: histogram},
+		{Field: "status", New: r.Status},
+		{Field: "thresholdResults", New: r.ThresholdResults},
+		{Field: "significance", New: r.Significance},
+	}
+}
+
+// diffRuns returns the aggregates of the updated run that differ from the stored run
+func diffRuns(stored, updated *Run) ([]*RunChange, error) {
+	olds := runAggregates(stored)
+	news := runAggregates(updated)
+
+	var changes []*RunChange
+
+	for i, c := range news {
+		old, err := json.Marshal(olds[i].New)
+		if err != nil {
+			return nil, err
+		}
+
+		val, err := json.Marshal(c.New)
+		if err != nil {
+			return nil, err
+		}
+
+		if string(old) != string(val) {
+			c.Old = olds[i].New
+			changes = append(changes, c)
+		}
+	}
+
+	return changes, nil
+}
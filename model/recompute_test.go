@@ -0,0 +1,172 @@
+package model
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecomputeService(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	rcs := &RecomputeService{DB: db, Config: &config.DBConfig{BatchSize: 3}}
+	runs := &RunService{DB: db}
+
+	tst := &Test{Project: &Project{}, Name: "recompute", FailOnThreshold: true, Thresholds: map[Threshold]*ThresholdSetting{
+		ThresholdMean: &ThresholdSetting{Threshold: 5 * time.Millisecond},
+	}}
+
+	var rid, emptyID uint
+
+	t.Run("create runs", func(t *testing.T) {
+		err := db.Create(tst).Error
+		assert.NoError(t, err)
+
+		now := time.Now()
+
+		// the uploaded summary does not match the details
+		r := &Run{
+			TestID:  tst.ID,
+			Date:    now,
+			Count:   1,
+			Average: time.Second,
+			LatencyDistribution: []*LatencyDistribution{
+				&LatencyDistribution{Percentage: 50, Latency: time.Second},
+			},
+			Histogram: []*Bucket{
+				&Bucket{Mark: 1, Count: 1, Frequency: 1},
+			},
+		}
+
+		err = runs.Create(r)
+		assert.NoError(t, err)
+
+		rid = r.ID
+
+		for i := 1; i <= 10; i++ {
+			err := db.Create(&Detail{RunID: rid, Latency: float64(time.Duration(i) * time.Millisecond),
+				Timestamp: now.Add(time.Duration(i) * time.Millisecond), Status: "OK"}).Error
+			assert.NoError(t, err)
+		}
+
+		empty := &Run{TestID: tst.ID, Date: now.Add(-time.Minute), Count: 5}
+		err = runs.Create(empty)
+		assert.NoError(t, err)
+
+		emptyID = empty.ID
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		stats, err := rcs.RecomputeRun(rid, true)
+		assert.NoError(t, err)
+
+		assert.True(t, stats.DryRun)
+		assert.Equal(t, uint(1), stats.Runs)
+		assert.Equal(t, uint(1), stats.Changed)
+		assert.Len(t, stats.Results, 1)
+
+		res := stats.Results[0]
+		assert.Equal(t, rid, res.RunID)
+		assert.Equal(t, uint64(10), res.Details)
+
+		fields := make(map[string]*RunChange)
+		for _, c := range res.Changes {
+			fields[c.Field] = c
+		}
+
+		assert.Contains(t, fields, "count")
+		assert.Equal(t, uint64(1), fields["count"].Old)
+		assert.Equal(t, uint64(10), fields["count"].New)
+		assert.Contains(t, fields, "histogram")
+		assert.Contains(t, fields, "latencyDistribution")
+		assert.Contains(t, fields, "thresholdResults")
+
+		r, err := runs.FindByID(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), r.Count)
+		assert.Len(t, r.Histogram, 1)
+	})
+
+	t.Run("recompute run", func(t *testing.T) {
+		stats, err := rcs.RecomputeRun(rid, false)
+		assert.NoError(t, err)
+		assert.False(t, stats.DryRun)
+		assert.Equal(t, uint(1), stats.Changed)
+
+		r, err := runs.FindByID(rid)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(10), r.Count)
+		assert.Equal(t, 5500*time.Microsecond, r.Average)
+		assert.Equal(t, time.Millisecond, r.Fastest)
+		assert.Equal(t, 10*time.Millisecond, r.Slowest)
+		assert.Len(t, r.LatencyDistribution, 7)
+		assert.Len(t, r.Histogram, 11)
+		assert.Equal(t, StatusFail, r.Status)
+		assert.Len(t, r.ThresholdResults, 1)
+
+		count := 0
+		err = db.Model(&Bucket{}).Where("run_id = ?", rid).Count(&count).Error
+		assert.NoError(t, err)
+		assert.Equal(t, 11, count)
+
+		err = db.Model(&LatencyDistribution{}).Where("run_id = ?", rid).Count(&count).Error
+		assert.NoError(t, err)
+		assert.Equal(t, 7, count)
+
+		// the run is the latest of the test
+		updated, err := (&TestService{DB: db}).FindByID(tst.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusFail, updated.Status)
+	})
+
+	t.Run("recompute test", func(t *testing.T) {
+		stats, err := rcs.RecomputeTest(tst.ID, false)
+		assert.NoError(t, err)
+
+		assert.Equal(t, uint(2), stats.Runs)
+		assert.Equal(t, uint(0), stats.Changed)
+		assert.Equal(t, uint(1), stats.Skipped)
+		assert.Len(t, stats.Results, 2)
+
+		// runs are recomputed from the oldest
+		assert.Equal(t, emptyID, stats.Results[0].RunID)
+		assert.True(t, stats.Results[0].Skipped)
+		assert.Equal(t, rid, stats.Results[1].RunID)
+		assert.Empty(t, stats.Results[1].Changes)
+
+		r, err := runs.FindByID(emptyID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(5), r.Count)
+	})
+
+	t.Run("recompute project", func(t *testing.T) {
+		stats, err := rcs.RecomputeProject(tst.ProjectID, true)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), stats.Runs)
+		assert.Equal(t, uint(0), stats.Changed)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := rcs.RecomputeRun(12345, false)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		_, err = rcs.RecomputeTest(12345, false)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		_, err = rcs.RecomputeProject(12345, false)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+}
@@ -0,0 +1,234 @@
+package model
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/jinzhu/gorm"
+)
+
+// ErrRetentionRunning is returned when the retention job is triggered while it is already running
+var ErrRetentionRunning = errors.New("Retention job is already running")
+
+// RetentionStats holds the stats of a retention job run
+type RetentionStats struct {
+	// Time the job started
+	StartTime time.Time `json:"startTime"`
+
+	// Time the job finished
+	EndTime time.Time `json:"endTime"`
+
+	// Number of projects processed
+	Projects uint `json:"projects"`
+
+	// Number of records deleted
+	Deleted CascadeResult `json:"deleted"`
+
+	// Error message if the job failed
+	Error string `json:"error,omitempty"`
+}
+
+// RetentionService is our implementation
+type RetentionService struct {
+	DB     *gorm.DB
+	Config *config.RetentionConfig
+
+	// running is set while a prune job runs
+	running int32
+
+	// mu guards last only, so the stats can be read while a job runs
+	mu   sync.Mutex
+	last *RetentionStats
+}
+
+// LastStats returns the stats of the last retention job run, or nil if the job has not run yet
+func (rs *RetentionService) LastStats() *RetentionStats {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.last == nil {
+		return nil
+	}
+
+	stats := *rs.last
+
+	return &stats
+}
+
+// Prune enforces the retention policy of every project.
+// Only one prune job runs at a time, ErrRetentionRunning is returned if a job is already running.
+func (rs *RetentionService) Prune() (*RetentionStats, error) {
+	if !atomic.CompareAndSwapInt32(&rs.running, 0, 1) {
+		return nil, ErrRetentionRunning
+	}
+	defer atomic.StoreInt32(&rs.running, 0)
+
+	stats := &RetentionStats{StartTime: time.Now()}
+
+	err := rs.prune(stats)
+	if err != nil {
+		stats.Error = err.Error()
+	}
+
+	stats.EndTime = time.Now()
+
+	rs.mu.Lock()
+	rs.last = stats
+	rs.mu.Unlock()
+
+	res := *stats
+
+	return &res, err
+}
+
+func (rs *RetentionService) prune(stats *RetentionStats) error {
+	var projectIDs []uint
+	err := rs.DB.Unscoped().Model(&Project{}).Order("id asc").Pluck("id", &projectIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, pid := range projectIDs {
+		policy := rs.Config.GetPolicy(pid)
+
+		if err := rs.pruneProject(pid, &policy, &stats.Deleted); err != nil {
+			return err
+		}
+
+		stats.Projects++
+	}
+
+	return nil
+}
+
+func (rs *RetentionService) pruneProject(pid uint, policy *config.RetentionPolicy, res *CascadeResult) error {
+	if cutoff := policy.GetDetailsCutoff(time.Now()); !cutoff.IsZero() {
+		// details are aged by the date of their run, as imported details are inserted long after the calls.
+		// The details of running runs are kept until the runs finish.
+		n, err := rs.deleteDetails(
+			"run_id IN (SELECT id FROM runs WHERE date < ? AND state <> ? AND test_id IN (SELECT id FROM tests WHERE project_id = ?))",
+			cutoff, RunRunning, pid)
+
+		res.Details += n
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if policy.KeepRuns == 0 {
+		return nil
+	}
+
+	var testIDs []uint
+	err := rs.DB.Unscoped().Model(&Test{}).Where("project_id = ?", pid).Pluck("id", &testIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, tid := range testIDs {
+		if err := rs.pruneRuns(tid, policy, res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pruneRuns deletes the runs of the test beyond the most recent completed ones kept by the policy.
+// Running runs are never pruned, and aborted runs are kept along with the completed runs newer than them.
+// The run pinned as the baseline of the test is spared.
+func (rs *RetentionService) pruneRuns(tid uint, policy *config.RetentionPolicy, res *CascadeResult) error {
+	var baselineIDs []uint
+	err := rs.DB.Unscoped().Model(&Test{}).Where("id = ?", tid).Pluck("baseline_run_id", &baselineIDs).Error
+	if err != nil {
+		return err
+	}
+
+	var runs []*Run
+	err = rs.DB.Unscoped().Select("id, state").Where("test_id = ? AND state <> ?", tid, RunRunning).
+		Order("date desc, id desc").Find(&runs).Error
+	if err != nil {
+		return err
+	}
+
+	var runIDs []uint
+	kept := uint(0)
+
+	for _, r := range runs {
+		if len(baselineIDs) > 0 && r.ID == baselineIDs[0] {
+			continue
+		}
+
+		if kept < policy.KeepRuns {
+			if r.State == RunCompleted {
+				kept++
+			}
+
+			continue
+		}
+
+		runIDs = append(runIDs, r.ID)
+	}
+
+	batchSize := int(rs.Config.BatchSize)
+
+	for len(runIDs) > 0 {
+		n := batchSize
+		if n > len(runIDs) {
+			n = len(runIDs)
+		}
+
+		batch := runIDs[:n]
+		runIDs = runIDs[n:]
+
+		dn, err := rs.deleteDetails("run_id IN (?)", batch)
+		res.Details += dn
+		if err != nil {
+			return err
+		}
+
+		if policy.KeepRunSummaries {
+			continue
+		}
+
+		rr := new(CascadeResult)
+		err = transact(rs.DB, func(tx *gorm.DB) error {
+			return cascadeRuns(tx, deleteWhere, rr, "id IN (?)", batch)
+		})
+		if err != nil {
+			return err
+		}
+
+		res.Add(rr)
+	}
+
+	return nil
+}
+
+// deleteDetails hard deletes the matching details in batches
+func (rs *RetentionService) deleteDetails(query string, args ...interface{}) (uint, error) {
+	total := uint(0)
+
+	for {
+		var ids []uint
+		err := rs.DB.Unscoped().Model(&Detail{}).Where(query, args...).
+			Limit(rs.Config.BatchSize).Pluck("id", &ids).Error
+		if err != nil {
+			return total, err
+		}
+
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		n, err := deleteWhere(rs.DB, &Detail{}, "id IN (?)", ids)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+}
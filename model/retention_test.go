@@ -0,0 +1,222 @@
+package model
+
+import (
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionService_Prune(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	var pid, pid2, tid, tid2 uint
+
+	old := time.Now().Add(-10 * 24 * time.Hour)
+
+	// the first run of each batch is old, and each run has a detail inserted long ago
+	// that is kept as long as its run is recent
+	createRuns := func(t *testing.T, testID uint) {
+		for i := 0; i < 3; i++ {
+			date := time.Now()
+			if i == 0 {
+				date = old
+			}
+
+			r := &Run{
+				TestID: testID,
+				Date:   date,
+				LatencyDistribution: []*LatencyDistribution{
+					&LatencyDistribution{Percentage: 50},
+				},
+				Histogram: []*Bucket{
+					&Bucket{Mark: 0.01},
+				},
+			}
+			err := db.Create(r).Error
+			assert.NoError(t, err)
+
+			err = db.Create(&Detail{RunID: r.ID, Latency: 1.23, Model: Model{CreatedAt: old}}).Error
+			assert.NoError(t, err)
+
+			err = db.Create(&Detail{RunID: r.ID, Latency: 1.23}).Error
+			assert.NoError(t, err)
+		}
+	}
+
+	t.Run("create projects with runs", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			tst := &Test{Project: &Project{}, Name: "RetentionTest"}
+			err := db.Create(tst).Error
+			assert.NoError(t, err)
+
+			createRuns(t, tst.ID)
+
+			if i == 0 {
+				pid = tst.ProjectID
+				tid = tst.ID
+			} else {
+				pid2 = tst.ProjectID
+				tid2 = tst.ID
+			}
+		}
+	})
+
+	days := uint(0)
+	keepSummaries := false
+
+	dao := RetentionService{DB: db, Config: &config.RetentionConfig{
+		BatchSize:        1,
+		DetailsDays:      7,
+		KeepRuns:         2,
+		KeepRunSummaries: true,
+	}}
+
+	t.Run("no stats before first run", func(t *testing.T) {
+		assert.Nil(t, dao.LastStats())
+	})
+
+	t.Run("prune with global policy", func(t *testing.T) {
+		stats, err := dao.Prune()
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), stats.Projects)
+		assert.Empty(t, stats.Error)
+		assert.False(t, stats.EndTime.Before(stats.StartTime))
+
+		// the 2 details of the old run per project, regardless of when the details were inserted
+		assert.Equal(t, CascadeResult{Details: 4}, stats.Deleted)
+
+		count := 0
+		db.Unscoped().Model(&Run{}).Count(&count)
+		assert.Equal(t, 6, count)
+
+		db.Unscoped().Model(&Detail{}).Count(&count)
+		assert.Equal(t, 8, count)
+
+		db.Unscoped().Model(&Detail{}).Where("created_at < ?", time.Now().Add(-7*24*time.Hour)).Count(&count)
+		assert.Equal(t, 4, count)
+
+		last := dao.LastStats()
+		assert.Equal(t, stats, last)
+	})
+
+	t.Run("prune with project override", func(t *testing.T) {
+		createRuns(t, tid2)
+
+		dao.Config.Projects = []config.ProjectRetentionConfig{
+			{ProjectID: pid2, DetailsDays: &days, KeepRunSummaries: &keepSummaries},
+		}
+
+		stats, err := dao.Prune()
+
+		// the 2 most recent runs are kept by date, the details of the old run of the first batch are already gone
+		assert.NoError(t, err)
+		assert.Equal(t, CascadeResult{
+			Runs:                 4,
+			LatencyDistributions: 4,
+			Buckets:              4,
+			Details:              6,
+		}, stats.Deleted)
+
+		count := 0
+		db.Unscoped().Model(&Run{}).Where("test_id = ?", tid).Count(&count)
+		assert.Equal(t, 3, count)
+
+		db.Unscoped().Model(&Run{}).Where("test_id = ?", tid2).Count(&count)
+		assert.Equal(t, 2, count)
+
+		db.Unscoped().Model(&Project{}).Where("id = ?", pid).Count(&count)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("prune keeps running runs and counts completed runs only", func(t *testing.T) {
+		now := time.Now()
+
+		runs := []*Run{
+			&Run{TestID: tid, Date: old, State: RunRunning},
+			&Run{TestID: tid2, Date: old, State: RunRunning},
+			&Run{TestID: tid2, Date: now.Add(time.Minute), State: RunAborted},
+			&Run{TestID: tid2, Date: now.Add(2 * time.Minute)},
+		}
+
+		for _, r := range runs {
+			err := db.Create(r).Error
+			assert.NoError(t, err)
+
+			err = db.Create(&Detail{RunID: r.ID, Latency: 1.23}).Error
+			assert.NoError(t, err)
+		}
+
+		stats, err := dao.Prune()
+
+		// only the oldest completed run of the second test is beyond the 2 kept completed runs
+		assert.NoError(t, err)
+		assert.Equal(t, CascadeResult{
+			Runs:                 1,
+			LatencyDistributions: 1,
+			Buckets:              1,
+			Details:              2,
+		}, stats.Deleted)
+
+		for _, r := range runs {
+			count := 0
+			db.Unscoped().Model(&Run{}).Where("id = ?", r.ID).Count(&count)
+			assert.Equal(t, 1, count)
+
+			db.Unscoped().Model(&Detail{}).Where("run_id = ?", r.ID).Count(&count)
+			assert.Equal(t, 1, count)
+		}
+
+		count := 0
+		db.Unscoped().Model(&Run{}).Where("test_id = ? AND state = ?", tid2, RunCompleted).Count(&count)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("prune spares the pinned baseline run", func(t *testing.T) {
+		r := &Run{TestID: tid2, Date: old}
+		err := db.Create(r).Error
+		assert.NoError(t, err)
+
+		err = db.Model(&Test{}).Where("id = ?", tid2).UpdateColumn("baseline_run_id", r.ID).Error
+		assert.NoError(t, err)
+
+		stats, err := dao.Prune()
+
+		assert.NoError(t, err)
+		assert.Zero(t, stats.Deleted.Runs)
+
+		count := 0
+		db.Unscoped().Model(&Run{}).Where("id = ?", r.ID).Count(&count)
+		assert.Equal(t, 1, count)
+
+		db.Unscoped().Model(&Run{}).Where("test_id = ? AND state = ?", tid2, RunCompleted).Count(&count)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("skip prune while running", func(t *testing.T) {
+		last := dao.LastStats()
+
+		atomic.StoreInt32(&dao.running, 1)
+		defer atomic.StoreInt32(&dao.running, 0)
+
+		stats, err := dao.Prune()
+
+		assert.Equal(t, ErrRetentionRunning, err)
+		assert.Nil(t, stats)
+		assert.Equal(t, last, dao.LastStats())
+	})
+}
@@ -3,10 +3,12 @@ package model
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/bojand/ghz-web/config"
 	"github.com/jinzhu/gorm"
 )
 
@@ -51,6 +53,20 @@ type Options struct {
 	Metadata      *map[string]string `json:"metadata,omitempty"`
 }
 
+// RunState is the state of a run in its lifecycle
+type RunState string
+
+const (
+	// RunRunning means the run is receiving details while the load test is executing
+	RunRunning = RunState("running")
+
+	// RunCompleted means the run is finished and has been evaluated
+	RunCompleted = RunState("completed")
+
+	// RunAborted means the run stopped receiving details before it was finished
+	RunAborted = RunState("aborted")
+)
+
 // Run represents a project
 type Run struct {
 	Model
@@ -74,10 +90,28 @@ type Run struct {
 	LatencyDistribution []*LatencyDistribution `json:"latencyDistribution"`
 	Histogram           []*Bucket              `json:"histogram"`
 
+	Significance *Significance `json:"significance,omitempty" gorm:"-"`
+
+	// Evaluation of the test's thresholds for this run
+	ThresholdResults []*ThresholdResult `json:"thresholdResults,omitempty" gorm:"-"`
+
+	// Whether some of the run's details failed to be created.
+	// Only possible for results submitted with best effort.
+	Partial bool `json:"partial"`
+
+	// The state of the run. Runs created incrementally are running until they are finished.
+	State RunState `json:"state" gorm:"default:'completed'"`
+
+	// The call and host of the options, stored in their own columns to filter the runs by them
+	OptionsCall string `json:"-" gorm:"column:options_call"`
+	OptionsHost string `json:"-" gorm:"column:options_host"`
+
 	// temp conversion vars
-	ErrorDistJSON      string `json:"-" gorm:"column:error_dist"`
-	StatusCodeDistJSON string `json:"-" gorm:"column:status_code_dist"`
-	OptionsJSON        string `json:"-" gorm:"column:options"`
+	ErrorDistJSON        string `json:"-" gorm:"column:error_dist"`
+	StatusCodeDistJSON   string `json:"-" gorm:"column:status_code_dist"`
+	OptionsJSON          string `json:"-" gorm:"column:options"`
+	SignificanceJSON     string `json:"-" gorm:"column:significance"`
+	ThresholdResultsJSON string `json:"-" gorm:"column:threshold_results"`
 }
 
 // BeforeSave is called by GORM before save
@@ -86,7 +120,15 @@ func (r *Run) BeforeSave(scope *gorm.Scope) error {
 		return errors.New("Run must belong to a test")
 	}
 
-	r.Status = StatusOK
+	if r.State == "" {
+		r.State = RunCompleted
+	}
+
+	// runs evaluated against the test's thresholds keep the status of the evaluation
+	evaluated := r.ThresholdResults != nil
+	if !evaluated || r.Status != StatusFail {
+		r.Status = StatusOK
+	}
 
 	errDist := []byte("")
 	if r.ErrorDist != nil && len(r.ErrorDist) > 0 {
@@ -96,7 +138,9 @@ func (r *Run) BeforeSave(scope *gorm.Scope) error {
 			return err
 		}
 
-		r.Status = StatusFail
+		if !evaluated {
+			r.Status = StatusFail
+		}
 	}
 
 	r.ErrorDistJSON = string(errDist)
@@ -122,22 +166,54 @@ func (r *Run) BeforeSave(scope *gorm.Scope) error {
 	}
 
 	r.OptionsJSON = string(options)
+	r.OptionsCall, r.OptionsHost = r.optionValues()
+
+	significance := []byte("")
+	if r.Significance != nil {
+		var err error
+		significance, err = json.Marshal(r.Significance)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.SignificanceJSON = string(significance)
+
+	if err := r.marshalThresholdResults(); err != nil {
+		return err
+	}
 
 	if scope != nil {
 		scope.SetColumn("status", r.Status)
+		scope.SetColumn("state", r.State)
 		scope.SetColumn("error_dist", r.ErrorDistJSON)
 		scope.SetColumn("status_code_dist", r.StatusCodeDistJSON)
 		scope.SetColumn("options", r.OptionsJSON)
+		scope.SetColumn("options_call", r.OptionsCall)
+		scope.SetColumn("options_host", r.OptionsHost)
+		scope.SetColumn("significance", r.SignificanceJSON)
+		scope.SetColumn("threshold_results", r.ThresholdResultsJSON)
 	}
 
 	return nil
 }
 
+// optionValues returns the call and host of the options of the run
+func (r *Run) optionValues() (string, string) {
+	if r.Options == nil {
+		return "", ""
+	}
+
+	return r.Options.Call, r.Options.Host
+}
+
 // AfterSave is called by GORM after model is saved during create or update
 func (r *Run) AfterSave() error {
 	r.ErrorDistJSON = ""
 	r.StatusCodeDistJSON = ""
 	r.OptionsJSON = ""
+	r.SignificanceJSON = ""
+	r.ThresholdResultsJSON = ""
 	return nil
 }
 
@@ -175,6 +251,43 @@ func (r *Run) AfterFind() error {
 
 	r.OptionsJSON = ""
 
+	significance := strings.TrimSpace(r.SignificanceJSON)
+	if significance != "" {
+		r.Significance = new(Significance)
+		if err := json.Unmarshal([]byte(significance), r.Significance); err != nil {
+			return err
+		}
+	}
+
+	r.SignificanceJSON = ""
+
+	thresholdResults := strings.TrimSpace(r.ThresholdResultsJSON)
+	if thresholdResults != "" {
+		r.ThresholdResults = make([]*ThresholdResult, 0)
+		if err := json.Unmarshal([]byte(thresholdResults), &r.ThresholdResults); err != nil {
+			return err
+		}
+	}
+
+	r.ThresholdResultsJSON = ""
+
+	return nil
+}
+
+// marshalThresholdResults sets the threshold results column value.
+// An evaluation without any results is stored as an empty list.
+func (r *Run) marshalThresholdResults() error {
+	thresholdResults := []byte("")
+	if r.ThresholdResults != nil {
+		var err error
+		thresholdResults, err = json.Marshal(r.ThresholdResults)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.ThresholdResultsJSON = string(thresholdResults)
+
 	return nil
 }
 
@@ -201,6 +314,104 @@ func (r *Run) GetThresholdValues() (time.Duration, time.Duration) {
 	return median, nine5
 }
 
+// GetPercentileValues gets the latency values of the percentiles from the latency distribution.
+// It also returns the percentiles that are not in the latency distribution.
+func (r *Run) GetPercentileValues(percentiles []float64) (map[Threshold]time.Duration, []float64) {
+	values := make(map[Threshold]time.Duration, len(percentiles))
+	missing := make([]float64, 0)
+
+	for _, p := range percentiles {
+		found := false
+
+		for _, l := range r.LatencyDistribution {
+			if float64(l.Percentage) == p {
+				values[PercentileThreshold(p)] = l.Latency
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, p)
+		}
+	}
+
+	return values, missing
+}
+
+// ComputePercentiles computes the latency values of the percentiles from the call
+// latencies using the nearest-rank method
+func ComputePercentiles(latencies []float64, percentiles []float64) map[Threshold]time.Duration {
+	values := make(map[Threshold]time.Duration, len(percentiles))
+
+	n := len(latencies)
+	if n == 0 {
+		return values
+	}
+
+	sorted := make([]float64, n)
+	copy(sorted, latencies)
+	sort.Float64s(sorted)
+
+	for _, p := range percentiles {
+		// small epsilon guards against float error for percentiles like 99.9
+		rank := int(math.Ceil(p/100*float64(n) - 1e-9))
+		if rank < 1 {
+			rank = 1
+		}
+
+		if rank > n {
+			rank = n
+		}
+
+		values[PercentileThreshold(p)] = time.Duration(sorted[rank-1])
+	}
+
+	return values
+}
+
+// GetErrorRate returns the percentage of calls that resulted in an error
+func (r *Run) GetErrorRate() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+
+	errCount := 0
+	for _, n := range r.ErrorDist {
+		errCount += n
+	}
+
+	return float64(errCount) * 100 / float64(r.Count)
+}
+
+// GetStatusCodeCount returns the number of calls with the status code.
+// For codes not in the status code distribution the calls are counted from
+// error distribution messages such as "rpc error: code = Unavailable desc = ..."
+func (r *Run) GetStatusCodeCount(code string) int {
+	if n, ok := r.StatusCodeDist[code]; ok {
+		return n
+	}
+
+	count := 0
+	match := "code = " + code + " "
+	for msg, n := range r.ErrorDist {
+		if strings.Contains(msg, match) {
+			count += n
+		}
+	}
+
+	return count
+}
+
+// GetStatusCodeRate returns the percentage of calls with the status code
+func (r *Run) GetStatusCodeRate(code string) float64 {
+	if r.Count == 0 {
+		return 0
+	}
+
+	return float64(r.GetStatusCodeCount(code)) * 100 / float64(r.Count)
+}
+
 // HasErrors returns whether run has any errors
 func (r *Run) HasErrors() bool {
 	hasErrors := false
@@ -243,19 +454,49 @@ func (rs *RunService) FindByID(id uint) (*Run, error) {
 	return r, err
 }
 
+// MigrateOptions fills the option columns of the runs stored before the columns existed,
+// including the runs in the trash. The runs are updated in batches of the default batch size.
+func (rs *RunService) MigrateOptions() error {
+	db := rs.DB.Unscoped()
+
+	for {
+		var runs []*Run
+		err := db.Select("id, options").Where("options_call IS NULL").
+			Limit(config.DefaultBatchSize).Find(&runs).Error
+		if err != nil {
+			return err
+		}
+
+		if len(runs) == 0 {
+			return nil
+		}
+
+		for _, r := range runs {
+			call, host := r.optionValues()
+
+			err := db.Model(&Run{}).Where("id = ?", r.ID).
+				UpdateColumns(map[string]interface{}{"options_call": call, "options_host": host}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
 // FindLatest returns the latest created run for test
 func (rs *RunService) FindLatest(tid uint) (*Run, error) {
 	r := new(Run)
 	r.Histogram = make([]*Bucket, 100)
 	r.LatencyDistribution = make([]*LatencyDistribution, 100)
 
-	fmt.Printf("Quering\n\n")
+	err := rs.DB.Model(&Run{}).Where("test_id = ? AND state = ?", tid, RunCompleted).Order("date desc").First(r).Error
 
-	err := rs.DB.Model(&Run{}).Where("test_id = ?", tid).Order("date desc").First(r).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
 
 	if err != nil {
-		r = nil
-		return r, nil
+		return nil, err
 	}
 
 	err = rs.DB.Model(r).Related(&r.Histogram).Related(&r.LatencyDistribution).Error
@@ -266,91 +507,298 @@ func (rs *RunService) FindLatest(tid uint) (*Run, error) {
 	return r, err
 }
 
-// FindByTestID finds tests by project
-func (rs *RunService) FindByTestID(tid, num, page uint, populate bool) ([]*Run, error) {
-	t := &Test{}
-	t.ID = tid
+// FindPercentileValues gets the latency values of the percentiles for the run.
+// Percentiles not in the latency distribution are computed from a bounded sample of the stored details.
+func (rs *RunService) FindPercentileValues(r *Run, percentiles []float64) (map[Threshold]time.Duration, error) {
+	return rs.percentileValues(r, percentiles, nil)
+}
 
-	offset := uint(0)
-	if page >= 0 && num >= 0 {
-		offset = page * num
+// percentileValues gets the latency values of the percentiles for the run. Percentiles not in the
+// latency distribution are computed from the call latencies, or from a bounded sample of the stored
+// details if there are none. Percentiles without any latency to compute them from are left out.
+func (rs *RunService) percentileValues(r *Run, percentiles []float64,
+	latencies []float64) (map[Threshold]time.Duration, error) {
+
+	values, missing := r.GetPercentileValues(percentiles)
+	if len(missing) == 0 {
+		return values, nil
 	}
 
-	s := make([]*Run, num)
+	if len(latencies) == 0 && r.ID != 0 {
+		var err error
+		if latencies, err = rs.sampleLatencies(r.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	for thc, val := range ComputePercentiles(latencies, missing) {
+		values[thc] = val
+	}
 
-	err := rs.DB.Offset(offset).Limit(num).Order("id desc").Model(t).Related(&s).Error
+	return values, nil
+}
+
+// FindBaseline finds the baseline for the test, which is either the pinned baseline run
+// or the last passing runs of the test excluding the run with excludeID.
+// Returns nil if the test has no baseline or the pinned run no longer exists.
+func (rs *RunService) FindBaseline(t *Test, excludeID uint) (*Baseline, error) {
+	var runs []*Run
+
+	if t.BaselineRunID != 0 {
+		r, err := rs.FindByID(t.BaselineRunID)
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
 
-	if populate {
 		if err != nil {
 			return nil, err
 		}
 
-		for _, run := range s {
-			run.LatencyDistribution = make([]*LatencyDistribution, 10)
-			err := rs.DB.Model(run).Related(&run.LatencyDistribution).Error
-			if err != nil {
-				return nil, err
-			}
+		if r.TestID != t.ID {
+			return nil, ErrBaselineRunNotInTest
+		}
 
-			run.Histogram = make([]*Bucket, 10)
-			err = rs.DB.Model(run).Related(&run.Histogram).Error
-			if err != nil {
+		runs = []*Run{r}
+	} else if t.BaselineRuns > 0 {
+		err := rs.DB.Where("test_id = ? AND status = ? AND state = ? AND id <> ?",
+			t.ID, string(StatusOK), RunCompleted, excludeID).
+			Order("date desc").Limit(t.BaselineRuns).Find(&runs).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range runs {
+			if err := rs.DB.Model(r).Related(&r.LatencyDistribution).Error; err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	return s, err
+	if len(runs) == 0 {
+		return nil, nil
+	}
+
+	percentiles := make([]map[Threshold]time.Duration, len(runs))
+	for i, r := range runs {
+		var err error
+		if percentiles[i], err = rs.FindPercentileValues(r, t.GetPercentiles()); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewBaseline(t.GetBaselineThresholds(), runs, percentiles), nil
 }
 
-// FindByTestIDSorted lists tests using sorting
-func (rs *RunService) FindByTestIDSorted(tid, num, page uint, sortField, order string,
-	histogram bool, latency bool) ([]*Run, error) {
-	if (sortField != "id" && sortField != "count" && sortField != "total" && sortField != "average" &&
-		sortField != "fastest" && sortField != "slowest" && sortField != "rps" && sortField != "date") ||
-		(order != "asc" && order != "desc") {
-		return nil, errors.New("Invalid sort parameters")
+// FindSignificance compares the latencies of the run against the latencies of the test's
+// pinned baseline run, or the run's predecessor if the test has no pinned baseline.
+// The latencies of the compared run are a bounded sample of its details.
+// Returns nil if there is no run to compare against or not enough latencies.
+func (rs *RunService) FindSignificance(t *Test, r *Run, latencies []float64) (*Significance, error) {
+	prev := new(Run)
+
+	err := gorm.ErrRecordNotFound
+	if t.BaselineRunID != 0 && t.BaselineRunID != r.ID {
+		err = rs.DB.Where("id = ? AND test_id = ?", t.BaselineRunID, t.ID).First(prev).Error
+	}
+
+	if gorm.IsRecordNotFoundError(err) {
+		err = rs.DB.Where("test_id = ? AND id <> ? AND date <= ? AND state = ?", t.ID, r.ID, r.Date, RunCompleted).
+			Order("date desc").First(prev).Error
 	}
 
-	offset := uint(0)
-	if page >= 0 && num >= 0 {
-		offset = page * num
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	prevLatencies, err := rs.sampleLatencies(prev.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := CompareLatencies(latencies, prevLatencies)
+	if s != nil {
+		s.CompareRunID = prev.ID
+	}
+
+	return s, nil
+}
+
+// sampleLatencies streams the latencies of the run's details in batches into a bounded sample,
+// so that memory does not grow with the number of details
+func (rs *RunService) sampleLatencies(rid uint) ([]float64, error) {
+	sample := NewLatencySample(DefaultSampleSize)
+
+	lastID := uint(0)
+
+	for {
+		var details []*Detail
+		err := rs.DB.Select("id, latency").Where("run_id = ? AND id > ?", rid, lastID).
+			Order("id asc").Limit(config.DefaultBatchSize).Find(&details).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range details {
+			sample.Add(d.Latency)
+		}
+
+		if len(details) < config.DefaultBatchSize {
+			return sample.Latencies(), nil
+		}
+
+		lastID = details[len(details)-1].ID
+	}
+}
+
+// Evaluate evaluates the run against the thresholds and the baseline of the test,
+// setting the status, threshold results and significance of the run.
+// Percentiles not in the latency distribution are computed from the call latencies,
+// or from the stored details of the run if no latencies are given.
+func (rs *RunService) Evaluate(t *Test, r *Run, latencies []float64) (*BaselineComparison, error) {
+	percentiles, err := rs.percentileValues(r, t.GetPercentiles(), latencies)
+	if err != nil {
+		return nil, err
+	}
+
+	significance, err := rs.FindSignificance(t, r, latencies)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Significance = significance
+
+	baseline, err := rs.FindBaseline(t, r.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	orderSQL := sortField + " " + order
+	return t.SetStatus(r, percentiles, baseline), nil
+}
+
+// FindByTestID finds runs by test, along with their latency distribution and histogram if populate is set
+func (rs *RunService) FindByTestID(tid, num, page uint, populate bool) ([]*Run, error) {
+	return rs.FindByTestIDQuery(tid, num, page, NewQuery(RunQueryFields), populate, populate)
+}
 
-	t := &Test{}
-	t.ID = tid
+// FindByTestIDQuery lists the runs of the test matching the query
+func (rs *RunService) FindByTestIDQuery(tid, num, page uint, q *Query, histogram bool, latency bool) ([]*Run, error) {
+	return findRuns(rs.DB, tid, num, page, q, histogram, latency)
+}
 
+// FindByTestIDQueryUnscoped lists the runs of the test matching the query including the ones in the trash.
+// The histogram and the latency distribution of the runs in the trash are populated as well.
+func (rs *RunService) FindByTestIDQueryUnscoped(tid, num, page uint, q *Query, histogram bool, latency bool) ([]*Run, error) {
+	return findRuns(rs.DB.Unscoped(), tid, num, page, q, histogram, latency)
+}
+
+// CountQuery returns the number of runs of the test matching the query
+func (rs *RunService) CountQuery(tid uint, q *Query) (uint, error) {
+	return countRuns(rs.DB, tid, q)
+}
+
+// CountQueryUnscoped returns the number of runs of the test matching the query including the ones in the trash
+func (rs *RunService) CountQueryUnscoped(tid uint, q *Query) (uint, error) {
+	return countRuns(rs.DB.Unscoped(), tid, q)
+}
+
+func findRuns(db *gorm.DB, tid, num, page uint, q *Query, histogram bool, latency bool) ([]*Run, error) {
 	s := make([]*Run, num)
 
-	err := rs.DB.Order(orderSQL).Offset(offset).Limit(num).Model(t).Related(&s).Error
+	err := q.find(db.Where("test_id = ?", tid), "id desc", num, page).Find(&s).Error
 
 	if err != nil {
 		return nil, err
 	}
 
-	if histogram {
-		for _, run := range s {
+	for _, run := range s {
+		if histogram {
 			run.Histogram = make([]*Bucket, 10)
-			err = rs.DB.Model(run).Related(&run.Histogram).Error
-			if err != nil {
+			if err := db.Model(run).Related(&run.Histogram).Error; err != nil {
 				return nil, err
 			}
 		}
-	}
 
-	if latency {
-		for _, run := range s {
+		if latency {
 			run.LatencyDistribution = make([]*LatencyDistribution, 10)
-			err := rs.DB.Model(run).Related(&run.LatencyDistribution).Error
-			if err != nil {
+			if err := db.Model(run).Related(&run.LatencyDistribution).Error; err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	return s, err
+	return s, nil
+}
+
+func countRuns(db *gorm.DB, tid uint, q *Query) (uint, error) {
+	count := uint(0)
+	err := q.where(db.Model(&Run{}).Where("test_id = ?", tid)).Count(&count).Error
+	return count, err
+}
+
+// FindByTestIDSorted lists runs using sorting
+func (rs *RunService) FindByTestIDSorted(tid, num, page uint, sortField, order string,
+	histogram bool, latency bool) ([]*Run, error) {
+	q, err := sortedQuery(RunQueryFields, sortField, order)
+	if err != nil {
+		return nil, err
+	}
+
+	return rs.FindByTestIDQuery(tid, num, page, q, histogram, latency)
+}
+
+// UpdateStatus stores the status and the threshold results of the run
+func (rs *RunService) UpdateStatus(r *Run) error {
+	if err := r.marshalThresholdResults(); err != nil {
+		return err
+	}
+
+	// the columns are set by table since the json columns clash with the ignored fields
+	err := rs.DB.Table("runs").Where("id = ?", r.ID).UpdateColumns(map[string]interface{}{
+		"status":            r.Status,
+		"threshold_results": r.ThresholdResultsJSON,
+	}).Error
+
+	r.ThresholdResultsJSON = ""
+
+	return err
+}
+
+// AddProgress adds the number of details received by the running run to its count
+// and records the time the details were received.
+// Returns gorm.ErrRecordNotFound if the run is not running.
+func (rs *RunService) AddProgress(r *Run, n uint) error {
+	now := gorm.NowFunc()
+
+	res := rs.DB.Model(&Run{}).Where("id = ? AND state = ?", r.ID, RunRunning).UpdateColumns(map[string]interface{}{
+		"count":      gorm.Expr("count + ?", n),
+		"updated_at": now,
+	})
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	r.Count += uint64(n)
+	r.UpdatedAt = now
+
+	return nil
+}
+
+// AbortStale aborts the running runs that last received details before the cutoff
+// and returns the number of runs aborted
+func (rs *RunService) AbortStale(cutoff time.Time) (uint, error) {
+	res := rs.DB.Model(&Run{}).Where("state = ? AND updated_at < ?", RunRunning, cutoff).
+		UpdateColumn("state", RunAborted)
+
+	return uint(res.RowsAffected), res.Error
 }
 
 // Update updates a run
@@ -363,7 +811,25 @@ func (rs *RunService) Update(r *Run) error {
 	return rs.DB.Save(r).Error
 }
 
-// Delete deletes a run
-func (rs *RunService) Delete(r *Run) error {
-	return errors.New("Not Implemented Yet")
+// Delete moves the run along with its details, latency distribution and histogram to the trash
+func (rs *RunService) Delete(r *Run) (*CascadeResult, error) {
+	res := new(CascadeResult)
+
+	err := transact(rs.DB, func(tx *gorm.DB) error {
+		if err := cascadeRuns(tx, trashOp(gorm.NowFunc()), res, "id = ?", r.ID); err != nil {
+			return err
+		}
+
+		if res.Runs == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/bojand/ghz-web/config"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 )
@@ -23,18 +24,31 @@ func TestRunModel_BeforeSave(t *testing.T) {
 		expectError bool
 	}{
 		{"no test id", &Run{}, &Run{}, true},
-		{"with test id", &Run{TestID: 123}, &Run{TestID: 123, Status: "ok"}, false},
+		{"with test id", &Run{TestID: 123}, &Run{TestID: 123, Status: "ok", State: RunCompleted}, false},
 		{"with error dist",
 			&Run{TestID: 123, ErrorDist: map[string]int{"foo": 1, "bar": 2}},
-			&Run{TestID: 123, ErrorDist: map[string]int{"foo": 1, "bar": 2}, ErrorDistJSON: "{\"bar\":2,\"foo\":1}", Status: "fail"},
+			&Run{TestID: 123, ErrorDist: map[string]int{"foo": 1, "bar": 2}, ErrorDistJSON: "{\"bar\":2,\"foo\":1}", Status: "fail", State: RunCompleted},
 			false},
 		{"with status dist",
 			&Run{TestID: 123, StatusCodeDist: map[string]int{"foo": 1, "bar": 2}},
-			&Run{TestID: 123, StatusCodeDist: map[string]int{"foo": 1, "bar": 2}, StatusCodeDistJSON: "{\"bar\":2,\"foo\":1}", Status: "ok"},
+			&Run{TestID: 123, StatusCodeDist: map[string]int{"foo": 1, "bar": 2}, StatusCodeDistJSON: "{\"bar\":2,\"foo\":1}", Status: "ok", State: RunCompleted},
 			false},
 		{"with options",
 			&Run{TestID: 123, Options: &Options{N: 1000, C: 10, Data: map[string]interface{}{"name": "bob"}}},
-			&Run{TestID: 123, Options: &Options{N: 1000, C: 10, Data: map[string]interface{}{"name": "bob"}}, OptionsJSON: "{\"n\":1000,\"c\":10,\"data\":{\"name\":\"bob\"}}", Status: "ok"},
+			&Run{TestID: 123, Options: &Options{N: 1000, C: 10, Data: map[string]interface{}{"name": "bob"}}, OptionsJSON: "{\"n\":1000,\"c\":10,\"data\":{\"name\":\"bob\"}}", Status: "ok", State: RunCompleted},
+			false},
+		{"evaluated with error dist",
+			&Run{TestID: 123, ErrorDist: map[string]int{"foo": 1}, ThresholdResults: []*ThresholdResult{}},
+			&Run{TestID: 123, ErrorDist: map[string]int{"foo": 1}, ErrorDistJSON: "{\"foo\":1}", ThresholdResults: []*ThresholdResult{}, ThresholdResultsJSON: "[]", Status: "ok", State: RunCompleted},
+			false},
+		{"evaluated as failed",
+			&Run{TestID: 123, Status: StatusFail, ThresholdResults: []*ThresholdResult{{Threshold: ThresholdMean, Limit: 10, Value: 20, Status: StatusFail}}},
+			&Run{TestID: 123, Status: StatusFail, ThresholdResults: []*ThresholdResult{{Threshold: ThresholdMean, Limit: 10, Value: 20, Status: StatusFail}},
+				ThresholdResultsJSON: "[{\"threshold\":\"mean\",\"limit\":10,\"value\":20,\"status\":\"fail\"}]", State: RunCompleted},
+			false},
+		{"running",
+			&Run{TestID: 123, State: RunRunning},
+			&Run{TestID: 123, Status: "ok", State: RunRunning},
 			false},
 	}
 
@@ -110,6 +124,10 @@ func TestRunModel_AfterFind(t *testing.T) {
 			&Run{TestID: 123, OptionsJSON: "{\"n\":1000,\"c\":10,\"data\":{\"name\":\"bob\"}}"},
 			&Run{TestID: 123, Options: &Options{N: 1000, C: 10, Data: map[string]interface{}{"name": "bob"}}},
 			false},
+		{"with threshold results",
+			&Run{TestID: 123, ThresholdResultsJSON: "[{\"threshold\":\"mean\",\"limit\":10,\"value\":20,\"status\":\"fail\"}]"},
+			&Run{TestID: 123, ThresholdResults: []*ThresholdResult{{Threshold: ThresholdMean, Limit: 10, Value: 20, Status: StatusFail}}},
+			false},
 	}
 
 	for _, tt := range runs {
@@ -171,6 +189,124 @@ func TestRunModel_GetThresholdValues(t *testing.T) {
 	}
 }
 
+func TestRunModel_GetPercentileValues(t *testing.T) {
+	r := &Run{TestID: 123, LatencyDistribution: []*LatencyDistribution{
+		&LatencyDistribution{Percentage: 50, Latency: milli1},
+		&LatencyDistribution{Percentage: 90, Latency: milli2},
+		&LatencyDistribution{Percentage: 99, Latency: milli3},
+	}}
+
+	var runs = []struct {
+		name            string
+		in              []float64
+		expected        map[Threshold]time.Duration
+		expectedMissing []float64
+	}{
+		{"none", []float64{}, map[Threshold]time.Duration{}, []float64{}},
+		{"in distribution", []float64{90, 99}, map[Threshold]time.Duration{
+			Threshold("p90"): milli2,
+			Threshold("p99"): milli3,
+		}, []float64{}},
+		{"with missing", []float64{99, 99.9}, map[Threshold]time.Duration{
+			Threshold("p99"): milli3,
+		}, []float64{99.9}},
+	}
+
+	for _, tt := range runs {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, missing := r.GetPercentileValues(tt.in)
+
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.expectedMissing, missing)
+		})
+	}
+}
+
+func TestComputePercentiles(t *testing.T) {
+	latencies := make([]float64, 1000)
+	for i := range latencies {
+		// reverse order to make sure we sort
+		latencies[i] = float64(1000 - i)
+	}
+
+	var tests = []struct {
+		name      string
+		latencies []float64
+		in        []float64
+		expected  map[Threshold]time.Duration
+	}{
+		{"no latencies", []float64{}, []float64{99}, map[Threshold]time.Duration{}},
+		{"single latency", []float64{5}, []float64{50, 99.9}, map[Threshold]time.Duration{
+			Threshold("p50"):   5,
+			Threshold("p99.9"): 5,
+		}},
+		{"1000 latencies", latencies, []float64{50, 90, 99, 99.9, 100}, map[Threshold]time.Duration{
+			Threshold("p50"):   500,
+			Threshold("p90"):   900,
+			Threshold("p99"):   990,
+			Threshold("p99.9"): 999,
+			Threshold("p100"):  1000,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := ComputePercentiles(tt.latencies, tt.in)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+
+	assert.Equal(t, float64(1000), latencies[0])
+}
+
+func TestRunModel_GetErrorRate(t *testing.T) {
+	var runs = []struct {
+		name     string
+		in       *Run
+		expected float64
+	}{
+		{"with no count", &Run{ErrorDist: map[string]int{"error": 1}}, 0},
+		{"with no errors", &Run{Count: 100}, 0},
+		{"with errors", &Run{Count: 1000, ErrorDist: map[string]int{"error 1": 3, "error 2": 2}}, 0.5},
+	}
+
+	for _, tt := range runs {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.in.GetErrorRate())
+		})
+	}
+}
+
+func TestRunModel_GetStatusCodeCount(t *testing.T) {
+	r := &Run{
+		Count: 200,
+		ErrorDist: map[string]int{
+			"rpc error: code = Unavailable desc = Service unavialable.": 11,
+			"rpc error: code = Unavailable desc = Connection refused.":  4,
+			"rpc error: code = Internal desc = Internal error.":         29,
+		},
+		StatusCodeDist: map[string]int{"OK": 150, "Internal": 30},
+	}
+
+	var tests = []struct {
+		code         string
+		expected     int
+		expectedRate float64
+	}{
+		{"OK", 150, 75},
+		{"Internal", 30, 15},
+		{"Unavailable", 15, 7.5},
+		{"Unknown", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			assert.Equal(t, tt.expected, r.GetStatusCodeCount(tt.code))
+			assert.Equal(t, tt.expectedRate, r.GetStatusCodeRate(tt.code))
+		})
+	}
+}
+
 func TestRunModel_HasErrors(t *testing.T) {
 	var runs = []struct {
 		name     string
@@ -199,7 +335,7 @@ func TestRunService_Create(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{})
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	dao := RunService{DB: db}
@@ -350,7 +486,7 @@ func TestRunService_Count(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{})
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	dao := RunService{DB: db}
@@ -466,7 +602,7 @@ func TestRunService_FindByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{})
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	dao := RunService{DB: db}
@@ -537,7 +673,7 @@ func TestRunService_FindByTestID(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{})
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	dao := RunService{DB: db}
@@ -713,7 +849,7 @@ func TestRunService_FindLatest(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{})
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 	db.LogMode(true)
 
@@ -876,7 +1012,7 @@ func TestRunService_FindByTestIDSorted(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{})
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	dao := RunService{DB: db}
@@ -1138,7 +1274,7 @@ func TestRunService_Update(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{})
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	dao := RunService{DB: db}
@@ -1239,3 +1375,320 @@ func TestRunService_Update(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestRunService_Delete(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	dao := RunService{DB: db}
+	var rid, otherRID uint
+
+	t.Run("new runs with details", func(t *testing.T) {
+		tst := &Test{
+			Project: &Project{},
+			Name:    "Test 111 ",
+		}
+
+		for i := 0; i < 2; i++ {
+			r := &Run{
+				Test:  tst,
+				Count: 100,
+				LatencyDistribution: []*LatencyDistribution{
+					&LatencyDistribution{Percentage: 50, Latency: milli1},
+					&LatencyDistribution{Percentage: 95, Latency: milli2},
+				},
+				Histogram: []*Bucket{
+					&Bucket{Mark: 0.01, Count: 1},
+				},
+			}
+
+			err := dao.Create(r)
+			assert.NoError(t, err)
+
+			for k := 0; k < 4; k++ {
+				err := db.Create(&Detail{RunID: r.ID, Latency: 1.23}).Error
+				assert.NoError(t, err)
+			}
+
+			if i == 0 {
+				rid = r.ID
+			} else {
+				otherRID = r.ID
+			}
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		r := &Run{}
+		r.ID = rid
+
+		res, err := dao.Delete(r)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &CascadeResult{
+			Runs:                 1,
+			LatencyDistributions: 2,
+			Buckets:              1,
+			Details:              4,
+		}, res)
+
+		_, err = dao.FindByID(rid)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		or, err := dao.FindByID(otherRID)
+		assert.NoError(t, err)
+		assert.Len(t, or.LatencyDistribution, 2)
+		assert.Len(t, or.Histogram, 1)
+
+		count := 0
+		db.Unscoped().Model(&Detail{}).Where("run_id = ?", otherRID).Count(&count)
+		assert.Equal(t, 4, count)
+	})
+
+	t.Run("fail delete unknown run", func(t *testing.T) {
+		r := &Run{}
+		r.ID = 4321
+
+		res, err := dao.Delete(r)
+
+		assert.Error(t, err)
+		assert.Nil(t, res)
+	})
+}
+
+func TestRunService_FindPercentileValues(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	dao := RunService{DB: db}
+	var rid uint
+
+	t.Run("new run with details", func(t *testing.T) {
+		r := &Run{
+			Test: &Test{Project: &Project{}},
+			LatencyDistribution: []*LatencyDistribution{
+				&LatencyDistribution{Percentage: 99, Latency: milli5},
+			},
+		}
+
+		err := dao.Create(r)
+		assert.NoError(t, err)
+
+		for k := 1; k <= 100; k++ {
+			err := db.Create(&Detail{RunID: r.ID, Latency: float64(k) * float64(milli1)}).Error
+			assert.NoError(t, err)
+		}
+
+		rid = r.ID
+	})
+
+	t.Run("computes missing percentiles from details", func(t *testing.T) {
+		r, err := dao.FindByID(rid)
+		assert.NoError(t, err)
+
+		values, err := dao.FindPercentileValues(r, []float64{90, 99, 99.9})
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[Threshold]time.Duration{
+			Threshold("p90"):   90 * milli1,
+			Threshold("p99"):   milli5,
+			Threshold("p99.9"): 100 * milli1,
+		}, values)
+	})
+
+	t.Run("evaluate computes missing percentiles from details", func(t *testing.T) {
+		r, err := dao.FindByID(rid)
+		assert.NoError(t, err)
+
+		tst := &Test{Model: Model{ID: r.TestID}, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("p90"): &ThresholdSetting{Threshold: 50 * milli1},
+			}}
+
+		_, err = dao.Evaluate(tst, r, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, StatusFail, r.Status)
+		assert.Equal(t, []*ThresholdResult{
+			{Threshold: Threshold("p90"), Limit: float64(50 * milli1), Value: float64(90 * milli1), Status: StatusFail},
+		}, r.ThresholdResults)
+	})
+
+	t.Run("samples the details of large runs", func(t *testing.T) {
+		r := &Run{Test: &Test{Project: &Project{}, Name: "large"}}
+		err := dao.Create(r)
+		assert.NoError(t, err)
+
+		// more details than the sample keeps, over several batches
+		n := DefaultSampleSize + 2*config.DefaultBatchSize + 10
+		details := make([]*Detail, n)
+		for k := range details {
+			details[k] = &Detail{Latency: float64(k%100+1) * float64(milli1)}
+		}
+
+		res := (&DetailService{DB: db}).CreateBatch(r.ID, details)
+		assert.Equal(t, uint(n), res.Created)
+
+		latencies, err := dao.sampleLatencies(r.ID)
+		assert.NoError(t, err)
+		assert.Len(t, latencies, DefaultSampleSize)
+
+		values, err := dao.FindPercentileValues(r, []float64{50})
+		assert.NoError(t, err)
+		assert.InDelta(t, float64(50*milli1), float64(values[Threshold("p50")]), float64(3*milli1))
+	})
+}
+
+func TestRunService_UpdateStatus(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	dao := RunService{DB: db}
+
+	r := &Run{
+		Test:      &Test{Project: &Project{}, Name: "update status"},
+		Count:     100,
+		ErrorDist: map[string]int{"error": 1},
+	}
+
+	t.Run("status from error distribution", func(t *testing.T) {
+		err := dao.Create(r)
+		assert.NoError(t, err)
+
+		fr, err := dao.FindByID(r.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusFail, fr.Status)
+		assert.Nil(t, fr.ThresholdResults)
+	})
+
+	t.Run("status from evaluation", func(t *testing.T) {
+		results := []*ThresholdResult{{Threshold: ThresholdErrorRate, Limit: 5, Value: 1, Status: StatusOK}}
+
+		r.Status = StatusOK
+		r.ThresholdResults = results
+
+		err := dao.UpdateStatus(r)
+		assert.NoError(t, err)
+		assert.Empty(t, r.ThresholdResultsJSON)
+
+		fr, err := dao.FindByID(r.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusOK, fr.Status)
+		assert.Equal(t, results, fr.ThresholdResults)
+		assert.Equal(t, uint64(100), fr.Count)
+	})
+
+	t.Run("evaluated status is kept on update", func(t *testing.T) {
+		fr, err := dao.FindByID(r.ID)
+		assert.NoError(t, err)
+
+		fr.Count = 200
+		err = dao.Update(fr)
+		assert.NoError(t, err)
+
+		fr, err = dao.FindByID(r.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusOK, fr.Status)
+		assert.Equal(t, uint64(200), fr.Count)
+	})
+}
+
+func TestRunService_Lifecycle(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	dao := RunService{DB: db}
+
+	tst := &Test{Project: &Project{}, Name: "lifecycle"}
+	err = db.Create(tst).Error
+	assert.NoError(t, err)
+
+	completed := &Run{TestID: tst.ID, Date: time.Now().Add(-time.Hour)}
+	running := &Run{TestID: tst.ID, Date: time.Now(), State: RunRunning}
+
+	t.Run("create", func(t *testing.T) {
+		err := dao.Create(completed)
+		assert.NoError(t, err)
+		assert.Equal(t, RunCompleted, completed.State)
+
+		err = dao.Create(running)
+		assert.NoError(t, err)
+
+		r, err := dao.FindByID(running.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, RunRunning, r.State)
+	})
+
+	t.Run("latest skips running runs", func(t *testing.T) {
+		r, err := dao.FindLatest(tst.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, completed.ID, r.ID)
+	})
+
+	t.Run("add progress", func(t *testing.T) {
+		err := dao.AddProgress(running, 10)
+		assert.NoError(t, err)
+
+		err = dao.AddProgress(running, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(15), running.Count)
+
+		r, err := dao.FindByID(running.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(15), r.Count)
+
+		err = dao.AddProgress(completed, 1)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+
+	t.Run("abort stale", func(t *testing.T) {
+		n, err := dao.AbortStale(time.Now().Add(-time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), n)
+
+		n, err = dao.AbortStale(time.Now().Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), n)
+
+		r, err := dao.FindByID(running.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, RunAborted, r.State)
+		assert.Equal(t, uint64(15), r.Count)
+
+		r, err = dao.FindByID(completed.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, RunCompleted, r.State)
+	})
+}
@@ -0,0 +1,60 @@
+package model
+
+import "math/rand"
+
+// DefaultSampleSize is the default maximum number of latencies kept by a latency sample
+const DefaultSampleSize = 10000
+
+// LatencySample keeps a uniform random sample of bounded size from a stream of
+// latencies using reservoir sampling. It is used to evaluate runs whose details
+// are too many to hold in memory.
+type LatencySample struct {
+	size      int
+	seen      int
+	latencies []float64
+	rnd       *rand.Rand
+}
+
+// NewLatencySample creates a new latency sample keeping at most size latencies.
+// The default sample size is used if size is not positive.
+func NewLatencySample(size int) *LatencySample {
+	s := newLatencySample(size, rand.New(rand.NewSource(1)))
+	s.latencies = make([]float64, 0, s.size)
+
+	return s
+}
+
+// newLatencySample creates a latency sample that grows as latencies are added and draws
+// from the given source, so that many small samples can be kept without allocating ahead
+func newLatencySample(size int, rnd *rand.Rand) *LatencySample {
+	if size <= 0 {
+		size = DefaultSampleSize
+	}
+
+	return &LatencySample{size: size, rnd: rnd}
+}
+
+// Add adds the latency to the sample
+func (s *LatencySample) Add(latency float64) {
+	s.seen++
+
+	if len(s.latencies) < s.size {
+		s.latencies = append(s.latencies, latency)
+		return
+	}
+
+	if i := s.rnd.Intn(s.seen); i < s.size {
+		s.latencies[i] = latency
+	}
+}
+
+// Seen returns the number of latencies added to the sample
+func (s *LatencySample) Seen() int {
+	return s.seen
+}
+
+// Latencies returns the sampled latencies. All the latencies are returned if no more
+// than the sample size were added.
+func (s *LatencySample) Latencies() []float64 {
+	return s.latencies
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencySample(t *testing.T) {
+	t.Run("keeps all latencies up to the size", func(t *testing.T) {
+		s := NewLatencySample(10)
+		for _, l := range seq(1, 10) {
+			s.Add(l)
+		}
+
+		assert.Equal(t, 10, s.Seen())
+		assert.Equal(t, seq(1, 10), s.Latencies())
+	})
+
+	t.Run("bounded size", func(t *testing.T) {
+		s := NewLatencySample(100)
+		for _, l := range seq(1, 100000) {
+			s.Add(l)
+		}
+
+		assert.Equal(t, 100000, s.Seen())
+		assert.Len(t, s.Latencies(), 100)
+
+		// a uniform sample has a median close to the median of the stream
+		assert.InDelta(t, 50000, median(s.Latencies()), 15000)
+	})
+
+	t.Run("grows up to the size with a shared source", func(t *testing.T) {
+		rnd := rand.New(rand.NewSource(1))
+
+		s := newLatencySample(100, rnd)
+		assert.Equal(t, 0, cap(s.Latencies()))
+
+		s2 := newLatencySample(100, rnd)
+		for _, l := range seq(1, 1000) {
+			s.Add(l)
+			s2.Add(l)
+		}
+
+		assert.Len(t, s.Latencies(), 100)
+		assert.Len(t, s2.Latencies(), 100)
+		assert.Equal(t, 1000, s2.Seen())
+	})
+
+	t.Run("default size", func(t *testing.T) {
+		s := NewLatencySample(0)
+		assert.Equal(t, DefaultSampleSize, cap(s.Latencies()))
+	})
+}
@@ -0,0 +1,261 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// SeriesMetric is a run metric of a time series. Latency percentiles are named like p95.
+type SeriesMetric string
+
+const (
+	// SeriesCount is the number of calls of the runs
+	SeriesCount = SeriesMetric("count")
+
+	// SeriesTotal is the total duration of the runs in nanoseconds
+	SeriesTotal = SeriesMetric("total")
+
+	// SeriesAverage is the average latency of the runs in nanoseconds
+	SeriesAverage = SeriesMetric("average")
+
+	// SeriesFastest is the fastest latency of the runs in nanoseconds
+	SeriesFastest = SeriesMetric("fastest")
+
+	// SeriesSlowest is the slowest latency of the runs in nanoseconds
+	SeriesSlowest = SeriesMetric("slowest")
+
+	// SeriesRPS is the requests per second of the runs
+	SeriesRPS = SeriesMetric("rps")
+
+	// SeriesErrors is the percentage of calls of the runs that resulted in an error
+	SeriesErrors = SeriesMetric("errors")
+)
+
+// seriesMetrics are the run summary metrics in the order they are listed
+var seriesMetrics = []SeriesMetric{SeriesCount, SeriesTotal, SeriesAverage,
+	SeriesFastest, SeriesSlowest, SeriesRPS, SeriesErrors}
+
+// ParseSeriesMetric parses the name of a series metric
+func ParseSeriesMetric(name string) (SeriesMetric, error) {
+	for _, m := range seriesMetrics {
+		if string(m) == name {
+			return m, nil
+		}
+	}
+
+	if p, ok := Threshold(name).Percentile(); ok && p == float64(int(p)) {
+		return SeriesMetric(name), nil
+	}
+
+	return "", fmt.Errorf("Invalid metric '%s'. Valid metrics are count, total, average, fastest, "+
+		"slowest, rps, errors and latency percentiles such as p95", name)
+}
+
+// percentage returns the latency distribution percentage of a percentile metric
+func (m SeriesMetric) percentage() (int, bool) {
+	p, ok := Threshold(m).Percentile()
+	return int(p), ok
+}
+
+// value returns the value of the metric for the run, and whether the run has the value
+func (m SeriesMetric) value(r *Run) (float64, bool) {
+	switch m {
+	case SeriesCount:
+		return float64(r.Count), true
+	case SeriesTotal:
+		return float64(r.Total), true
+	case SeriesAverage:
+		return float64(r.Average), true
+	case SeriesFastest:
+		return float64(r.Fastest), true
+	case SeriesSlowest:
+		return float64(r.Slowest), true
+	case SeriesRPS:
+		return r.Rps, true
+	case SeriesErrors:
+		return r.GetErrorRate(), true
+	}
+
+	percentage, _ := m.percentage()
+	for _, l := range r.LatencyDistribution {
+		if l.Percentage == percentage {
+			return float64(l.Latency), true
+		}
+	}
+
+	return 0, false
+}
+
+// SeriesPoint is the downsampled value of a metric over the runs within a time bucket
+type SeriesPoint struct {
+	// The start of the bucket
+	Start time.Time `json:"start"`
+
+	// The number of runs in the bucket with the metric
+	Runs uint `json:"runs"`
+
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+}
+
+// Series is the time series of a metric. Buckets without runs have no point.
+type Series struct {
+	Metric SeriesMetric   `json:"metric"`
+	Points []*SeriesPoint `json:"points"`
+}
+
+// TimeSeries holds the downsampled time series of the metrics of the completed runs of a test
+type TimeSeries struct {
+	TestID uint `json:"testID"`
+
+	// The start and the end of the series
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// The number of buckets the time range is divided into, and the duration of each bucket
+	Buckets        uint          `json:"buckets"`
+	BucketDuration time.Duration `json:"bucketDuration"`
+
+	// The number of runs in the time range
+	Runs uint `json:"runs"`
+
+	Series []*Series `json:"series"`
+}
+
+// FindSeries downsamples the metrics of the completed runs of the test dated within the time range
+// into the number of buckets. A zero from or to defaults to the date of the first or the last run.
+func (rs *RunService) FindSeries(tid uint, metrics []SeriesMetric, from, to time.Time, buckets uint) (*TimeSeries, error) {
+	db := rs.DB.Model(&Run{}).Where("test_id = ? AND state = ?", tid, RunCompleted)
+	if !from.IsZero() {
+		db = db.Where("date >= ?", from)
+	}
+
+	if !to.IsZero() {
+		db = db.Where("date <= ?", to)
+	}
+
+	var runs []*Run
+	err := db.Select("id, date, count, total, average, fastest, slowest, rps, error_dist").
+		Order("date asc, id asc").Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rs.findSeriesPercentiles(runs, metrics); err != nil {
+		return nil, err
+	}
+
+	ts := &TimeSeries{TestID: tid, From: from, To: to, Buckets: buckets, Runs: uint(len(runs))}
+
+	if len(runs) > 0 {
+		if ts.From.IsZero() {
+			ts.From = runs[0].Date
+		}
+
+		if ts.To.IsZero() {
+			ts.To = runs[len(runs)-1].Date
+		}
+	}
+
+	ts.BucketDuration = ts.To.Sub(ts.From) / time.Duration(buckets)
+
+	for _, m := range metrics {
+		ts.Series = append(ts.Series, ts.downsample(m, runs))
+	}
+
+	return ts, nil
+}
+
+// findSeriesPercentiles loads the latency distribution percentiles of the percentile metrics for the runs
+func (rs *RunService) findSeriesPercentiles(runs []*Run, metrics []SeriesMetric) error {
+	var percentages []int
+	for _, m := range metrics {
+		if p, ok := m.percentage(); ok {
+			percentages = append(percentages, p)
+		}
+	}
+
+	if len(percentages) == 0 || len(runs) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint]*Run, len(runs))
+	ids := make([]uint, 0, len(runs))
+
+	for _, r := range runs {
+		byID[r.ID] = r
+		ids = append(ids, r.ID)
+	}
+
+	// the ids are queried in batches to stay within the limits of bound parameters
+	const batchSize = 500
+
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		var dist []*LatencyDistribution
+		err := rs.DB.Where("run_id IN (?) AND percentage IN (?)", ids[start:end], percentages).Find(&dist).Error
+		if err != nil {
+			return err
+		}
+
+		for _, l := range dist {
+			r := byID[l.RunID]
+			r.LatencyDistribution = append(r.LatencyDistribution, l)
+		}
+	}
+
+	return nil
+}
+
+// downsample aggregates the metric of the runs into the buckets of the series
+func (ts *TimeSeries) downsample(m SeriesMetric, runs []*Run) *Series {
+	s := &Series{Metric: m, Points: make([]*SeriesPoint, 0)}
+
+	points := make(map[uint]*SeriesPoint)
+
+	for _, r := range runs {
+		v, ok := m.value(r)
+		if !ok {
+			continue
+		}
+
+		i := uint(0)
+		if ts.BucketDuration > 0 {
+			i = uint(r.Date.Sub(ts.From) / ts.BucketDuration)
+		}
+
+		if i >= ts.Buckets {
+			i = ts.Buckets - 1
+		}
+
+		p := points[i]
+		if p == nil {
+			p = &SeriesPoint{Start: ts.From.Add(time.Duration(i) * ts.BucketDuration), Min: v, Max: v}
+			points[i] = p
+			s.Points = append(s.Points, p)
+		}
+
+		if v < p.Min {
+			p.Min = v
+		}
+
+		if v > p.Max {
+			p.Max = v
+		}
+
+		// the sum is kept in the average until all the runs are added
+		p.Avg += v
+		p.Runs++
+	}
+
+	for _, p := range s.Points {
+		p.Avg = p.Avg / float64(p.Runs)
+	}
+
+	return s
+}
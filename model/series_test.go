@@ -0,0 +1,141 @@
+package model
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSeriesMetric(t *testing.T) {
+	var tests = []struct {
+		in          string
+		expected    SeriesMetric
+		expectError bool
+	}{
+		{"average", SeriesAverage, false},
+		{"rps", SeriesRPS, false},
+		{"errors", SeriesErrors, false},
+		{"p95", SeriesMetric("p95"), false},
+		{"p99.9", "", true},
+		{"p0", "", true},
+		{"median", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			m, err := ParseSeriesMetric(tt.in)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, m)
+			}
+		})
+	}
+}
+
+func TestRunService_FindSeries(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	rs := &RunService{DB: db}
+
+	tst := &Test{Project: &Project{}, Name: "series"}
+
+	start, _ := time.Parse("2006-01-02", "2018-01-01")
+	day := 24 * time.Hour
+
+	t.Run("create runs", func(t *testing.T) {
+		err := db.Create(tst).Error
+		assert.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			r := &Run{
+				TestID:    tst.ID,
+				Date:      start.Add(time.Duration(i) * day),
+				Count:     100,
+				Average:   time.Duration(i+1) * time.Millisecond,
+				Rps:       float64(100 * (i + 1)),
+				ErrorDist: map[string]int{"timeout": i},
+			}
+
+			// the last run has no 95th percentile
+			if i < 9 {
+				r.LatencyDistribution = []*LatencyDistribution{
+					&LatencyDistribution{Percentage: 50, Latency: time.Duration(i+1) * time.Millisecond},
+					&LatencyDistribution{Percentage: 95, Latency: time.Duration(2*(i+1)) * time.Millisecond},
+				}
+			}
+
+			assert.NoError(t, rs.Create(r))
+		}
+
+		running := &Run{TestID: tst.ID, Date: start.Add(10 * day), Average: time.Second, State: RunRunning}
+		assert.NoError(t, rs.Create(running))
+	})
+
+	t.Run("downsamples the runs", func(t *testing.T) {
+		ts, err := rs.FindSeries(tst.ID, []SeriesMetric{SeriesAverage, SeriesErrors, "p95"}, time.Time{}, time.Time{}, 5)
+		assert.NoError(t, err)
+
+		assert.Equal(t, tst.ID, ts.TestID)
+		assert.Equal(t, uint(10), ts.Runs)
+		assert.True(t, start.Equal(ts.From))
+		assert.True(t, start.Add(9*day).Equal(ts.To))
+		assert.Equal(t, 9*day/5, ts.BucketDuration)
+		assert.Len(t, ts.Series, 3)
+
+		avg := ts.Series[0]
+		assert.Equal(t, SeriesAverage, avg.Metric)
+		assert.Len(t, avg.Points, 5)
+
+		for i, p := range avg.Points {
+			assert.Equal(t, uint(2), p.Runs)
+			assert.Equal(t, float64(time.Duration(2*i+1)*time.Millisecond), p.Min)
+			assert.Equal(t, float64(time.Duration(2*i+2)*time.Millisecond), p.Max)
+			assert.Equal(t, float64(time.Duration(2*i+1)*time.Millisecond+500*time.Microsecond), p.Avg)
+			assert.True(t, start.Add(time.Duration(i)*ts.BucketDuration).Equal(p.Start))
+		}
+
+		errors := ts.Series[1]
+		assert.Equal(t, float64(0), errors.Points[0].Min)
+		assert.Equal(t, float64(1), errors.Points[0].Max)
+		assert.Equal(t, float64(9), errors.Points[4].Max)
+
+		p95 := ts.Series[2]
+		assert.Len(t, p95.Points, 5)
+		assert.Equal(t, uint(1), p95.Points[4].Runs)
+		assert.Equal(t, float64(18*time.Millisecond), p95.Points[4].Avg)
+	})
+
+	t.Run("within time range", func(t *testing.T) {
+		ts, err := rs.FindSeries(tst.ID, []SeriesMetric{SeriesRPS}, start.Add(2*day), start.Add(5*day), 200)
+		assert.NoError(t, err)
+
+		assert.Equal(t, uint(4), ts.Runs)
+		assert.Len(t, ts.Series[0].Points, 4)
+		assert.Equal(t, float64(300), ts.Series[0].Points[0].Avg)
+		assert.Equal(t, float64(600), ts.Series[0].Points[3].Avg)
+	})
+
+	t.Run("no runs", func(t *testing.T) {
+		ts, err := rs.FindSeries(12345, []SeriesMetric{SeriesRPS}, time.Time{}, time.Time{}, 10)
+		assert.NoError(t, err)
+
+		assert.Equal(t, uint(0), ts.Runs)
+		assert.Len(t, ts.Series, 1)
+		assert.Empty(t, ts.Series[0].Points)
+	})
+}
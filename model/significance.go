@@ -0,0 +1,136 @@
+package model
+
+import (
+	"math"
+	"sort"
+)
+
+// SignificanceLevel is the default significance level for regression detection
+const SignificanceLevel = 0.05
+
+// SignificanceResult is the outcome of a significance test between two runs
+type SignificanceResult string
+
+const (
+	// SignificanceRegressed means the run is significantly slower
+	SignificanceRegressed = SignificanceResult("regressed")
+
+	// SignificanceImproved means the run is significantly faster
+	SignificanceImproved = SignificanceResult("improved")
+
+	// SignificanceNone means there is no significant change
+	SignificanceNone = SignificanceResult("none")
+)
+
+// Significance is the result of a Mann-Whitney U test comparing the call latencies
+// of a run against the latencies of another run
+type Significance struct {
+	// The id of the run compared against
+	CompareRunID uint `json:"compareRunID"`
+
+	// Whether the run regressed, improved or had no significant change
+	Result SignificanceResult `json:"result"`
+
+	// Two-sided p-value of the test
+	PValue float64 `json:"pValue"`
+
+	// Rank-biserial correlation between -1 and 1. Positive values mean the run is slower.
+	EffectSize float64 `json:"effectSize"`
+
+	// Change of the median latency relative to the compared run as a percentage
+	MedianChange float64 `json:"medianChange"`
+}
+
+// IsRegression returns whether the significance shows a regression that is significant
+// at the given level and with a median latency increase larger than minChange percent.
+// The default significance level is used if alpha is not set.
+func (s *Significance) IsRegression(alpha, minChange float64) bool {
+	if alpha <= 0 {
+		alpha = SignificanceLevel
+	}
+
+	return s.PValue < alpha && s.EffectSize > 0 && s.MedianChange > minChange
+}
+
+// CompareLatencies performs a Mann-Whitney U test of the latencies against the
+// previous latencies. Returns nil if either sample has fewer than 2 values.
+func CompareLatencies(latencies, previous []float64) *Significance {
+	n1, n2 := len(latencies), len(previous)
+	if n1 < 2 || n2 < 2 {
+		return nil
+	}
+
+	type sample struct {
+		val     float64
+		current bool
+	}
+
+	all := make([]sample, 0, n1+n2)
+	for _, v := range latencies {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range previous {
+		all = append(all, sample{v, false})
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].val < all[j].val })
+
+	// rank with ties getting the average rank
+	n := len(all)
+	rankSum := 0.0
+	tieSum := 0.0
+	for i := 0; i < n; {
+		j := i
+		for j < n && all[j].val == all[i].val {
+			j++
+		}
+
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].current {
+				rankSum += rank
+			}
+		}
+
+		t := float64(j - i)
+		tieSum += t*t*t - t
+
+		i = j
+	}
+
+	fn1, fn2, fn := float64(n1), float64(n2), float64(n)
+
+	u := rankSum - fn1*(fn1+1)/2
+	mu := fn1 * fn2 / 2
+	sigma := math.Sqrt(fn1 * fn2 / 12 * ((fn + 1) - tieSum/(fn*(fn-1))))
+
+	pValue := 1.0
+	if sigma > 0 {
+		// normal approximation with continuity correction
+		z := (math.Abs(u-mu) - 0.5) / sigma
+		if z < 0 {
+			z = 0
+		}
+		pValue = math.Erfc(z / math.Sqrt2)
+	}
+
+	s := &Significance{
+		Result:     SignificanceNone,
+		PValue:     pValue,
+		EffectSize: 2*u/(fn1*fn2) - 1,
+	}
+
+	if prevMedian := median(previous); prevMedian != 0 {
+		s.MedianChange = (median(latencies) - prevMedian) * 100 / prevMedian
+	}
+
+	if pValue < SignificanceLevel {
+		if s.EffectSize > 0 {
+			s.Result = SignificanceRegressed
+		} else if s.EffectSize < 0 {
+			s.Result = SignificanceImproved
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func seq(from, to int) []float64 {
+	s := make([]float64, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		s = append(s, float64(i))
+	}
+	return s
+}
+
+func TestCompareLatencies(t *testing.T) {
+	var tests = []struct {
+		name           string
+		latencies      []float64
+		previous       []float64
+		expectedResult SignificanceResult
+		expectedP      float64
+		expectedEffect float64
+		expectedChange float64
+	}{
+		{"identical", seq(1, 100), seq(1, 100), SignificanceNone, 1, 0, 0},
+		{"faster", seq(1, 5), seq(6, 10), SignificanceImproved, 0.0121858, -1, -62.5},
+		{"slower", seq(6, 10), seq(1, 5), SignificanceRegressed, 0.0121858, 1, 166.6667},
+		{"noise", []float64{10, 12, 11, 13, 9, 10}, []float64{11, 10, 12, 9, 13, 10}, SignificanceNone, 1, 0, 0},
+		{"shifted with overlap", seq(20, 119), seq(1, 100), SignificanceRegressed, 0.0000267, 0.3439, 37.6238},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := CompareLatencies(tt.latencies, tt.previous)
+
+			assert.NotNil(t, actual)
+			assert.Equal(t, tt.expectedResult, actual.Result)
+			assert.InDelta(t, tt.expectedP, actual.PValue, 0.0000005)
+			assert.InDelta(t, tt.expectedEffect, actual.EffectSize, 0.0001)
+			assert.InDelta(t, tt.expectedChange, actual.MedianChange, 0.0001)
+		})
+	}
+
+	t.Run("not enough latencies", func(t *testing.T) {
+		assert.Nil(t, CompareLatencies([]float64{1}, seq(1, 10)))
+		assert.Nil(t, CompareLatencies(seq(1, 10), []float64{}))
+	})
+}
+
+func TestSignificance_IsRegression(t *testing.T) {
+	s := &Significance{Result: SignificanceRegressed, PValue: 0.01, EffectSize: 0.4, MedianChange: 12}
+
+	assert.True(t, s.IsRegression(0, 10))
+	assert.False(t, s.IsRegression(0, 15))
+	assert.False(t, s.IsRegression(0.005, 10))
+	assert.False(t, (&Significance{PValue: 0.01, EffectSize: -0.4, MedianChange: 12}).IsRegression(0, 10))
+	assert.False(t, (&Significance{PValue: 0.2, EffectSize: 0.4, MedianChange: 12}).IsRegression(0, 10))
+}
+
+func TestTestModel_SetStatus_Regression(t *testing.T) {
+	var tests = []struct {
+		name           string
+		model          *Test
+		in             *Significance
+		expectedStatus Status
+		expectedThold  Status
+	}{
+		{"no significance", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdRegression: &ThresholdSetting{MaxRegression: 10},
+			}}, nil, StatusOK, StatusOK},
+		{"significant but small", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdRegression: &ThresholdSetting{MaxRegression: 10},
+			}}, &Significance{PValue: 0.001, EffectSize: 0.2, MedianChange: 5}, StatusOK, StatusOK},
+		{"large but not significant", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdRegression: &ThresholdSetting{MaxRegression: 10},
+			}}, &Significance{PValue: 0.3, EffectSize: 0.2, MedianChange: 25}, StatusOK, StatusOK},
+		{"significant and large", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdRegression: &ThresholdSetting{MaxRegression: 10},
+			}}, &Significance{PValue: 0.001, EffectSize: 0.2, MedianChange: 25}, StatusFail, StatusFail},
+		{"significant and large as key metric", &Test{FailOnKeyMetric: true, KeyMetric: ThresholdRegression,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdRegression: &ThresholdSetting{MaxRegression: 10, NumericalThreshold: NumericalLimit(0.01)},
+			}}, &Significance{PValue: 0.001, EffectSize: 0.2, MedianChange: 25}, StatusFail, StatusFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := tt.model
+			actual.SetStatus(&Run{Significance: tt.in}, nil, &Baseline{Values: map[Threshold]float64{}})
+
+			assert.Equal(t, tt.expectedStatus, actual.Status)
+			assert.Equal(t, tt.expectedThold, actual.Thresholds[ThresholdRegression].Status)
+		})
+	}
+}
+
+func TestRunService_FindSignificance(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	dao := RunService{DB: db}
+	tst := &Test{Project: &Project{}, Name: "significance"}
+	now := time.Now()
+
+	var rids []uint
+
+	t.Run("no previous run", func(t *testing.T) {
+		err := db.Create(tst).Error
+		assert.NoError(t, err)
+
+		s, err := dao.FindSignificance(tst, &Run{TestID: tst.ID, Date: now}, seq(1, 10))
+		assert.NoError(t, err)
+		assert.Nil(t, s)
+	})
+
+	t.Run("create runs", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			r := &Run{TestID: tst.ID, Date: now.Add(time.Duration(i-2) * time.Hour)}
+			err := dao.Create(r)
+			assert.NoError(t, err)
+
+			for _, l := range seq(1+i*50, 20+i*50) {
+				err := db.Create(&Detail{RunID: r.ID, Latency: l}).Error
+				assert.NoError(t, err)
+			}
+
+			rids = append(rids, r.ID)
+		}
+	})
+
+	t.Run("compares against predecessor", func(t *testing.T) {
+		s, err := dao.FindSignificance(tst, &Run{TestID: tst.ID, Date: now}, seq(51, 70))
+		assert.NoError(t, err)
+		assert.NotNil(t, s)
+		assert.Equal(t, rids[1], s.CompareRunID)
+		assert.Equal(t, SignificanceNone, s.Result)
+	})
+
+	t.Run("compares against pinned baseline", func(t *testing.T) {
+		tst.BaselineRunID = rids[0]
+
+		s, err := dao.FindSignificance(tst, &Run{TestID: tst.ID, Date: now}, seq(51, 70))
+		assert.NoError(t, err)
+		assert.NotNil(t, s)
+		assert.Equal(t, rids[0], s.CompareRunID)
+		assert.Equal(t, SignificanceRegressed, s.Result)
+	})
+
+	t.Run("significance is stored with the run", func(t *testing.T) {
+		s := &Significance{CompareRunID: rids[0], Result: SignificanceImproved, PValue: 0.01, EffectSize: -0.5}
+		r := &Run{TestID: tst.ID, Date: now, Significance: s}
+		err := dao.Create(r)
+		assert.NoError(t, err)
+
+		fr, err := dao.FindByID(r.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, s, fr.Significance)
+	})
+}
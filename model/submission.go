@@ -0,0 +1,83 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Submission records a submission of raw results, so that the same results
+// submitted again return the original response instead of being created again
+type Submission struct {
+	Model
+
+	// The idempotency key sent along with the results, if any
+	IdempotencyKey *string `json:"idempotencyKey,omitempty" gorm:"unique_index"`
+
+	// Hash of the submitted run summary
+	Hash string `json:"hash" gorm:"index"`
+
+	// The test the results were submitted to. Zero for results submitted
+	// as new, which create their own project and test.
+	TestID uint `json:"testID"`
+
+	// The created run
+	RunID uint `json:"runID"`
+
+	// The original response
+	Response string `json:"-" gorm:"type:text"`
+}
+
+// SubmissionService is our implementation
+type SubmissionService struct {
+	DB *gorm.DB
+}
+
+// liveRunsCond matches submissions whose runs still exist and are not in the trash
+const liveRunsCond = "run_id IN (SELECT id FROM runs WHERE deleted_at IS NULL)"
+
+// FindByKey finds the submission with the idempotency key created since the time.
+// Returns nil if there is no such submission.
+func (ss *SubmissionService) FindByKey(key string, since time.Time) (*Submission, error) {
+	return ss.find(since, "idempotency_key = ?", key)
+}
+
+// FindByHash finds the latest submission of the content hash to the test created since the time.
+// Returns nil if there is no such submission.
+func (ss *SubmissionService) FindByHash(tid uint, hash string, since time.Time) (*Submission, error) {
+	return ss.find(since, "test_id = ? AND hash = ?", tid, hash)
+}
+
+func (ss *SubmissionService) find(since time.Time, query string, args ...interface{}) (*Submission, error) {
+	s := new(Submission)
+
+	err := ss.DB.Where(query, args...).Where("created_at >= ?", since).Where(liveRunsCond).
+		Order("created_at desc").Order("id desc").First(s).Error
+
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Create records the submission and deletes the submissions created before expiredBefore.
+// A previous submission with the same idempotency key is replaced if its run no longer exists.
+func (ss *SubmissionService) Create(s *Submission, expiredBefore time.Time) error {
+	return transact(ss.DB, func(tx *gorm.DB) error {
+		stale := tx.Unscoped().Where("created_at < ?", expiredBefore)
+		if s.IdempotencyKey != nil {
+			stale = stale.Or("idempotency_key = ? AND NOT "+liveRunsCond, *s.IdempotencyKey)
+		}
+
+		if err := stale.Delete(&Submission{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(s).Error
+	})
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmissionService(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &LatencyDistribution{}, &Bucket{},
+		&StatusChange{}, &Submission{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ss := &SubmissionService{DB: db}
+	rs := &RunService{DB: db}
+
+	o := &Test{Project: &Project{}}
+	err = db.Create(o).Error
+	assert.NoError(t, err)
+
+	r := &Run{TestID: o.ID}
+	err = db.Create(r).Error
+	assert.NoError(t, err)
+
+	r2 := &Run{TestID: o.ID}
+	err = db.Create(r2).Error
+	assert.NoError(t, err)
+
+	key := "build-1"
+	now := time.Now()
+	hour := now.Add(-time.Hour)
+
+	t.Run("not found", func(t *testing.T) {
+		s, err := ss.FindByKey(key, hour)
+		assert.NoError(t, err)
+		assert.Nil(t, s)
+
+		s, err = ss.FindByHash(o.ID, "abc", hour)
+		assert.NoError(t, err)
+		assert.Nil(t, s)
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		err := ss.Create(&Submission{IdempotencyKey: &key, Hash: "abc", TestID: o.ID, RunID: r.ID, Response: "{}"}, hour)
+		assert.NoError(t, err)
+
+		err = ss.Create(&Submission{Hash: "def", TestID: o.ID, RunID: r2.ID, Response: "{}"}, hour)
+		assert.NoError(t, err)
+	})
+
+	t.Run("FindByKey", func(t *testing.T) {
+		s, err := ss.FindByKey(key, hour)
+		assert.NoError(t, err)
+		assert.NotNil(t, s)
+		assert.Equal(t, r.ID, s.RunID)
+		assert.Equal(t, "{}", s.Response)
+
+		s, err = ss.FindByKey(key, now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Nil(t, s)
+	})
+
+	t.Run("FindByHash", func(t *testing.T) {
+		s, err := ss.FindByHash(o.ID, "def", hour)
+		assert.NoError(t, err)
+		assert.NotNil(t, s)
+		assert.Equal(t, r2.ID, s.RunID)
+
+		s, err = ss.FindByHash(0, "def", hour)
+		assert.NoError(t, err)
+		assert.Nil(t, s)
+	})
+
+	t.Run("ignores submissions of deleted runs", func(t *testing.T) {
+		_, err := rs.Delete(r)
+		assert.NoError(t, err)
+
+		s, err := ss.FindByKey(key, hour)
+		assert.NoError(t, err)
+		assert.Nil(t, s)
+	})
+
+	t.Run("Create replaces key of deleted run", func(t *testing.T) {
+		err := ss.Create(&Submission{IdempotencyKey: &key, Hash: "ghi", TestID: o.ID, RunID: r2.ID}, hour)
+		assert.NoError(t, err)
+
+		s, err := ss.FindByKey(key, hour)
+		assert.NoError(t, err)
+		assert.NotNil(t, s)
+		assert.Equal(t, "ghi", s.Hash)
+	})
+
+	t.Run("Create deletes expired", func(t *testing.T) {
+		err := ss.Create(&Submission{Hash: "jkl", TestID: o.ID, RunID: r2.ID}, now.Add(time.Minute))
+		assert.NoError(t, err)
+
+		count := 0
+		err = db.Model(&Submission{}).Count(&count).Error
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
@@ -0,0 +1,224 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// summaryPercentiles are the percentages of the latency distribution computed by ghz
+var summaryPercentiles = []float64{10, 25, 50, 75, 90, 95, 99}
+
+// histogramBuckets is the number of histogram buckets between the fastest and slowest calls.
+// ghz adds a final bucket for the slowest call.
+const histogramBuckets = 10
+
+// Summarizer computes the summary of a run from the details of its calls as they are added,
+// using the same rules as ghz. The average is over all calls, while the fastest and slowest
+// calls, the latency distribution and the histogram are over the successful calls only.
+type Summarizer struct {
+	count  uint64
+	sum    float64
+	errors map[string]int
+	codes  map[string]int
+
+	// the span of the calls
+	start, end time.Time
+
+	// latencies of the successful calls
+	okCount          int
+	fastest, slowest float64
+	sample           *LatencySample
+	latencies        []float64
+}
+
+// NewSummarizer creates a new summarizer. The latency distribution and histogram are
+// computed from a sample of at most sampleSize successful calls, or from all the
+// successful calls if sampleSize is not positive.
+func NewSummarizer(sampleSize int) *Summarizer {
+	s := &Summarizer{errors: make(map[string]int), codes: make(map[string]int)}
+
+	if sampleSize > 0 {
+		s.sample = NewLatencySample(sampleSize)
+	}
+
+	return s
+}
+
+// Add adds the detail of a call
+func (s *Summarizer) Add(d *Detail) {
+	s.count++
+	s.sum += d.Latency
+
+	if !d.Timestamp.IsZero() {
+		if s.start.IsZero() || d.Timestamp.Before(s.start) {
+			s.start = d.Timestamp
+		}
+
+		if end := d.Timestamp.Add(time.Duration(d.Latency)); end.After(s.end) {
+			s.end = end
+		}
+	}
+
+	status := d.Status
+	if status == "" {
+		status = "OK"
+	}
+
+	s.codes[status]++
+
+	if d.Error != "" {
+		s.errors[d.Error]++
+		return
+	}
+
+	if s.okCount == 0 || d.Latency < s.fastest {
+		s.fastest = d.Latency
+	}
+
+	if s.okCount == 0 || d.Latency > s.slowest {
+		s.slowest = d.Latency
+	}
+
+	s.okCount++
+
+	if s.sample != nil {
+		s.sample.Add(d.Latency)
+	} else {
+		s.latencies = append(s.latencies, d.Latency)
+	}
+}
+
+// Count returns the number of calls added
+func (s *Summarizer) Count() uint64 {
+	return s.count
+}
+
+// Summarize sets the summary of the run computed from the calls added so far.
+// The total is the time from the start of the first call to the end of the last one,
+// or the sum of the latencies if the calls have no timestamps.
+// The date of the run is set to the start of the first call if it is not set.
+func (s *Summarizer) Summarize(r *Run) {
+	r.Count = s.count
+	r.Average, r.Fastest, r.Slowest, r.Total, r.Rps = 0, 0, 0, 0, 0
+	r.ErrorDist, r.StatusCodeDist = nil, nil
+	r.LatencyDistribution, r.Histogram = nil, nil
+
+	if s.count == 0 {
+		return
+	}
+
+	r.Average = time.Duration(s.sum / float64(s.count))
+
+	r.Total = time.Duration(s.sum)
+	if !s.start.IsZero() {
+		r.Total = s.end.Sub(s.start)
+	}
+
+	if r.Total > 0 {
+		r.Rps = float64(s.count) / r.Total.Seconds()
+	}
+
+	if r.Date.IsZero() {
+		r.Date = s.start
+	}
+
+	if len(s.errors) > 0 {
+		r.ErrorDist = copyCounts(s.errors)
+	}
+
+	r.StatusCodeDist = copyCounts(s.codes)
+
+	if s.okCount == 0 {
+		return
+	}
+
+	r.Fastest = time.Duration(s.fastest)
+	r.Slowest = time.Duration(s.slowest)
+
+	latencies := s.latencies
+	if s.sample != nil {
+		latencies = s.sample.Latencies()
+	}
+
+	sorted := make([]float64, len(latencies))
+	copy(sorted, latencies)
+	sort.Float64s(sorted)
+
+	r.LatencyDistribution = latencyDistribution(sorted)
+	r.Histogram = histogram(sorted, s.fastest, s.slowest)
+}
+
+// Summarize sets the summary of the run computed from the details of all its calls
+func (r *Run) Summarize(details []*Detail) {
+	s := NewSummarizer(0)
+	for _, d := range details {
+		s.Add(d)
+	}
+
+	s.Summarize(r)
+}
+
+// latencyDistribution computes the latency distribution of the sorted latencies as ghz does.
+// The latency of a percentage is the first latency whose rank, as a whole percentage
+// of the number of calls, reaches it. Each call sets at most one percentage, and the
+// percentages not reached by any call are left at 0, as they are in ghz summaries.
+func latencyDistribution(sorted []float64) []*LatencyDistribution {
+	dist := make([]*LatencyDistribution, len(summaryPercentiles))
+	for i, p := range summaryPercentiles {
+		dist[i] = &LatencyDistribution{Percentage: int(p)}
+	}
+
+	j := 0
+	for i := 0; i < len(sorted) && j < len(dist); i++ {
+		if i*100/len(sorted) >= dist[j].Percentage {
+			dist[j].Latency = time.Duration(sorted[i])
+			j++
+		}
+	}
+
+	return dist
+}
+
+// histogram computes the histogram of the sorted latencies with evenly sized buckets
+// from the fastest to the slowest call. A latency is counted in the first bucket whose
+// mark it does not exceed. Marks are in seconds.
+func histogram(sorted []float64, fastest, slowest float64) []*Bucket {
+	marks := make([]float64, histogramBuckets+1)
+	counts := make([]int, histogramBuckets+1)
+
+	size := (slowest - fastest) / histogramBuckets
+	for i := 0; i < histogramBuckets; i++ {
+		marks[i] = fastest + size*float64(i)
+	}
+
+	marks[histogramBuckets] = slowest
+
+	b := 0
+	for _, l := range sorted {
+		for l > marks[b] && b < histogramBuckets {
+			b++
+		}
+
+		counts[b]++
+	}
+
+	buckets := make([]*Bucket, len(marks))
+	for i, mark := range marks {
+		buckets[i] = &Bucket{
+			Mark:      mark / float64(time.Second),
+			Count:     counts[i],
+			Frequency: float64(counts[i]) / float64(len(sorted)),
+		}
+	}
+
+	return buckets
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	c := make(map[string]int, len(counts))
+	for k, v := range counts {
+		c[k] = v
+	}
+
+	return c
+}
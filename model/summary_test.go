@@ -0,0 +1,145 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_Summarize(t *testing.T) {
+	start := time.Date(2018, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	details := make([]*Detail, 0)
+	for i := 1; i <= 10; i++ {
+		details = append(details, &Detail{
+			Timestamp: start.Add(time.Duration(i-1) * 100 * time.Millisecond),
+			Latency:   float64(time.Duration(i) * time.Millisecond),
+			Status:    "OK",
+		})
+	}
+
+	details = append(details, &Detail{
+		Timestamp: start.Add(50 * time.Millisecond),
+		Latency:   float64(100 * time.Millisecond),
+		Status:    "Unavailable",
+		Error:     "rpc error: code = Unavailable",
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		r := new(Run)
+		r.Summarize(details)
+
+		assert.Equal(t, uint64(11), r.Count)
+		assert.Equal(t, start, r.Date)
+		assert.Equal(t, 910*time.Millisecond, r.Total)
+		assert.InDelta(t, 11/0.91, r.Rps, 0.0001)
+		assert.Equal(t, 155*time.Millisecond/11, r.Average)
+
+		// the failed call is not counted in the fastest and slowest calls
+		assert.Equal(t, time.Millisecond, r.Fastest)
+		assert.Equal(t, 10*time.Millisecond, r.Slowest)
+
+		assert.Equal(t, map[string]int{"rpc error: code = Unavailable": 1}, r.ErrorDist)
+		assert.Equal(t, map[string]int{"OK": 10, "Unavailable": 1}, r.StatusCodeDist)
+	})
+
+	t.Run("latency distribution", func(t *testing.T) {
+		r := new(Run)
+		r.Summarize(details)
+
+		expected := []struct {
+			percentage int
+			latency    time.Duration
+		}{
+			{10, 2 * time.Millisecond},
+			{25, 4 * time.Millisecond},
+			{50, 6 * time.Millisecond},
+			{75, 9 * time.Millisecond},
+			{90, 10 * time.Millisecond},
+			{95, 0},
+			{99, 0},
+		}
+
+		assert.Len(t, r.LatencyDistribution, len(expected))
+		for i, e := range expected {
+			assert.Equal(t, e.percentage, r.LatencyDistribution[i].Percentage)
+			assert.Equal(t, e.latency, r.LatencyDistribution[i].Latency, "p%d", e.percentage)
+		}
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		r := new(Run)
+		r.Summarize(details)
+
+		assert.Len(t, r.Histogram, 11)
+		assert.InDelta(t, 0.001, r.Histogram[0].Mark, 1e-12)
+		assert.InDelta(t, 0.0019, r.Histogram[1].Mark, 1e-12)
+		assert.InDelta(t, 0.010, r.Histogram[10].Mark, 1e-12)
+
+		counts := make([]int, len(r.Histogram))
+		total := 0.0
+		for i, b := range r.Histogram {
+			counts[i] = b.Count
+			total += b.Frequency
+		}
+
+		assert.Equal(t, []int{1, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1}, counts)
+		assert.InDelta(t, 1, total, 0.0001)
+	})
+
+	t.Run("without timestamps", func(t *testing.T) {
+		r := new(Run)
+		r.Summarize([]*Detail{{Latency: 1e6}, {Latency: 3e6}})
+
+		assert.True(t, r.Date.IsZero())
+		assert.Equal(t, 4*time.Millisecond, r.Total)
+		assert.Equal(t, 2*time.Millisecond, r.Average)
+		assert.InDelta(t, 500, r.Rps, 0.0001)
+		assert.Nil(t, r.ErrorDist)
+		assert.Equal(t, map[string]int{"OK": 2}, r.StatusCodeDist)
+	})
+
+	t.Run("only errors", func(t *testing.T) {
+		r := new(Run)
+		r.Summarize([]*Detail{{Latency: 1e6, Error: "failed"}})
+
+		assert.Equal(t, uint64(1), r.Count)
+		assert.Equal(t, time.Millisecond, r.Average)
+		assert.Zero(t, r.Fastest)
+		assert.Nil(t, r.LatencyDistribution)
+		assert.Nil(t, r.Histogram)
+	})
+
+	t.Run("no details", func(t *testing.T) {
+		r := &Run{Count: 5, Average: time.Second}
+		r.Summarize(nil)
+
+		assert.Zero(t, r.Count)
+		assert.Zero(t, r.Average)
+	})
+}
+
+func TestSummarizer_Sample(t *testing.T) {
+	s := NewSummarizer(100)
+	for i := 1; i <= 10000; i++ {
+		s.Add(&Detail{Latency: float64(i)})
+	}
+
+	r := new(Run)
+	s.Summarize(r)
+
+	assert.Equal(t, uint64(10000), s.Count())
+	assert.Equal(t, uint64(10000), r.Count)
+	assert.Equal(t, time.Duration(1), r.Fastest)
+	assert.Equal(t, time.Duration(10000), r.Slowest)
+	assert.Len(t, r.LatencyDistribution, 7)
+	assert.InDelta(t, 5000, float64(r.LatencyDistribution[2].Latency), 1500)
+
+	total := 0
+	for _, b := range r.Histogram {
+		total += b.Count
+	}
+
+	assert.Equal(t, 100, total)
+}
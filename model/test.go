@@ -3,6 +3,8 @@ package model
 import (
 	"encoding/json"
 	"errors"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,13 +33,86 @@ const (
 
 	// ThresholdRPS is the threshold for the RPS metric
 	ThresholdRPS = Threshold("rps")
+
+	// ThresholdErrorRate is the threshold for the maximum percentage of calls that resulted in an error
+	ThresholdErrorRate = Threshold("errorRate")
+
+	// ThresholdRegression is the threshold for a statistically significant latency regression.
+	// It fails if the run's significance shows a regression at the NumericalThreshold
+	// significance level, with a median latency increase over MaxRegression percent.
+	ThresholdRegression = Threshold("regression")
+)
+
+const (
+	statusCountPrefix = "status:"
+	statusRatePrefix  = "statusRate:"
 )
 
+// StatusCountThreshold returns the threshold for the maximum number of calls
+// with the status code, for example status:Unavailable
+func StatusCountThreshold(code string) Threshold {
+	return Threshold(statusCountPrefix + code)
+}
+
+// StatusRateThreshold returns the threshold for the minimum percentage of calls
+// with the status code, for example statusRate:OK
+func StatusRateThreshold(code string) Threshold {
+	return Threshold(statusRatePrefix + code)
+}
+
+// StatusCount returns the status code of a status count threshold,
+// and whether the threshold is a status count threshold
+func (t Threshold) StatusCount() (string, bool) {
+	return thresholdSuffix(t, statusCountPrefix)
+}
+
+// StatusRate returns the status code of a status rate threshold,
+// and whether the threshold is a status rate threshold
+func (t Threshold) StatusRate() (string, bool) {
+	return thresholdSuffix(t, statusRatePrefix)
+}
+
+func thresholdSuffix(t Threshold, prefix string) (string, bool) {
+	str := string(t)
+	if !strings.HasPrefix(str, prefix) || len(str) == len(prefix) {
+		return "", false
+	}
+
+	return str[len(prefix):], true
+}
+
+// PercentileThreshold returns the threshold for the latency percentile, for example p99 or p99.9
+func PercentileThreshold(p float64) Threshold {
+	return Threshold("p" + strconv.FormatFloat(p, 'f', -1, 64))
+}
+
+// Percentile returns the latency percentile of a percentile threshold such as p99 or p99.9,
+// and whether the threshold is a percentile threshold
+func (t Threshold) Percentile() (float64, bool) {
+	str := string(t)
+	if len(str) < 2 || str[0] != 'p' {
+		return 0, false
+	}
+
+	p, err := strconv.ParseFloat(str[1:], 64)
+	if err != nil || p <= 0 || p > 100 {
+		return 0, false
+	}
+
+	return p, true
+}
+
 // ThresholdSetting setting
 type ThresholdSetting struct {
-	Status             Status        `json:"status" validate:"oneof=ok fail"`
-	Threshold          time.Duration `json:"threshold,omitempty"`
-	NumericalThreshold float64       `json:"numericalThreshold,omitempty"`
+	Status    Status        `json:"status" validate:"oneof=ok fail"`
+	Threshold time.Duration `json:"threshold,omitempty"`
+
+	// The limit of numerical thresholds. Unlike an unset limit, a limit of 0 is checked,
+	// for example to allow no Unavailable status codes at all.
+	NumericalThreshold *float64 `json:"numericalThreshold,omitempty"`
+
+	// Maximum regression against the test's baseline as a percentage
+	MaxRegression float64 `json:"maxRegression,omitempty"`
 }
 
 // UnmarshalJSON prases a ThresholdSetting value from JSON string
@@ -59,6 +134,20 @@ func (m *ThresholdSetting) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// NumericalLimit returns the value as the limit of a numerical threshold
+func NumericalLimit(v float64) *float64 {
+	return &v
+}
+
+// numericalLimit returns the limit of the numerical threshold and whether it is set
+func (m *ThresholdSetting) numericalLimit() (float64, bool) {
+	if m.NumericalThreshold == nil {
+		return 0, false
+	}
+
+	return *m.NumericalThreshold, true
+}
+
 var durationConstants = [6]Threshold{ThresholdMean, ThresholdMedian, Threshold95th, ThresholdFastest, ThresholdSlowest}
 
 // Test represents a test
@@ -74,6 +163,8 @@ type Test struct {
 	FailOnError     bool                            `json:"failOnError"`
 	FailOnThreshold bool                            `json:"failOnThreshold"`
 	FailOnKeyMetric bool                            `json:"failOnKeyMetric"`
+	BaselineRunID   uint                            `json:"baselineRunID"`
+	BaselineRuns    uint                            `json:"baselineRuns"`
 	ThresholdsJSON  string                          `json:"-" gorm:"column:thresholds"`
 }
 
@@ -166,54 +257,188 @@ func (t *Test) AfterFind() error {
 	return nil
 }
 
-// SetStatus sets this test's status based on the settings and the values in params
-func (t *Test) SetStatus(tMean, tMedian, t95, fastest, slowest time.Duration, rps float64, hasError bool) {
+// GetPercentiles returns the sorted latency percentiles of the percentile thresholds
+func (t *Test) GetPercentiles() []float64 {
+	percentiles := make([]float64, 0)
+
+	for thc := range t.Thresholds {
+		if p, ok := thc.Percentile(); ok {
+			percentiles = append(percentiles, p)
+		}
+	}
+
+	sort.Float64s(percentiles)
+
+	return percentiles
+}
+
+// ThresholdResult is the evaluation of a threshold for a run
+type ThresholdResult struct {
+	Threshold Threshold `json:"threshold"`
+
+	// The configured limit. Durations are in nanoseconds, maximum regressions in percent.
+	Limit float64 `json:"limit"`
+
+	// The observed value of the run. For regression results this is the change in percent.
+	Value float64 `json:"value"`
+
+	// Whether the limit is a maximum regression against the test's baseline
+	Regression bool `json:"regression,omitempty"`
+
+	Status Status `json:"status"`
+}
+
+// SetStatus sets this test's status based on the settings and the values of the run.
+// The evaluation of each threshold is recorded on the run and the run gets the resulting status.
+// The percentiles map holds the latency values for the percentile thresholds,
+// the ones without a value are not evaluated.
+// If a baseline is given the thresholds with a maximum regression are compared against it,
+// and the comparison is returned.
+func (t *Test) SetStatus(r *Run, percentiles map[Threshold]time.Duration, baseline *Baseline) *BaselineComparison {
 	// reset our status
 	t.Status = StatusOK
 
-	compareVal := []time.Duration{tMean, tMedian, t95, fastest, slowest}
-
-	for i, thc := range durationConstants {
-		if t.Thresholds[thc] != nil {
+	r.ThresholdResults = make([]*ThresholdResult, 0, len(t.Thresholds))
 
-			// reset each threshold status
-			t.Thresholds[thc].Status = StatusOK
+	median, nine5 := r.GetThresholdValues()
 
-			val := compareVal[i]
+	compareVal := []time.Duration{r.Average, median, nine5, r.Fastest, r.Slowest}
 
-			if t.Thresholds[thc].Threshold > 0 && val > 0 && val > t.Thresholds[thc].Threshold {
-				t.Thresholds[thc].Status = StatusFail
+	for i, thc := range durationConstants {
+		if t.Thresholds[thc] != nil {
+			t.checkDurationThreshold(r, thc, compareVal[i])
+		}
+	}
 
-				if t.FailOnThreshold {
-					t.Status = StatusFail
-				}
+	for thc, ths := range t.Thresholds {
+		if ths == nil {
+			continue
+		}
 
-				if t.KeyMetric == thc && t.FailOnKeyMetric {
-					t.Status = StatusFail
-				}
+		if p, ok := thc.Percentile(); ok {
+			// percentiles without a value are not evaluated
+			if val, ok := percentiles[PercentileThreshold(p)]; ok {
+				t.checkDurationThreshold(r, thc, val)
+			} else {
+				ths.Status = StatusOK
 			}
+		} else if code, ok := thc.StatusCount(); ok {
+			t.checkMaxThreshold(r, thc, float64(r.GetStatusCodeCount(code)))
+		} else if code, ok := thc.StatusRate(); ok {
+			t.checkMinThreshold(r, thc, r.GetStatusCodeRate(code), r.Count > 0)
 		}
 	}
 
+	if t.Thresholds[ThresholdErrorRate] != nil {
+		t.checkMaxThreshold(r, ThresholdErrorRate, r.GetErrorRate())
+	}
+
 	if t.Thresholds[ThresholdRPS] != nil {
-		// reset
-		t.Thresholds[ThresholdRPS].Status = StatusOK
+		t.checkMinThreshold(r, ThresholdRPS, r.Rps, r.Rps > 0.0)
+	}
 
-		if t.Thresholds[ThresholdRPS].NumericalThreshold > 0.0 && rps > 0.0 &&
-			rps < t.Thresholds[ThresholdRPS].NumericalThreshold {
-			t.Thresholds[ThresholdRPS].Status = StatusFail
+	if ths := t.Thresholds[ThresholdRegression]; ths != nil {
+		ths.Status = StatusOK
 
-			if t.FailOnThreshold {
-				t.Status = StatusFail
-			}
+		res := &ThresholdResult{Threshold: ThresholdRegression, Limit: ths.MaxRegression,
+			Regression: true, Status: StatusOK}
+
+		if r.Significance != nil {
+			res.Value = r.Significance.MedianChange
 
-			if t.KeyMetric == ThresholdRPS && t.FailOnKeyMetric {
-				t.Status = StatusFail
+			alpha, _ := ths.numericalLimit()
+			if r.Significance.IsRegression(alpha, ths.MaxRegression) {
+				res.Status = StatusFail
+				t.failThreshold(ThresholdRegression)
 			}
 		}
+
+		r.ThresholdResults = append(r.ThresholdResults, res)
+	}
+
+	if t.FailOnError && r.HasErrors() {
+		t.Status = StatusFail
+	}
+
+	var comparison *BaselineComparison
+	if baseline != nil {
+		comparison = t.compareBaseline(r, percentiles, baseline)
+	}
+
+	sort.Slice(r.ThresholdResults, func(i, j int) bool {
+		ri, rj := r.ThresholdResults[i], r.ThresholdResults[j]
+		if ri.Threshold == rj.Threshold {
+			return !ri.Regression && rj.Regression
+		}
+
+		return ri.Threshold < rj.Threshold
+	})
+
+	r.Status = t.Status
+
+	return comparison
+}
+
+// checkDurationThreshold sets the status of the duration threshold based on the value
+func (t *Test) checkDurationThreshold(r *Run, thc Threshold, val time.Duration) {
+	// reset each threshold status
+	t.Thresholds[thc].Status = StatusOK
+
+	limit := t.Thresholds[thc].Threshold
+	if limit <= 0 {
+		return
+	}
+
+	fail := val > 0 && val > limit
+	t.recordResult(r, thc, float64(limit), float64(val), fail)
+}
+
+// checkMaxThreshold sets the status of the numerical threshold that the value must not exceed
+func (t *Test) checkMaxThreshold(r *Run, thc Threshold, val float64) {
+	t.Thresholds[thc].Status = StatusOK
+
+	limit, ok := t.Thresholds[thc].numericalLimit()
+	if !ok || limit < 0.0 {
+		return
+	}
+
+	t.recordResult(r, thc, limit, val, val > limit)
+}
+
+// checkMinThreshold sets the status of the numerical threshold that the value must reach.
+// The threshold is only checked if the run has a value for it.
+func (t *Test) checkMinThreshold(r *Run, thc Threshold, val float64, hasValue bool) {
+	t.Thresholds[thc].Status = StatusOK
+
+	limit, ok := t.Thresholds[thc].numericalLimit()
+	if !ok || !hasValue {
+		return
+	}
+
+	t.recordResult(r, thc, limit, val, val < limit)
+}
+
+// recordResult records the evaluation of the threshold on the run and fails the threshold if needed
+func (t *Test) recordResult(r *Run, thc Threshold, limit, val float64, fail bool) {
+	res := &ThresholdResult{Threshold: thc, Limit: limit, Value: val, Status: StatusOK}
+
+	if fail {
+		res.Status = StatusFail
+		t.failThreshold(thc)
+	}
+
+	r.ThresholdResults = append(r.ThresholdResults, res)
+}
+
+// failThreshold marks the threshold as failed and fails the test if required by the settings
+func (t *Test) failThreshold(thc Threshold) {
+	t.Thresholds[thc].Status = StatusFail
+
+	if t.FailOnThreshold {
+		t.Status = StatusFail
 	}
 
-	if t.FailOnError && hasError {
+	if t.KeyMetric == thc && t.FailOnKeyMetric {
 		t.Status = StatusFail
 	}
 }
@@ -244,44 +469,70 @@ func (ts *TestService) FindByName(pid uint, name string) (*Test, error) {
 	return t, err
 }
 
+// FindByNames finds the test by its name and the name of its project, normalized as they are stored.
+// Returns gorm.ErrRecordNotFound if either is not set or does not exist.
+func (ts *TestService) FindByNames(projectName, testName string) (*Test, error) {
+	projectName, testName = NormalizeName(projectName), NormalizeName(testName)
+	if projectName == "" || testName == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	t := new(Test)
+	err := ts.DB.Where("name = ? AND project_id IN (SELECT id FROM projects WHERE name = ? AND deleted_at IS NULL)",
+		testName, projectName).First(t).Error
+	if err != nil {
+		t = nil
+	}
+	return t, err
+}
+
 // FindByProjectID finds tests by project
 func (ts *TestService) FindByProjectID(pid, num, page uint) ([]*Test, error) {
-	p := &Project{}
-	p.ID = pid
+	return ts.FindByProjectIDQuery(pid, num, page, NewQuery(TestQueryFields))
+}
 
-	offset := uint(0)
-	if page >= 0 && num >= 0 {
-		offset = page * num
-	}
+// FindByProjectIDQuery lists the tests of the project matching the query
+func (ts *TestService) FindByProjectIDQuery(pid, num, page uint, q *Query) ([]*Test, error) {
+	return findTests(ts.DB, pid, num, page, q)
+}
+
+// FindByProjectIDQueryUnscoped lists the tests of the project matching the query including the ones in the trash
+func (ts *TestService) FindByProjectIDQueryUnscoped(pid, num, page uint, q *Query) ([]*Test, error) {
+	return findTests(ts.DB.Unscoped(), pid, num, page, q)
+}
+
+// CountQuery returns the number of tests of the project matching the query
+func (ts *TestService) CountQuery(pid uint, q *Query) (uint, error) {
+	return countTests(ts.DB, pid, q)
+}
+
+// CountQueryUnscoped returns the number of tests of the project matching the query including the ones in the trash
+func (ts *TestService) CountQueryUnscoped(pid uint, q *Query) (uint, error) {
+	return countTests(ts.DB.Unscoped(), pid, q)
+}
 
+func findTests(db *gorm.DB, pid, num, page uint, q *Query) ([]*Test, error) {
 	s := make([]*Test, num)
 
-	err := ts.DB.Offset(offset).Limit(num).Order("name desc").Model(p).Related(&s).Error
+	err := q.find(db.Where("project_id = ?", pid), "name desc", num, page).Find(&s).Error
 
 	return s, err
 }
 
+func countTests(db *gorm.DB, pid uint, q *Query) (uint, error) {
+	count := uint(0)
+	err := q.where(db.Model(&Test{}).Where("project_id = ?", pid)).Count(&count).Error
+	return count, err
+}
+
 // FindByProjectIDSorted lists tests using sorting
 func (ts *TestService) FindByProjectIDSorted(pid, num, page uint, sortField, order string) ([]*Test, error) {
-	if (sortField != "name" && sortField != "id") || (order != "asc" && order != "desc") {
-		return nil, errors.New("Invalid sort parameters")
-	}
-
-	offset := uint(0)
-	if page >= 0 && num >= 0 {
-		offset = page * num
+	q, err := sortedQuery(TestQueryFields, sortField, order)
+	if err != nil {
+		return nil, err
 	}
 
-	orderSQL := sortField + " " + order
-
-	p := &Project{}
-	p.ID = pid
-
-	s := make([]*Test, num)
-
-	err := ts.DB.Order(orderSQL).Offset(offset).Limit(num).Model(p).Related(&s).Error
-
-	return s, err
+	return ts.FindByProjectIDQuery(pid, num, page, q)
 }
 
 // Count returns the total number of tests
@@ -296,6 +547,98 @@ func (ts *TestService) Create(t *Test) error {
 	return ts.DB.Create(t).Error
 }
 
+// FindOrCreate finds the project and the test by their names, creating the missing ones
+// in a single transaction. The found or created records are set to p and t. The description
+// and settings of p and t are only used for the created records.
+// A concurrent call may create the same project or test first, failing on their unique names,
+// in which case the records created by the concurrent call are found on a second attempt.
+// An *InTrashError is returned if the project or the test is in the trash.
+func (ts *TestService) FindOrCreate(p *Project, t *Test) error {
+	var err error
+
+	for attempt := 0; attempt < 2; attempt++ {
+		pc, tc := *p, *t
+		pc.Model, tc.Model = Model{}, Model{}
+
+		err = transact(ts.DB, func(tx *gorm.DB) error {
+			if err := findOrCreateProject(tx, &pc); err != nil {
+				return err
+			}
+
+			tc.ProjectID = pc.ID
+			tc.Project = nil
+
+			return findOrCreateTest(tx, &tc)
+		})
+
+		if err == nil {
+			*p, *t = pc, tc
+			return nil
+		}
+
+		if _, ok := err.(*InTrashError); ok {
+			return err
+		}
+	}
+
+	return err
+}
+
+// NormalizeName normalizes the name of a project or test as it is stored by the BeforeSave hooks
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.Replace(name, " ", "", -1))
+}
+
+func findOrCreateProject(tx *gorm.DB, p *Project) error {
+	name := NormalizeName(p.Name)
+	if name == "" {
+		return tx.Create(p).Error
+	}
+
+	existing := new(Project)
+	err := tx.Unscoped().First(existing, "name = ?", name).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return tx.Create(p).Error
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if existing.DeletedAt != nil {
+		return &InTrashError{Kind: "project", ID: existing.ID, Name: name}
+	}
+
+	*p = *existing
+
+	return nil
+}
+
+func findOrCreateTest(tx *gorm.DB, t *Test) error {
+	name := NormalizeName(t.Name)
+	if name == "" {
+		return tx.Create(t).Error
+	}
+
+	existing := new(Test)
+	err := tx.Unscoped().First(existing, "project_id = ? AND name = ?", t.ProjectID, name).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return tx.Create(t).Error
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if existing.DeletedAt != nil {
+		return &InTrashError{Kind: "test", ID: existing.ID, Name: name}
+	}
+
+	*t = *existing
+
+	return nil
+}
+
 // Update updates tests
 func (ts *TestService) Update(t *Test) error {
 	testToUpdate := &Test{}
@@ -311,7 +654,25 @@ func (ts *TestService) Update(t *Test) error {
 	return ts.DB.Save(t).Error
 }
 
-// Delete deletes tests
-func (ts *TestService) Delete(t *Test) error {
-	return errors.New("Not Implemented Yet")
+// Delete moves the test along with all of its runs to the trash
+func (ts *TestService) Delete(t *Test) (*CascadeResult, error) {
+	res := new(CascadeResult)
+
+	err := transact(ts.DB, func(tx *gorm.DB) error {
+		if err := cascadeTests(tx, trashOp(gorm.NowFunc()), res, "id = ?", t.ID); err != nil {
+			return err
+		}
+
+		if res.Tests == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
@@ -161,7 +161,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0)},
 			}}, [6]time.Duration{
 			milli1, milli2, milli3, milli4, milli4, milli4,
 		}, 0.0, false, &Test{Status: StatusOK, FailOnError: false, FailOnThreshold: true, FailOnKeyMetric: true,
@@ -171,7 +171,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0, Status: StatusOK},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0), Status: StatusOK},
 			}}},
 		{"RPS below limit", &Test{FailOnThreshold: true, FailOnKeyMetric: true,
 			Thresholds: map[Threshold]*ThresholdSetting{
@@ -180,7 +180,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0)},
 			}}, [6]time.Duration{
 			milli1, milli2, milli3, milli4, milli4, milli4,
 		}, 200.0, false, &Test{Status: StatusFail, FailOnError: false, FailOnThreshold: true, FailOnKeyMetric: true,
@@ -190,7 +190,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000, Status: StatusFail},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000), Status: StatusFail},
 			}}},
 		{"RPS above limit", &Test{FailOnThreshold: true, FailOnKeyMetric: true,
 			Thresholds: map[Threshold]*ThresholdSetting{
@@ -199,7 +199,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0)},
 			}}, [6]time.Duration{
 			milli1, milli2, milli3, milli4, milli4, milli4,
 		}, 2000.0, false, &Test{Status: StatusOK, FailOnError: false, FailOnThreshold: true, FailOnKeyMetric: true,
@@ -209,7 +209,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0, Status: StatusOK},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0), Status: StatusOK},
 			}}},
 		{"slowest over limit but FailOnThreshold false", &Test{FailOnThreshold: false, FailOnKeyMetric: true,
 			Thresholds: map[Threshold]*ThresholdSetting{
@@ -292,7 +292,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0)},
 			}}, [6]time.Duration{
 			milli1, milli2, milli3, milli4, milli4, milli4,
 		}, 200.0, false, &Test{Status: StatusOK, FailOnError: false, FailOnThreshold: false, FailOnKeyMetric: true,
@@ -302,7 +302,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0, Status: StatusFail},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0), Status: StatusFail},
 			}}},
 		{"RPS under limit fail and KeyMetric", &Test{FailOnThreshold: false, FailOnKeyMetric: true,
 			KeyMetric: ThresholdRPS,
@@ -312,7 +312,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0)},
 			}}, [6]time.Duration{
 			milli1, milli2, milli3, milli4, milli4, milli4,
 		}, 200.0, false, &Test{Status: StatusFail, FailOnError: false, FailOnThreshold: false, FailOnKeyMetric: true,
@@ -323,7 +323,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0, Status: StatusFail},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0), Status: StatusFail},
 			}}},
 		{"RPS over limit fail and KeyMetric", &Test{FailOnThreshold: false, FailOnKeyMetric: true,
 			KeyMetric: ThresholdRPS,
@@ -333,7 +333,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0)},
 			}}, [6]time.Duration{
 			milli1, milli2, milli3, milli4, milli4, milli4,
 		}, 2000.0, false, &Test{Status: StatusOK, FailOnError: false, FailOnThreshold: false, FailOnKeyMetric: true,
@@ -344,7 +344,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0, Status: StatusOK},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0), Status: StatusOK},
 			}}},
 		{"RPS under limit fail and KeyMetric fail", &Test{FailOnThreshold: false, FailOnKeyMetric: false,
 			KeyMetric: ThresholdRPS,
@@ -354,7 +354,7 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0)},
 			}}, [6]time.Duration{
 			milli1, milli2, milli3, milli4, milli4, milli4,
 		}, 200.0, false, &Test{Status: StatusOK, FailOnError: false, FailOnThreshold: false, FailOnKeyMetric: false,
@@ -365,19 +365,323 @@ func TestTestModel_SetStatus(t *testing.T) {
 				Threshold95th:    &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdFastest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
 				ThresholdSlowest: &ThresholdSetting{Threshold: milli5, Status: StatusOK},
-				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: 1000.0, Status: StatusFail},
+				ThresholdRPS:     &ThresholdSetting{NumericalThreshold: NumericalLimit(1000.0), Status: StatusFail},
+			}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Run{Average: tt.in[0], Fastest: tt.in[3], Slowest: tt.in[5], Rps: tt.inNum,
+				LatencyDistribution: []*LatencyDistribution{
+					&LatencyDistribution{Percentage: 50, Latency: tt.in[1]},
+					&LatencyDistribution{Percentage: 95, Latency: tt.in[2]},
+				}}
+
+			if tt.inError {
+				r.ErrorDist = map[string]int{"error": 1}
+			}
+
+			actual := tt.model
+			actual.SetStatus(r, nil, nil)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTestModel_SetStatus_Percentiles(t *testing.T) {
+	var tests = []struct {
+		name     string
+		model    *Test
+		in       map[Threshold]time.Duration
+		expected *Test
+	}{
+		{"percentiles within limit", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("p99"):   &ThresholdSetting{Threshold: milli5},
+				Threshold("p99.9"): &ThresholdSetting{Threshold: milli5},
+			}}, map[Threshold]time.Duration{
+			Threshold("p99"):   milli3,
+			Threshold("p99.9"): milli4,
+		}, &Test{Status: StatusOK, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("p99"):   &ThresholdSetting{Threshold: milli5, Status: StatusOK},
+				Threshold("p99.9"): &ThresholdSetting{Threshold: milli5, Status: StatusOK},
+			}}},
+		{"p99.9 over limit", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("p99"):   &ThresholdSetting{Threshold: milli5},
+				Threshold("p99.9"): &ThresholdSetting{Threshold: milli3},
+			}}, map[Threshold]time.Duration{
+			Threshold("p99"):   milli3,
+			Threshold("p99.9"): milli4,
+		}, &Test{Status: StatusFail, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("p99"):   &ThresholdSetting{Threshold: milli5, Status: StatusOK},
+				Threshold("p99.9"): &ThresholdSetting{Threshold: milli3, Status: StatusFail},
+			}}},
+		{"p90 key metric over limit", &Test{FailOnKeyMetric: true, KeyMetric: Threshold("p90.0"),
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("p90.0"): &ThresholdSetting{Threshold: milli1},
+			}}, map[Threshold]time.Duration{
+			Threshold("p90"): milli2,
+		}, &Test{Status: StatusFail, FailOnKeyMetric: true, KeyMetric: Threshold("p90.0"),
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("p90.0"): &ThresholdSetting{Threshold: milli1, Status: StatusFail},
+			}}},
+		{"missing percentile value", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("p99"): &ThresholdSetting{Threshold: milli1},
+			}}, nil, &Test{Status: StatusOK, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("p99"): &ThresholdSetting{Threshold: milli1, Status: StatusOK},
+			}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := tt.model
+			actual.SetStatus(&Run{Average: milli1}, tt.in, nil)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTestModel_SetStatus_ErrorThresholds(t *testing.T) {
+	run := &Run{
+		Count: 1000,
+		ErrorDist: map[string]int{
+			"rpc error: code = Unavailable desc = Service unavialable.": 11,
+			"rpc error: code = Internal desc = Internal error.":         29,
+		},
+		StatusCodeDist: map[string]int{"OK": 960, "Internal": 29},
+	}
+
+	var tests = []struct {
+		name     string
+		model    *Test
+		expected *Test
+	}{
+		{"error rate within limit", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(5)},
+			}}, &Test{Status: StatusOK, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(5), Status: StatusOK},
+			}}},
+		{"error rate over limit", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(0.5)},
+			}}, &Test{Status: StatusFail, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(0.5), Status: StatusFail},
+			}}},
+		{"error rate over limit without fail on threshold", &Test{
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(0.5)},
+			}}, &Test{Status: StatusOK,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(0.5), Status: StatusFail},
+			}}},
+		{"status count from error distribution over limit as key metric", &Test{FailOnKeyMetric: true,
+			KeyMetric: Threshold("status:Unavailable"),
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("status:Unavailable"): &ThresholdSetting{NumericalThreshold: NumericalLimit(10)},
+				Threshold("status:Internal"):    &ThresholdSetting{NumericalThreshold: NumericalLimit(30)},
+			}}, &Test{Status: StatusFail, FailOnKeyMetric: true,
+			KeyMetric: Threshold("status:Unavailable"),
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("status:Unavailable"): &ThresholdSetting{NumericalThreshold: NumericalLimit(10), Status: StatusFail},
+				Threshold("status:Internal"):    &ThresholdSetting{NumericalThreshold: NumericalLimit(30), Status: StatusOK},
+			}}},
+		{"zero tolerance status count", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("status:Unavailable"): &ThresholdSetting{NumericalThreshold: NumericalLimit(0)},
+				Threshold("status:Aborted"):     &ThresholdSetting{NumericalThreshold: NumericalLimit(0)},
+			}}, &Test{Status: StatusFail, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("status:Unavailable"): &ThresholdSetting{NumericalThreshold: NumericalLimit(0), Status: StatusFail},
+				Threshold("status:Aborted"):     &ThresholdSetting{NumericalThreshold: NumericalLimit(0), Status: StatusOK},
+			}}},
+		{"zero tolerance error rate", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(0)},
+			}}, &Test{Status: StatusFail, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(0), Status: StatusFail},
+			}}},
+		{"unset error rate limit is not checked", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdErrorRate: &ThresholdSetting{},
+			}}, &Test{Status: StatusOK, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdErrorRate: &ThresholdSetting{Status: StatusOK},
+			}}},
+		{"status rate under limit", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("statusRate:OK"): &ThresholdSetting{NumericalThreshold: NumericalLimit(99.9)},
+			}}, &Test{Status: StatusFail, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("statusRate:OK"): &ThresholdSetting{NumericalThreshold: NumericalLimit(99.9), Status: StatusFail},
+			}}},
+		{"status rate within limit", &Test{FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("statusRate:OK"): &ThresholdSetting{NumericalThreshold: NumericalLimit(95)},
+			}}, &Test{Status: StatusOK, FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				Threshold("statusRate:OK"): &ThresholdSetting{NumericalThreshold: NumericalLimit(95), Status: StatusOK},
 			}}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			actual := tt.model
-			actual.SetStatus(tt.in[0], tt.in[1], tt.in[2], tt.in[3], tt.in[5], tt.inNum, tt.inError)
+			actual.SetStatus(run, nil, nil)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTestModel_SetStatus_ThresholdResults(t *testing.T) {
+	r := &Run{
+		Count:   100,
+		Average: milli3,
+		Rps:     500,
+		LatencyDistribution: []*LatencyDistribution{
+			&LatencyDistribution{Percentage: 95, Latency: milli5},
+		},
+		ErrorDist: map[string]int{"error": 1},
+	}
+
+	tst := &Test{FailOnThreshold: true,
+		Thresholds: map[Threshold]*ThresholdSetting{
+			ThresholdMean:      &ThresholdSetting{Threshold: milli2, MaxRegression: 10},
+			Threshold95th:      &ThresholdSetting{Threshold: milli5},
+			ThresholdRPS:       &ThresholdSetting{NumericalThreshold: NumericalLimit(400)},
+			ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(5)},
+			ThresholdSlowest:   &ThresholdSetting{MaxRegression: 10},
+		}}
+
+	baseline := &Baseline{RunIDs: []uint{1}, Values: map[Threshold]float64{
+		ThresholdMean: float64(milli2),
+	}}
+
+	tst.SetStatus(r, nil, baseline)
+
+	assert.Equal(t, StatusFail, tst.Status)
+	assert.Equal(t, StatusFail, r.Status)
+	assert.Equal(t, []*ThresholdResult{
+		{Threshold: Threshold95th, Limit: float64(milli5), Value: float64(milli5), Status: StatusOK},
+		{Threshold: ThresholdErrorRate, Limit: 5, Value: 1, Status: StatusOK},
+		{Threshold: ThresholdMean, Limit: float64(milli2), Value: float64(milli3), Status: StatusFail},
+		{Threshold: ThresholdMean, Limit: 10, Value: 50, Regression: true, Status: StatusFail},
+		{Threshold: ThresholdRPS, Limit: 400, Value: 500, Status: StatusOK},
+	}, r.ThresholdResults)
+
+	t.Run("zero limit without errors", func(t *testing.T) {
+		r := &Run{Count: 100, StatusCodeDist: map[string]int{"OK": 100}}
+		tst := &Test{FailOnThreshold: true, Thresholds: map[Threshold]*ThresholdSetting{
+			ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(0)},
+		}}
+
+		tst.SetStatus(r, nil, nil)
+
+		assert.Equal(t, StatusOK, tst.Status)
+		assert.Equal(t, []*ThresholdResult{
+			{Threshold: ThresholdErrorRate, Limit: 0, Value: 0, Status: StatusOK},
+		}, r.ThresholdResults)
+	})
+
+	t.Run("percentile without a value is not evaluated", func(t *testing.T) {
+		r := &Run{Count: 100}
+		tst := &Test{FailOnThreshold: true, Thresholds: map[Threshold]*ThresholdSetting{
+			Threshold("p99"): &ThresholdSetting{Threshold: milli1},
+		}}
+
+		tst.SetStatus(r, map[Threshold]time.Duration{}, nil)
+
+		assert.Equal(t, StatusOK, tst.Status)
+		assert.Empty(t, r.ThresholdResults)
+	})
+
+	t.Run("without thresholds", func(t *testing.T) {
+		r := &Run{ErrorDist: map[string]int{"error": 1}}
+		tst := &Test{FailOnError: true}
+
+		tst.SetStatus(r, nil, nil)
+
+		assert.Equal(t, StatusFail, r.Status)
+		assert.NotNil(t, r.ThresholdResults)
+		assert.Empty(t, r.ThresholdResults)
+	})
+}
+
+func TestThreshold_StatusCode(t *testing.T) {
+	code, ok := Threshold("status:Unavailable").StatusCount()
+	assert.True(t, ok)
+	assert.Equal(t, "Unavailable", code)
+
+	_, ok = Threshold("status:").StatusCount()
+	assert.False(t, ok)
+
+	_, ok = Threshold("statusRate:OK").StatusCount()
+	assert.False(t, ok)
+
+	code, ok = Threshold("statusRate:OK").StatusRate()
+	assert.True(t, ok)
+	assert.Equal(t, "OK", code)
+
+	_, ok = ThresholdErrorRate.StatusRate()
+	assert.False(t, ok)
+
+	assert.Equal(t, Threshold("status:Unavailable"), StatusCountThreshold("Unavailable"))
+	assert.Equal(t, Threshold("statusRate:OK"), StatusRateThreshold("OK"))
+}
+
+func TestThreshold_Percentile(t *testing.T) {
+	var tests = []struct {
+		in         Threshold
+		expected   float64
+		expectedOK bool
+	}{
+		{Threshold("p99"), 99, true},
+		{Threshold("p99.9"), 99.9, true},
+		{Threshold("p100"), 100, true},
+		{Threshold("p0"), 0, false},
+		{Threshold("p101"), 0, false},
+		{Threshold("pasdf"), 0, false},
+		{Threshold("p"), 0, false},
+		{Threshold95th, 0, false},
+		{ThresholdMedian, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.in), func(t *testing.T) {
+			actual, ok := tt.in.Percentile()
+			assert.Equal(t, tt.expectedOK, ok)
 			assert.Equal(t, tt.expected, actual)
 		})
 	}
 }
 
+func TestPercentileThreshold(t *testing.T) {
+	assert.Equal(t, Threshold("p99"), PercentileThreshold(99))
+	assert.Equal(t, Threshold("p99.9"), PercentileThreshold(99.9))
+	assert.Equal(t, Threshold("p99.99"), PercentileThreshold(99.99))
+}
+
+func TestTestModel_GetPercentiles(t *testing.T) {
+	tst := &Test{Thresholds: map[Threshold]*ThresholdSetting{
+		ThresholdMean:      &ThresholdSetting{Threshold: milli1},
+		Threshold95th:      &ThresholdSetting{Threshold: milli1},
+		Threshold("p99.9"): &ThresholdSetting{Threshold: milli1},
+		Threshold("p90"):   &ThresholdSetting{Threshold: milli1},
+		Threshold("p99"):   &ThresholdSetting{Threshold: milli1},
+	}}
+
+	assert.Equal(t, []float64{90, 99, 99.9}, tst.GetPercentiles())
+	assert.Equal(t, []float64{}, (&Test{}).GetPercentiles())
+}
+
 func TestThresholdSetting_UnmarshalJSON(t *testing.T) {
 	var tests = []struct {
 		name     string
@@ -387,6 +691,8 @@ func TestThresholdSetting_UnmarshalJSON(t *testing.T) {
 		{"just status", `{"status":"ok"}`, ThresholdSetting{Status: StatusOK}},
 		{"status and duration", `{"status":"ok","threshold":1000000}`, ThresholdSetting{Status: StatusOK, Threshold: milli1}},
 		{"status and duration 2", `{"status":"fail","threshold":2000000}`, ThresholdSetting{Status: StatusFail, Threshold: milli2}},
+		{"numerical", `{"status":"ok","numericalThreshold":0.5}`, ThresholdSetting{Status: StatusOK, NumericalThreshold: NumericalLimit(0.5)}},
+		{"zero numerical", `{"status":"ok","numericalThreshold":0}`, ThresholdSetting{Status: StatusOK, NumericalThreshold: NumericalLimit(0)}},
 	}
 
 	for _, tt := range tests {
@@ -462,6 +768,24 @@ func TestTestModel_AfterFind(t *testing.T) {
 					ThresholdMean:   &ThresholdSetting{Threshold: milli2, Status: StatusOK},
 				}},
 			false},
+		{"percentile thresholds",
+			&Test{ProjectID: 1, Name: "test5",
+				ThresholdsJSON: `{"p99":{"status":"ok","threshold":4000000},"p99.9":{"status":"fail","threshold":5000000}}`},
+			&Test{ProjectID: 1, Name: "test5",
+				Thresholds: map[Threshold]*ThresholdSetting{
+					Threshold("p99"):   &ThresholdSetting{Threshold: milli4, Status: StatusOK},
+					Threshold("p99.9"): &ThresholdSetting{Threshold: milli5, Status: StatusFail},
+				}},
+			false},
+		{"zero and unset numerical thresholds",
+			&Test{ProjectID: 1, Name: "test6",
+				ThresholdsJSON: `{"errorRate":{"status":"ok","maxRegression":10},"status:Unavailable":{"status":"ok","numericalThreshold":0}}`},
+			&Test{ProjectID: 1, Name: "test6",
+				Thresholds: map[Threshold]*ThresholdSetting{
+					ThresholdErrorRate:              &ThresholdSetting{MaxRegression: 10, Status: StatusOK},
+					Threshold("status:Unavailable"): &ThresholdSetting{NumericalThreshold: NumericalLimit(0), Status: StatusOK},
+				}},
+			false},
 	}
 
 	for _, tt := range tests {
@@ -505,6 +829,17 @@ func TestTestModel_BeforeSave(t *testing.T) {
 				},
 				ThresholdsJSON: `{"95th":{"status":"ok","threshold":4000000},"mean":{"status":"ok","threshold":2000000},"median":{"status":"ok","threshold":3000000}}`},
 			false},
+		{"zero numerical threshold",
+			&Test{ProjectID: 1, Name: "test5",
+				Thresholds: map[Threshold]*ThresholdSetting{
+					ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(0), Status: StatusOK},
+				}},
+			&Test{ProjectID: 1, Name: "test5",
+				Thresholds: map[Threshold]*ThresholdSetting{
+					ThresholdErrorRate: &ThresholdSetting{NumericalThreshold: NumericalLimit(0), Status: StatusOK},
+				},
+				ThresholdsJSON: `{"errorRate":{"status":"ok","numericalThreshold":0}}`},
+			false},
 	}
 
 	for _, tt := range tests {
@@ -1037,6 +1372,176 @@ func TestTestService_FindByName(t *testing.T) {
 	})
 }
 
+func TestTestService_FindOrCreate(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &LatencyDistribution{}, &Bucket{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	dao := TestService{DB: db}
+	var pid, tid uint
+
+	t.Run("creates missing project and test", func(t *testing.T) {
+		p := &Project{Name: "My Project", Description: "Project Description"}
+		o := &Test{
+			Name:            "My Test",
+			Description:     "Test Description",
+			FailOnThreshold: true,
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdMean: &ThresholdSetting{Threshold: time.Second},
+			},
+		}
+
+		err := dao.FindOrCreate(p, o)
+
+		assert.NoError(t, err)
+		assert.NotZero(t, p.ID)
+		assert.Equal(t, "myproject", p.Name)
+		assert.Equal(t, "Project Description", p.Description)
+		assert.NotZero(t, o.ID)
+		assert.Equal(t, p.ID, o.ProjectID)
+		assert.Equal(t, "mytest", o.Name)
+		assert.True(t, o.FailOnThreshold)
+
+		pid = p.ID
+		tid = o.ID
+	})
+
+	t.Run("finds existing project and test", func(t *testing.T) {
+		p := &Project{Name: "my project", Description: "Other"}
+		o := &Test{
+			Name: "MY TEST",
+			Thresholds: map[Threshold]*ThresholdSetting{
+				ThresholdMedian: &ThresholdSetting{Threshold: time.Second},
+			},
+		}
+
+		err := dao.FindOrCreate(p, o)
+
+		assert.NoError(t, err)
+		assert.Equal(t, pid, p.ID)
+		assert.Equal(t, "Project Description", p.Description)
+		assert.Equal(t, tid, o.ID)
+		assert.Equal(t, "Test Description", o.Description)
+		assert.True(t, o.FailOnThreshold)
+		assert.Len(t, o.Thresholds, 1)
+		assert.NotNil(t, o.Thresholds[ThresholdMean])
+	})
+
+	t.Run("creates missing test in existing project", func(t *testing.T) {
+		p := &Project{Name: "myproject"}
+		o := &Test{Name: "Other Test"}
+
+		err := dao.FindOrCreate(p, o)
+
+		assert.NoError(t, err)
+		assert.Equal(t, pid, p.ID)
+		assert.NotZero(t, o.ID)
+		assert.NotEqual(t, tid, o.ID)
+		assert.Equal(t, pid, o.ProjectID)
+	})
+
+	t.Run("creates test with random name", func(t *testing.T) {
+		p := &Project{Name: "myproject"}
+		o := &Test{}
+
+		err := dao.FindOrCreate(p, o)
+
+		assert.NoError(t, err)
+		assert.Equal(t, pid, p.ID)
+		assert.NotZero(t, o.ID)
+		assert.NotEmpty(t, o.Name)
+	})
+
+	t.Run("fails for test in the trash", func(t *testing.T) {
+		_, err := dao.Delete(&Test{Model: Model{ID: tid}})
+		assert.NoError(t, err)
+
+		p := &Project{Name: "myproject"}
+		o := &Test{Name: "mytest"}
+
+		err = dao.FindOrCreate(p, o)
+
+		assert.EqualError(t, err, "Test mytest is in the trash")
+		assert.Equal(t, &InTrashError{Kind: "test", ID: tid, Name: "mytest"}, err)
+		assert.Zero(t, o.ID)
+	})
+
+	t.Run("fails for project in the trash", func(t *testing.T) {
+		_, err := (&ProjectService{DB: db}).Delete(&Project{Model: Model{ID: pid}})
+		assert.NoError(t, err)
+
+		p := &Project{Name: "My Project"}
+		o := &Test{Name: "Another Test"}
+
+		err = dao.FindOrCreate(p, o)
+
+		assert.EqualError(t, err, "Project myproject is in the trash")
+		assert.Equal(t, &InTrashError{Kind: "project", ID: pid, Name: "myproject"}, err)
+		assert.Zero(t, p.ID)
+		assert.Zero(t, o.ID)
+	})
+}
+
+func TestTestService_FindByNames(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &LatencyDistribution{}, &Bucket{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	dao := TestService{DB: db}
+	p := &Project{Name: "My Project"}
+	o := &Test{Name: "My Test"}
+
+	t.Run("create new test and project", func(t *testing.T) {
+		err := dao.FindOrCreate(p, o)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("find valid", func(t *testing.T) {
+		found, err := dao.FindByNames("MY PROJECT", "my test")
+
+		assert.NoError(t, err)
+		assert.Equal(t, o.ID, found.ID)
+		assert.Equal(t, p.ID, found.ProjectID)
+	})
+
+	t.Run("find invalid", func(t *testing.T) {
+		found, err := dao.FindByNames("myproject", "other")
+
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, found)
+
+		found, err = dao.FindByNames("", "mytest")
+
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, found)
+	})
+
+	t.Run("find in the trash", func(t *testing.T) {
+		_, err := (&ProjectService{DB: db}).Delete(&Project{Model: Model{ID: p.ID}})
+		assert.NoError(t, err)
+
+		found, err := dao.FindByNames("myproject", "mytest")
+
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, found)
+	})
+}
+
 func TestTestService_FindByProject(t *testing.T) {
 	defer os.Remove(dbName)
 
@@ -1359,11 +1864,11 @@ func TestTestService_Delete(t *testing.T) {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(&Project{}, &Test{})
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
 	db.Exec("PRAGMA foreign_keys = ON;")
 
 	dao := TestService{DB: db}
-	var tid uint
+	var tid, otherTID uint
 	var pid uint
 
 	t.Run("create new test and project", func(t *testing.T) {
@@ -1411,24 +1916,86 @@ func TestTestService_Delete(t *testing.T) {
 		assert.Equal(t, o.ThresholdsJSON, ct.ThresholdsJSON)
 		assert.True(t, o.CreatedAt.Equal(ct.CreatedAt))
 		assert.True(t, o.UpdatedAt.Equal(ct.CreatedAt))
+
+		o2 := Test{
+			ProjectID: pid,
+			Name:      "Test 222",
+		}
+		err = dao.Create(&o2)
+
+		assert.NoError(t, err)
+
+		otherTID = o2.ID
 	})
 
-	t.Run("delete", func(t *testing.T) {
-		o := Test{
-			ProjectID:   pid,
-			Name:        "Test 222 ",
-			Description: "Test Description 2 ",
-			Status:      StatusFail,
-			Thresholds: map[Threshold]*ThresholdSetting{
-				Threshold95th:   &ThresholdSetting{Threshold: milli4, Status: StatusOK},
-				ThresholdMedian: &ThresholdSetting{Threshold: milli3, Status: StatusOK},
-				ThresholdMean:   &ThresholdSetting{Threshold: milli2, Status: StatusOK},
-			},
+	t.Run("create runs and details", func(t *testing.T) {
+		for _, id := range []uint{tid, tid, otherTID} {
+			r := &Run{
+				TestID: id,
+				LatencyDistribution: []*LatencyDistribution{
+					&LatencyDistribution{Percentage: 50},
+				},
+				Histogram: []*Bucket{
+					&Bucket{Mark: 0.01},
+					&Bucket{Mark: 0.02},
+				},
+			}
+			err := db.Create(r).Error
+			assert.NoError(t, err)
+
+			for k := 0; k < 3; k++ {
+				err := db.Create(&Detail{RunID: r.ID, Latency: 1.23}).Error
+				assert.NoError(t, err)
+			}
 		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		o := Test{}
 		o.ID = tid
 
-		err := dao.Delete(&o)
+		res, err := dao.Delete(&o)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &CascadeResult{
+			Tests:                1,
+			Runs:                 2,
+			LatencyDistributions: 2,
+			Buckets:              4,
+			Details:              6,
+		}, res)
+
+		_, err = dao.FindByID(tid)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		count := 0
+		db.Model(&Run{}).Count(&count)
+		assert.Equal(t, 1, count)
+
+		db.Model(&Detail{}).Count(&count)
+		assert.Equal(t, 3, count)
+
+		db.Model(&LatencyDistribution{}).Count(&count)
+		assert.Equal(t, 1, count)
+
+		db.Model(&Bucket{}).Count(&count)
+		assert.Equal(t, 2, count)
+
+		db.Unscoped().Model(&Run{}).Where("test_id = ? AND deleted_at IS NOT NULL", tid).Count(&count)
+		assert.Equal(t, 2, count)
+
+		cp := &Project{}
+		err = db.First(cp, pid).Error
+		assert.NoError(t, err)
+	})
+
+	t.Run("fail delete unknown test", func(t *testing.T) {
+		o := Test{}
+		o.ID = 4321
+
+		res, err := dao.Delete(&o)
 
 		assert.Error(t, err)
+		assert.Nil(t, res)
 	})
 }
@@ -0,0 +1,205 @@
+package model
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/jinzhu/gorm"
+)
+
+// MaxTimelineWindows is the maximum number of windows of a run timeline
+const MaxTimelineWindows = 10000
+
+// timelineSampleSize is the maximum number of latencies kept by each window of a timeline
+// to compute its latency distribution
+const timelineSampleSize = 1000
+
+// ErrTimelineWindows is returned when the window width splits the run into too many windows
+var ErrTimelineWindows = errors.New("Window width too small. The run would be split into more than 10000 windows")
+
+// TimelineWindow holds the calls of a run that started within a time window
+type TimelineWindow struct {
+	// The start of the window
+	Start time.Time `json:"start"`
+
+	// The number of calls
+	Count uint64 `json:"count"`
+
+	// The number of calls per second
+	Rps float64 `json:"rps"`
+
+	// The number of calls that resulted in an error
+	Errors uint64 `json:"errors"`
+
+	// The average latency of the calls
+	Average time.Duration `json:"average"`
+
+	LatencyDistribution []*LatencyDistribution `json:"latencyDistribution"`
+	StatusCodeDist      map[string]int         `json:"statusCodeDistribution"`
+}
+
+// Timeline splits the details of a run into consecutive windows of the same width by the time the calls started.
+// Windows without calls are kept to show stalls within the run.
+type Timeline struct {
+	RunID uint `json:"runID"`
+
+	// The start of the first call
+	Start time.Time `json:"start"`
+
+	// The width of each window
+	Width time.Duration `json:"width"`
+
+	// The number of details with a timestamp
+	Count uint64 `json:"count"`
+
+	Windows []*TimelineWindow `json:"windows"`
+}
+
+// Timeline computes the timeline of the run from the timestamps of its details.
+// If the width is not set the windows are a second wide, or wider in whole seconds
+// to keep the number of windows within the maximum.
+// The latency percentiles of each window default to the ones of the run summary,
+// and are computed from a bounded sample of the latencies of the window.
+func (ds *DetailService) Timeline(r *Run, width time.Duration, percentiles []float64) (*Timeline, error) {
+	tl := &Timeline{RunID: r.ID, Width: width, Windows: make([]*TimelineWindow, 0)}
+
+	if len(percentiles) == 0 {
+		percentiles = summaryPercentiles
+	}
+
+	// details without a timestamp are stored with the zero time
+	timed := ds.DB.Where("run_id = ? AND timestamp > ?", r.ID, time.Unix(0, 0).UTC())
+
+	first, last := new(Detail), new(Detail)
+
+	err := timed.Order("timestamp asc").First(first).Error
+	if err == nil {
+		err = timed.Order("timestamp desc").First(last).Error
+	}
+
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return tl, nil
+		}
+
+		return nil, err
+	}
+
+	duration := last.Timestamp.Sub(first.Timestamp)
+
+	if tl.Width <= 0 {
+		tl.Width = time.Second
+		if min := duration / MaxTimelineWindows; min >= tl.Width {
+			tl.Width = (min/time.Second + 1) * time.Second
+		}
+	}
+
+	n := int64(duration/tl.Width) + 1
+	if n > MaxTimelineWindows {
+		return nil, ErrTimelineWindows
+	}
+
+	tl.Start = first.Timestamp
+
+	// the windows share the source of their samples, as the details are added in a single goroutine
+	rnd := rand.New(rand.NewSource(1))
+
+	windows := make([]*timelineWindow, n)
+	for i := range windows {
+		windows[i] = &timelineWindow{sample: newLatencySample(timelineSampleSize, rnd), codes: make(map[string]int)}
+	}
+
+	size := config.DefaultBatchSize
+	if ds.Config != nil {
+		size = ds.Config.GetBatchSize()
+	}
+
+	lastID := uint(0)
+
+	for {
+		var details []*Detail
+		err := timed.Where("id > ?", lastID).Order("id asc").Limit(size).Find(&details).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range details {
+			// the order of the database may differ for timestamps stored in other time zones
+			i := int64(d.Timestamp.Sub(tl.Start) / tl.Width)
+			if i < 0 {
+				i = 0
+			} else if i >= n {
+				i = n - 1
+			}
+
+			windows[i].add(d)
+			tl.Count++
+		}
+
+		if len(details) < size {
+			break
+		}
+
+		lastID = details[len(details)-1].ID
+	}
+
+	for i, w := range windows {
+		tl.Windows = append(tl.Windows, w.window(tl.Start.Add(time.Duration(i)*tl.Width), tl.Width, percentiles))
+	}
+
+	return tl, nil
+}
+
+// timelineWindow accumulates the details of a window. The latency distribution is computed
+// from a bounded sample of the latencies, so that memory does not grow with the number of details.
+type timelineWindow struct {
+	sample *LatencySample
+	sum    float64
+	errors uint64
+	codes  map[string]int
+}
+
+func (w *timelineWindow) add(d *Detail) {
+	w.sample.Add(d.Latency)
+	w.sum += d.Latency
+
+	if d.Error != "" {
+		w.errors++
+	}
+
+	status := d.Status
+	if status == "" {
+		status = "OK"
+	}
+
+	w.codes[status]++
+}
+
+func (w *timelineWindow) window(start time.Time, width time.Duration, percentiles []float64) *TimelineWindow {
+	count := uint64(w.sample.Seen())
+
+	tw := &TimelineWindow{
+		Start:               start,
+		Count:               count,
+		Rps:                 float64(count) / width.Seconds(),
+		Errors:              w.errors,
+		LatencyDistribution: make([]*LatencyDistribution, 0, len(percentiles)),
+		StatusCodeDist:      w.codes,
+	}
+
+	if count == 0 {
+		return tw
+	}
+
+	tw.Average = time.Duration(w.sum / float64(count))
+
+	values := ComputePercentiles(w.sample.Latencies(), percentiles)
+	for _, p := range percentiles {
+		tw.LatencyDistribution = append(tw.LatencyDistribution,
+			&LatencyDistribution{Percentage: int(p), Latency: values[PercentileThreshold(p)]})
+	}
+
+	return tw
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetailService_Timeline(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ds := &DetailService{DB: db, Config: &config.DBConfig{BatchSize: 4}}
+
+	start := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	r := &Run{Test: &Test{Project: &Project{}, Name: "timeline"}, Date: start}
+
+	t.Run("create details", func(t *testing.T) {
+		assert.NoError(t, db.Create(r).Error)
+
+		// 4 calls in the first second, none in the next and 2 in the last one
+		details := []*Detail{
+			&Detail{Timestamp: start, Latency: float64(10 * time.Millisecond)},
+			&Detail{Timestamp: start.Add(200 * time.Millisecond), Latency: float64(20 * time.Millisecond)},
+			&Detail{Timestamp: start.Add(400 * time.Millisecond), Latency: float64(30 * time.Millisecond),
+				Status: "Unavailable", Error: "connection refused"},
+			&Detail{Timestamp: start.Add(900 * time.Millisecond), Latency: float64(40 * time.Millisecond)},
+			&Detail{Timestamp: start.Add(2100 * time.Millisecond), Latency: float64(100 * time.Millisecond)},
+			&Detail{Timestamp: start.Add(2500 * time.Millisecond), Latency: float64(300 * time.Millisecond),
+				Status: "DeadlineExceeded", Error: "deadline exceeded"},
+			&Detail{Latency: float64(time.Second)},
+		}
+
+		for _, d := range details {
+			d.RunID = r.ID
+			assert.NoError(t, db.Create(d).Error)
+		}
+	})
+
+	t.Run("default width", func(t *testing.T) {
+		tl, err := ds.Timeline(r, 0, nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, r.ID, tl.RunID)
+		assert.True(t, start.Equal(tl.Start))
+		assert.Equal(t, time.Second, tl.Width)
+		assert.Equal(t, uint64(6), tl.Count)
+		assert.Len(t, tl.Windows, 3)
+
+		w := tl.Windows[0]
+		assert.True(t, start.Equal(w.Start))
+		assert.Equal(t, uint64(4), w.Count)
+		assert.Equal(t, float64(4), w.Rps)
+		assert.Equal(t, uint64(1), w.Errors)
+		assert.Equal(t, 25*time.Millisecond, w.Average)
+		assert.Len(t, w.LatencyDistribution, 7)
+		assert.Equal(t, 50, w.LatencyDistribution[2].Percentage)
+		assert.Equal(t, 20*time.Millisecond, w.LatencyDistribution[2].Latency)
+		assert.Equal(t, map[string]int{"OK": 3, "Unavailable": 1}, w.StatusCodeDist)
+
+		stall := tl.Windows[1]
+		assert.Equal(t, uint64(0), stall.Count)
+		assert.Equal(t, float64(0), stall.Rps)
+		assert.Empty(t, stall.LatencyDistribution)
+
+		w = tl.Windows[2]
+		assert.True(t, start.Add(2*time.Second).Equal(w.Start))
+		assert.Equal(t, uint64(2), w.Count)
+		assert.Equal(t, uint64(1), w.Errors)
+		assert.Equal(t, 200*time.Millisecond, w.Average)
+		assert.Equal(t, map[string]int{"OK": 1, "DeadlineExceeded": 1}, w.StatusCodeDist)
+	})
+
+	t.Run("width and percentiles", func(t *testing.T) {
+		tl, err := ds.Timeline(r, 500*time.Millisecond, []float64{50, 99})
+		assert.NoError(t, err)
+
+		assert.Equal(t, 500*time.Millisecond, tl.Width)
+		assert.Len(t, tl.Windows, 6)
+
+		counts := make([]uint64, len(tl.Windows))
+		for i, w := range tl.Windows {
+			counts[i] = w.Count
+		}
+
+		assert.Equal(t, []uint64{3, 1, 0, 0, 1, 1}, counts)
+
+		w := tl.Windows[0]
+		assert.Equal(t, float64(6), w.Rps)
+		assert.Len(t, w.LatencyDistribution, 2)
+		assert.Equal(t, 99, w.LatencyDistribution[1].Percentage)
+		assert.Equal(t, 30*time.Millisecond, w.LatencyDistribution[1].Latency)
+	})
+
+	t.Run("too many windows", func(t *testing.T) {
+		_, err := ds.Timeline(r, 100*time.Microsecond, nil)
+		assert.Equal(t, ErrTimelineWindows, err)
+	})
+
+	t.Run("no details", func(t *testing.T) {
+		tl, err := ds.Timeline(&Run{}, 0, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), tl.Count)
+		assert.Empty(t, tl.Windows)
+	})
+}
+
+func TestTimelineWindow_Sample(t *testing.T) {
+	w := &timelineWindow{sample: newLatencySample(timelineSampleSize, rand.New(rand.NewSource(1))), codes: make(map[string]int)}
+
+	n := 10 * timelineSampleSize
+	for i := 1; i <= n; i++ {
+		w.add(&Detail{Latency: float64(i)})
+	}
+
+	// the count and the average are exact while the latencies are bounded
+	assert.Len(t, w.sample.Latencies(), timelineSampleSize)
+
+	tw := w.window(time.Time{}, time.Second, []float64{50})
+
+	assert.Equal(t, uint64(n), tw.Count)
+	assert.Equal(t, float64(n), tw.Rps)
+	assert.Equal(t, time.Duration((n+1)/2), tw.Average)
+	assert.Equal(t, map[string]int{"OK": n}, tw.StatusCodeDist)
+	assert.InDelta(t, n/2, float64(tw.LatencyDistribution[0].Latency), float64(n/10))
+}
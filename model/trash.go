@@ -0,0 +1,195 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrParentInTrash is returned when restoring an item whose parent is still in the trash
+var ErrParentInTrash = errors.New("Parent is in trash")
+
+// InTrashError is returned when finding a project or test by a name that is in the trash.
+// The item has to be restored before it can be used again.
+type InTrashError struct {
+	// Kind of the item, either "project" or "test"
+	Kind string
+
+	// ID and name of the item in the trash
+	ID   uint
+	Name string
+}
+
+func (e *InTrashError) Error() string {
+	return strings.ToUpper(e.Kind[:1]) + e.Kind[1:] + " " + e.Name + " is in the trash"
+}
+
+// TrashService is our implementation
+type TrashService struct {
+	DB *gorm.DB
+}
+
+// CountProjects returns the total number of projects in the trash
+func (ts *TrashService) CountProjects() (uint, error) {
+	count := uint(0)
+	err := ts.trashed().Model(&Project{}).Count(&count).Error
+	return count, err
+}
+
+// ListProjects lists projects in the trash, most recently deleted first
+func (ts *TrashService) ListProjects(limit, page uint) ([]*Project, error) {
+	s := make([]*Project, limit)
+
+	err := ts.trashed().Order("deleted_at desc").Offset(page * limit).Limit(limit).Find(&s).Error
+
+	return s, err
+}
+
+// CountTests returns the total number of tests in the trash
+func (ts *TrashService) CountTests() (uint, error) {
+	count := uint(0)
+	err := ts.trashed().Model(&Test{}).Count(&count).Error
+	return count, err
+}
+
+// ListTests lists tests in the trash, most recently deleted first
+func (ts *TrashService) ListTests(limit, page uint) ([]*Test, error) {
+	s := make([]*Test, limit)
+
+	err := ts.trashed().Order("deleted_at desc").Offset(page * limit).Limit(limit).Find(&s).Error
+
+	return s, err
+}
+
+// CountRuns returns the total number of runs in the trash
+func (ts *TrashService) CountRuns() (uint, error) {
+	count := uint(0)
+	err := ts.trashed().Model(&Run{}).Count(&count).Error
+	return count, err
+}
+
+// ListRuns lists runs in the trash, most recently deleted first
+func (ts *TrashService) ListRuns(limit, page uint) ([]*Run, error) {
+	s := make([]*Run, limit)
+
+	err := ts.trashed().Order("deleted_at desc").Offset(page * limit).Limit(limit).Find(&s).Error
+
+	return s, err
+}
+
+// RestoreProject restores the project along with the tests and runs
+// that were moved to the trash with it
+func (ts *TrashService) RestoreProject(id uint) (*CascadeResult, error) {
+	p := new(Project)
+	if err := ts.trashed().First(p, id).Error; err != nil {
+		return nil, err
+	}
+
+	res := new(CascadeResult)
+
+	err := transact(ts.DB, func(tx *gorm.DB) error {
+		return cascadeProjects(tx, restoreOp(*p.DeletedAt), res, "id = ?", id)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// RestoreTest restores the test along with the runs that were moved to the trash with it.
+// The test's project must not be in the trash.
+func (ts *TrashService) RestoreTest(id uint) (*CascadeResult, error) {
+	t := new(Test)
+	if err := ts.trashed().First(t, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := ts.DB.First(&Project{}, t.ProjectID).Error; gorm.IsRecordNotFoundError(err) {
+		return nil, ErrParentInTrash
+	} else if err != nil {
+		return nil, err
+	}
+
+	res := new(CascadeResult)
+
+	err := transact(ts.DB, func(tx *gorm.DB) error {
+		return cascadeTests(tx, restoreOp(*t.DeletedAt), res, "id = ?", id)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// RestoreRun restores the run along with its details, latency distribution and histogram.
+// The run's test must not be in the trash.
+func (ts *TrashService) RestoreRun(id uint) (*CascadeResult, error) {
+	r := new(Run)
+	if err := ts.trashed().First(r, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := ts.DB.First(&Test{}, r.TestID).Error; gorm.IsRecordNotFoundError(err) {
+		return nil, ErrParentInTrash
+	} else if err != nil {
+		return nil, err
+	}
+
+	res := new(CascadeResult)
+
+	err := transact(ts.DB, func(tx *gorm.DB) error {
+		return cascadeRuns(tx, restoreOp(*r.DeletedAt), res, "id = ?", id)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Purge permanently deletes all the projects, tests and runs
+// that were moved to the trash before the given time
+func (ts *TrashService) Purge(before time.Time) (*CascadeResult, error) {
+	res := new(CascadeResult)
+
+	err := transact(ts.DB, func(tx *gorm.DB) error {
+		pr := new(CascadeResult)
+		if err := cascadeProjects(tx, deleteWhere, pr, "deleted_at < ?", before); err != nil {
+			return err
+		}
+
+		tr := new(CascadeResult)
+		if err := cascadeTests(tx, deleteWhere, tr, "deleted_at < ?", before); err != nil {
+			return err
+		}
+
+		rr := new(CascadeResult)
+		if err := cascadeRuns(tx, deleteWhere, rr, "deleted_at < ?", before); err != nil {
+			return err
+		}
+
+		res.Add(pr)
+		res.Add(tr)
+		res.Add(rr)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// trashed returns a query for records in the trash only
+func (ts *TrashService) trashed() *gorm.DB {
+	return ts.DB.Unscoped().Where("deleted_at IS NOT NULL")
+}
@@ -0,0 +1,276 @@
+package model
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrashService(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &Bucket{}, &LatencyDistribution{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	ps := ProjectService{DB: db}
+	ts := TestService{DB: db}
+	rs := RunService{DB: db}
+	dao := TrashService{DB: db}
+
+	var pid, tid, rid, rid2 uint
+
+	t.Run("create project with test, runs and details", func(t *testing.T) {
+		p := &Project{Name: "TrashProj"}
+		err := ps.Create(p)
+		assert.NoError(t, err)
+
+		tst := &Test{ProjectID: p.ID, Name: "TrashTest"}
+		err = ts.Create(tst)
+		assert.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			r := &Run{
+				TestID: tst.ID,
+				LatencyDistribution: []*LatencyDistribution{
+					&LatencyDistribution{Percentage: 50},
+				},
+				Histogram: []*Bucket{
+					&Bucket{Mark: 0.01},
+				},
+			}
+			err := db.Create(r).Error
+			assert.NoError(t, err)
+
+			for k := 0; k < 2; k++ {
+				err := db.Create(&Detail{RunID: r.ID, Latency: 1.23}).Error
+				assert.NoError(t, err)
+			}
+
+			if i == 0 {
+				rid = r.ID
+			} else {
+				rid2 = r.ID
+			}
+		}
+
+		pid = p.ID
+		tid = tst.ID
+	})
+
+	t.Run("empty trash", func(t *testing.T) {
+		count, err := dao.CountProjects()
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), count)
+
+		runs, err := dao.ListRuns(20, 0)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 0)
+	})
+
+	t.Run("trash run then project", func(t *testing.T) {
+		r := &Run{}
+		r.ID = rid2
+		_, err := rs.Delete(r)
+		assert.NoError(t, err)
+
+		time.Sleep(10 * time.Millisecond)
+
+		p := &Project{}
+		p.ID = pid
+		res, err := ps.Delete(p)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), res.Runs)
+	})
+
+	t.Run("list trash", func(t *testing.T) {
+		count, err := dao.CountProjects()
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), count)
+
+		projects, err := dao.ListProjects(20, 0)
+		assert.NoError(t, err)
+		assert.Len(t, projects, 1)
+		assert.Equal(t, pid, projects[0].ID)
+		assert.NotNil(t, projects[0].DeletedAt)
+
+		count, err = dao.CountTests()
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), count)
+
+		count, err = dao.CountRuns()
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), count)
+
+		runs, err := dao.ListRuns(20, 0)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 2)
+		assert.Equal(t, rid, runs[0].ID)
+		assert.Equal(t, rid2, runs[1].ID)
+
+		runs, err = dao.ListRuns(1, 1)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 1)
+		assert.Equal(t, rid2, runs[0].ID)
+	})
+
+	t.Run("list queries ignore trash unless unscoped", func(t *testing.T) {
+		q := NewQuery(ProjectQueryFields)
+
+		count, err := ps.CountQuery(q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), count)
+
+		projects, err := ps.ListQuery(20, 0, q)
+		assert.NoError(t, err)
+		assert.Len(t, projects, 0)
+
+		count, err = ps.CountQueryUnscoped(q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), count)
+
+		projects, err = ps.ListQueryUnscoped(20, 0, q)
+		assert.NoError(t, err)
+		assert.Len(t, projects, 1)
+		assert.Equal(t, pid, projects[0].ID)
+
+		q = NewQuery(TestQueryFields)
+
+		count, err = ts.CountQuery(pid, q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), count)
+
+		count, err = ts.CountQueryUnscoped(pid, q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), count)
+
+		tests, err := ts.FindByProjectIDQueryUnscoped(pid, 20, 0, q)
+		assert.NoError(t, err)
+		assert.Len(t, tests, 1)
+		assert.Equal(t, tid, tests[0].ID)
+
+		q = NewQuery(RunQueryFields)
+
+		runs, err := rs.FindByTestIDQuery(tid, 20, 0, q, true, true)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 0)
+
+		count, err = rs.CountQueryUnscoped(tid, q)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), count)
+
+		runs, err = rs.FindByTestIDQueryUnscoped(tid, 20, 0, q, true, true)
+		assert.NoError(t, err)
+		assert.Len(t, runs, 2)
+		assert.Len(t, runs[0].LatencyDistribution, 1)
+		assert.Len(t, runs[0].Histogram, 1)
+	})
+
+	t.Run("fail restore test with project in trash", func(t *testing.T) {
+		res, err := dao.RestoreTest(tid)
+		assert.Equal(t, ErrParentInTrash, err)
+		assert.Nil(t, res)
+	})
+
+	t.Run("fail restore run with test in trash", func(t *testing.T) {
+		res, err := dao.RestoreRun(rid)
+		assert.Equal(t, ErrParentInTrash, err)
+		assert.Nil(t, res)
+	})
+
+	t.Run("fail restore unknown project", func(t *testing.T) {
+		res, err := dao.RestoreProject(4321)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, res)
+	})
+
+	t.Run("restore project", func(t *testing.T) {
+		res, err := dao.RestoreProject(pid)
+		assert.NoError(t, err)
+		assert.Equal(t, &CascadeResult{
+			Projects:             1,
+			Tests:                1,
+			Runs:                 1,
+			LatencyDistributions: 1,
+			Buckets:              1,
+			Details:              2,
+		}, res)
+
+		_, err = ps.FindByID(pid)
+		assert.NoError(t, err)
+
+		_, err = ts.FindByID(tid)
+		assert.NoError(t, err)
+
+		_, err = rs.FindByID(rid)
+		assert.NoError(t, err)
+
+		_, err = rs.FindByID(rid2)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+
+	t.Run("fail restore project not in trash", func(t *testing.T) {
+		res, err := dao.RestoreProject(pid)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, res)
+	})
+
+	t.Run("restore run", func(t *testing.T) {
+		res, err := dao.RestoreRun(rid2)
+		assert.NoError(t, err)
+		assert.Equal(t, &CascadeResult{
+			Runs:                 1,
+			LatencyDistributions: 1,
+			Buckets:              1,
+			Details:              2,
+		}, res)
+
+		r, err := rs.FindByID(rid2)
+		assert.NoError(t, err)
+		assert.Len(t, r.Histogram, 1)
+
+		count, err := dao.CountRuns()
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), count)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		tst := &Test{}
+		tst.ID = tid
+		_, err := ts.Delete(tst)
+		assert.NoError(t, err)
+
+		res, err := dao.Purge(time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, &CascadeResult{}, res)
+
+		res, err = dao.Purge(time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, &CascadeResult{
+			Tests:                1,
+			Runs:                 2,
+			LatencyDistributions: 2,
+			Buckets:              2,
+			Details:              4,
+		}, res)
+
+		count := 0
+		db.Unscoped().Model(&Detail{}).Count(&count)
+		assert.Equal(t, 0, count)
+
+		tc, err := dao.CountTests()
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), tc)
+
+		_, err = ps.FindByID(pid)
+		assert.NoError(t, err)
+	})
+}
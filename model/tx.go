@@ -0,0 +1,35 @@
+package model
+
+import (
+	"github.com/bojand/ghz-web/config"
+	"github.com/jinzhu/gorm"
+)
+
+// TxServices are the services bound to a transaction
+type TxServices struct {
+	Projects    *ProjectService
+	Tests       *TestService
+	Runs        *RunService
+	Details     *DetailService
+	Submissions *SubmissionService
+}
+
+// TxService runs operations of several services within a single transaction
+type TxService struct {
+	DB     *gorm.DB
+	Config *config.DBConfig
+}
+
+// Transact calls fn with the services bound to a new transaction. The transaction
+// is committed if fn returns no error and rolled back otherwise.
+func (s *TxService) Transact(fn func(tx *TxServices) error) error {
+	return transact(s.DB, func(tx *gorm.DB) error {
+		return fn(&TxServices{
+			Projects:    &ProjectService{DB: tx},
+			Tests:       &TestService{DB: tx},
+			Runs:        &RunService{DB: tx},
+			Details:     &DetailService{DB: tx, Config: s.Config},
+			Submissions: &SubmissionService{DB: tx},
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bojand/ghz-web/config"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxService_Transact(t *testing.T) {
+	defer os.Remove(dbName)
+
+	db, err := gorm.Open("sqlite3", dbName)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer db.Close()
+
+	db.AutoMigrate(&Project{}, &Test{}, &Run{}, &Detail{}, &LatencyDistribution{}, &Bucket{}, &StatusChange{})
+	db.Exec("PRAGMA foreign_keys = ON;")
+
+	txs := &TxService{DB: db, Config: &config.DBConfig{Type: "sqlite3", BatchSize: 10}}
+	ps := &ProjectService{DB: db}
+	rs := &RunService{DB: db}
+	ds := &DetailService{DB: db}
+
+	create := func(tx *TxServices) (*Run, *BatchResult, error) {
+		p := &Project{}
+		if err := tx.Projects.Create(p); err != nil {
+			return nil, nil, err
+		}
+
+		o := &Test{ProjectID: p.ID}
+		if err := tx.Tests.Create(o); err != nil {
+			return nil, nil, err
+		}
+
+		r := &Run{TestID: o.ID}
+		if err := tx.Runs.Create(r); err != nil {
+			return nil, nil, err
+		}
+
+		s := make([]*Detail, 25)
+		for n := range s {
+			s[n] = &Detail{Latency: float64(n + 1)}
+		}
+
+		return r, tx.Details.CreateBatch(r.ID, s), nil
+	}
+
+	t.Run("commits", func(t *testing.T) {
+		var r *Run
+
+		err := txs.Transact(func(tx *TxServices) error {
+			var res *BatchResult
+			var err error
+			r, res, err = create(tx)
+			if err != nil {
+				return err
+			}
+
+			assert.Equal(t, uint(25), res.Created)
+			assert.Equal(t, uint(0), res.Failed)
+
+			return nil
+		})
+
+		assert.NoError(t, err)
+
+		count, err := ps.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), count)
+
+		_, err = rs.FindByID(r.ID)
+		assert.NoError(t, err)
+
+		count, err = ds.Count(r.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(25), count)
+	})
+
+	t.Run("rolls back", func(t *testing.T) {
+		var r *Run
+
+		err := txs.Transact(func(tx *TxServices) error {
+			var err error
+			r, _, err = create(tx)
+			if err != nil {
+				return err
+			}
+
+			return errors.New("failed")
+		})
+
+		assert.EqualError(t, err, "failed")
+
+		count, err := ps.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), count)
+
+		_, err = rs.FindByID(r.ID)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		count, err = ds.Count(r.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), count)
+	})
+}
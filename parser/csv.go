@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/pkg/errors"
+)
+
+// csv columns of ghz output
+const (
+	columnDuration  = "duration (ms)"
+	columnStatus    = "status"
+	columnError     = "error"
+	columnTimestamp = "timestamp"
+)
+
+// ParseCSV parses ghz output in CSV format into a single run.
+// The output has a header row with the duration (ms), status and error columns,
+// and optionally a timestamp column, followed by a row for each call.
+// The run summary is computed from the calls.
+func ParseCSV(reader io.Reader) (*Result, error) {
+	cr := csv.NewReader(reader)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing header")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := columns[columnDuration]; !ok {
+		return nil, errors.Errorf("missing %s column", columnDuration)
+	}
+
+	last := len(header) - 1
+	res := &Result{Run: new(model.Run)}
+
+	for n := 2; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// errors are not quoted in ghz output so they may span several fields
+		if len(record) > len(header) && columns[columnError] == last {
+			record = append(record[:last], strings.Join(record[last:], ","))
+		}
+
+		field := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+
+			return strings.TrimSpace(record[i])
+		}
+
+		ms, err := strconv.ParseFloat(field(columnDuration), 64)
+		if err != nil {
+			return nil, errors.Errorf("line %d: invalid duration %q", n, field(columnDuration))
+		}
+
+		d := &model.Detail{
+			Latency: ms * float64(time.Millisecond),
+			Status:  field(columnStatus),
+			Error:   field(columnError),
+		}
+
+		if ts := field(columnTimestamp); ts != "" {
+			if d.Timestamp, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+				return nil, errors.Wrapf(err, "line %d", n)
+			}
+		}
+
+		res.Details = append(res.Details, d)
+	}
+
+	if len(res.Details) == 0 {
+		return nil, errors.New("no calls")
+	}
+
+	res.summarize()
+
+	return res, nil
+}
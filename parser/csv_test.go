@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCSV(t *testing.T) {
+	t.Run("ghz output", func(t *testing.T) {
+		file, err := os.Open("../test/run0.csv")
+		assert.NoError(t, err)
+		defer file.Close()
+
+		res, err := ParseCSV(file)
+
+		assert.NoError(t, err)
+		assert.Len(t, res.Details, 937)
+
+		d := res.Details[0]
+		assert.InDelta(t, 2222054, d.Latency, 0.001)
+		assert.Equal(t, "Internal", d.Status)
+		assert.Equal(t, "rpc error: code = Internal desc = Internal error.", d.Error)
+		assert.True(t, d.Timestamp.IsZero())
+
+		d = res.Details[1]
+		assert.InDelta(t, 2394001, d.Latency, 0.001)
+		assert.Equal(t, "OK", d.Status)
+		assert.Empty(t, d.Error)
+
+		r := res.Run
+		assert.Equal(t, uint64(937), r.Count)
+		assert.Equal(t, time.Duration(276048), r.Fastest)
+		assert.Equal(t, time.Duration(8043194), r.Slowest)
+		assert.NotZero(t, r.Average)
+		assert.NotZero(t, r.Total)
+		assert.NotZero(t, r.Rps)
+		assert.Len(t, r.LatencyDistribution, 7)
+		assert.Len(t, r.Histogram, 11)
+		assert.Equal(t, map[string]int{"OK": 880, "Internal": 28, "PermissionDenied": 20, "Unavailable": 9}, r.StatusCodeDist)
+	})
+
+	t.Run("timestamps and errors with commas", func(t *testing.T) {
+		input := `duration (ms),status,timestamp,error
+1.5,OK,2018-08-01T23:17:21.493Z,
+2.5,Unavailable,2018-08-01T23:17:21.494Z,rpc error: code = Unavailable desc = a, b
+`
+
+		res, err := ParseCSV(strings.NewReader(input))
+
+		assert.NoError(t, err)
+		assert.Len(t, res.Details, 2)
+		assert.Equal(t, "rpc error: code = Unavailable desc = a, b", res.Details[1].Error)
+		assert.Equal(t, time.Date(2018, 8, 1, 23, 17, 21, 494000000, time.UTC), res.Details[1].Timestamp)
+
+		r := res.Run
+		assert.Equal(t, time.Date(2018, 8, 1, 23, 17, 21, 493000000, time.UTC), r.Date)
+		assert.Equal(t, 3500*time.Microsecond, r.Total)
+		assert.Equal(t, 2*time.Millisecond, r.Average)
+		assert.Equal(t, map[string]int{"rpc error: code = Unavailable desc = a, b": 1}, r.ErrorDist)
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		res, err := ParseCSV(strings.NewReader(""))
+
+		assert.EqualError(t, err, "missing header")
+		assert.Nil(t, res)
+	})
+
+	t.Run("missing duration column", func(t *testing.T) {
+		res, err := ParseCSV(strings.NewReader("status,error\nOK,\n"))
+
+		assert.EqualError(t, err, "missing duration (ms) column")
+		assert.Nil(t, res)
+	})
+
+	t.Run("no calls", func(t *testing.T) {
+		res, err := ParseCSV(strings.NewReader("duration (ms),status,error\n"))
+
+		assert.EqualError(t, err, "no calls")
+		assert.Nil(t, res)
+	})
+
+	t.Run("invalid duration", func(t *testing.T) {
+		res, err := ParseCSV(strings.NewReader("duration (ms),status,error\n1,OK,\nfoo,OK,\n"))
+
+		assert.EqualError(t, err, `line 3: invalid duration "foo"`)
+		assert.Nil(t, res)
+	})
+}
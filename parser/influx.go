@@ -0,0 +1,413 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/pkg/errors"
+)
+
+// MeasurementHistogram is the measurement of the histogram buckets of a run
+const MeasurementHistogram = "ghz_histogram"
+
+// UnknownError is the error distribution key of the failed calls counted in a run summary.
+// The summary only counts the errors, their messages are only known from the details.
+const UnknownError = "Unknown error"
+
+// point is a parsed line of InfluxDB line protocol
+type point struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]interface{}
+	timestamp   time.Time
+
+	// the unparsed measurement and tags identifying the series of the point
+	series string
+}
+
+// ParseInflux parses ghz output in InfluxDB line protocol.
+// Points with a count field are run summaries, and points with a latency field are
+// details of calls. Details belong to the latest run summary before them. Details
+// without a run summary before them belong to a run created from their tags, with
+// consecutive details of the same series belonging to the same run. Histogram points
+// belong to the run with the same date tag, or to the latest run if there is none.
+func ParseInflux(reader io.Reader) ([]*Result, error) {
+	var results []*Result
+	var current *Result
+	var currentSeries string
+	byDate := make(map[string]*Result)
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	n := 0
+	for scanner.Scan() {
+		n++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, err := parsePoint(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", n)
+		}
+
+		switch {
+		case p.measurement == MeasurementHistogram:
+			res := byDate[p.tags["date"]]
+			if res == nil {
+				res = current
+			}
+
+			if res == nil {
+				return nil, errors.Errorf("line %d: histogram without a run", n)
+			}
+
+			b, err := p.bucket()
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %d", n)
+			}
+
+			res.Run.Histogram = append(res.Run.Histogram, b)
+
+		case p.has("count"):
+			r, err := p.run()
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %d", n)
+			}
+
+			current = &Result{Run: r}
+			currentSeries = ""
+			results = append(results, current)
+
+			if date, ok := p.tags["date"]; ok {
+				byDate[date] = current
+			}
+
+		case p.has("latency"):
+			if current == nil || (currentSeries != "" && currentSeries != p.series) {
+				r := new(model.Run)
+				if r.Options, err = p.options(); err != nil {
+					return nil, errors.Wrapf(err, "line %d", n)
+				}
+
+				current = &Result{Run: r}
+				currentSeries = p.series
+				results = append(results, current)
+			}
+
+			current.Details = append(current.Details, p.detail())
+
+		default:
+			return nil, errors.Errorf("line %d: unknown point %s", n, p.measurement)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, res := range results {
+		res.summarize()
+		setFrequencies(res.Run)
+	}
+
+	return results, nil
+}
+
+// run creates a run from the run summary point
+func (p *point) run() (*model.Run, error) {
+	r := new(model.Run)
+
+	var err error
+	if r.Options, err = p.options(); err != nil {
+		return nil, err
+	}
+
+	r.Date = p.timestamp
+	if date, ok := p.tags["date"]; ok {
+		if r.Date, err = time.Parse(time.RFC3339Nano, date); err != nil {
+			return nil, err
+		}
+	}
+
+	r.Count = uint64(p.float("count"))
+	r.Total = time.Duration(p.float("total"))
+	r.Average = time.Duration(p.float("average"))
+	r.Fastest = time.Duration(p.float("fastest"))
+	r.Slowest = time.Duration(p.float("slowest"))
+	r.Rps = p.float("rps")
+
+	if p.has("median") {
+		r.LatencyDistribution = append(r.LatencyDistribution,
+			&model.LatencyDistribution{Percentage: 50, Latency: time.Duration(p.float("median"))})
+	}
+
+	if p.has("p95") {
+		r.LatencyDistribution = append(r.LatencyDistribution,
+			&model.LatencyDistribution{Percentage: 95, Latency: time.Duration(p.float("p95"))})
+	}
+
+	// a run with errors but no error count has at least one failed call
+	errCount := int(p.float("errors"))
+	if errCount == 0 && p.tags["hasErrors"] == "true" {
+		errCount = 1
+	}
+
+	if errCount > 0 {
+		r.ErrorDist = map[string]int{UnknownError: errCount}
+	}
+
+	return r, nil
+}
+
+// detail creates a call detail from the point
+func (p *point) detail() *model.Detail {
+	return &model.Detail{
+		Timestamp: p.timestamp,
+		Latency:   p.float("latency"),
+		Error:     p.string("error"),
+		Status:    p.string("status"),
+	}
+}
+
+// bucket creates a histogram bucket from the point
+func (p *point) bucket() (*model.Bucket, error) {
+	mark, err := strconv.ParseFloat(p.tags["mark_s"], 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "mark_s")
+	}
+
+	return &model.Bucket{Mark: mark, Count: int(p.float("count"))}, nil
+}
+
+// options creates the run options from the tags of the point
+func (p *point) options() (*model.Options, error) {
+	o := new(model.Options)
+	found := false
+
+	for k, v := range p.tags {
+		var err error
+		found = found || (k != "date" && k != "hasErrors")
+
+		switch k {
+		case "call":
+			o.Call = v
+		case "proto":
+			o.Proto = v
+		case "host":
+			o.Host = v
+		case "cert":
+			o.Cert = v
+		case "cname":
+			o.CName = v
+		case "n":
+			o.N, err = strconv.Atoi(v)
+		case "c":
+			o.C, err = strconv.Atoi(v)
+		case "qps":
+			o.QPS, err = strconv.Atoi(v)
+		case "z":
+			o.Z, err = time.ParseDuration(v)
+		case "timeout":
+			o.Timeout, err = strconv.Atoi(v)
+		case "dialTimeout":
+			o.DialTimtout, err = strconv.Atoi(v)
+		case "keepalive":
+			o.KeepaliveTime, err = strconv.Atoi(v)
+		case "data":
+			err = json.Unmarshal([]byte(v), &o.Data)
+		case "metadata":
+			md := make(map[string]string)
+			err = json.Unmarshal([]byte(v), &md)
+			o.Metadata = &md
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, k)
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return o, nil
+}
+
+func (p *point) has(field string) bool {
+	_, ok := p.fields[field]
+	return ok
+}
+
+// float returns the numeric value of the field, or 0 if it is not numeric
+func (p *point) float(field string) float64 {
+	switch v := p.fields[field].(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	}
+
+	return 0
+}
+
+// string returns the string value of the field, or an empty string if it is not a string
+func (p *point) string(field string) string {
+	s, _ := p.fields[field].(string)
+	return s
+}
+
+// parsePoint parses a line of line protocol: measurement[,tag=value...] field=value[,field=value...] [timestamp]
+// Unlike the line protocol, tag values may be double quoted as they are in ghz output.
+func parsePoint(line string) (*point, error) {
+	sections := splitUnquoted(line, ' ')
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, errors.New("invalid point")
+	}
+
+	series := splitUnquoted(sections[0], ',')
+
+	p := &point{
+		measurement: unescape(series[0]),
+		tags:        make(map[string]string, len(series)-1),
+		fields:      make(map[string]interface{}),
+		series:      sections[0],
+	}
+
+	for _, tag := range series[1:] {
+		k, v, err := splitKeyValue(tag)
+		if err != nil {
+			return nil, err
+		}
+
+		if unquoted, ok := unquote(v); ok {
+			v = unquoted
+		} else {
+			v = unescape(v)
+		}
+
+		p.tags[k] = v
+	}
+
+	for _, field := range splitUnquoted(sections[1], ',') {
+		k, v, err := splitKeyValue(field)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.fields[k], err = parseFieldValue(v); err != nil {
+			return nil, errors.Wrap(err, k)
+		}
+	}
+
+	if len(sections) == 3 {
+		ns, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "timestamp")
+		}
+
+		p.timestamp = time.Unix(0, ns).UTC()
+	}
+
+	return p, nil
+}
+
+func parseFieldValue(v string) (interface{}, error) {
+	if s, ok := unquote(v); ok {
+		return s, nil
+	}
+
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	if strings.HasSuffix(v, "i") || strings.HasSuffix(v, "u") {
+		return strconv.ParseInt(v[:len(v)-1], 10, 64)
+	}
+
+	return strconv.ParseFloat(v, 64)
+}
+
+// splitUnquoted splits s on the separator outside of double quotes and escapes,
+// omitting empty parts
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped := false, false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			if i > start {
+				parts = append(parts, s[start:i])
+			}
+			start = i + 1
+		}
+	}
+
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+
+	return parts
+}
+
+// splitKeyValue splits key=value on the first unescaped equals sign
+func splitKeyValue(s string) (string, string, error) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+
+		if s[i] == '=' {
+			return unescape(s[:i]), s[i+1:], nil
+		}
+	}
+
+	return "", "", errors.New("invalid key value " + s)
+}
+
+// unquote returns the content of the double quoted string with its escapes removed
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false
+	}
+
+	return unescape(s[1 : len(s)-1]), true
+}
+
+// unescape removes the backslashes escaping characters
+func unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInflux(t *testing.T) {
+	t.Run("runs and histograms", func(t *testing.T) {
+		lines, err := os.Open("../test/lines.txt")
+		assert.NoError(t, err)
+		defer lines.Close()
+
+		histogram, err := os.Open("../test/histogram.txt")
+		assert.NoError(t, err)
+		defer histogram.Close()
+
+		results, err := ParseInflux(io.MultiReader(lines, histogram))
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 10)
+
+		r := results[0].Run
+		assert.Empty(t, results[0].Details)
+		assert.Equal(t, time.Date(2018, 8, 1, 23, 17, 21, 493000000, time.UTC), r.Date)
+		assert.Equal(t, uint64(1000), r.Count)
+		assert.Equal(t, time.Duration(48657369), r.Total)
+		assert.Equal(t, time.Duration(1848222), r.Average)
+		assert.Equal(t, time.Duration(276048), r.Fastest)
+		assert.Equal(t, time.Duration(8043194), r.Slowest)
+		assert.Equal(t, 20551.87, r.Rps)
+		assert.Equal(t, map[string]int{UnknownError: 63}, r.ErrorDist)
+		assert.Equal(t, 6.3, r.GetErrorRate())
+		assert.Equal(t, map[string]int{UnknownError: 60}, results[1].Run.ErrorDist)
+
+		assert.Len(t, r.LatencyDistribution, 2)
+		assert.Equal(t, 50, r.LatencyDistribution[0].Percentage)
+		assert.Equal(t, time.Duration(1799080), r.LatencyDistribution[0].Latency)
+		assert.Equal(t, 95, r.LatencyDistribution[1].Percentage)
+		assert.Equal(t, time.Duration(4115917), r.LatencyDistribution[1].Latency)
+
+		assert.NotNil(t, r.Options)
+		assert.Equal(t, "helloworld.Greeter.SayHello", r.Options.Call)
+		assert.Equal(t, "/testdata/greeter.proto", r.Options.Proto)
+		assert.Equal(t, "0.0.0.0:50051", r.Options.Host)
+		assert.Equal(t, 1000, r.Options.N)
+		assert.Equal(t, 50, r.Options.C)
+		assert.Equal(t, 20, r.Options.Timeout)
+		assert.Equal(t, 10, r.Options.DialTimtout)
+		assert.Equal(t, map[string]interface{}{"name": "{{.InputName}}"}, r.Options.Data)
+		assert.Equal(t, &map[string]string{"rn": "{{.RequestNumber}}"}, r.Options.Metadata)
+
+		for _, res := range results {
+			assert.Len(t, res.Run.Histogram, 11)
+
+			total := 0.0
+			for i, b := range res.Run.Histogram {
+				total += b.Frequency
+
+				if i > 0 {
+					assert.True(t, res.Run.Histogram[i-1].Mark < b.Mark)
+				}
+			}
+
+			assert.InDelta(t, 1, total, 0.0001)
+		}
+
+		assert.Equal(t, 0.000276048, r.Histogram[0].Mark)
+		assert.Equal(t, 1, r.Histogram[0].Count)
+		assert.Equal(t, 160, r.Histogram[1].Count)
+	})
+
+	t.Run("details", func(t *testing.T) {
+		points, err := os.Open("../test/points.txt")
+		assert.NoError(t, err)
+		defer points.Close()
+
+		results, err := ParseInflux(points)
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+
+		res := results[0]
+		assert.Len(t, res.Details, 846)
+
+		d := res.Details[0]
+		assert.Equal(t, 1079544.0, d.Latency)
+		assert.Equal(t, "rpc error: code = Internal desc = Internal error.", d.Error)
+		assert.Equal(t, "Internal", d.Status)
+		assert.Equal(t, time.Unix(0, 1533156934300000000).UTC(), d.Timestamp)
+
+		r := res.Run
+		assert.Equal(t, uint64(846), r.Count)
+		assert.Equal(t, time.Unix(0, 1533156934300000000).UTC(), r.Date)
+		assert.NotZero(t, r.Average)
+		assert.NotZero(t, r.Fastest)
+		assert.NotZero(t, r.Slowest)
+		assert.True(t, r.Fastest <= r.Average && r.Average <= r.Slowest)
+		assert.NotZero(t, r.Total)
+		assert.NotZero(t, r.Rps)
+		assert.Equal(t, map[string]int{"OK": 796, "Internal": 28, "PermissionDenied": 13, "Unavailable": 9}, r.StatusCodeDist)
+		assert.Len(t, r.ErrorDist, 3)
+
+		assert.NotNil(t, r.Options)
+		assert.Equal(t, "helloworld.Greeter.SayHello", r.Options.Call)
+		assert.Equal(t, 100, r.Options.N)
+		assert.Equal(t, 10, r.Options.C)
+	})
+
+	t.Run("run summary with errors but no error count", func(t *testing.T) {
+		input := `ghz_run,call="a.B.C",hasErrors=true count=2,total=3000000,average=1500000,fastest=1000000,slowest=2000000,rps=666.67 1533156934300000000
+ghz_run,call="a.B.C",hasErrors=false count=2,total=3000000,average=1500000,fastest=1000000,slowest=2000000,rps=666.67 1533156934300000000
+`
+
+		results, err := ParseInflux(strings.NewReader(input))
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, map[string]int{UnknownError: 1}, results[0].Run.ErrorDist)
+		assert.True(t, results[0].Run.HasErrors())
+		assert.Nil(t, results[1].Run.ErrorDist)
+		assert.False(t, results[1].Run.HasErrors())
+	})
+
+	t.Run("details of run summary", func(t *testing.T) {
+		input := `ghz_run,call="a.B.C" count=2,total=3000000,average=1500000,fastest=1000000,slowest=2000000,rps=666.67,errors=1 1533156934300000000
+ghz_run,call="a.B.C" latency=1000000i,error="",status="OK" 1533156934300000000
+ghz_run,call="a.B.C" latency=2000000i,error="rpc error: code = Unavailable desc = a \"quoted\", error",status="Unavailable" 1533156934301000000
+`
+
+		results, err := ParseInflux(strings.NewReader(input))
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Len(t, results[0].Details, 2)
+
+		r := results[0].Run
+		assert.Equal(t, uint64(2), r.Count)
+		assert.Equal(t, time.Duration(3000000), r.Total)
+		assert.Equal(t, 666.67, r.Rps)
+		assert.Equal(t, map[string]int{"OK": 1, "Unavailable": 1}, r.StatusCodeDist)
+		assert.Equal(t, map[string]int{`rpc error: code = Unavailable desc = a "quoted", error`: 1}, r.ErrorDist)
+
+		d := results[0].Details[1]
+		assert.Equal(t, 2000000.0, d.Latency)
+		assert.Equal(t, `rpc error: code = Unavailable desc = a "quoted", error`, d.Error)
+	})
+
+	t.Run("details of different series", func(t *testing.T) {
+		input := `ghz_run,call="a.B.C" latency=1000000 1533156934300000000
+ghz_run,call="a.B.C" latency=2000000 1533156934301000000
+ghz_run,call="a.B.D" latency=3000000 1533156934302000000
+`
+
+		results, err := ParseInflux(strings.NewReader(input))
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Len(t, results[0].Details, 2)
+		assert.Len(t, results[1].Details, 1)
+		assert.Equal(t, "a.B.D", results[1].Run.Options.Call)
+	})
+
+	t.Run("histogram without a run", func(t *testing.T) {
+		input := `ghz_histogram,mark_s=0.001 count=1 1533165441494000000`
+
+		results, err := ParseInflux(strings.NewReader(input))
+
+		assert.EqualError(t, err, "line 1: histogram without a run")
+		assert.Nil(t, results)
+	})
+
+	t.Run("invalid lines", func(t *testing.T) {
+		for _, input := range []string{
+			"ghz_run",
+			"ghz_run count",
+			"ghz_run count=a",
+			"ghz_run,n=a count=1",
+			"ghz_run count=1 a",
+			"ghz_run foo=1",
+		} {
+			results, err := ParseInflux(strings.NewReader("\n" + input))
+
+			assert.Error(t, err, input)
+			assert.Contains(t, err.Error(), "line 2", input)
+			assert.Nil(t, results)
+		}
+	})
+}
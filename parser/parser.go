@@ -0,0 +1,61 @@
+// Package parser parses the output of ghz in formats other than JSON into runs and their details
+package parser
+
+import (
+	"sort"
+
+	"github.com/bojand/ghz-web/model"
+)
+
+// Result is a run parsed from ghz output along with the details of its calls
+type Result struct {
+	Run     *model.Run
+	Details []*model.Detail
+}
+
+// summarize sets the summary of the run computed from the details if the output has none.
+// Otherwise only the error and status code distributions missing from ghz summaries are set.
+// The errors of the details replace the errors counted by the summary, as they have messages.
+func (res *Result) summarize() {
+	r := res.Run
+
+	if len(res.Details) == 0 {
+		return
+	}
+
+	if r.Count == 0 {
+		r.Summarize(res.Details)
+		return
+	}
+
+	computed := &model.Run{Date: r.Date}
+	computed.Summarize(res.Details)
+
+	if r.ErrorDist == nil || len(computed.ErrorDist) > 0 {
+		r.ErrorDist = computed.ErrorDist
+	}
+
+	if r.StatusCodeDist == nil {
+		r.StatusCodeDist = computed.StatusCodeDist
+	}
+}
+
+// setFrequencies sets the frequencies of the histogram buckets of the run
+func setFrequencies(r *model.Run) {
+	total := 0
+	for _, b := range r.Histogram {
+		total += b.Count
+	}
+
+	if total == 0 {
+		return
+	}
+
+	for _, b := range r.Histogram {
+		b.Frequency = float64(b.Count) / float64(total)
+	}
+
+	sort.SliceStable(r.Histogram, func(i, j int) bool {
+		return r.Histogram[i].Mark < r.Histogram[j].Mark
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bojand/ghz-web/model"
+	"github.com/labstack/echo"
+)
+
+var recomputeUsage = `Usage: ghz-web [options...] recompute [recompute options...]
+Rebuilds the summary, latency distribution and histogram of stored runs from their details
+and evaluates them against the thresholds of their tests again.
+Recompute options:
+  -run	Id of the run to recompute.
+  -test	Id of the test to recompute all runs of.
+  -project	Id of the project to recompute all runs of.
+  -dry-run	Print the changes without storing them.
+`
+
+// Recompute runs the recompute command with the arguments and prints the stats as JSON
+func (app *Application) Recompute(args []string) error {
+	fs := flag.NewFlagSet("recompute", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, recomputeUsage)
+	}
+
+	runID := fs.Uint("run", 0, "Id of the run to recompute.")
+	testID := fs.Uint("test", 0, "Id of the test to recompute all runs of.")
+	projectID := fs.Uint("project", 0, "Id of the project to recompute all runs of.")
+	dryRun := fs.Bool("dry-run", false, "Print the changes without storing them.")
+
+	fs.Parse(args)
+
+	set := 0
+	for _, id := range []uint{*runID, *testID, *projectID} {
+		if id != 0 {
+			set++
+		}
+	}
+
+	if set != 1 {
+		fs.Usage()
+		return errors.New("exactly one of -run, -test or -project is required")
+	}
+
+	app.Server = echo.New()
+
+	app.setupLogger()
+
+	if err := app.setupDatabase(); err != nil {
+		return err
+	}
+	defer app.DB.Close()
+
+	rcs := &model.RecomputeService{DB: app.DB, Config: &app.Config.Database}
+
+	var stats *model.RecomputeStats
+	var err error
+
+	switch {
+	case *runID != 0:
+		stats, err = rcs.RecomputeRun(*runID, *dryRun)
+	case *testID != 0:
+		stats, err = rcs.RecomputeTest(*testID, *dryRun)
+	default:
+		stats, err = rcs.RecomputeProject(*projectID, *dryRun)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(stats)
+}
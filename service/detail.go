@@ -1,6 +1,10 @@
 package service
 
-import "github.com/bojand/ghz-web/model"
+import (
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+)
 
 // DetailService is the interface for runs
 type DetailService interface {
@@ -8,10 +12,14 @@ type DetailService interface {
 	FindByID(rid uint) (*model.Detail, error)
 	FindByRunID(rid uint, limit, page uint) ([]*model.Detail, error)
 	FindByRunIDAll(rid uint) ([]*model.Detail, error)
+	Timeline(r *model.Run, width time.Duration, percentiles []float64) (*model.Timeline, error)
+	FindByRunIDQuery(rid, num, page uint, q *model.Query) ([]*model.Detail, error)
+	CountQuery(rid uint, q *model.Query) (uint, error)
 	FindByRunIDSorted(rid, num, page uint, sortField, order string) ([]*model.Detail, error)
 	Create(m *model.Detail) error
-	CreateBatch(uint, []*model.Detail) (uint, uint)
+	CreateBatch(uint, []*model.Detail) *model.BatchResult
 	Update(m *model.Detail) error
 	Delete(m *model.Detail) error
-	DeleteAll(rid uint) error
+	DeleteAll(rid uint) (*model.CascadeResult, error)
+	Summarize(r *model.Run) ([]float64, error)
 }
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"github.com/bojand/ghz-web/model"
+)
+
+// ErrorService is the interface for analyzing the errors of runs
+type ErrorService interface {
+	FindRunErrors(r *model.Run) (*model.RunErrors, error)
+	FindTestErrors(tid uint) (*model.ErrorReport, error)
+	FindProjectErrors(pid uint) (*model.ErrorReport, error)
+}
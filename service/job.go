@@ -0,0 +1,13 @@
+package service
+
+import (
+	"github.com/bojand/ghz-web/model"
+)
+
+// JobService is the interface for ingestion jobs
+type JobService interface {
+	Create(j *model.Job) error
+	FindByID(id uint) (*model.Job, error)
+	FindUnfinished() ([]*model.Job, error)
+	Update(j *model.Job) error
+}
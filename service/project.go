@@ -8,8 +8,15 @@ type ProjectService interface {
 	FindByID(id uint) (*model.Project, error)
 	FindByName(name string) (*model.Project, error)
 	List(limit, page uint) ([]*model.Project, error)
+	ListQuery(limit, page uint, q *model.Query) ([]*model.Project, error)
+	CountQuery(q *model.Query) (uint, error)
+	ListQueryUnscoped(limit, page uint, q *model.Query) ([]*model.Project, error)
+	CountQueryUnscoped(q *model.Query) (uint, error)
 	ListSorted(limit, page uint, sortField, order string) ([]*model.Project, error)
 	Create(p *model.Project) error
 	Update(p *model.Project) error
-	Delete(p *model.Project) error
+	CountStatusHistory(pid uint) (uint, error)
+	FindStatusHistory(pid, limit, page uint) ([]*model.StatusChange, error)
+	FindStatusSummary(pid, window uint) (*model.StatusSummary, error)
+	Delete(p *model.Project) (*model.CascadeResult, error)
 }
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"github.com/bojand/ghz-web/model"
+)
+
+// RecomputeService is the interface for recomputing the aggregates of stored runs
+type RecomputeService interface {
+	RecomputeRun(id uint, dryRun bool) (*model.RecomputeStats, error)
+	RecomputeTest(tid uint, dryRun bool) (*model.RecomputeStats, error)
+	RecomputeProject(pid uint, dryRun bool) (*model.RecomputeStats, error)
+}
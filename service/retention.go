@@ -0,0 +1,11 @@
+package service
+
+import (
+	"github.com/bojand/ghz-web/model"
+)
+
+// RetentionService is the interface for the data retention job
+type RetentionService interface {
+	Prune() (*model.RetentionStats, error)
+	LastStats() *model.RetentionStats
+}
@@ -1,15 +1,31 @@
 package service
 
-import "github.com/bojand/ghz-web/model"
+import (
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+)
 
 // RunService is the interface for runs
 type RunService interface {
 	Count(tid uint) (uint, error)
 	FindLatest(tid uint) (*model.Run, error)
+	FindPercentileValues(r *model.Run, percentiles []float64) (map[model.Threshold]time.Duration, error)
+	FindBaseline(t *model.Test, excludeID uint) (*model.Baseline, error)
+	FindSignificance(t *model.Test, r *model.Run, latencies []float64) (*model.Significance, error)
+	Evaluate(t *model.Test, r *model.Run, latencies []float64) (*model.BaselineComparison, error)
 	FindByID(id uint) (*model.Run, error)
 	FindByTestID(tid uint, limit, page uint, populate bool) ([]*model.Run, error)
+	FindByTestIDQuery(tid, num, page uint, q *model.Query, histogram bool, latency bool) ([]*model.Run, error)
+	CountQuery(tid uint, q *model.Query) (uint, error)
+	FindByTestIDQueryUnscoped(tid, num, page uint, q *model.Query, histogram bool, latency bool) ([]*model.Run, error)
+	CountQueryUnscoped(tid uint, q *model.Query) (uint, error)
 	FindByTestIDSorted(tid, num, page uint, sortField, order string, histogram bool, latency bool) ([]*model.Run, error)
+	FindSeries(tid uint, metrics []model.SeriesMetric, from, to time.Time, buckets uint) (*model.TimeSeries, error)
 	Create(m *model.Run) error
 	Update(m *model.Run) error
-	Delete(m *model.Run) error
+	UpdateStatus(m *model.Run) error
+	AddProgress(m *model.Run, n uint) error
+	AbortStale(cutoff time.Time) (uint, error)
+	Delete(m *model.Run) (*model.CascadeResult, error)
 }
@@ -0,0 +1,14 @@
+package service
+
+import (
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+)
+
+// SubmissionService is the interface for raw result submissions
+type SubmissionService interface {
+	FindByKey(key string, since time.Time) (*model.Submission, error)
+	FindByHash(tid uint, hash string, since time.Time) (*model.Submission, error)
+	Create(s *model.Submission, expiredBefore time.Time) error
+}
@@ -7,9 +7,19 @@ type TestService interface {
 	Count(pid uint) (uint, error)
 	FindByID(id uint) (*model.Test, error)
 	FindByName(pid uint, name string) (*model.Test, error)
+	FindByNames(projectName, testName string) (*model.Test, error)
 	FindByProjectID(pid uint, limit, page uint) ([]*model.Test, error)
+	FindByProjectIDQuery(pid, num, page uint, q *model.Query) ([]*model.Test, error)
+	CountQuery(pid uint, q *model.Query) (uint, error)
+	FindByProjectIDQueryUnscoped(pid, num, page uint, q *model.Query) ([]*model.Test, error)
+	CountQueryUnscoped(pid uint, q *model.Query) (uint, error)
 	FindByProjectIDSorted(pid, num, page uint, sortField, order string) ([]*model.Test, error)
 	Create(m *model.Test) error
+	FindOrCreate(p *model.Project, t *model.Test) error
 	Update(m *model.Test) error
-	Delete(m *model.Test) error
+	UpdateStatus(m *model.Test, r *model.Run) error
+	CountStatusHistory(tid uint) (uint, error)
+	FindStatusHistory(tid, limit, page uint) ([]*model.StatusChange, error)
+	FindStatusSummary(tid, window uint) (*model.StatusSummary, error)
+	Delete(m *model.Test) (*model.CascadeResult, error)
 }
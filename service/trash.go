@@ -0,0 +1,21 @@
+package service
+
+import (
+	"time"
+
+	"github.com/bojand/ghz-web/model"
+)
+
+// TrashService is the interface for the trash
+type TrashService interface {
+	CountProjects() (uint, error)
+	ListProjects(limit, page uint) ([]*model.Project, error)
+	CountTests() (uint, error)
+	ListTests(limit, page uint) ([]*model.Test, error)
+	CountRuns() (uint, error)
+	ListRuns(limit, page uint) ([]*model.Run, error)
+	RestoreProject(id uint) (*model.CascadeResult, error)
+	RestoreTest(id uint) (*model.CascadeResult, error)
+	RestoreRun(id uint) (*model.CascadeResult, error)
+	Purge(before time.Time) (*model.CascadeResult, error)
+}
@@ -0,0 +1,8 @@
+package service
+
+import "github.com/bojand/ghz-web/model"
+
+// TxService is the interface for running operations within a transaction
+type TxService interface {
+	Transact(fn func(tx *model.TxServices) error) error
+}
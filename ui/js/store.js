@@ -71,6 +71,14 @@ export default {
     return data
   },
 
+  async fetchSeries (projectId, testId, metrics, buckets) {
+    const { data } = await axios.get(
+      `http://localhost:3000/api/projects/${projectId}/tests/${testId}/series?metric=${metrics.join(',')}&buckets=${buckets}`
+    )
+
+    return data
+  },
+
   async fetchInfo () {
     const { data } = await axios.get(`http://localhost:3000/api/info`)
 